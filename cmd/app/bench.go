@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"splitter-files/internal/benchmark"
+	"splitter-files/internal/worker"
+	"splitter-files/pkg/fileutils"
+)
+
+// runBench carves a generated corpus with known embedded files and reports
+// throughput, precision and recall
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	sizeMB := fs.Int("size", 8, "Size of the synthetic input in MB")
+	seed := fs.Int64("seed", 1, "Seed of the corpus generator (the same seed gives the same input)")
+	workers := fs.Int("workers", fileutils.GetPhysicalCPUCount(), "Number of workers")
+	fs.Usage = func() {
+		fmt.Println("Usage: file-splitter bench [-size MB] [-seed N] [-workers N]\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	data, files := benchmark.GenerateCorpus(*sizeMB*1024*1024, *seed)
+
+	outputDir, err := os.MkdirTemp("", "file-splitter-bench")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+		return exitIOError
+	}
+	defer os.RemoveAll(outputDir)
+
+	fmt.Printf("Synthetic corpus: %.2f MB, %d embedded files (seed %d), %d workers\n",
+		float64(len(data))/(1024*1024), len(files), *seed, *workers)
+
+	startTime := time.Now()
+	results, _, _ := worker.ProcessFile(data, outputDir, worker.Options{
+		NumWorkers: *workers,
+		// The per-file lines would drown the report
+		Reporter:   worker.SilentReporter{},
+		Writers:    worker.DefaultWriters,
+		WriteQueue: worker.DefaultWriteQueue,
+	})
+	elapsed := time.Since(startTime)
+
+	score := benchmark.Evaluate(files, results)
+	fmt.Printf("Throughput:       %.2f MB/s (%s)\n", float64(len(data))/(1024*1024)/elapsed.Seconds(), elapsed)
+	fmt.Printf("Extracted files:  %d\n", score.Extracted)
+	fmt.Printf("Precision:        %.2f%% (%d of %d extracted files match an embedded file)\n",
+		score.Precision(), score.Matched, score.Extracted)
+	fmt.Printf("Recall:           %.2f%% (%d of %d embedded files found, %d with exact size)\n",
+		score.Recall(), score.Found, score.Total, score.Exact)
+
+	fmt.Printf("\nBy type:\n")
+	for _, ext := range score.Types() {
+		ts := score.ByType[ext]
+		fmt.Printf("- %-5s: found %d/%d, exact size %d\n", ext, ts.Found, ts.Embedded, ts.Exact)
+	}
+	return exitSuccess
+}
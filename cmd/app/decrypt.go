@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"splitter-files/internal/output"
+)
+
+// runDecrypt restores files written with -encrypt-key next to the encrypted
+// ones, without the .enc suffix and with the same modification time
+func runDecrypt(args []string) int {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	keyFile := fs.String("key", "", "File with the AES-256 key the files were encrypted with")
+	fs.Usage = func() {
+		fmt.Println("Usage: file-splitter decrypt -key file <file.enc>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *keyFile == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return exitInvalidArguments
+	}
+
+	key, err := output.LoadKey(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -key: %v\n", err)
+		return exitInvalidArguments
+	}
+
+	code := exitSuccess
+	for _, path := range fs.Args() {
+		if err := decryptFile(key, path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error decrypting %s: %v\n", path, err)
+			code = exitCompletedWithErrors
+		}
+	}
+	return code
+}
+
+func decryptFile(key []byte, path string) error {
+	if !strings.HasSuffix(path, output.EncryptionSuffix) {
+		return fmt.Errorf("no %s suffix", output.EncryptionSuffix)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	plaintext, err := output.Decrypt(key, data)
+	if err != nil {
+		return err
+	}
+
+	target := strings.TrimSuffix(path, output.EncryptionSuffix)
+	if err := os.WriteFile(target, plaintext, 0644); err != nil {
+		return err
+	}
+	return os.Chtimes(target, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,20 @@
+package main
+
+// Exit codes, so scripts can branch on the outcome of a run
+const (
+	// exitSuccess: files were extracted (or verified) without errors
+	exitSuccess = 0
+	// exitCompletedWithErrors: the run finished, but some files could not be
+	// written, failed to verify, or crashed or timed out a parser
+	exitCompletedWithErrors = 1
+	// exitInvalidArguments: invalid flags or arguments (also used by the flag package)
+	exitInvalidArguments = 2
+	// exitNothingFound: no files were found
+	exitNothingFound = 3
+	// exitIOError: the input could not be read, the output could not be created,
+	// or the run was stopped because the output was full or not writable
+	exitIOError = 4
+	// exitInterrupted: a -tui run was interrupted by Ctrl-C or SIGTERM (the
+	// terminal is restored first)
+	exitInterrupted = 130
+)
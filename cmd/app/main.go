@@ -1,13 +1,22 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"runtime/debug"
 	"strings"
+	"syscall"
 	"time"
 
 	"splitter-files/internal/extractor"
+	"splitter-files/internal/notify"
+	"splitter-files/internal/output"
+	"splitter-files/internal/scanner"
+	"splitter-files/internal/tui"
 	"splitter-files/internal/worker"
 	"splitter-files/pkg/fileutils"
 )
@@ -17,91 +26,711 @@ const Version = "1.2.1"
 var (
 	versionFlag    = flag.Bool("version", false, "Print version information")
 	extensionsFlag = flag.String("ext", "", "Comma-separated list of file extensions to extract")
+	imagesFlag     = flag.Bool("images", false, "Extract images (JPEG), in addition to -ext")
+	documentsFlag  = flag.Bool("documents", false, "Extract documents (Office, OpenDocument, PDF, RTF, EPUB, OneNote), in addition to -ext")
+	archivesFlag   = flag.Bool("archives", false, "Extract archives (ZIP, JAR, APK, self-extracting archives), in addition to -ext")
+	officeFlag     = flag.Bool("office", false, "Extract Microsoft Office documents (Word, Excel, PowerPoint, Visio, Publisher, Project, OneNote), in addition to -ext")
+	excludeFlag    = flag.String("exclude", "", "Comma-separated list of file extensions not to extract")
+	sizeFilterFlag = flag.String("size-filter", "", "Size range of extracted files per format, e.g. \"jpg:100KB-20MB,pdf:10KB-\"")
+	sizeRulesFlag  = flag.String("size-filter-file", "", "File with -size-filter rules, one or more per line")
+	encryptedFlag  = flag.Bool("only-encrypted", false, "Extract only password-protected Office documents; other files are listed as skipped")
+	afterFlag      = flag.String("after", "", "Skip files whose embedded timestamp (EXIF, Office properties, PE header) is before this date (2006-01-02 or RFC 3339)")
+	beforeFlag     = flag.String("before", "", "Skip files whose embedded timestamp is on or after this date")
+	utf16Flag      = flag.Bool("grep-utf16", false, "Also match -grep patterns against the content decoded as UTF-16LE text")
+	macrosFlag     = flag.Bool("only-macros", false, "Extract only Office documents with VBA macros; other files are listed as skipped")
+	offsetFlag     = flag.String("offset", "0", "Position to start scanning the input at (e.g. 0x100000, 512M); reported positions stay absolute")
+	lengthFlag     = flag.String("length", "0", "Number of bytes to scan from -offset (0 - up to the end of the input)")
+	priorityFlag   = flag.String("priorities", "", "Priorities of formats whose signatures match at the same position, e.g. \"zip:10\" to carve OOXML documents as plain ZIP archives (higher wins)")
+	ignoreFlag     = flag.String("ignore-ranges", "", "File of byte ranges (start-end per line, or a previous jsonl report) where no files are looked for")
+	containersFlag = flag.Bool("detect-containers", false, "Report large high-entropy regions without a known signature as possible encrypted containers")
+	containerSize  = flag.Int("container-min-size", worker.DefaultContainerMinSize, "Minimum size in bytes of a possible encrypted container")
+	dumpUncovered  = flag.Bool("dump-uncovered", false, "Write every uncovered area of at least -uncovered-min-size to uncovered/unknown_<start>_<end>.bin in the output directory")
+	uncoveredSize  = flag.String("uncovered-min-size", "4K", "Minimum size of the uncovered areas -dump-uncovered writes, e.g. 64K")
+	clamdFlag      = flag.String("clamd", "", "clamd socket (unix:/path, tcp:host:port) to scan every extracted file")
+	quarantineFlag = flag.String("quarantine", "", "Directory to move files flagged by clamd to")
+	caseIDFlag     = flag.String("case-id", "", "Case identifier prefixed to the names of extracted files and recorded in the reports")
+	evidenceIDFlag = flag.String("evidence-id", "", "Evidence item identifier prefixed to the names of extracted files (after -case-id) and recorded in the reports")
+	namesFlag      = flag.Bool("original-names", false, "Name extracted files after the original name recovered from their metadata (title, archive contents, URL, PE export name)")
+	setTimesFlag   = flag.Bool("set-times", false, "Set the modification time of extracted files from their metadata (EXIF, document properties, PE headers)")
+	dumpVBAFlag    = flag.Bool("dump-vba", false, "Write the VBA macro source of macro-enabled documents to a <file>.vba directory")
+	mediaFlag      = flag.Bool("extract-media", false, "Also extract the media (word/media/, xl/media/, ppt/media/) of DOCX, XLSX and PPTX documents into a <file>.media directory")
+	attachmentFlag = flag.Bool("extract-attachments", false, "Also extract the files embedded in PDFs into a <file>.attachments directory")
+	revisionsFlag  = flag.Bool("pdf-revisions", false, "Also write the earlier revisions of incrementally updated PDFs as <file>.rev<N>.pdf")
+	repairFlag     = flag.Bool("repair-pdf", false, "Save PDFs cut off before their trailer with a rebuilt cross-reference table instead of rejecting them")
+	repairZipFlag  = flag.Bool("repair-zip", false, "Save ZIP archives and Office packages without a readable central directory rebuilt from their local file headers")
+	recursiveFlag  = flag.Bool("recursive", false, "Also carve the entries of extracted ZIP archives, and of the archives found in them, into a <file>.carved directory")
+	maxDepthFlag   = flag.Int("max-depth", worker.DefaultMaxDepth, "Number of levels of archives inside archives -recursive opens")
+	maxExpandFlag  = flag.String("max-expanded", "1G", "Total size of the entries -recursive may decompress, e.g. 4G (0 - no limit)")
+	selfVerifyFlag = flag.Bool("self-verify", false, "Check every carved file as a whole file of its format, as the verify command does, and mark the files that fail as suspect")
+	strictFlag     = flag.Bool("strict", false, "Drop the carved files that fail -self-verify instead of marking them (implies -self-verify)")
+	validationFlag = flag.String("validation", "normal", "How much of the structure of a file validators require: strict (fewer false positives), normal or lenient (also JPEGs without an end marker and PDFs cut off before their trailer)")
+	hardenedFlag   = flag.Bool("hardened", false, "Treat the input as hostile: give up on a candidate whose detection takes longer than -candidate-timeout, 30s unless set")
+	timeoutFlag    = flag.Duration("candidate-timeout", 0, "Give up on a candidate whose detection and analysis take longer, e.g. 10s (0 - no limit)")
+	previewFlag    = flag.Bool("text-preview", false, "Record the first 500 characters of the text of Office, OpenDocument, PDF, RTF and HTML documents in the JSON report")
+	passwordsFlag  = flag.String("passwords", "", "Wordlist (a password per line) to decrypt encrypted Office documents with; decrypted copies are written next to them as <file>.decrypted.<ext>")
+	maxMemoryFlag  = flag.String("max-memory", "", "Memory budget (e.g. 512M, 4G): the input is memory-mapped, the garbage collector aims at this heap size, the signature index takes a quarter of it, indexing a window of the input at a time when needed, and the files being written at once the rest (analysis buffers are not counted)")
+	writersFlag    = flag.Int("writers", worker.DefaultWriters, "Number of output writers (0 - workers write files themselves)")
+	writeQueueFlag = flag.Int("write-queue", worker.DefaultWriteQueue, "Number of extracted files waiting to be written before workers block")
+	writeBufFlag   = flag.String("write-buffer", "256K", "Write buffer size per output file")
+	casFlag        = flag.Bool("content-addressed", false, "Store every distinct file once as objects/ab/cd/<sha256>.<ext>; report.jsonl maps input positions to the objects")
+	shardCountFlag = flag.Int("shard-count", 0, "Spread extracted files over numbered subdirectories (shard_0001, ...) of at most this many files (0 - no limit)")
+	shardSizeFlag  = flag.String("shard-size", "", "Spread extracted files over numbered subdirectories of at most this size, e.g. 50G")
+	compressFlag   = flag.String("compress", "", "Compress every extracted file: gzip or zstd (adds .gz or .zst to the names)")
+	encryptKeyFlag = flag.String("encrypt-key", "", "File with an AES-256 key (32 bytes or 64 hex digits) to encrypt every extracted file with (adds .enc to the names)")
+	fsyncFlag      = flag.Bool("fsync", false, "Flush every extracted file to stable storage (evidence integrity)")
+	maxFilesFlag   = flag.Int("max-files", 0, "Stop after writing this many files, those written next to extracted files included (0 - no limit)")
+	maxTimeFlag    = flag.Duration("max-duration", 0, "Stop after this much time, e.g. 10m or 2h (0 - no limit)")
+	manifestFlag   = flag.Bool("manifest", false, "Write the SHA-256 of every extracted file to manifest.sha256 in the output directory (checked by the verify command)")
+	tuiFlag        = flag.Bool("tui", false, "Show a live view of the run: coverage map, counters per type, throughput and the recent extractions")
+	eventsFlag     = flag.String("events", "", "Write a JSON event per step of the run (start, progress, candidate, file, skipped, error, done) to this file, or - for stdout, for orchestrators tracking the run")
+	notifyFlag     = flag.String("notify", "", "Post a summary of the run (counts, coverage, report location) to this webhook URL, or log it to syslog (syslog, syslog://host:port, syslog+tcp://host:port) when the run finishes or fails")
+	reportFlag     = flag.String("report", "console", "Output per extracted file: console, jsonl (JSON lines on stdout, summary on stderr) or silent")
+	pprofFlag      = flag.String("pprof", "", "Address (e.g. localhost:6060) to serve pprof profiles and runtime stats on during the run")
+	carveMapFlag   = flag.String("carvemap", "", "Write the position, length, type and SHA-256 of every extracted file to this .carvemap file, for the reextract command")
+	gpsExportFlag  = flag.String("gps-export", "", "Write the GPS locations of extracted photos to a KML (.kml) or GeoJSON (.geojson) file")
+	timelineFlag   = flag.String("timeline", "", "Write the timestamps recovered from extracted files (EXIF, Office properties, PE headers) as a sorted timeline to a CSV (.csv) or bodyfile (.body) file")
+	coverageFlag   = flag.String("coverage-map", "", "Draw what of the input the extracted files cover as coverage.png or coverage.svg in the output directory: png or svg")
+	partitionsFlag = flag.String("coverage-partitions", "", "File with the byte ranges of the partitions (as for -ignore-ranges) to draw a strip of the coverage map for each")
 )
 
+// grepPatterns collects the patterns of repeated -grep flags
+var grepPatterns patternList
+
+func init() {
+	flag.Var(&grepPatterns, "grep", "Regular expression `pattern` (RE2 syntax) the content of extracted files must match; repeat for several patterns, matches are listed in the report")
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "bench":
+			os.Exit(runBench(os.Args[2:]))
+		case "verify":
+			os.Exit(runVerify(os.Args[2:]))
+		case "reextract":
+			os.Exit(runReextract(os.Args[2:]))
+		case "summary":
+			os.Exit(runSummary(os.Args[2:]))
+		case "decrypt":
+			os.Exit(runDecrypt(os.Args[2:]))
+		case "serve":
+			os.Exit(runServe(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 
 	if *versionFlag {
 		fmt.Printf("File Splitter version %s\n", Version)
-		os.Exit(0)
+		os.Exit(exitSuccess)
 	}
 
 	if len(flag.Args()) < 2 {
 		printUsage()
-		os.Exit(1)
+		os.Exit(exitInvalidArguments)
 	}
 
 	args := flag.Args()
 	inputFile := args[0]
 	outputDir := args[1]
 
-	allowedExtensions := parseExtensions(*extensionsFlag)
+	allowedExtensions := extractor.ParseExtensions(*extensionsFlag)
+	allFormats := *extensionsFlag == "" || *extensionsFlag == "all"
+	for category, selected := range map[string]bool{"images": *imagesFlag, "documents": *documentsFlag, "archives": *archivesFlag, "office": *officeFlag} {
+		if selected {
+			extractor.AddCategory(allowedExtensions, category)
+			allFormats = *extensionsFlag == "all"
+		}
+	}
+	allowedExtensions = extractor.ExcludeExtensions(allowedExtensions, *excludeFlag)
 	numWorkers := fileutils.GetPhysicalCPUCount()
 	if len(args) > 2 {
 		if n, err := fmt.Sscanf(args[2], "%d", &numWorkers); err != nil || n != 1 || numWorkers < 1 {
-			fmt.Printf("Invalid number of workers, using default (%d)\n", numWorkers)
+			fmt.Fprintf(os.Stderr, "Invalid number of workers, using default (%d)\n", numWorkers)
+		}
+	}
+
+	// In jsonl mode stdout carries only the JSON lines, and with the - output
+	// only the tar stream
+	var out, reportOut io.Writer = os.Stdout, os.Stdout
+	if outputDir == output.StdoutName {
+		out, reportOut = os.Stderr, os.Stderr
+	}
+	// The event stream takes stdout over from everything else
+	var events *worker.EventStream
+	switch *eventsFlag {
+	case "":
+	case "-":
+		if outputDir == output.StdoutName {
+			fmt.Fprintln(os.Stderr, "-events - can't be used with the - output, which writes to stdout")
+			os.Exit(exitInvalidArguments)
+		}
+		events = worker.NewEventStream(os.Stdout)
+		out, reportOut = os.Stderr, os.Stderr
+	default:
+		f, err := os.Create(*eventsFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating event stream: %v\n", err)
+			os.Exit(exitIOError)
+		}
+		defer f.Close()
+		events = worker.NewEventStream(f)
+	}
+	var reporter worker.Reporter
+	switch *reportFlag {
+	case "console":
+		reporter = &worker.ConsoleReporter{Out: reportOut}
+	case "jsonl":
+		reporter = worker.NewJSONReporter(reportOut)
+		out = os.Stderr
+	case "silent":
+		reporter = worker.SilentReporter{}
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -report value: %s (use console, jsonl or silent)\n", *reportFlag)
+		os.Exit(exitInvalidArguments)
+	}
+
+	var notifier notify.Notifier
+	if *notifyFlag != "" {
+		var err error
+		if notifier, err = notify.New(*notifyFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -notify value: %v\n", err)
+			os.Exit(exitInvalidArguments)
+		}
+	}
+	summary := notify.Summary{
+		CaseID:     *caseIDFlag,
+		EvidenceID: *evidenceIDFlag,
+		Input:      inputFile,
+		Output:     outputDir,
+	}
+	// fail ends a run that can't go on with an error, telling the notifier first
+	fail := func(code int, format string, args ...any) {
+		msg := fmt.Sprintf(format, args...)
+		fmt.Fprintln(os.Stderr, msg)
+		summary.Status, summary.Error = notify.StatusFailed, msg
+		sendNotification(notifier, summary)
+		os.Exit(code)
+	}
+
+	if *pprofFlag != "" {
+		addr, err := startPprof(*pprofFlag)
+		if err != nil {
+			fail(exitIOError, "Error starting pprof server: %v", err)
+		}
+		fmt.Fprintf(out, "Profiling at http://%s/debug/pprof/, runtime stats at http://%s/debug/vars\n", addr, addr)
+	}
+
+	var err error
+	var maxMemory int64
+	if *maxMemoryFlag != "" {
+		maxMemory, err = fileutils.ParseSize(*maxMemoryFlag)
+		if err != nil || maxMemory == 0 {
+			fail(exitInvalidArguments, "Invalid -max-memory value: %s", *maxMemoryFlag)
+		}
+		// Makes the garbage collector keep the heap under the budget
+		debug.SetMemoryLimit(maxMemory)
+	}
+
+	writeBufferSize, err := fileutils.ParseSize(*writeBufFlag)
+	if err != nil || writeBufferSize == 0 {
+		fail(exitInvalidArguments, "Invalid -write-buffer value: %s", *writeBufFlag)
+	}
+
+	uncoveredMinSize, err := fileutils.ParseSize(*uncoveredSize)
+	if err != nil || uncoveredMinSize == 0 {
+		fail(exitInvalidArguments, "Invalid -uncovered-min-size value: %s", *uncoveredSize)
+	}
+
+	var shardSize int64
+	if *shardSizeFlag != "" {
+		shardSize, err = fileutils.ParseSize(*shardSizeFlag)
+		if err != nil || shardSize == 0 {
+			fail(exitInvalidArguments, "Invalid -shard-size value: %s", *shardSizeFlag)
+		}
+	}
+	if *shardCountFlag < 0 {
+		fail(exitInvalidArguments, "Invalid -shard-count value: %d", *shardCountFlag)
+	}
+	if *casFlag && (*shardCountFlag > 0 || shardSize > 0) {
+		fail(exitInvalidArguments, "-content-addressed can't be combined with -shard-count and -shard-size")
+	}
+
+	if *maxDepthFlag < 1 {
+		fail(exitInvalidArguments, "Invalid -max-depth value: %d", *maxDepthFlag)
+	}
+	maxExpanded, err := fileutils.ParseSize(*maxExpandFlag)
+	if err != nil {
+		fail(exitInvalidArguments, "Invalid -max-expanded value: %s", *maxExpandFlag)
+	}
+	validation, err := extractor.ParseValidationLevel(*validationFlag)
+	if err != nil {
+		fail(exitInvalidArguments, "Invalid -validation value: %v", err)
+	}
+	if *timeoutFlag < 0 {
+		fail(exitInvalidArguments, "Invalid -candidate-timeout value: %v", *timeoutFlag)
+	}
+	candidateTimeout := *timeoutFlag
+	if *hardenedFlag && candidateTimeout == 0 {
+		candidateTimeout = worker.DefaultCandidateTimeout
+	}
+
+	offset, err := fileutils.ParseOffset(*offsetFlag)
+	if err != nil {
+		fail(exitInvalidArguments, "Invalid -offset value: %s", *offsetFlag)
+	}
+	length, err := fileutils.ParseOffset(*lengthFlag)
+	if err != nil {
+		fail(exitInvalidArguments, "Invalid -length value: %s", *lengthFlag)
+	}
+
+	sizeFilter := make(extractor.SizeFilter)
+	if *sizeRulesFlag != "" {
+		rules, err := extractor.LoadSizeFilter(*sizeRulesFlag)
+		if err != nil {
+			fail(exitInvalidArguments, "Error reading size filter: %v", err)
 		}
+		sizeFilter = rules
+	}
+	// Rules given on the command line override those of the file
+	rules, err := extractor.ParseSizeFilter(*sizeFilterFlag)
+	if err != nil {
+		fail(exitInvalidArguments, "Invalid -size-filter value: %v", err)
+	}
+	for ext, r := range rules {
+		sizeFilter[ext] = r
 	}
 
-	data, err := os.ReadFile(inputFile)
+	for name, id := range map[string]string{"-case-id": *caseIDFlag, "-evidence-id": *evidenceIDFlag} {
+		if err := extractor.CheckIdentifier(id); err != nil {
+			fail(exitInvalidArguments, "Invalid %s value: %v", name, err)
+		}
+	}
+
+	priorities, err := extractor.ParsePriorities(*priorityFlag)
 	if err != nil {
-		fmt.Printf("Error reading input file: %v\n", err)
-		os.Exit(1)
+		fail(exitInvalidArguments, "Invalid -priorities value: %v", err)
+	}
+	extractor.SetSignaturePriorities(priorities)
+
+	var ignoreRanges []fileutils.ByteRange
+	if *ignoreFlag != "" {
+		if ignoreRanges, err = fileutils.ReadRanges(*ignoreFlag); err != nil {
+			fail(exitInvalidArguments, "Error reading ignored ranges: %v", err)
+		}
 	}
 
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		fmt.Printf("Error creating output directory: %v\n", err)
-		os.Exit(1)
+	var partitions []fileutils.ByteRange
+	if *coverageFlag != "" && *coverageFlag != "png" && *coverageFlag != "svg" {
+		fail(exitInvalidArguments, "Invalid -coverage-map value: %s (use png or svg)", *coverageFlag)
+	}
+	if *partitionsFlag != "" {
+		if partitions, err = fileutils.ReadRanges(*partitionsFlag); err != nil {
+			fail(exitInvalidArguments, "Error reading partitions: %v", err)
+		}
 	}
 
-	fmt.Printf("Processing file %s (%d bytes) with %d workers\n",
+	var after, before time.Time
+	if *afterFlag != "" {
+		if after, err = parseDate(*afterFlag); err != nil {
+			fail(exitInvalidArguments, "Invalid -after value: %s", *afterFlag)
+		}
+	}
+	if *beforeFlag != "" {
+		if before, err = parseDate(*beforeFlag); err != nil {
+			fail(exitInvalidArguments, "Invalid -before value: %s", *beforeFlag)
+		}
+	}
+
+	var grep *extractor.ContentFilter
+	if len(grepPatterns) > 0 {
+		grep, err = extractor.NewContentFilter(grepPatterns, *utf16Flag)
+		if err != nil {
+			fail(exitInvalidArguments, "Invalid -grep pattern: %v", err)
+		}
+	}
+
+	var data []byte
+	if maxMemory > 0 {
+		// Mapped pages are backed by the input file and are not charged to the heap
+		var unmap func() error
+		data, unmap, err = fileutils.MapFile(inputFile)
+		if err == nil {
+			defer unmap()
+			if offset > int64(len(data)) {
+				err = fmt.Errorf("offset %d is past the end of %s (%d bytes)", offset, inputFile, len(data))
+			} else {
+				data = data[offset:]
+				if length > 0 && length < int64(len(data)) {
+					data = data[:length]
+				}
+			}
+		}
+	} else {
+		// Only the scanned region is read, not the whole image
+		data, err = fileutils.ReadFileRange(inputFile, offset, length)
+	}
+	if err != nil {
+		fail(exitIOError, "Error reading input file: %v", err)
+	}
+
+	if *compressFlag != "" {
+		if _, err := output.CompressionSuffix(*compressFlag); err != nil {
+			fail(exitInvalidArguments, "Invalid -compress value: %v", err)
+		}
+	}
+
+	var encryptKey []byte
+	if *encryptKeyFlag != "" {
+		if encryptKey, err = output.LoadKey(*encryptKeyFlag); err != nil {
+			fail(exitInvalidArguments, "Invalid -encrypt-key: %v", err)
+		}
+		if *clamdFlag != "" || *dumpVBAFlag {
+			// Both need the plaintext of the extracted files on disk
+			fail(exitInvalidArguments, "-clamd and -dump-vba can't be used with -encrypt-key")
+		}
+		if *reportFlag == "jsonl" || *eventsFlag != "" {
+			// Their records carry the text previews and grep matches in the clear
+			fail(exitInvalidArguments, "-report jsonl and -events can't be used with -encrypt-key")
+		}
+		if *gpsExportFlag != "" || *timelineFlag != "" || *namesFlag {
+			// Photo locations, timestamps and document titles would be written in the clear
+			fail(exitInvalidArguments, "-gps-export, -timeline and -original-names can't be used with -encrypt-key")
+		}
+	}
+
+	var passwords []string
+	if *passwordsFlag != "" {
+		if passwords, err = extractor.LoadPasswords(*passwordsFlag); err != nil {
+			fail(exitInvalidArguments, "Invalid -passwords: %v", err)
+		}
+	}
+
+	sink, err := openSink(outputDir)
+	if err != nil {
+		fail(exitInvalidArguments, "Error opening output: %v", err)
+	}
+	if sink == nil {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			fail(exitIOError, "Error creating output directory: %v", err)
+		}
+	} else if *clamdFlag != "" || *dumpVBAFlag {
+		// Both work on the files written to the local output directory
+		fail(exitInvalidArguments, "-clamd and -dump-vba need a local output directory")
+	}
+
+	var clamd *scanner.ClamdClient
+	if *clamdFlag != "" {
+		clamd, err = scanner.NewClamdClient(*clamdFlag)
+		if err == nil {
+			err = clamd.Ping()
+		}
+		if err != nil {
+			fail(exitIOError, "Error connecting to clamd: %v", err)
+		}
+	}
+
+	if *quarantineFlag != "" {
+		if err := os.MkdirAll(*quarantineFlag, 0755); err != nil {
+			fail(exitIOError, "Error creating quarantine directory: %v", err)
+		}
+	}
+
+	fmt.Fprintf(out, "Processing file %s (%d bytes) with %d workers\n",
 		inputFile, len(data), numWorkers)
-	if len(allowedExtensions) > 0 {
+	if *caseIDFlag != "" || *evidenceIDFlag != "" {
+		fmt.Fprintf(out, "Case: %s, evidence: %s\n", *caseIDFlag, *evidenceIDFlag)
+	}
+	if offset > 0 || length > 0 {
+		fmt.Fprintf(out, "Scanning bytes %d-%d of the input\n", offset, offset+int64(len(data)))
+	}
+	if *excludeFlag != "" && allFormats {
+		fmt.Fprintf(out, "Extracting all except: %s\n", *excludeFlag)
+	} else if len(allowedExtensions) > 0 {
 		extList := fileutils.GetMapKeys(allowedExtensions)
-		fmt.Printf("Extracting only: %s\n", strings.Join(extList, ", "))
+		fmt.Fprintf(out, "Extracting only: %s\n", strings.Join(extList, ", "))
+	}
+
+	var ui *tui.UI
+	var signals chan os.Signal
+	if *tuiFlag {
+		if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			fail(exitInvalidArguments, "-tui needs a terminal")
+		}
+		ui = tui.New(os.Stdout, inputFile, int(offset), len(data))
+		reporter = ui
+		ui.Start()
+
+		// Ctrl-C or a kill would otherwise end the run with the terminal left
+		// in the alternate screen, without a cursor and echo
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		interrupted := summary
+		go func() {
+			sig := <-signals
+			ui.Stop()
+			fmt.Fprintf(os.Stderr, "Interrupted by %v\n", sig)
+			interrupted.Status, interrupted.Error = notify.StatusFailed, "interrupted by "+sig.String()
+			sendNotification(notifier, interrupted)
+			os.Exit(exitInterrupted)
+		}()
 	}
 
 	startTime := time.Now()
-	results, stats, err := worker.ProcessFile(data, outputDir, numWorkers, allowedExtensions)
+	results, stats, err := worker.ProcessFile(data, outputDir, worker.Options{
+		NumWorkers:         numWorkers,
+		AllowedExtensions:  allowedExtensions,
+		Offset:             int(offset),
+		IgnoreRanges:       ignoreRanges,
+		Reporter:           reporter,
+		Events:             events,
+		OriginalNames:      *namesFlag,
+		SetTimes:           *setTimesFlag,
+		DumpVBA:            *dumpVBAFlag,
+		Passwords:          passwords,
+		ExtractMedia:       *mediaFlag,
+		ExtractAttachments: *attachmentFlag,
+		PDFRevisions:       *revisionsFlag,
+		RepairPDF:          *repairFlag,
+		RepairZip:          *repairZipFlag,
+		Recursive:          *recursiveFlag,
+		MaxDepth:           *maxDepthFlag,
+		MaxExpanded:        maxExpanded,
+		TextPreview:        *previewFlag,
+		SelfVerify:         *selfVerifyFlag || *strictFlag,
+		Strict:             *strictFlag,
+		Validation:         validation,
+		CandidateTimeout:   candidateTimeout,
+		SizeFilter:         sizeFilter,
+		OnlyEncrypted:      *encryptedFlag,
+		OnlyMacros:         *macrosFlag,
+		After:              after,
+		Before:             before,
+		Grep:               grep,
+		MaxFiles:           *maxFilesFlag,
+		MaxDuration:        *maxTimeFlag,
+		Hash:               *manifestFlag || *carveMapFlag != "",
+		MaxMemory:          maxMemory,
+		Writers:            *writersFlag,
+		WriteQueue:         *writeQueueFlag,
+		WriteBufferSize:    int(writeBufferSize),
+		Fsync:              *fsyncFlag,
+		CaseID:             *caseIDFlag,
+		EvidenceID:         *evidenceIDFlag,
+		ContentAddressed:   *casFlag,
+		ShardFiles:         *shardCountFlag,
+		ShardBytes:         shardSize,
+		Compress:           *compressFlag,
+		EncryptKey:         encryptKey,
+		Sink:               sink,
+		DetectContainers:   *containersFlag,
+		ContainerMinSize:   *containerSize,
+		DumpUncovered:      *dumpUncovered,
+		UncoveredMinSize:   int(uncoveredMinSize),
+		Clamd:              clamd,
+		QuarantineDir:      *quarantineFlag,
+	})
 	elapsed := time.Since(startTime)
+	if ui != nil {
+		signal.Stop(signals)
+		ui.Stop()
+	}
 
-	if err != nil {
-		fmt.Printf("Processing completed with errors: %v\n", err)
+	code := exitSuccess
+	var processingErr *worker.ProcessingError
+	if errors.As(err, &processingErr) {
+		summary.Failures = len(processingErr.Failures)
+		if len(processingErr.WriteErrors) > 0 {
+			summary.Error = fmt.Sprintf("%d files could not be written: %v", len(processingErr.WriteErrors), processingErr.WriteErrors[0])
+		}
+		fmt.Fprintf(out, "Processing completed with errors: %v\n", err)
+		for _, writeErr := range processingErr.WriteErrors {
+			fmt.Fprintf(out, "- %v\n", writeErr)
+		}
+		if len(processingErr.WriteErrors) > 0 || processingErr.ValidationErrors() > 0 {
+			code = exitCompletedWithErrors
+		}
+		if processingErr.OutputFailure != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s; the run was stopped and the results cover the files written before\n", processingErr.OutputFailure)
+			summary.Error = processingErr.OutputFailure
+			code = exitIOError
+		}
+	}
+	if len(results) == 0 && code == exitSuccess {
+		code = exitNothingFound
+	}
+
+	if encryptKey != nil {
+		// The hexdumps would show the uncovered data the dumps keep encrypted
+		for i := range stats.UncoveredAreas {
+			stats.UncoveredAreas[i].Preview = nil
+		}
+	}
+	fileutils.PrintStats(out, stats, results)
+	if *casFlag {
+		objects := make(map[string]bool)
+		for _, res := range results {
+			objects[res.SHA256] = true
+		}
+		fmt.Fprintf(out, "Distinct objects stored: %d of %d files\n", len(objects), len(results))
+	}
+
+	// The files the run writes about itself go next to the extracted files,
+	// encrypted like them
+	var runSink fileutils.Sink = &fileutils.DirSink{}
+	if sink != nil {
+		runSink = sink
+	}
+	var runSuffix string
+	if encryptKey != nil {
+		encrypted, err := output.NewEncryptSink(encryptKey, runSink)
+		if err != nil {
+			fail(exitInvalidArguments, "Invalid -encrypt-key: %v", err)
+		}
+		runSink, runSuffix = encrypted, output.EncryptionSuffix
+	}
+
+	if *manifestFlag {
+		name := fileutils.ManifestName + runSuffix
+		if err := writeManifest(runSink, outputDir, name, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing manifest: %v\n", err)
+			code = exitCompletedWithErrors
+		} else {
+			fmt.Fprintf(out, "\nManifest written to %s\n", fileutils.OutputPath(outputDir, name))
+		}
 	}
 
-	fileutils.PrintStats(stats, results)
-	fmt.Printf("\nProcessing completed in %s\n", elapsed)
+	// The report maps the positions of content-addressed files to their
+	// objects, and lists what encrypted outputs hold
+	if sink != nil || *casFlag || encryptKey != nil {
+		name := reportName + runSuffix
+		if err := writeReport(runSink, outputDir, name, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+			code = exitCompletedWithErrors
+		} else {
+			summary.Report = fileutils.OutputPath(outputDir, name)
+			fmt.Fprintf(out, "\nReport written to %s\n", summary.Report)
+		}
+	}
+	if *coverageFlag != "" {
+		m := fileutils.CoverageMap{
+			InputName:  inputFile,
+			Scanned:    fileutils.ByteRange{Start: offset, End: offset + int64(len(data))},
+			Ignored:    ignoreRanges,
+			Partitions: partitions,
+		}
+		if info, err := os.Stat(inputFile); err == nil {
+			m.InputSize = info.Size()
+		}
+		if path, err := writeCoverageMap(runSink, outputDir, m, *coverageFlag, runSuffix, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing coverage map: %v\n", err)
+			code = exitCompletedWithErrors
+		} else {
+			fmt.Fprintf(out, "\nCoverage map written to %s\n", path)
+		}
+	}
+	if sink != nil {
+		if err := closeSink(sink); err != nil {
+			fmt.Fprintf(os.Stderr, "Error finishing output: %v\n", err)
+			code = exitIOError
+		}
+	}
+
+	if *carveMapFlag != "" {
+		var inputSize int64
+		if info, err := os.Stat(inputFile); err == nil {
+			inputSize = info.Size()
+		}
+		if err := fileutils.WriteCarveMap(*carveMapFlag, inputFile, inputSize, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing carve map: %v\n", err)
+			code = exitCompletedWithErrors
+		} else {
+			if summary.Report == "" {
+				summary.Report = *carveMapFlag
+			}
+			fmt.Fprintf(out, "\nCarve map written to %s\n", *carveMapFlag)
+		}
+	}
+
+	if *gpsExportFlag != "" {
+		if n, err := fileutils.ExportLocations(*gpsExportFlag, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting GPS locations: %v\n", err)
+			code = exitCompletedWithErrors
+		} else {
+			fmt.Fprintf(out, "\nGPS locations written to %s: %d\n", *gpsExportFlag, n)
+		}
+	}
+
+	if *timelineFlag != "" {
+		if n, err := fileutils.ExportTimeline(*timelineFlag, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing timeline: %v\n", err)
+			code = exitCompletedWithErrors
+		} else {
+			fmt.Fprintf(out, "\nTimeline written to %s: %d events\n", *timelineFlag, n)
+		}
+	}
+	fmt.Fprintf(out, "\nProcessing completed in %s\n", elapsed)
+
+	summary.Status = notify.StatusDone
+	if code == exitIOError {
+		summary.Status = notify.StatusFailed
+	}
+	summary.Files, summary.Skipped, summary.Coverage = len(results), stats.Skipped, stats.Coverage
+	summary.Duration = elapsed.Seconds()
+	sendNotification(notifier, summary)
+	os.Exit(code)
+}
+
+// sendNotification tells the notifier of the run, if any, how it ended; a
+// notification that can't be sent doesn't change the exit code
+func sendNotification(notifier notify.Notifier, s notify.Summary) {
+	if notifier == nil {
+		return
+	}
+	s.Time = time.Now().UTC()
+	if err := notifier.Notify(s); err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending notification: %v\n", err)
+	}
+}
+
+// parseDate accepts a date or an RFC 3339 time; dates without a zone are UTC
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// patternList is a flag that may be given several times
+type patternList []string
+
+func (p *patternList) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *patternList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
 }
 
 func printUsage() {
 	fmt.Println(`File Splitter - tool for extracting embedded files from binary data.
 Version:`, Version, `
 Usage: file-splitter [flags] <input_file> <output_directory> [num_workers]
+       file-splitter bench [-size MB] [-seed N] [-workers N]
+       file-splitter verify [-key file] <output_directory>
+       file-splitter decrypt -key file <file.enc>...
+       file-splitter reextract [-ext list] [-entries list] <input_file> <carvemap> <output_directory>
+       file-splitter summary [-json] <carvemap>...
+       file-splitter serve [-addr host:port] [-data dir] [-input-root dir] [-notify target]
 
 Flags:`)
 	flag.PrintDefaults()
 	fmt.Printf("\nSupported file extensions: %s\n", strings.Join(extractor.GetSupportedExtensions(), ", "))
+	fmt.Printf("Signature order for equal -priorities: %s\n", strings.Join(extractor.SignatureOrder(), ", "))
 	fmt.Println(`Examples:
   file-splitter -ext pdf,jpg,docx data.bin output_dir
   file-splitter -ext all data.bin output_dir 8`)
 }
-
-func parseExtensions(extStr string) map[string]bool {
-	allowed := make(map[string]bool)
-	if extStr == "all" {
-		for _, ext := range extractor.GetSupportedExtensions() {
-			allowed[ext] = true
-		}
-		return allowed
-	}
-
-	exts := strings.Split(extStr, ",")
-	for _, ext := range exts {
-		ext = strings.TrimSpace(strings.ToLower(ext))
-		if ext != "" {
-			allowed[ext] = true
-		}
-	}
-	return allowed
-}
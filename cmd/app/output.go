@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"splitter-files/internal/models"
+	"splitter-files/internal/output"
+	"splitter-files/internal/worker"
+	"splitter-files/pkg/fileutils"
+)
+
+// reportName is the JSON lines report stored next to the files of remote outputs
+const reportName = "report.jsonl"
+
+// coverageMapName is the name of the -coverage-map image without its extension
+const coverageMapName = "coverage"
+
+// openSink returns the sink of a remote output location such as s3://bucket/prefix
+// or - (a tar stream on stdout), or nil if the output is a local directory
+func openSink(outputDir string) (fileutils.Sink, error) {
+	switch {
+	case outputDir == output.StdoutName:
+		return output.NewTarSink(os.Stdout, outputDir), nil
+	case strings.HasPrefix(outputDir, "s3://"):
+		return output.NewS3Sink(outputDir)
+	case strings.Contains(outputDir, "://"):
+		return nil, fmt.Errorf("unsupported output location %s", outputDir)
+	}
+	return nil, nil
+}
+
+// writeManifest stores the manifest of the extracted files as name with the sink
+func writeManifest(sink fileutils.Sink, outputDir, name string, results []models.ExtractionResult) error {
+	return sink.Put(fileutils.WriteJob{
+		Path: fileutils.OutputPath(outputDir, name),
+		Data: fileutils.ManifestData(outputDir, results),
+	})
+}
+
+// writeReport stores a JSON line per extracted file as name with the sink,
+// since the output location can't be listed like a directory
+func writeReport(sink fileutils.Sink, outputDir, name string, results []models.ExtractionResult) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, res := range results {
+		if err := enc.Encode(worker.NewResultRecord(res)); err != nil {
+			return err
+		}
+	}
+	return sink.Put(fileutils.WriteJob{Path: fileutils.OutputPath(outputDir, name), Data: buf.Bytes()})
+}
+
+// writeCoverageMap draws the coverage of the input by the extracted files as
+// coverage.<format> and stores it with the sink, with nameSuffix appended to
+// its name, returning its path
+func writeCoverageMap(sink fileutils.Sink, outputDir string, m fileutils.CoverageMap, format, nameSuffix string, results []models.ExtractionResult) (string, error) {
+	for _, res := range results {
+		m.Files = append(m.Files, fileutils.CoverageFile{Start: int64(res.Start), End: int64(res.End), Name: res.Filename})
+	}
+	if m.InputSize < m.Scanned.End {
+		m.InputSize = m.Scanned.End
+	}
+	data, err := fileutils.RenderCoverageMap(m, format)
+	if err != nil {
+		return "", err
+	}
+	path := fileutils.OutputPath(outputDir, coverageMapName+"."+format+nameSuffix)
+	return path, sink.Put(fileutils.WriteJob{Path: path, Data: data})
+}
+
+// closeSink finishes the output of sinks that need it, like the end of a tar stream
+func closeSink(sink fileutils.Sink) error {
+	if closer, ok := sink.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
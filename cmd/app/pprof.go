@@ -0,0 +1,30 @@
+package main
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// startPprof serves net/http/pprof under /debug/pprof/ and runtime stats (memory,
+// GC, goroutines) under /debug/vars on addr for the rest of the run
+func startPprof(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+
+	started := time.Now()
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("uptime_seconds", expvar.Func(func() interface{} {
+		return time.Since(started).Seconds()
+	}))
+
+	go http.Serve(ln, nil)
+	return ln.Addr().String(), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/pkg/fileutils"
+)
+
+// runReextract writes selected files of a carve map straight from the input
+// they were carved from, without scanning it again
+func runReextract(args []string) int {
+	fs := flag.NewFlagSet("reextract", flag.ExitOnError)
+	extFlag := fs.String("ext", "", "Comma-separated list of file extensions to extract")
+	entriesFlag := fs.String("entries", "", "Entries of the carve map to extract, numbered from 1, e.g. \"3,10-20\" (default all)")
+	fs.Usage = func() {
+		fmt.Println("Usage: file-splitter reextract [-ext list] [-entries list] <input_file> <carvemap> <output_directory>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 3 {
+		fs.Usage()
+		return exitInvalidArguments
+	}
+	inputFile, mapFile, outputDir := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+	selected, err := parseEntries(*entriesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -entries value: %v\n", err)
+		return exitInvalidArguments
+	}
+	allowedExtensions := extractor.ParseExtensions(*extFlag)
+
+	carveMap, err := fileutils.ReadCarveMap(mapFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading carve map: %v\n", err)
+		return exitIOError
+	}
+	known := extractor.ParseExtensions("all")
+	for i, entry := range carveMap.Entries {
+		if !known[entry.Extension] {
+			fmt.Fprintf(os.Stderr, "Error reading carve map: entry %d has the unknown extension %q\n", i+1, entry.Extension)
+			return exitIOError
+		}
+	}
+	info, err := os.Stat(inputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input file: %v\n", err)
+		return exitIOError
+	}
+	if carveMap.InputSize != 0 && carveMap.InputSize != info.Size() {
+		fmt.Fprintf(os.Stderr, "Warning: %s was made for %s of %d bytes, %s has %d bytes\n",
+			mapFile, carveMap.Input, carveMap.InputSize, inputFile, info.Size())
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+		return exitIOError
+	}
+
+	names := extractor.NewOutputNames(outputDir, true)
+	var extracted, failed int
+	for i, entry := range carveMap.Entries {
+		if selected != nil && !selected(i+1) {
+			continue
+		}
+		if len(allowedExtensions) > 0 && !allowedExtensions[entry.Extension] {
+			continue
+		}
+
+		name := names.Claim(extractor.CarveMapFileName(entry.Start, entry.Extension), "."+entry.Extension)
+		if err := reextractEntry(inputFile, entry, fileutils.OutputPath(outputDir, name)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error extracting entry %d (%s at %d): %v\n", i+1, entry.Extension, entry.Start, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Extracted %s (%d bytes)\n", name, entry.Length)
+		extracted++
+	}
+
+	fmt.Printf("\nExtracted files: %d\n", extracted)
+	if failed > 0 {
+		fmt.Printf("Failed: %d\n", failed)
+		return exitCompletedWithErrors
+	}
+	if extracted == 0 {
+		return exitNothingFound
+	}
+	return exitSuccess
+}
+
+// reextractEntry copies an entry out of the input, checking it still has the
+// content the map recorded
+func reextractEntry(inputFile string, entry fileutils.CarveEntry, path string) error {
+	data, err := fileutils.ReadFileRange(inputFile, entry.Start, entry.Length)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != entry.Length {
+		return fmt.Errorf("input ends after %d of %d bytes", len(data), entry.Length)
+	}
+	if entry.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != entry.SHA256 {
+			return fmt.Errorf("SHA-256 differs from the carve map")
+		}
+	}
+	return fileutils.WriteFile(fileutils.WriteJob{Path: path, Data: data}, 0, false)
+}
+
+// parseEntries parses a list of entry numbers and ranges such as "3,10-20";
+// an empty list selects every entry (nil)
+func parseEntries(s string) (func(int) bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ranges [][2]int
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.Atoi(first)
+		if err != nil || from < 1 {
+			return nil, fmt.Errorf("invalid entry %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil || to < from {
+				return nil, fmt.Errorf("invalid entry range %q", part)
+			}
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return func(n int) bool {
+		for _, r := range ranges {
+			if r[0] <= n && n <= r[1] {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
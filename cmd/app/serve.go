@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"splitter-files/internal/notify"
+	"splitter-files/internal/server"
+	"splitter-files/pkg/fileutils"
+)
+
+// runServe exposes extraction as a REST service
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	dataDir := fs.String("data", "splitter-jobs", "Directory for uploaded inputs and carved files of the jobs")
+	inputRoot := fs.String("input-root", "", "Directory whose files may be submitted by path (disabled if empty)")
+	maxUpload := fs.String("max-upload", "4G", "Largest accepted upload")
+	jobs := fs.Int("jobs", 1, "Number of jobs processed at once")
+	workers := fs.Int("workers", fileutils.GetPhysicalCPUCount(), "Number of workers per job")
+	notifyTarget := fs.String("notify", "", "Webhook URL, syslog or syslog://host:port told when each job finishes or fails")
+	fs.Usage = func() {
+		fmt.Println("Usage: file-splitter serve [-addr host:port] [-data dir] [-input-root dir] [-jobs N] [-workers N] [-notify target]\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	uploadLimit, err := fileutils.ParseSize(*maxUpload)
+	if err != nil || uploadLimit == 0 {
+		fmt.Fprintf(os.Stderr, "Invalid -max-upload value: %s\n", *maxUpload)
+		return exitInvalidArguments
+	}
+
+	var notifier notify.Notifier
+	if *notifyTarget != "" {
+		if notifier, err = notify.New(*notifyTarget); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -notify value: %v\n", err)
+			return exitInvalidArguments
+		}
+	}
+
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating data directory: %v\n", err)
+		return exitIOError
+	}
+
+	srv := server.New(server.Config{
+		DataDir:    *dataDir,
+		InputRoot:  *inputRoot,
+		MaxUpload:  uploadLimit,
+		Jobs:       *jobs,
+		NumWorkers: *workers,
+		Notifier:   notifier,
+	})
+
+	fmt.Printf("Serving on http://%s/jobs (data in %s)\n", *addr, *dataDir)
+	if err := http.ListenAndServe(*addr, srv.Handler()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error serving: %v\n", err)
+		return exitIOError
+	}
+	return exitSuccess
+}
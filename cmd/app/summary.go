@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"splitter-files/pkg/fileutils"
+)
+
+// runSummary rolls up the carve maps of runs over several inputs into one
+// report: totals per input, counts per file type and duplicates over all inputs
+func runSummary(args []string) int {
+	fs := flag.NewFlagSet("summary", flag.ExitOnError)
+	jsonFlag := fs.Bool("json", false, "Write the summary as JSON")
+	fs.Usage = func() {
+		fmt.Println("Usage: file-splitter summary [-json] <carvemap>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitInvalidArguments
+	}
+
+	var maps []*fileutils.CarveMap
+	for _, path := range fs.Args() {
+		m, err := fileutils.ReadCarveMap(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading carve map: %v\n", err)
+			return exitIOError
+		}
+		maps = append(maps, m)
+	}
+	summary := fileutils.SummarizeCarveMaps(maps)
+
+	if *jsonFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(summary); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing summary: %v\n", err)
+			return exitIOError
+		}
+	} else {
+		printSummary(summary)
+	}
+	if summary.Files == 0 {
+		return exitNothingFound
+	}
+	return exitSuccess
+}
+
+func printSummary(summary fileutils.Summary) {
+	fmt.Printf("=== Summary of %d inputs ===\n", len(summary.Inputs))
+	for _, input := range summary.Inputs {
+		fmt.Printf("\n%s (%d bytes)\n", input.Input, input.InputSize)
+		fmt.Printf("  Files:     %d (%d distinct), %d bytes\n", input.Files, input.Distinct, input.Bytes)
+		if input.InputSize > 0 {
+			fmt.Printf("  Coverage:  %.2f%%\n", float64(input.Covered)/float64(input.InputSize)*100)
+		}
+		fmt.Printf("  Types:     %s\n", formatTypeCounts(input.Types))
+	}
+
+	fmt.Printf("\nTotal files:           %d\n", summary.Files)
+	fmt.Printf("Total size:            %d bytes\n", summary.Bytes)
+	fmt.Printf("Distinct files:        %d (%d bytes)\n", summary.Distinct, summary.DistinctBytes)
+	fmt.Printf("Duplicates:            %d (%d bytes)\n", summary.Files-summary.Distinct, summary.Bytes-summary.DistinctBytes)
+	fmt.Printf("In several inputs:     %d\n", summary.Shared)
+
+	fmt.Printf("\nFile types distribution:\n")
+	for _, ext := range fileutils.SortedTypes(summary.Types) {
+		fmt.Printf("- %-30s: %d\n", ext, summary.Types[ext])
+	}
+}
+
+// formatTypeCounts lists the counts of file types, the most frequent first
+func formatTypeCounts(types map[string]int) string {
+	if len(types) == 0 {
+		return "none"
+	}
+	var counts []string
+	for _, ext := range fileutils.SortedTypes(types) {
+		counts = append(counts, fmt.Sprintf("%s %d", ext, types[ext]))
+	}
+	return strings.Join(counts, ", ")
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/output"
+	"splitter-files/pkg/fileutils"
+)
+
+// runVerify re-validates the files of a previous run and checks them against
+// its manifest, reporting corrupt, modified, missing and unexpected files
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyFile := fs.String("key", "", "File with the AES-256 key of an output written with -encrypt-key")
+	fs.Usage = func() {
+		fmt.Println("Usage: file-splitter verify [-key file] <output_directory>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitInvalidArguments
+	}
+	outputDir := fs.Arg(0)
+
+	var key []byte
+	if *keyFile != "" {
+		var err error
+		if key, err = output.LoadKey(*keyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -key: %v\n", err)
+			return exitInvalidArguments
+		}
+	}
+
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading output directory: %v\n", err)
+		return exitIOError
+	}
+
+	hashes, err := readManifest(outputDir, key)
+	if os.IsNotExist(err) {
+		fmt.Printf("No %s in %s, checking file formats only\n", fileutils.ManifestName, outputDir)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading manifest: %v\n", err)
+		return exitIOError
+	}
+
+	// Manifest entries may point outside the directory (quarantined files)
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		switch {
+		case entry.Type().IsRegular() && !isRunOutput(entry.Name()):
+			names[entry.Name()] = true
+		case entry.IsDir() && (fileutils.IsShardDir(entry.Name()) || entry.Name() == extractor.ObjectsDir || strings.HasSuffix(entry.Name(), extractor.MediaDirSuffix) || strings.HasSuffix(entry.Name(), extractor.AttachmentsDirSuffix) || strings.HasSuffix(entry.Name(), extractor.NestedDirSuffix) || strings.HasSuffix(entry.Name(), extractor.SFXDirSuffix)):
+			err := filepath.WalkDir(filepath.Join(outputDir, entry.Name()), func(path string, d os.DirEntry, err error) error {
+				if err != nil || !d.Type().IsRegular() {
+					return err
+				}
+				name, err := filepath.Rel(outputDir, path)
+				names[name] = true
+				return err
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading output directory: %v\n", err)
+				return exitIOError
+			}
+		}
+	}
+	for name := range hashes {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var valid, corrupt, modified, missing, unlisted int
+	for _, name := range sorted {
+		data, err := readOutputFile(filepath.Join(outputDir, name), key)
+		if os.IsNotExist(err) {
+			fmt.Printf("MISSING   %s\n", name)
+			missing++
+			continue
+		}
+		if err != nil {
+			fmt.Printf("CORRUPT   %s: %v\n", name, err)
+			corrupt++
+			continue
+		}
+
+		ok := true
+		if hashes != nil {
+			sum := sha256.Sum256(data)
+			if expected, listed := hashes[name]; !listed {
+				fmt.Printf("UNLISTED  %s: not in the manifest\n", name)
+				unlisted++
+				ok = false
+			} else if hex.EncodeToString(sum[:]) != expected {
+				fmt.Printf("MODIFIED  %s: SHA-256 differs from the manifest\n", name)
+				modified++
+				ok = false
+			}
+		}
+
+		// Media of documents, attachments of PDFs and the parts of
+		// self-extracting archives are in formats the tool doesn't carve
+		if dir := filepath.Ext(filepath.Dir(name)); dir == extractor.MediaDirSuffix || dir == extractor.AttachmentsDirSuffix || dir == extractor.SFXDirSuffix {
+			if ok {
+				valid++
+			}
+			continue
+		}
+		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(output.TrimCompressionSuffix(strings.TrimSuffix(name, output.EncryptionSuffix))), "."))
+		if err := extractor.VerifyFile(data, ext); err != nil {
+			fmt.Printf("CORRUPT   %s: %v\n", name, err)
+			corrupt++
+			ok = false
+		}
+		if ok {
+			valid++
+		}
+	}
+
+	fmt.Printf("\nVerified files: %d\n", len(sorted))
+	fmt.Printf("- Valid:     %d\n", valid)
+	fmt.Printf("- Corrupt:   %d\n", corrupt)
+	if hashes != nil {
+		fmt.Printf("- Modified:  %d\n", modified)
+		fmt.Printf("- Missing:   %d\n", missing)
+		fmt.Printf("- Unlisted:  %d\n", unlisted)
+	}
+
+	if corrupt+modified+missing+unlisted > 0 {
+		return exitCompletedWithErrors
+	}
+	if len(sorted) == 0 {
+		return exitNothingFound
+	}
+	return exitSuccess
+}
+
+// readOutputFile reads an extracted file, decrypting files written with
+// -encrypt-key and decompressing files written with -compress; the manifest
+// holds the hash of the original content
+func readOutputFile(path string, key []byte) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, output.EncryptionSuffix) {
+		if key == nil {
+			return nil, fmt.Errorf("encrypted, verify with -key")
+		}
+		if data, err = output.Decrypt(key, data); err != nil {
+			return nil, err
+		}
+		path = strings.TrimSuffix(path, output.EncryptionSuffix)
+	}
+	return output.Decompress(path, data)
+}
+
+// readManifest reads the manifest of an output directory, decrypting the one
+// written with -encrypt-key
+func readManifest(outputDir string, key []byte) (map[string]string, error) {
+	path := filepath.Join(outputDir, fileutils.ManifestName)
+	data, err := os.ReadFile(path + output.EncryptionSuffix)
+	if os.IsNotExist(err) {
+		return fileutils.ReadManifest(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%s is encrypted, verify with -key", fileutils.ManifestName)
+	}
+	if data, err = output.Decrypt(key, data); err != nil {
+		return nil, err
+	}
+	return fileutils.ParseManifest(bytes.NewReader(data))
+}
+
+// isRunOutput tells the files a run writes about itself, which are not carved
+// files and are not in the manifest
+func isRunOutput(name string) bool {
+	name = strings.TrimSuffix(name, output.EncryptionSuffix)
+	switch name {
+	case fileutils.ManifestName, reportName, coverageMapName + ".png", coverageMapName + ".svg":
+		return true
+	}
+	return false
+}
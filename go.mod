@@ -1,3 +1,7 @@
 module splitter-files
 
 go 1.22.2
+
+require github.com/klauspost/compress v1.17.11
+
+require golang.org/x/text v0.21.0
@@ -0,0 +1,101 @@
+package benchmark
+
+import (
+	"sort"
+
+	"splitter-files/internal/models"
+)
+
+// TypeScore is the accuracy for one format of the corpus
+type TypeScore struct {
+	Embedded int
+	Found    int
+	Exact    int
+}
+
+// Score compares extraction results with the files planted in the corpus
+type Score struct {
+	Extracted int
+	// Matched counts extracted files starting at a planted file of the same format
+	Matched int
+	// Found counts planted files that were extracted, Exact those extracted with the exact size
+	Found  int
+	Exact  int
+	Total  int
+	ByType map[string]*TypeScore
+}
+
+// Precision is the share of extracted files that match a planted file
+func (s Score) Precision() float64 {
+	if s.Extracted == 0 {
+		return 0
+	}
+	return float64(s.Matched) / float64(s.Extracted) * 100
+}
+
+// Recall is the share of planted files that were extracted
+func (s Score) Recall() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Found) / float64(s.Total) * 100
+}
+
+// Types returns the formats of the corpus in alphabetical order
+func (s Score) Types() []string {
+	types := make([]string, 0, len(s.ByType))
+	for ext := range s.ByType {
+		types = append(types, ext)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Evaluate scores the results of a run against the planted files
+func Evaluate(files []EmbeddedFile, results []models.ExtractionResult) Score {
+	score := Score{
+		Extracted: len(results),
+		Total:     len(files),
+		ByType:    make(map[string]*TypeScore),
+	}
+
+	planted := make(map[int]EmbeddedFile, len(files))
+	for _, f := range files {
+		planted[f.Start] = f
+		if score.ByType[f.Extension] == nil {
+			score.ByType[f.Extension] = &TypeScore{}
+		}
+		score.ByType[f.Extension].Embedded++
+	}
+
+	found := make(map[int]bool)
+	for _, res := range results {
+		f, ok := planted[res.Start]
+		if !ok || normalizeExtension(res.Extension) != f.Extension {
+			continue
+		}
+
+		score.Matched++
+		if found[f.Start] {
+			continue
+		}
+		found[f.Start] = true
+
+		ts := score.ByType[f.Extension]
+		score.Found++
+		ts.Found++
+		if res.Start+res.Size == f.End {
+			score.Exact++
+			ts.Exact++
+		}
+	}
+
+	return score
+}
+
+func normalizeExtension(ext string) string {
+	if ext == "jpeg" {
+		return "jpg"
+	}
+	return ext
+}
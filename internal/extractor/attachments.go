@@ -0,0 +1,89 @@
+package extractor
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AttachmentsDirSuffix is appended to the name of a PDF, without its extension,
+// to name the directory its embedded files are extracted to
+const AttachmentsDirSuffix = ".attachments"
+
+// maxAttachmentSize bounds the decoded size of an embedded file
+const maxAttachmentSize = 256 << 20
+
+// pdfAttachment is a file embedded in a PDF
+type pdfAttachment struct {
+	Name string
+	Data []byte
+}
+
+// pdfAttachments returns the files embedded in a PDF: the embedded file streams
+// of its file specifications, which the EmbeddedFiles name tree of the catalog
+// and the file attachment annotations of pages point at. Streams that can't be
+// decoded are left out, and so are those left when deadline has passed.
+func pdfAttachments(data []byte, deadline time.Time) ([]pdfAttachment, error) {
+	objects := parsePDFObjects(data, deadline)
+	// Streams of encrypted documents are ciphertext
+	if _, encrypted := pdfEncryption(data, objects); encrypted {
+		return nil, errors.New("encrypted document")
+	}
+
+	nums := make([]int, 0, len(objects))
+	for num := range objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	var attachments []pdfAttachment
+	seen := make(map[int]bool)
+	for _, num := range nums {
+		if deadlinePassed(deadline) {
+			break
+		}
+		spec := objects[num].value
+		ef, ok := pdfResolve(objects, pdfDictGet(spec, "EF"))
+		if !ok {
+			continue
+		}
+		ref := pdfDictGet(ef.value, "F")
+		if ref == nil {
+			ref = pdfDictGet(ef.value, "UF")
+		}
+		streamNum, ok := pdfRefNumber(ref)
+		if !ok || seen[streamNum] {
+			continue
+		}
+		seen[streamNum] = true
+
+		stream, ok := objects[streamNum]
+		if !ok || stream.stream == nil {
+			continue
+		}
+		content, err := pdfDecodeStream(stream, maxAttachmentSize)
+		if err != nil {
+			continue
+		}
+		attachments = append(attachments, pdfAttachment{Name: pdfFileSpecName(spec), Data: content})
+	}
+	if len(attachments) == 0 {
+		return nil, errors.New("no attachments")
+	}
+	return attachments, nil
+}
+
+// pdfFileSpecName returns the file name of a file specification, the Unicode
+// UF entry or the F entry, without its directories
+func pdfFileSpecName(spec []byte) string {
+	value := pdfDictGet(spec, "UF")
+	if value == nil {
+		value = pdfDictGet(spec, "F")
+	}
+	name := pdfTextString(pdfStringValue(value))
+	if i := strings.LastIndexAny(name, `/\:`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
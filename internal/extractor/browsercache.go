@@ -0,0 +1,232 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"splitter-files/internal/models"
+	"strings"
+)
+
+// Chrome "simple cache" entry (the *_0 files of the Cache_Data directory)
+const (
+	chromeCacheInitialMagic  uint64 = 0xfcfb6d1ba7725c30
+	chromeCacheFinalMagic    uint64 = 0xf4fa6f45970d41d8
+	chromeCacheHeaderSize           = 24
+	chromeCacheEOFSize              = 24
+	chromeCacheMaxKeyLength         = 64 * 1024
+	chromeCacheKeySHA256Flag        = 0x02
+)
+
+var (
+	chromeCacheMagic    = binary.LittleEndian.AppendUint64(nil, chromeCacheInitialMagic)
+	chromeCacheEOFMagic = binary.LittleEndian.AppendUint64(nil, chromeCacheFinalMagic)
+)
+
+// Firefox cache2 entry: content, metadata, big-endian offset of the metadata
+const (
+	firefoxCacheChunkSize  = 256 * 1024
+	firefoxCacheHeaderSize = 32
+	firefoxCacheMaxKeySize = 64 * 1024
+	firefoxCacheMaxSearch  = 32
+	// Distance from the end of the content the key is looked for in: the
+	// metadata header and the chunk hashes of up to 8 GB of content fit in it
+	firefoxCacheSearchWindow = 64 * 1024
+)
+
+func validateChromeCacheEntry(data []byte) bool {
+	if len(data) < chromeCacheHeaderSize || !bytes.HasPrefix(data, chromeCacheMagic) {
+		return false
+	}
+
+	version := binary.LittleEndian.Uint32(data[8:12])
+	if version < 5 || version > 9 {
+		return false
+	}
+
+	keyLength := int(binary.LittleEndian.Uint32(data[12:16]))
+	if keyLength == 0 || keyLength > chromeCacheMaxKeyLength || chromeCacheHeaderSize+keyLength > len(data) {
+		return false
+	}
+
+	return isPrintableASCII(data[chromeCacheHeaderSize : chromeCacheHeaderSize+keyLength])
+}
+
+// chromeCacheEOF reads the end-of-stream record that follows a stream of the
+// given start, returning the stream size and the position right after the record
+func chromeCacheEOF(data []byte, streamStart int) (int, int, uint32, bool) {
+	idx := bytes.Index(data[streamStart:], chromeCacheEOFMagic)
+	if idx == -1 {
+		return 0, 0, 0, false
+	}
+
+	eof := streamStart + idx
+	if eof+chromeCacheEOFSize > len(data) {
+		return 0, 0, 0, false
+	}
+
+	flags := binary.LittleEndian.Uint32(data[eof+8 : eof+12])
+	streamSize := int(binary.LittleEndian.Uint32(data[eof+16 : eof+20]))
+	return streamSize, eof + chromeCacheEOFSize, flags, true
+}
+
+// detectChromeCacheEntry extracts the body (stream 1) of a simple cache entry and
+// re-types it with the regular signatures
+func detectChromeCacheEntry(data []byte) (*models.ExtractionResult, []byte, error) {
+	if !validateChromeCacheEntry(data) {
+		return nil, nil, errors.New("invalid Chrome cache entry header")
+	}
+
+	keyLength := int(binary.LittleEndian.Uint32(data[12:16]))
+	key := string(data[chromeCacheHeaderSize : chromeCacheHeaderSize+keyLength])
+
+	bodyStart := chromeCacheHeaderSize + keyLength
+	bodySize, headersStart, _, ok := chromeCacheEOF(data, bodyStart)
+	if !ok || bodySize != headersStart-chromeCacheEOFSize-bodyStart {
+		return nil, nil, errors.New("Chrome cache entry body is truncated")
+	}
+	body := data[bodyStart : bodyStart+bodySize]
+
+	// Stream 0 holds the HTTP response headers and closes the entry
+	end := headersStart
+	if headersSize, entryEnd, flags, ok := chromeCacheEOF(data, headersStart); ok {
+		expected := entryEnd - chromeCacheEOFSize - headersStart
+		if flags&chromeCacheKeySHA256Flag != 0 {
+			expected -= 32
+		}
+		if headersSize == expected {
+			end = entryEnd
+		}
+	}
+
+	if len(body) == 0 {
+		return nil, nil, errors.New("Chrome cache entry has no body")
+	}
+
+	result := &models.ExtractionResult{
+		Size:      len(body),
+		End:       end,
+		FileType:  "Chrome Cache Entry",
+		Extension: "cache",
+		CacheInfo: &models.CacheEntryInfo{Browser: "Chrome", URL: stripCacheKeyPrefix(key)},
+	}
+
+	if payload, _, err := detectFile(body, nil, ValidationNormal, nil, 0, nil); err == nil {
+		result.FileType = fmt.Sprintf("Chrome Cache Entry (%s)", payload.FileType)
+		result.Extension = payload.Extension
+		result.OfficeInfo = payload.OfficeInfo
+		result.HighPriority = payload.HighPriority
+	}
+
+	return result, body, nil
+}
+
+// stripCacheKeyPrefix removes the partitioning prefix ("1/0/_dk_https://a.com https://a.com ")
+// Chrome puts in front of the URL
+func stripCacheKeyPrefix(key string) string {
+	if idx := strings.LastIndexByte(key, ' '); idx != -1 {
+		return key[idx+1:]
+	}
+	return key
+}
+
+type firefoxCacheEntry struct {
+	contentLength int
+	end           int
+	url           string
+}
+
+// findFirefoxCacheEntry checks whether data starts with the content of a Firefox
+// cache2 entry by locating the metadata whose trailing offset points back to it.
+// The key is only looked for around contentEnd, the end the content format gives
+// (which is a guess for formats without a length, so the metadata may lie before it)
+func findFirefoxCacheEntry(data []byte, contentEnd int) *firefoxCacheEntry {
+	pos := max(0, contentEnd-firefoxCacheSearchWindow)
+	limit := min(len(data), contentEnd+firefoxCacheSearchWindow)
+	for attempt := 0; attempt < firefoxCacheMaxSearch && pos < limit; attempt++ {
+		idx := bytes.Index(data[pos:limit], []byte(":http"))
+		if idx == -1 {
+			return nil
+		}
+		marker := pos + idx
+		pos = marker + 1
+
+		if entry := parseFirefoxCacheMetadata(data, marker); entry != nil {
+			return entry
+		}
+	}
+	return nil
+}
+
+// parseFirefoxCacheMetadata tries the metadata layouts that would place the key
+// around marker
+func parseFirefoxCacheMetadata(data []byte, marker int) *firefoxCacheEntry {
+	// The key looks like "a,:https://..." or "O^partitionKey=...,:https://...", so
+	// its start is somewhere shortly before the marker
+	for keyStart := marker; keyStart >= firefoxCacheHeaderSize && marker-keyStart < 512; keyStart-- {
+		header := data[keyStart-firefoxCacheHeaderSize : keyStart]
+		version := binary.BigEndian.Uint32(header[0:4])
+		if version < 2 || version > 3 {
+			continue
+		}
+
+		keySize := int(binary.BigEndian.Uint32(header[24:28]))
+		if keySize <= marker-keyStart || keySize > firefoxCacheMaxKeySize || keyStart+keySize >= len(data) || data[keyStart+keySize] != 0 {
+			continue
+		}
+		key := data[keyStart : keyStart+keySize]
+		if !isPrintableASCII(key) {
+			continue
+		}
+
+		// Metadata = hash (4) + chunk hashes (2 per 256 KB of content) + header
+		headerStart := keyStart - firefoxCacheHeaderSize
+		for chunks := 0; 4+2*chunks <= headerStart; chunks++ {
+			contentLength := headerStart - 4 - 2*chunks
+			if (contentLength+firefoxCacheChunkSize-1)/firefoxCacheChunkSize != chunks {
+				continue
+			}
+
+			end, ok := firefoxCacheMetadataEnd(data, keyStart+keySize+1, contentLength)
+			if !ok {
+				break
+			}
+
+			url := string(key)
+			if idx := bytes.Index(key, []byte(":http")); idx != -1 {
+				url = string(key[idx+1:])
+			}
+			return &firefoxCacheEntry{contentLength: contentLength, end: end, url: url}
+		}
+	}
+	return nil
+}
+
+// firefoxCacheMetadataEnd walks the NUL-terminated key/value elements and checks
+// that the trailing offset matches the content length
+func firefoxCacheMetadataEnd(data []byte, pos int, contentLength int) (int, bool) {
+	for pos+4 <= len(data) {
+		if int(binary.BigEndian.Uint32(data[pos:pos+4])) == contentLength {
+			return pos + 4, true
+		}
+
+		for i := 0; i < 2; i++ {
+			idx := bytes.IndexByte(data[pos:], 0)
+			if idx <= 0 || !isPrintableASCII(data[pos:pos+idx]) {
+				return 0, false
+			}
+			pos += idx + 1
+		}
+	}
+	return 0, false
+}
+
+func isPrintableASCII(data []byte) bool {
+	for _, b := range data {
+		if (b < 0x20 || b > 0x7E) && b != '\r' && b != '\n' && b != '\t' {
+			return false
+		}
+	}
+	return true
+}
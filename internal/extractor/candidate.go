@@ -0,0 +1,41 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	errNoSignature = errors.New("no known file signatures found")
+	errTooSmall    = errors.New("file too small")
+
+	// ErrFileLimit is returned for a candidate found once the limit of output
+	// files is reached, which is dropped without a trace
+	ErrFileLimit = errors.New("limit of output files reached")
+)
+
+// CandidateError is returned for a candidate that was not extracted: its data
+// fails the checks of the format whose magic number it starts with, the file
+// is smaller than its format allows, it could not be written, or processing
+// it crashed or timed out
+type CandidateError struct {
+	// Kind is one of the models.Failure* kinds
+	Kind string
+	// Extension is the format the candidate was taken for, empty if no magic
+	// number matches at all
+	Extension string
+	Start     int
+	Err       error
+}
+
+func (e *CandidateError) Error() string {
+	ext := e.Extension
+	if ext == "" {
+		ext = "candidate"
+	}
+	return fmt.Sprintf("%s at %d: %s: %v", ext, e.Start, e.Kind, e.Err)
+}
+
+func (e *CandidateError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,184 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/pem"
+	"strings"
+)
+
+var pemMagic = []byte("-----BEGIN ")
+
+const pemMaxBlockSize = 1024 * 1024
+
+// pemLabels lists the PEM block types carved as certificates and keys
+var pemLabels = map[string]string{
+	"CERTIFICATE":             "PEM Certificate",
+	"TRUSTED CERTIFICATE":     "PEM Certificate",
+	"X509 CRL":                "PEM Certificate Revocation List",
+	"CERTIFICATE REQUEST":     "PEM Certificate Request",
+	"NEW CERTIFICATE REQUEST": "PEM Certificate Request",
+	"PRIVATE KEY":             "PEM Private Key",
+	"ENCRYPTED PRIVATE KEY":   "PEM Private Key (encrypted)",
+	"RSA PRIVATE KEY":         "PEM Private Key (RSA)",
+	"EC PRIVATE KEY":          "PEM Private Key (EC)",
+	"DSA PRIVATE KEY":         "PEM Private Key (DSA)",
+	"PUBLIC KEY":              "PEM Public Key",
+	"RSA PUBLIC KEY":          "PEM Public Key (RSA)",
+}
+
+// pemLabel returns the block type written after "-----BEGIN "
+func pemLabel(data []byte) string {
+	if !bytes.HasPrefix(data, pemMagic) {
+		return ""
+	}
+
+	rest := data[len(pemMagic):]
+	if len(rest) > 64 {
+		rest = rest[:64]
+	}
+	idx := bytes.Index(rest, []byte("-----"))
+	if idx == -1 {
+		return ""
+	}
+	return string(rest[:idx])
+}
+
+// pemBlockSize decodes the PEM block at the start of data and returns its length
+// including the trailing line break, or 0 if the block is damaged
+func pemBlockSize(data []byte) int {
+	label := pemLabel(data)
+	if label == "" {
+		return 0
+	}
+
+	window := data
+	if len(window) > pemMaxBlockSize {
+		window = window[:pemMaxBlockSize]
+	}
+
+	block, rest := pem.Decode(window)
+	if block == nil || block.Type != label || len(block.Bytes) == 0 {
+		return 0
+	}
+	return len(window) - len(rest)
+}
+
+func validatePEM(data []byte) bool {
+	if _, ok := pemLabels[pemLabel(data)]; !ok {
+		return false
+	}
+	return pemBlockSize(data) > 0
+}
+
+func pemFileType(data []byte) string {
+	if fileType, ok := pemLabels[pemLabel(data)]; ok {
+		if strings.Contains(string(data[:pemBlockSize(data)]), "Proc-Type: 4,ENCRYPTED") {
+			return fileType + " (encrypted)"
+		}
+		return fileType
+	}
+	return "PEM Block"
+}
+
+// asn1Sequence parses a DER SEQUENCE header and returns the header and content lengths
+func asn1Sequence(data []byte) (int, int, bool) {
+	if len(data) < 2 || data[0] != 0x30 {
+		return 0, 0, false
+	}
+
+	if data[1] < 0x80 {
+		return 2, int(data[1]), true
+	}
+
+	numBytes := int(data[1] & 0x7F)
+	if numBytes == 0 || numBytes > 4 || len(data) < 2+numBytes {
+		return 0, 0, false
+	}
+
+	length := 0
+	for _, b := range data[2 : 2+numBytes] {
+		length = length<<8 | int(b)
+	}
+	// DER requires the shortest length form
+	if length < 0x80 || (numBytes > 1 && data[2] == 0) {
+		return 0, 0, false
+	}
+	return 2 + numBytes, length, true
+}
+
+// derObjectSize returns the total length of the DER SEQUENCE at the start of data
+func derObjectSize(data []byte) int {
+	header, length, ok := asn1Sequence(data)
+	if !ok {
+		return 0
+	}
+	return header + length
+}
+
+// validateDERCertificate checks the Certificate ::= SEQUENCE { tbsCertificate, signatureAlgorithm, signature }
+// structure of an X.509 certificate
+func validateDERCertificate(data []byte) bool {
+	header, length, ok := asn1Sequence(data)
+	if !ok || length < 128 || header+length > len(data) {
+		return false
+	}
+
+	tbs := data[header : header+length]
+	tbsHeader, tbsLength, ok := asn1Sequence(tbs)
+	if !ok || tbsHeader+tbsLength >= len(tbs) {
+		return false
+	}
+
+	body := tbs[tbsHeader:]
+	switch {
+	case bytes.HasPrefix(body, []byte{0xA0, 0x03, 0x02, 0x01}):
+		// Explicit version (v2/v3)
+		if len(body) < 5 || body[4] > 2 {
+			return false
+		}
+	case len(body) > 0 && body[0] == 0x02:
+		// v1 certificate starts with the serial number
+	default:
+		return false
+	}
+
+	// signatureAlgorithm AlgorithmIdentifier follows the tbsCertificate
+	algorithm := tbs[tbsHeader+tbsLength:]
+	_, algLength, ok := asn1Sequence(algorithm)
+	return ok && algLength > 2 && len(algorithm) > 2 && algorithm[2] == 0x06
+}
+
+var (
+	// id-data and id-signedData content types of the PFX authSafe
+	pkcs7DataOID       = []byte{0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07, 0x01}
+	pkcs7SignedDataOID = []byte{0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07, 0x02}
+	// Password-protected content: id-encryptedData and pkcs8ShroudedKeyBag
+	pkcs7EncryptedDataOID  = []byte{0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x07, 0x06}
+	pkcs12ShroudedKeyBagID = []byte{0x06, 0x0B, 0x2A, 0x86, 0x48, 0x86, 0xF7, 0x0D, 0x01, 0x0C, 0x0A, 0x01, 0x02}
+)
+
+// validatePKCS12 checks PFX ::= SEQUENCE { version INTEGER (3), authSafe ContentInfo, macData }
+func validatePKCS12(data []byte) bool {
+	header, length, ok := asn1Sequence(data)
+	if !ok || header+length > len(data) {
+		return false
+	}
+
+	body := data[header : header+length]
+	if !bytes.HasPrefix(body, []byte{0x02, 0x01, 0x03}) {
+		return false
+	}
+
+	authSafe := body[3:]
+	authHeader, _, ok := asn1Sequence(authSafe)
+	if !ok {
+		return false
+	}
+	contentType := authSafe[authHeader:]
+	return bytes.HasPrefix(contentType, pkcs7DataOID) || bytes.HasPrefix(contentType, pkcs7SignedDataOID)
+}
+
+// isEncryptedPKCS12 reports whether the keystore holds password-protected bags
+func isEncryptedPKCS12(data []byte) bool {
+	return bytes.Contains(data, pkcs7EncryptedDataOID) || bytes.Contains(data, pkcs12ShroudedKeyBagID)
+}
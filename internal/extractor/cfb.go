@@ -0,0 +1,408 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf16"
+)
+
+// Compound File Binary (OLE2) container used by legacy Office formats
+
+var cfbMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+const (
+	cfbHeaderSize   = 512
+	cfbDirEntrySize = 128
+	cfbMaxRegSect   = 0xFFFFFFFA
+	cfbEndOfChain   = 0xFFFFFFFE
+	cfbFreeSect     = 0xFFFFFFFF
+	cfbNoStream     = 0xFFFFFFFF
+
+	cfbTypeStorage = 1
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+)
+
+type cfbEntry struct {
+	Name  string
+	Type  byte
+	Left  uint32
+	Right uint32
+	Child uint32
+	CLSID [16]byte
+	Start uint32
+	Size  uint64
+}
+
+type cfbFile struct {
+	data           []byte
+	sectorSize     int
+	miniSectorSize int
+	miniCutoff     uint64
+	fat            []uint32
+	miniFAT        []uint32
+	entries        []cfbEntry
+	miniStream     []byte
+}
+
+func parseCFB(data []byte) (*cfbFile, error) {
+	if len(data) < cfbHeaderSize || !bytes.HasPrefix(data, cfbMagic) {
+		return nil, errors.New("not a compound file")
+	}
+
+	if binary.LittleEndian.Uint16(data[28:30]) != 0xFFFE {
+		return nil, errors.New("invalid byte order mark")
+	}
+
+	sectorShift := binary.LittleEndian.Uint16(data[30:32])
+	miniShift := binary.LittleEndian.Uint16(data[32:34])
+	if (sectorShift != 9 && sectorShift != 12) || miniShift != 6 {
+		return nil, errors.New("unsupported sector size")
+	}
+
+	c := &cfbFile{
+		data:           data,
+		sectorSize:     1 << sectorShift,
+		miniSectorSize: 1 << miniShift,
+		miniCutoff:     uint64(binary.LittleEndian.Uint32(data[56:60])),
+	}
+
+	// Collect FAT sector locations from the header DIFAT and the DIFAT chain
+	// The FAT can't take more sectors than there are
+	numFATSectors := int(binary.LittleEndian.Uint32(data[44:48]))
+	if numFATSectors > c.sectorCount() {
+		numFATSectors = c.sectorCount()
+	}
+	var fatSectors []uint32
+	for i := 0; i < 109 && len(fatSectors) < numFATSectors; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(data[76+i*4:80+i*4]))
+	}
+
+	difatSector := binary.LittleEndian.Uint32(data[68:72])
+	perSector := c.sectorSize/4 - 1
+	visited := newSectorSet(c.sectorCount())
+	for difatSector <= cfbMaxRegSect && len(fatSectors) < numFATSectors {
+		sector, ok := c.sector(difatSector)
+		if !ok || !visited.add(difatSector) {
+			return nil, errors.New("corrupted DIFAT chain")
+		}
+		for i := 0; i < perSector && len(fatSectors) < numFATSectors; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(sector[i*4:i*4+4]))
+		}
+		difatSector = binary.LittleEndian.Uint32(sector[perSector*4:])
+	}
+
+	for _, s := range fatSectors {
+		sector, ok := c.sector(s)
+		if !ok {
+			// Keep what we have, the file may be truncated
+			break
+		}
+		for i := 0; i+4 <= len(sector); i += 4 {
+			c.fat = append(c.fat, binary.LittleEndian.Uint32(sector[i:i+4]))
+		}
+	}
+	if len(c.fat) == 0 {
+		return nil, errors.New("no FAT sectors")
+	}
+
+	dirData, err := c.readChain(binary.LittleEndian.Uint32(data[48:52]), 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+cfbDirEntrySize <= len(dirData); i += cfbDirEntrySize {
+		c.entries = append(c.entries, parseCFBEntry(dirData[i:i+cfbDirEntrySize]))
+	}
+	if len(c.entries) == 0 || c.entries[0].Type != cfbTypeRoot {
+		return nil, errors.New("missing root directory entry")
+	}
+
+	if miniFAT, err := c.readChain(binary.LittleEndian.Uint32(data[60:64]), 0); err == nil {
+		for i := 0; i+4 <= len(miniFAT); i += 4 {
+			c.miniFAT = append(c.miniFAT, binary.LittleEndian.Uint32(miniFAT[i:i+4]))
+		}
+	}
+	root := c.entries[0]
+	c.miniStream, _ = c.readChain(root.Start, root.Size)
+
+	return c, nil
+}
+
+func parseCFBEntry(raw []byte) cfbEntry {
+	nameLen := int(binary.LittleEndian.Uint16(raw[64:66]))
+	if nameLen > 64 {
+		nameLen = 64
+	}
+
+	units := make([]uint16, 0, nameLen/2)
+	for i := 0; i+1 < nameLen; i += 2 {
+		u := binary.LittleEndian.Uint16(raw[i : i+2])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+
+	e := cfbEntry{
+		Name:  string(utf16.Decode(units)),
+		Type:  raw[66],
+		Left:  binary.LittleEndian.Uint32(raw[68:72]),
+		Right: binary.LittleEndian.Uint32(raw[72:76]),
+		Child: binary.LittleEndian.Uint32(raw[76:80]),
+		Start: binary.LittleEndian.Uint32(raw[116:120]),
+		Size:  binary.LittleEndian.Uint64(raw[120:128]),
+	}
+	copy(e.CLSID[:], raw[80:96])
+	return e
+}
+
+// sectorCount returns the number of whole sectors after the header
+func (c *cfbFile) sectorCount() int {
+	return len(c.data)/c.sectorSize - 1
+}
+
+// sectorSet marks the sectors a chain went through. A chain can't be longer
+// than the sectors there are, and one that comes back to a sector loops.
+type sectorSet []uint64
+
+func newSectorSet(n int) sectorSet {
+	if n < 0 {
+		n = 0
+	}
+	return make(sectorSet, (n+63)/64)
+}
+
+// add marks sector n, reporting false if it is out of the set or was marked before
+func (s sectorSet) add(n uint32) bool {
+	word, bit := int(n/64), uint64(1)<<(n%64)
+	if word >= len(s) || s[word]&bit != 0 {
+		return false
+	}
+	s[word] |= bit
+	return true
+}
+
+func (c *cfbFile) sector(n uint32) ([]byte, bool) {
+	if n > cfbMaxRegSect {
+		return nil, false
+	}
+	start := (int(n) + 1) * c.sectorSize
+	if start < 0 || start+c.sectorSize > len(c.data) {
+		return nil, false
+	}
+	return c.data[start : start+c.sectorSize], true
+}
+
+// readChain follows a FAT chain. A size of 0 reads the whole chain.
+func (c *cfbFile) readChain(start uint32, size uint64) ([]byte, error) {
+	var buf []byte
+	visited := newSectorSet(c.sectorCount())
+	for s := start; s != cfbEndOfChain; {
+		if int(s) >= len(c.fat) || !visited.add(s) {
+			return nil, errors.New("corrupted sector chain")
+		}
+		sector, ok := c.sector(s)
+		if !ok {
+			return nil, errors.New("sector out of range")
+		}
+		buf = append(buf, sector...)
+		if size > 0 && uint64(len(buf)) >= size {
+			break
+		}
+		s = c.fat[s]
+	}
+
+	if size > 0 {
+		if uint64(len(buf)) < size {
+			return nil, errors.New("stream is truncated")
+		}
+		buf = buf[:size]
+	}
+	return buf, nil
+}
+
+func (c *cfbFile) readMiniChain(start uint32, size uint64) ([]byte, error) {
+	var buf []byte
+	visited := newSectorSet(len(c.miniStream) / c.miniSectorSize)
+	for s := start; uint64(len(buf)) < size; {
+		if int(s) >= len(c.miniFAT) || !visited.add(s) {
+			return nil, errors.New("corrupted mini sector chain")
+		}
+		offset := int(s) * c.miniSectorSize
+		if offset+c.miniSectorSize > len(c.miniStream) {
+			return nil, errors.New("mini sector out of range")
+		}
+		buf = append(buf, c.miniStream[offset:offset+c.miniSectorSize]...)
+		s = c.miniFAT[s]
+	}
+	return buf[:size], nil
+}
+
+// compoundFile returns the compound file at the start of the candidate, or nil
+// if it doesn't start with one or its structures are too damaged to read. It is
+// parsed the first time a check asks for it, so that detection, properties,
+// indicators and decryption all read one parse
+func (cand *candidate) compoundFile() *cfbFile {
+	if !cand.cfbParsed {
+		cand.cfbParsed = true
+		if bytes.HasPrefix(cand.data, cfbMagic) {
+			cand.cfb, _ = parseCFB(cand.data)
+		}
+	}
+	return cand.cfb
+}
+
+// Root returns the root storage entry
+func (c *cfbFile) Root() *cfbEntry {
+	return &c.entries[0]
+}
+
+// Children lists the entries stored directly inside a storage
+func (c *cfbFile) Children(parent *cfbEntry) []*cfbEntry {
+	var children []*cfbEntry
+	visited := make(map[uint32]bool)
+
+	var walk func(id uint32)
+	walk = func(id uint32) {
+		if id == cfbNoStream || int(id) >= len(c.entries) || visited[id] {
+			return
+		}
+		visited[id] = true
+		e := &c.entries[id]
+		walk(e.Left)
+		children = append(children, e)
+		walk(e.Right)
+	}
+	walk(parent.Child)
+
+	return children
+}
+
+// Lookup resolves a path of storage/stream names starting at the root (case-insensitive)
+func (c *cfbFile) Lookup(path ...string) *cfbEntry {
+	current := c.Root()
+	for _, name := range path {
+		var next *cfbEntry
+		for _, child := range c.Children(current) {
+			if strings.EqualFold(child.Name, name) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// HasEntry reports whether any storage or stream in the file has the given name
+func (c *cfbFile) HasEntry(name string) bool {
+	for i := 1; i < len(c.entries); i++ {
+		if c.entries[i].Type != 0 && strings.EqualFold(c.entries[i].Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadStream returns the content of a stream entry
+func (c *cfbFile) ReadStream(e *cfbEntry) ([]byte, error) {
+	if e == nil || e.Type != cfbTypeStream {
+		return nil, errors.New("not a stream")
+	}
+	if e.Size == 0 {
+		return nil, nil
+	}
+
+	// Version 3 files may leave garbage in the high 32 bits
+	size := e.Size
+	if c.sectorSize == 512 {
+		size &= 0xFFFFFFFF
+	}
+
+	if size < c.miniCutoff {
+		return c.readMiniChain(e.Start, size)
+	}
+	return c.readChain(e.Start, size)
+}
+
+// writeStream overwrites the content of a stream in out, a copy of the file,
+// with content of the same size
+func (c *cfbFile) writeStream(out []byte, e *cfbEntry, content []byte) error {
+	size := uint64(len(content))
+	if size < c.miniCutoff {
+		// Mini sectors are stored in the mini stream, itself a chain of regular
+		// sectors; they never cross a sector boundary
+		var miniStreamSectors []uint32
+		visited := newSectorSet(c.sectorCount())
+		for s := c.Root().Start; s != cfbEndOfChain; {
+			if int(s) >= len(c.fat) || !visited.add(s) {
+				return errors.New("corrupted sector chain")
+			}
+			miniStreamSectors = append(miniStreamSectors, s)
+			s = c.fat[s]
+		}
+
+		perSector := c.sectorSize / c.miniSectorSize
+		visited = newSectorSet(len(miniStreamSectors) * perSector)
+		for s, written := e.Start, 0; written < len(content); {
+			if int(s) >= len(c.miniFAT) || int(s)/perSector >= len(miniStreamSectors) || !visited.add(s) {
+				return errors.New("corrupted mini sector chain")
+			}
+			offset := (int(miniStreamSectors[int(s)/perSector])+1)*c.sectorSize + int(s)%perSector*c.miniSectorSize
+			written += copy(out[offset:offset+c.miniSectorSize], content[written:])
+			s = c.miniFAT[s]
+		}
+		return nil
+	}
+
+	visited := newSectorSet(c.sectorCount())
+	for s, written := e.Start, 0; written < len(content); {
+		if int(s) >= len(c.fat) || !visited.add(s) {
+			return errors.New("corrupted sector chain")
+		}
+		if _, ok := c.sector(s); !ok {
+			return errors.New("sector out of range")
+		}
+		offset := (int(s) + 1) * c.sectorSize
+		written += copy(out[offset:offset+c.sectorSize], content[written:])
+		s = c.fat[s]
+	}
+	return nil
+}
+
+// guidBytes converts a textual GUID into its on-disk (mixed-endian) representation
+func guidBytes(s string) [16]byte {
+	var g [16]byte
+	s = strings.NewReplacer("{", "", "}", "", "-", "").Replace(s)
+	if len(s) != 32 {
+		return g
+	}
+
+	var raw [16]byte
+	for i := 0; i < 16; i++ {
+		var b byte
+		for _, ch := range s[i*2 : i*2+2] {
+			b <<= 4
+			switch {
+			case ch >= '0' && ch <= '9':
+				b |= byte(ch - '0')
+			case ch >= 'a' && ch <= 'f':
+				b |= byte(ch-'a') + 10
+			case ch >= 'A' && ch <= 'F':
+				b |= byte(ch-'A') + 10
+			}
+		}
+		raw[i] = b
+	}
+
+	g[0], g[1], g[2], g[3] = raw[3], raw[2], raw[1], raw[0]
+	g[4], g[5] = raw[5], raw[4]
+	g[6], g[7] = raw[7], raw[6]
+	copy(g[8:], raw[8:])
+	return g
+}
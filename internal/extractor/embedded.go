@@ -0,0 +1,77 @@
+package extractor
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"path"
+	"strings"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// relationships represents a .rels part of an OPC package
+type relationships struct {
+	Relationship []struct {
+		Type       string `xml:"Type,attr"`
+		Target     string `xml:"Target,attr"`
+		TargetMode string `xml:"TargetMode,attr"`
+	} `xml:"Relationship"`
+}
+
+// readEmbeddedObjects lists the embedded objects, images and external references
+// of an OOXML package; these are where weaponized documents usually hide their
+// payload. No relationship part is read after deadline.
+func readEmbeddedObjects(zipReader *zip.Reader, info *models.OfficeDocumentInfo, deadline time.Time) {
+	for _, file := range zipReader.File {
+		name := file.Name
+		dir := path.Base(path.Dir(name))
+
+		switch {
+		case strings.HasSuffix(name, "/vbaProject.bin"):
+			info.IsMacro = true
+		case dir == "embeddings":
+			kind := "package"
+			if strings.HasPrefix(path.Base(name), "oleObject") || strings.HasSuffix(name, ".bin") {
+				kind = "OLE object"
+			}
+			info.Embedded = append(info.Embedded, models.EmbeddedObject{Kind: kind, Name: name})
+		case dir == "activeX" && strings.HasSuffix(name, ".bin"):
+			info.Embedded = append(info.Embedded, models.EmbeddedObject{Kind: "ActiveX control", Name: name})
+		case dir == "media":
+			info.Embedded = append(info.Embedded, models.EmbeddedObject{Kind: "image", Name: name})
+		case dir == "_rels" && strings.HasSuffix(name, ".rels") && !deadlinePassed(deadline):
+			info.External = append(info.External, externalReferences(file)...)
+		}
+	}
+}
+
+// externalReferences returns the relationships of a .rels part that point outside the package
+func externalReferences(file *zip.File) []models.ExternalReference {
+	rc, err := openEntry(file)
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+
+	var rels relationships
+	if err := xml.NewDecoder(rc).Decode(&rels); err != nil {
+		return nil
+	}
+
+	// word/_rels/document.xml.rels describes word/document.xml
+	source := path.Join(path.Dir(path.Dir(file.Name)), strings.TrimSuffix(path.Base(file.Name), ".rels"))
+
+	var refs []models.ExternalReference
+	for _, rel := range rels.Relationship {
+		if !strings.EqualFold(rel.TargetMode, "External") {
+			continue
+		}
+		refs = append(refs, models.ExternalReference{
+			Type:   path.Base(rel.Type),
+			Target: rel.Target,
+			Source: source,
+		})
+	}
+	return refs
+}
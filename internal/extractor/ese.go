@@ -0,0 +1,65 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Extensible Storage Engine database (Windows.edb, ntds.dit, SRUDB.dat, WebCacheV01.dat, ...)
+var eseMagic = []byte{0xEF, 0xCD, 0xAB, 0x89}
+
+const (
+	eseHeaderSize      = 668
+	eseFormatVersion   = 0x620
+	eseDefaultPageSize = 4096
+)
+
+// esePageSize returns the page size declared in the database header or 0 if it is invalid
+func esePageSize(data []byte) int {
+	if len(data) < eseHeaderSize {
+		return 0
+	}
+
+	pageSize := int(binary.LittleEndian.Uint32(data[236:240]))
+	switch pageSize {
+	case 0:
+		// Databases created before Windows Vista don't record the page size
+		return eseDefaultPageSize
+	case 2048, 4096, 8192, 16384, 32768:
+		return pageSize
+	}
+	return 0
+}
+
+func validateESE(data []byte) bool {
+	if len(data) < eseHeaderSize || !bytes.Equal(data[4:8], eseMagic) {
+		return false
+	}
+
+	if binary.LittleEndian.Uint32(data[8:12]) != eseFormatVersion {
+		return false
+	}
+
+	// File type: 0 - database, 1 - streaming file
+	if binary.LittleEndian.Uint32(data[12:16]) != 0 {
+		return false
+	}
+
+	// Database state: just created, dirty shutdown, clean shutdown, being converted, force detach
+	state := binary.LittleEndian.Uint32(data[52:56])
+	if state < 1 || state > 5 {
+		return false
+	}
+
+	pageSize := esePageSize(data)
+	if pageSize == 0 {
+		return false
+	}
+
+	// The second page holds a shadow copy of the header
+	if len(data) >= 2*pageSize && !bytes.Equal(data[pageSize+4:pageSize+8], eseMagic) {
+		return false
+	}
+
+	return true
+}
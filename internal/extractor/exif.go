@@ -0,0 +1,258 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// EXIF metadata stored in the APP1 segment of JPEG images (TIFF structure)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+const (
+	exifTagExifIFD            = 0x8769
+	exifTagGPSIFD             = 0x8825
+	exifTagDateTime           = 0x0132
+	exifTagDateTimeOriginal   = 0x9003
+	exifTagOffsetTimeOriginal = 0x9011
+
+	gpsTagLatitudeRef  = 0x0001
+	gpsTagLatitude     = 0x0002
+	gpsTagLongitudeRef = 0x0003
+	gpsTagLongitude    = 0x0004
+	gpsTagAltitudeRef  = 0x0005
+	gpsTagAltitude     = 0x0006
+
+	tiffTypeASCII    = 2
+	tiffTypeLong     = 4
+	tiffTypeRational = 5
+)
+
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte // inline value or the data it points to
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// jpegExif returns the TIFF structure of the EXIF segment of a JPEG image
+func jpegExif(data []byte) *tiffReader {
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		if marker == 0xD9 || marker == 0xDA {
+			// Metadata segments precede the image data
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil
+		}
+
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, exifHeader) {
+			return newTIFFReader(segment[len(exifHeader):])
+		}
+		pos += 2 + length
+	}
+	return nil
+}
+
+func newTIFFReader(data []byte) *tiffReader {
+	if len(data) < 8 {
+		return nil
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	if order.Uint16(data[2:4]) != 42 {
+		return nil
+	}
+	return &tiffReader{data: data, order: order}
+}
+
+// ifd reads the entries of the image file directory at offset (0 - the first IFD)
+func (t *tiffReader) ifd(offset uint32) map[uint16]tiffEntry {
+	if offset == 0 {
+		offset = t.order.Uint32(t.data[4:8])
+	}
+	if int(offset)+2 > len(t.data) {
+		return nil
+	}
+
+	count := int(t.order.Uint16(t.data[offset : offset+2]))
+	entries := make(map[uint16]tiffEntry, count)
+	for i := 0; i < count; i++ {
+		pos := int(offset) + 2 + i*12
+		if pos+12 > len(t.data) {
+			break
+		}
+
+		raw := t.data[pos : pos+12]
+		e := tiffEntry{
+			typ:   t.order.Uint16(raw[2:4]),
+			count: t.order.Uint32(raw[4:8]),
+		}
+
+		size := tiffTypeSize(e.typ) * int(e.count)
+		if size <= 0 {
+			continue
+		}
+		if size <= 4 {
+			e.value = raw[8 : 8+size]
+		} else {
+			valueOffset := int(t.order.Uint32(raw[8:12]))
+			if valueOffset+size > len(t.data) {
+				continue
+			}
+			e.value = t.data[valueOffset : valueOffset+size]
+		}
+
+		entries[t.order.Uint16(raw[0:2])] = e
+	}
+	return entries
+}
+
+func tiffTypeSize(typ uint16) int {
+	switch typ {
+	case 1, tiffTypeASCII, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case tiffTypeLong, 9, 11:
+		return 4
+	case tiffTypeRational, 10, 12:
+		return 8
+	}
+	return 0
+}
+
+func (t *tiffReader) long(e tiffEntry) (uint32, bool) {
+	if e.typ != tiffTypeLong || len(e.value) < 4 {
+		return 0, false
+	}
+	return t.order.Uint32(e.value), true
+}
+
+func (t *tiffReader) rationals(e tiffEntry) []float64 {
+	if e.typ != tiffTypeRational {
+		return nil
+	}
+
+	var values []float64
+	for i := 0; i+8 <= len(e.value); i += 8 {
+		num := t.order.Uint32(e.value[i : i+4])
+		den := t.order.Uint32(e.value[i+4 : i+8])
+		if den == 0 {
+			return nil
+		}
+		values = append(values, float64(num)/float64(den))
+	}
+	return values
+}
+
+func (t *tiffReader) ascii(e tiffEntry) string {
+	if e.typ != tiffTypeASCII {
+		return ""
+	}
+	return string(bytes.TrimRight(e.value, "\x00 "))
+}
+
+// jpegGPSLocation returns the coordinates recorded in the EXIF GPS IFD of a JPEG image
+func jpegGPSLocation(data []byte) *models.GeoLocation {
+	t := jpegExif(data)
+	if t == nil {
+		return nil
+	}
+
+	gpsOffset, ok := t.long(t.ifd(0)[exifTagGPSIFD])
+	if !ok || gpsOffset == 0 {
+		return nil
+	}
+
+	gps := t.ifd(gpsOffset)
+	lat := t.rationals(gps[gpsTagLatitude])
+	lon := t.rationals(gps[gpsTagLongitude])
+	if len(lat) != 3 || len(lon) != 3 {
+		return nil
+	}
+
+	loc := &models.GeoLocation{
+		Latitude:  lat[0] + lat[1]/60 + lat[2]/3600,
+		Longitude: lon[0] + lon[1]/60 + lon[2]/3600,
+	}
+	if t.ascii(gps[gpsTagLatitudeRef]) == "S" {
+		loc.Latitude = -loc.Latitude
+	}
+	if t.ascii(gps[gpsTagLongitudeRef]) == "W" {
+		loc.Longitude = -loc.Longitude
+	}
+	if loc.Latitude < -90 || loc.Latitude > 90 || loc.Longitude < -180 || loc.Longitude > 180 {
+		return nil
+	}
+
+	if alt := t.rationals(gps[gpsTagAltitude]); len(alt) == 1 {
+		loc.Altitude = alt[0]
+		// Altitude reference 1 means below sea level
+		if ref := gps[gpsTagAltitudeRef]; len(ref.value) == 1 && ref.value[0] == 1 {
+			loc.Altitude = -loc.Altitude
+		}
+		loc.HasAltitude = true
+	}
+
+	return loc
+}
+
+// jpegCaptureTime returns when a JPEG photo was taken (DateTimeOriginal, or the
+// modification DateTime of IFD0 when the Exif IFD doesn't have it)
+func jpegCaptureTime(data []byte) time.Time {
+	t := jpegExif(data)
+	if t == nil {
+		return time.Time{}
+	}
+
+	ifd0 := t.ifd(0)
+	if exifOffset, ok := t.long(ifd0[exifTagExifIFD]); ok && exifOffset != 0 {
+		exif := t.ifd(exifOffset)
+		if ts := parseExifTime(t.ascii(exif[exifTagDateTimeOriginal]), t.ascii(exif[exifTagOffsetTimeOriginal])); !ts.IsZero() {
+			return ts
+		}
+	}
+	return parseExifTime(t.ascii(ifd0[exifTagDateTime]), "")
+}
+
+// parseExifTime parses "2006:01:02 15:04:05"; EXIF times are local to the camera,
+// so the zone is taken from the offset tag or the local time zone
+func parseExifTime(value, offset string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+
+	loc := time.Local
+	if o, err := time.Parse("-07:00", offset); err == nil {
+		_, seconds := o.Zone()
+		loc = time.FixedZone(offset, seconds)
+	}
+
+	ts, err := time.ParseInLocation("2006:01:02 15:04:05", value, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return ts
+}
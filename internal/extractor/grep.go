@@ -0,0 +1,97 @@
+package extractor
+
+import (
+	"regexp"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"splitter-files/internal/models"
+)
+
+// maxContentMatches caps the matches recorded per file
+const maxContentMatches = 100
+
+// ContentFilter extracts only files whose content matches one of its patterns
+type ContentFilter struct {
+	Patterns []*regexp.Regexp
+	// UTF16 also matches the patterns against the content decoded as UTF-16LE
+	// text, the encoding of binary Office documents and most Windows artifacts
+	UTF16 bool
+}
+
+// NewContentFilter compiles the patterns of a content filter
+func NewContentFilter(patterns []string, utf16 bool) (*ContentFilter, error) {
+	f := &ContentFilter{UTF16: utf16}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.Patterns = append(f.Patterns, re)
+	}
+	return f, nil
+}
+
+// Match returns the matches of the patterns in data, ordered by offset
+func (f *ContentFilter) Match(data []byte) []models.ContentMatch {
+	var matches []models.ContentMatch
+	for _, re := range f.Patterns {
+		for _, loc := range re.FindAllIndex(data, maxContentMatches) {
+			matches = append(matches, models.ContentMatch{Pattern: re.String(), Offset: loc[0]})
+		}
+	}
+
+	if f.UTF16 {
+		// Text may start at either byte alignment
+		for align := 0; align < 2 && align < len(data); align++ {
+			text := decodeUTF16(data[align:], nil)
+			var found []models.ContentMatch
+			for _, re := range f.Patterns {
+				for _, loc := range re.FindAllIndex(text, maxContentMatches) {
+					found = append(found, models.ContentMatch{Pattern: re.String(), Offset: loc[0], Encoding: "utf-16le"})
+				}
+			}
+			if len(found) == 0 {
+				continue
+			}
+
+			// Offsets in the decoded text are mapped back by decoding again
+			sort.Slice(found, func(i, j int) bool { return found[i].Offset < found[j].Offset })
+			next := 0
+			decodeUTF16(data[align:], func(textOffset, srcOffset int) {
+				for next < len(found) && found[next].Offset == textOffset {
+					found[next].Offset = align + srcOffset
+					next++
+				}
+			})
+			matches = append(matches, found...)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Offset < matches[j].Offset })
+	if len(matches) > maxContentMatches {
+		matches = matches[:maxContentMatches]
+	}
+	return matches
+}
+
+// decodeUTF16 converts UTF-16LE data to UTF-8, calling visit (if not nil)
+// with the UTF-8 and source offsets of every character
+func decodeUTF16(data []byte, visit func(textOffset, srcOffset int)) []byte {
+	text := make([]byte, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if visit != nil {
+			visit(len(text), i)
+		}
+		r := rune(data[i]) | rune(data[i+1])<<8
+		if utf16.IsSurrogate(r) && i+3 < len(data) {
+			if pair := utf16.DecodeRune(r, rune(data[i+2])|rune(data[i+3])<<8); pair != utf8.RuneError {
+				r = pair
+				i += 2
+			}
+		}
+		text = utf8.AppendRune(text, r)
+	}
+	return text
+}
@@ -0,0 +1,122 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// errDeadline stops the checks of a candidate whose time is up
+var errDeadline = errors.New("candidate deadline passed")
+
+// candidate is what the checks of one candidate share: its data, the time it
+// is given up at (zero for no limit) and its compound file, see compoundFile
+type candidate struct {
+	data     []byte
+	deadline time.Time
+
+	cfbParsed bool
+	cfb       *cfbFile
+}
+
+// expired reports whether the candidate is past its deadline; the checks that
+// can run long look at it between steps and stop there
+func (cand *candidate) expired() bool {
+	return deadlinePassed(cand.deadline)
+}
+
+// analyze runs analyzeFile, giving up on the candidate after opts.Timeout. The
+// analysis itself stops at its next deadline check; until then it runs on in
+// the background, counted by opts.Running, but it is left before anything is
+// written.
+func (opts DefaultFileProcessor) analyze(data []byte, startPos int, allowedExtensions map[string]bool, cand *candidate) (*models.ExtractionResult, []byte, error) {
+	if opts.Timeout <= 0 {
+		return analyzeFile(data, startPos, allowedExtensions, cand, opts)
+	}
+
+	type analysis struct {
+		result   *models.ExtractionResult
+		fileData []byte
+		err      error
+	}
+	done := make(chan analysis, 1)
+	if opts.Running != nil {
+		opts.Running.Add(1)
+	}
+	go func() {
+		var a analysis
+		// A panic of this goroutine can't be recovered by the worker
+		defer func() {
+			if v := recover(); v != nil {
+				a = analysis{err: PanicError(v, startPos)}
+			}
+			done <- a
+			if opts.Running != nil {
+				opts.Running.Done()
+			}
+		}()
+		a.result, a.fileData, a.err = analyzeFile(data, startPos, allowedExtensions, cand, opts)
+	}()
+
+	timer := time.NewTimer(opts.Timeout)
+	defer timer.Stop()
+	select {
+	case a := <-done:
+		return a.result, a.fileData, a.err
+	case <-timer.C:
+		return nil, nil, opts.timeoutError(data, startPos, allowedExtensions)
+	}
+}
+
+// timeoutError fails the candidate at startPos that wasn't done within opts.Timeout
+func (opts DefaultFileProcessor) timeoutError(data []byte, startPos int, allowedExtensions map[string]bool) error {
+	err := fmt.Errorf("not done after %v", opts.Timeout)
+	return &CandidateError{Kind: models.FailureTimeout, Extension: magicExtension(data, allowedExtensions), Start: startPos, Err: err}
+}
+
+// deadline is the time a candidate whose processing starts now is given up
+// at, zero when opts.Timeout doesn't limit it
+func (opts DefaultFileProcessor) deadline() time.Time {
+	if opts.Timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(opts.Timeout)
+}
+
+// deadlinePassed reports whether a deadline is set and over
+func deadlinePassed(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// PanicError fails the candidate at start whose processing panicked, so that
+// a parser tripping over crafted input loses that candidate and not the run; v
+// is the recovered value. It must be called by the deferred function that
+// recovered, to name where the panic happened.
+func PanicError(v any, start int) error {
+	return &CandidateError{Kind: models.FailurePanic, Start: start, Err: fmt.Errorf("panic: %v%s", v, panicLocation())}
+}
+
+// panicLocation returns " in <function> (<file>:<line>)" for the function
+// that panicked, the first one below the panic that is not part of the runtime
+func panicLocation() string {
+	pc := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pc[:runtime.Callers(2, pc)])
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			panicking = true
+		case panicking && !strings.HasPrefix(frame.Function, "runtime."):
+			return fmt.Sprintf(" in %s (%s:%d)", path.Base(frame.Function), path.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package extractor
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// minScanRangeSize keeps small inputs from being split into ranges not worth a goroutine
+const minScanRangeSize = 1024 * 1024
+
+// maxScanRangeSize bounds the ranges of large inputs, so that a cancelled run
+// stops indexing soon after
+const maxScanRangeSize = 64 * 1024 * 1024
+
+// IndexHitSize is the memory a match takes in the index, with its candidate
+const IndexHitSize = 16
+
+// SignatureIndex holds the positions of every signature magic number in an input,
+// found with one pass per magic instead of comparing all signatures at every offset
+// and searching the rest of the input again for each extracted file. It may cover
+// a window of the input only, which Advance moves on; lookups outside of it
+// search the input instead.
+type SignatureIndex struct {
+	mu   sync.RWMutex
+	hits map[string][]int
+	// Candidates start from start up to window, and the hits are complete
+	// from start up to end, which takes in the signatures at an offset in
+	// the files that start before window
+	start, window, end int
+}
+
+// Advance replaces the index with one of the window of data from start that
+// holds about limit bytes of hits (0 - up to the end of data), and returns
+// where the window ends. The window grows a round of ranges at a time, each a
+// small part of limit so a dense one can't overshoot it much, and up to
+// workers ranges are scanned concurrently. No range is started once cancelled
+// (if not nil) returns true, and the index then holds the ranges scanned so
+// far. indexed (if not nil) is called with the size of every range scanned.
+func (idx *SignatureIndex) Advance(data []byte, start int, limit int64, workers int, cancelled func() bool, indexed func(n int)) int {
+	var magics [][]byte
+	seen := make(map[string]bool)
+	maxLen, maxOffset := 0, 0
+	for _, sig := range fileSignatures {
+		maxOffset = max(maxOffset, sig.Offset)
+		if len(sig.MagicNumber) == 0 || seen[string(sig.MagicNumber)] {
+			continue
+		}
+		seen[string(sig.MagicNumber)] = true
+		magics = append(magics, sig.MagicNumber)
+		if len(sig.MagicNumber) > maxLen {
+			maxLen = len(sig.MagicNumber)
+		}
+	}
+
+	round := len(data)
+	if limit > 0 {
+		round = int(max(limit/IndexHitSize/8, minScanRangeSize))
+	}
+	var partial []map[string][]int
+	var size int64
+	window := start
+	for window < len(data) && (cancelled == nil || !cancelled()) {
+		end := min(window+round, len(data))
+		hits := scanRanges(data, window, end, magics, maxLen, workers, cancelled, indexed)
+		partial = append(partial, hits...)
+		window = end
+		for _, h := range hits {
+			for _, positions := range h {
+				size += int64(len(positions)) * IndexHitSize
+			}
+		}
+		if limit > 0 && size >= limit {
+			break
+		}
+	}
+	// The signatures of the files starting right before the window ends lie
+	// after it
+	end := window
+	if window < len(data) {
+		end = min(window+maxOffset, len(data))
+		partial = append(partial, scanRanges(data, window, end, magics, maxLen, 1, nil, nil)...)
+	}
+
+	hits := make(map[string][]int)
+	for _, magic := range magics {
+		var positions []int
+		for _, h := range partial {
+			positions = append(positions, h[string(magic)]...)
+		}
+		hits[string(magic)] = positions
+	}
+
+	idx.mu.Lock()
+	idx.hits, idx.start, idx.window, idx.end = hits, start, window, end
+	idx.mu.Unlock()
+	return window
+}
+
+// scanRanges finds the magics that start from start up to end in data,
+// scanning up to workers ranges of it concurrently, and returns the hits of
+// each range in input order
+func scanRanges(data []byte, start, end int, magics [][]byte, maxLen, workers int, cancelled func() bool, indexed func(n int)) []map[string][]int {
+	if max := (end - start) / minScanRangeSize; workers > max {
+		workers = max
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	rangeSize := (end - start + workers - 1) / workers
+	if rangeSize > maxScanRangeSize {
+		rangeSize = maxScanRangeSize
+	}
+	if rangeSize < 1 {
+		rangeSize = 1
+	}
+	ranges := (end - start + rangeSize - 1) / rangeSize
+
+	// Ranges overlap by the longest magic so matches crossing a boundary are found;
+	// each match is kept only by the range it starts in. Workers take the ranges
+	// in input order.
+	partial := make([]map[string][]int, ranges)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				r := int(next.Add(1) - 1)
+				if r >= ranges || (cancelled != nil && cancelled()) {
+					return
+				}
+				from := start + r*rangeSize
+				owned := min(from+rangeSize, end)
+				partial[r] = scanRange(data[from:min(owned+maxLen-1, len(data))], from, owned, magics)
+				if indexed != nil {
+					indexed(owned - from)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return partial
+}
+
+// scanRange returns the positions of each magic in chunk (which starts at offset
+// base in the input) that begin before owned
+func scanRange(chunk []byte, base, owned int, magics [][]byte) map[string][]int {
+	hits := make(map[string][]int, len(magics))
+	for _, magic := range magics {
+		var positions []int
+		for pos := 0; pos < len(chunk); {
+			i := bytes.Index(chunk[pos:], magic)
+			if i == -1 || base+pos+i >= owned {
+				break
+			}
+			positions = append(positions, base+pos+i)
+			pos += i + 1
+		}
+		hits[string(magic)] = positions
+	}
+	return hits
+}
+
+// Candidates returns the sorted positions in the window where a file of an
+// allowed type may start
+func (idx *SignatureIndex) Candidates(allowedExtensions map[string]bool) []int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var candidates []int
+	for _, sig := range fileSignatures {
+		if len(allowedExtensions) > 0 && !allowedExtensions[sig.Extension] {
+			continue
+		}
+
+		for _, pos := range idx.hits[string(sig.MagicNumber)] {
+			if start := pos - sig.Offset; start >= idx.start && start < idx.window {
+				candidates = append(candidates, start)
+			}
+		}
+	}
+
+	sort.Ints(candidates)
+	return slices.Compact(candidates)
+}
+
+// firstAfter returns the first occurrence of magic at or after pos among the
+// hits, or -1
+func (idx *SignatureIndex) firstAfter(magic []byte, pos int) int {
+	positions := idx.hits[string(magic)]
+	i := sort.SearchInts(positions, pos)
+	if i == len(positions) {
+		return -1
+	}
+	return positions[i]
+}
+
+// index returns the offset of the first occurrence of magic in data, like bytes.Index;
+// data must be the input from pos to its end when idx is set
+func (idx *SignatureIndex) index(data []byte, pos int, magic []byte) int {
+	if idx == nil {
+		return bytes.Index(data, magic)
+	}
+	idx.mu.RLock()
+	start, end := idx.start, idx.end
+	hit := -1
+	if pos >= start && pos < end {
+		hit = idx.firstAfter(magic, pos)
+	}
+	idx.mu.RUnlock()
+
+	switch {
+	case hit != -1:
+		return hit - pos
+	case pos < start || pos >= end:
+		return bytes.Index(data, magic)
+	case end == len(data)+pos:
+		return -1
+	}
+	// Past the hits the rest of the input is searched
+	if i := bytes.Index(data[end-pos:], magic); i != -1 {
+		return end - pos + i
+	}
+	return -1
+}
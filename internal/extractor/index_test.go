@@ -0,0 +1,47 @@
+package extractor
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestSignatureIndexWindows(t *testing.T) {
+	// JPEG and MP4 signatures, the latter 4 bytes into its files, spread over
+	// 3 MiB; the windows end on a round of 1 MiB, between a file and its
+	// signature
+	data := make([]byte, 3*minScanRangeSize)
+	for pos := 0; pos+16 < len(data); pos += 4093 {
+		copy(data[pos:], []byte{0xFF, 0xD8, 0xFF})
+		copy(data[pos+2048:], "ftyp")
+	}
+	for pos := minScanRangeSize; pos < len(data); pos += minScanRangeSize {
+		copy(data[pos+2:], "ftyp")
+	}
+
+	whole := &SignatureIndex{}
+	if end := whole.Advance(data, 0, 0, 4, nil, nil); end != len(data) {
+		t.Fatalf("index ends at %d, want %d", end, len(data))
+	}
+	want := whole.Candidates(nil)
+
+	windowed := &SignatureIndex{}
+	var got []int
+	windows := 0
+	for start := 0; start < len(data); windows++ {
+		end := windowed.Advance(data, start, 4*1024, 4, nil, nil)
+		got = append(got, windowed.Candidates(nil)...)
+		for _, pos := range []int{0, start, end - 1} {
+			if i, j := windowed.index(data[pos:], pos, mp4FtypMagic), bytes.Index(data[pos:], mp4FtypMagic); i != j {
+				t.Errorf("window %d-%d: ftyp after %d at %d, want %d", start, end, pos, i, j)
+			}
+		}
+		start = end
+	}
+	if windows != 3 {
+		t.Errorf("indexed in %d windows, want 3", windows)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("windows have %d candidates, want the %d of the whole index", len(got), len(want))
+	}
+}
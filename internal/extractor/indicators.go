@@ -0,0 +1,122 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// Traits of exploit documents: OLE objects hidden in Open XML packages, the
+// Equation Editor (CVE-2017-11882, CVE-2018-0802), ActiveX controls and
+// templates or OLE objects fetched from a remote server on opening
+// (CVE-2017-0199, CVE-2021-40444)
+
+// equationEditorCLSIDs are the classes of Equation Editor 2.0 and 3.0 objects
+var equationEditorCLSIDs = map[[16]byte]bool{
+	guidBytes("0002CE02-0000-0000-C000-000000000046"): true,
+	guidBytes("0002CE03-0000-0000-C000-000000000046"): true,
+}
+
+// maxEmbeddedSize bounds the decompressed size of an embedded object inspected
+// for indicators
+const maxEmbeddedSize = 64 << 20
+
+// readPackageIndicators records the exploit indicators of an OOXML package from
+// its embedded objects and external references, read by readEmbeddedObjects;
+// no embedded object is opened after deadline
+func readPackageIndicators(zipReader *zip.Reader, info *models.OfficeDocumentInfo, deadline time.Time) {
+	for _, obj := range info.Embedded {
+		switch obj.Kind {
+		case "OLE object":
+			if deadlinePassed(deadline) {
+				continue
+			}
+			if file := zipFile(zipReader, obj.Name); file != nil {
+				embeddedOLEIndicators(file, info)
+			}
+		case "ActiveX control":
+			addIndicator(info, models.IndicatorActiveX)
+		}
+	}
+
+	for _, ref := range info.External {
+		if !isRemoteTarget(ref.Target) {
+			continue
+		}
+		switch ref.Type {
+		case "attachedTemplate":
+			addIndicator(info, models.IndicatorRemoteTemplate)
+		case "oleObject":
+			addIndicator(info, models.IndicatorRemoteOLE)
+		}
+	}
+}
+
+// isRemoteTarget reports whether the target of an external relationship is on
+// another machine: a URL (http:, mhtml: ...) or a UNC path, but not a local file
+func isRemoteTarget(target string) bool {
+	target = strings.TrimSpace(target)
+	if strings.HasPrefix(target, `\\`) || strings.HasPrefix(target, "//") {
+		return true
+	}
+	// One-letter schemes are drive letters
+	u, err := url.Parse(target)
+	if err != nil || len(u.Scheme) < 2 {
+		return false
+	}
+	return !strings.EqualFold(u.Scheme, "file") || u.Host != "" && !strings.EqualFold(u.Host, "localhost")
+}
+
+func zipFile(zipReader *zip.Reader, name string) *zip.File {
+	for _, file := range zipReader.File {
+		if file.Name == name {
+			return file
+		}
+	}
+	return nil
+}
+
+// embeddedOLEIndicators flags an embedded part that is a compound file, and the
+// Equation Editor objects among them
+func embeddedOLEIndicators(file *zip.File, info *models.OfficeDocumentInfo) {
+	if file.UncompressedSize64 > maxEmbeddedSize {
+		return
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, maxEmbeddedSize))
+	rc.Close()
+	if err != nil || !bytes.HasPrefix(data, cfbMagic) {
+		return
+	}
+
+	addIndicator(info, models.IndicatorEmbeddedOLE)
+	if c, err := parseCFB(data); err == nil {
+		readCFBIndicators(c, info)
+	}
+}
+
+// readCFBIndicators records the exploit indicators of a compound file: storages
+// of the Equation Editor class or its "Equation Native" stream, and the
+// OCXNAME (Word) and Ctls (Excel) streams of ActiveX controls
+func readCFBIndicators(c *cfbFile, info *models.OfficeDocumentInfo) {
+	for i := range c.entries {
+		e := &c.entries[i]
+		if e.Type == 0 {
+			continue
+		}
+		switch {
+		case equationEditorCLSIDs[e.CLSID] || e.Type == cfbTypeStream && e.Name == "Equation Native":
+			addIndicator(info, models.IndicatorEquationEditor)
+		case e.Type == cfbTypeStream && (e.Name == "\x03OCXNAME" || e.Name == "Ctls"):
+			addIndicator(info, models.IndicatorActiveX)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package extractor
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// MediaDirSuffix is appended to the name of an OOXML document, without its
+// extension, to name the directory its media files are extracted to
+const MediaDirSuffix = ".media"
+
+// maxMediaSize bounds the decompressed size of a media part
+const maxMediaSize = 256 << 20
+
+// ooxmlMediaDirs are the folders holding the images, audio and video of Word,
+// Excel and PowerPoint documents
+var ooxmlMediaDirs = []string{"word/media/", "xl/media/", "ppt/media/"}
+
+// ooxmlMediaExtensions are the formats whose media can be extracted
+var ooxmlMediaExtensions = map[string]bool{
+	"docx": true, "docm": true,
+	"xlsx": true, "xlsm": true,
+	"pptx": true, "pptm": true,
+}
+
+// mediaPart is a media file stored in an OOXML package
+type mediaPart struct {
+	Name string
+	Data []byte
+}
+
+// ooxmlMedia returns the media parts of an OOXML document; parts that can't be
+// read or are too large are left out
+func ooxmlMedia(data []byte) ([]mediaPart, error) {
+	zipReader, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []mediaPart
+	for _, file := range zipReader.File {
+		if !isMediaPart(file.Name) || file.UncompressedSize64 > maxMediaSize {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxMediaSize))
+		rc.Close()
+		if err != nil {
+			continue
+		}
+		parts = append(parts, mediaPart{Name: file.Name, Data: content})
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("no media")
+	}
+	return parts, nil
+}
+
+func isMediaPart(name string) bool {
+	for _, dir := range ooxmlMediaDirs {
+		if strings.HasPrefix(name, dir) && !strings.HasSuffix(name, "/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+
+	"splitter-files/internal/models"
+)
+
+// mp4FtypMagic is the type of the ftyp box that starts MPEG-4 and QuickTime
+// files, after its 4-byte size
+var mp4FtypMagic = []byte("ftyp")
+
+// mp4MaxFtypSize bounds the ftyp box, which only lists a few brands
+const mp4MaxFtypSize = 256
+
+// mp4Brands maps the major brands of the ftyp box to the extension the files
+// are saved with
+var mp4Brands = map[string]string{
+	"isom": "mp4", "iso2": "mp4", "iso4": "mp4", "iso5": "mp4", "iso6": "mp4",
+	"mp41": "mp4", "mp42": "mp4", "avc1": "mp4", "dash": "mp4", "MSNV": "mp4", "XAVC": "mp4",
+	"M4V ": "mp4", "M4VH": "mp4", "M4VP": "mp4", "f4v ": "mp4",
+	"qt  ": "mov",
+}
+
+// mp4TopLevelBoxes are the boxes found at the top level of a file; walking the
+// boxes of a file stops at anything else
+var mp4TopLevelBoxes = map[string]bool{
+	"ftyp": true, "moov": true, "mdat": true, "free": true, "skip": true, "wide": true, "uuid": true,
+	"pdin": true, "meta": true, "moof": true, "mfra": true, "sidx": true, "styp": true, "ssix": true,
+	"prft": true, "emsg": true, "pnot": true,
+}
+
+// mp4LocationKey is the metadata key under which Apple devices record where a
+// video was taken, as an ISO 6709 string
+const mp4LocationKey = "com.apple.quicktime.location.ISO6709"
+
+func validateMP4(data []byte) bool {
+	return validateMP4Brand(data, "mp4")
+}
+
+func validateMOV(data []byte) bool {
+	return validateMP4Brand(data, "mov")
+}
+
+// validateMP4Brand checks the ftyp box of a file saved with extension ext and
+// the type of the box that follows it
+func validateMP4Brand(data []byte, ext string) bool {
+	if len(data) < 16 || !bytes.Equal(data[4:8], mp4FtypMagic) {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	if size < 16 || size > mp4MaxFtypSize || size%4 != 0 {
+		return false
+	}
+	if mp4Brands[string(data[8:12])] != ext {
+		return false
+	}
+
+	if len(data) >= size+8 {
+		return mp4TopLevelBoxes[string(data[size+4:size+8])]
+	}
+	return true
+}
+
+// mp4Box returns the type of the box at pos, its size and the size of its
+// header; a box without a size runs to the end of data
+func mp4Box(data []byte, pos int) (typ string, size, header int, ok bool) {
+	if pos+8 > len(data) {
+		return "", 0, 0, false
+	}
+	size, header = int(binary.BigEndian.Uint32(data[pos:])), 8
+	typ = string(data[pos+4 : pos+8])
+	switch size {
+	case 0:
+		size = len(data) - pos
+	case 1:
+		if pos+16 > len(data) {
+			return "", 0, 0, false
+		}
+		large := binary.BigEndian.Uint64(data[pos+8:])
+		if large > math.MaxInt32 {
+			return "", 0, 0, false
+		}
+		size, header = int(large), 16
+	}
+	if size < header {
+		return "", 0, 0, false
+	}
+	return typ, size, header, true
+}
+
+// mp4FileSize returns the length of an MPEG-4 or QuickTime file from its top
+// level boxes, or 0 when they don't include a movie and its media or the last
+// box runs to the end of the data without a size. The length is the one the
+// boxes declare, which is past the end of data for a truncated file.
+func mp4FileSize(data []byte) int {
+	var movie, media bool
+	pos := 0
+	for pos < len(data) {
+		typ, size, _, ok := mp4Box(data, pos)
+		if !ok || !mp4TopLevelBoxes[typ] {
+			break
+		}
+		if binary.BigEndian.Uint32(data[pos:]) == 0 {
+			return 0
+		}
+		switch typ {
+		case "moov":
+			movie = true
+		case "mdat", "moof":
+			media = true
+		}
+		pos += size
+	}
+	if !movie || !media {
+		return 0
+	}
+	return pos
+}
+
+// mp4Child returns the content of the first box of type typ in data, which
+// holds a sequence of boxes
+func mp4Child(data []byte, typ string) []byte {
+	for pos := 0; pos < len(data); {
+		boxType, size, header, ok := mp4Box(data, pos)
+		if !ok || pos+size > len(data) {
+			return nil
+		}
+		if boxType == typ {
+			return data[pos+header : pos+size]
+		}
+		pos += size
+	}
+	return nil
+}
+
+// mp4Location returns where a video was recorded: the ©xyz entry of the user
+// data of the movie, written by cameras and phones, or the location key of
+// the QuickTime metadata of the movie, written by Apple devices
+func mp4Location(data []byte) *models.GeoLocation {
+	moov := mp4Child(data, "moov")
+	if moov == nil {
+		return nil
+	}
+	if xyz := mp4Child(mp4Child(moov, "udta"), "\xa9xyz"); len(xyz) >= 4 {
+		// A QuickTime text entry: its length, its language and the text
+		if n := int(binary.BigEndian.Uint16(xyz)); 4+n <= len(xyz) {
+			if loc := parseISO6709(string(xyz[4 : 4+n])); loc != nil {
+				return loc
+			}
+		}
+	}
+	return parseISO6709(mp4MetadataValue(mp4Child(moov, "meta"), mp4LocationKey))
+}
+
+// mp4MetadataValue returns the value of a key of QuickTime metadata: the keys
+// box lists the key names, and the items of the ilst box are typed by the
+// index of their key in the list, from 1
+func mp4MetadataValue(meta []byte, key string) string {
+	// The meta box of ISO files has a version and flags before its boxes
+	if len(meta) >= 4 && binary.BigEndian.Uint32(meta) == 0 {
+		meta = meta[4:]
+	}
+	keys := mp4Child(meta, "keys")
+	if len(keys) < 8 {
+		return ""
+	}
+
+	index := 0
+	count := int(binary.BigEndian.Uint32(keys[4:]))
+	for i, pos := 1, 8; i <= count && pos+8 <= len(keys); i++ {
+		size := int(binary.BigEndian.Uint32(keys[pos:]))
+		if size < 8 || pos+size > len(keys) {
+			return ""
+		}
+		if string(keys[pos+8:pos+size]) == key {
+			index = i
+			break
+		}
+		pos += size
+	}
+	if index == 0 {
+		return ""
+	}
+
+	item := mp4Child(mp4Child(meta, "ilst"), string(binary.BigEndian.AppendUint32(nil, uint32(index))))
+	// The data box holds the type of the value and its locale before it
+	if value := mp4Child(item, "data"); len(value) > 8 {
+		return string(value[8:])
+	}
+	return ""
+}
+
+// parseISO6709 reads a point written as in ISO 6709, such as
+// "+37.3349-122.0090+010.000/": the latitude, the longitude and an optional
+// altitude, each with its sign; degrees may be followed by minutes and seconds
+// (+DDMM.M, +DDMMSS.S) and a coordinate system may follow the altitude
+func parseISO6709(s string) *models.GeoLocation {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "/")
+	s, _, _ = strings.Cut(s, "CRS")
+
+	var parts []string
+	for i := 0; i < len(s); {
+		j := i + 1
+		for j < len(s) && s[j] != '+' && s[j] != '-' {
+			j++
+		}
+		parts = append(parts, s[i:j])
+		i = j
+	}
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil
+	}
+
+	lat, ok := iso6709Degrees(parts[0], 2)
+	if !ok || lat < -90 || lat > 90 {
+		return nil
+	}
+	lon, ok := iso6709Degrees(parts[1], 3)
+	if !ok || lon < -180 || lon > 180 {
+		return nil
+	}
+	loc := &models.GeoLocation{Latitude: lat, Longitude: lon}
+	if len(parts) == 3 {
+		alt, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil
+		}
+		loc.Altitude, loc.HasAltitude = alt, true
+	}
+	return loc
+}
+
+// iso6709Degrees reads a signed ISO 6709 coordinate whose degrees have
+// degreeDigits digits, followed by minutes and seconds when it has more
+func iso6709Degrees(s string, degreeDigits int) (float64, bool) {
+	if len(s) < 2 || s[0] != '+' && s[0] != '-' {
+		return 0, false
+	}
+	digits, fraction, _ := strings.Cut(s[1:], ".")
+	if strings.Trim(digits+fraction, "0123456789") != "" {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(s[1:], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	var degrees float64
+	switch len(digits) {
+	case degreeDigits:
+		degrees = value
+	case degreeDigits + 2:
+		whole := math.Floor(value / 100)
+		degrees = whole + (value-whole*100)/60
+	case degreeDigits + 4:
+		whole := math.Floor(value / 10000)
+		minutes := math.Floor((value - whole*10000) / 100)
+		degrees = whole + minutes/60 + (value-whole*10000-minutes*100)/3600
+	default:
+		return 0, false
+	}
+	if s[0] == '-' {
+		degrees = -degrees
+	}
+	return degrees, true
+}
@@ -0,0 +1,35 @@
+package extractor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseISO6709(t *testing.T) {
+	tests := []struct {
+		in            string
+		lat, lon, alt float64
+		hasAlt        bool
+	}{
+		{"+37.3349-122.0090+010.000/", 37.3349, -122.009, 10, true},
+		{"-33.8688+151.2093/", -33.8688, 151.2093, 0, false},
+		{"+3403.1234-11814.5678/", 34 + 3.1234/60, -(118 + 14.5678/60), 0, false},
+		{"+340312-1181434+100CRSWGS_84/", 34 + 3.0/60 + 12.0/3600, -(118 + 14.0/60 + 34.0/3600), 100, true},
+	}
+	for _, tt := range tests {
+		loc := parseISO6709(tt.in)
+		if loc == nil {
+			t.Errorf("%s: no location", tt.in)
+			continue
+		}
+		if math.Abs(loc.Latitude-tt.lat) > 1e-9 || math.Abs(loc.Longitude-tt.lon) > 1e-9 || loc.HasAltitude != tt.hasAlt || loc.Altitude != tt.alt {
+			t.Errorf("%s: got %+v", tt.in, *loc)
+		}
+	}
+
+	for _, in := range []string{"", "+37.3349/", "+91.0000+010.0000/", "+37.3349-122.0x90/", "+3.3-122.0090/"} {
+		if loc := parseISO6709(in); loc != nil {
+			t.Errorf("%s: got %+v, want none", in, *loc)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package extractor
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"splitter-files/internal/models"
+	"splitter-files/pkg/fileutils"
+)
+
+const maxOriginalNameLength = 64
+
+// originalName guesses the name a file had before it was lost from the metadata
+// recovered during detection (empty if there is no plausible candidate)
+func originalName(result *models.ExtractionResult, data []byte) string {
+	var name string
+	switch {
+	case result.OfficeInfo != nil && result.OfficeInfo.Title != "":
+		name = result.OfficeInfo.Title
+	case result.CacheInfo != nil:
+		name = urlFileName(result.CacheInfo.URL)
+	case result.Extension == "zip":
+		name = zipArchiveName(data)
+	case result.Extension == "exe":
+		name = peExportName(data)
+	}
+
+	return sanitizeFileName(name, result.Extension)
+}
+
+// urlFileName returns the last path segment of a URL
+func urlFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+// zipArchiveName returns the common top-level directory of an archive, or the
+// name of its only file, which is usually what the archive was named after
+func zipArchiveName(data []byte) string {
+	zipReader, err := openZip(data)
+	if err != nil || len(zipReader.File) == 0 {
+		return ""
+	}
+
+	if len(zipReader.File) == 1 {
+		name := path.Base(zipEntryName(zipReader.File[0]))
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+
+	var top string
+	for _, file := range zipReader.File {
+		first := strings.SplitN(strings.TrimPrefix(zipEntryName(file), "/"), "/", 2)
+		if len(first) < 2 {
+			return ""
+		}
+		if top == "" {
+			top = first[0]
+		} else if top != first[0] {
+			return ""
+		}
+	}
+	return top
+}
+
+// zipEntryName returns the name of an archive entry; names that aren't UTF-8
+// are in code page 437, the encoding the ZIP format had before UTF-8
+func zipEntryName(file *zip.File) string {
+	if utf8.ValidString(file.Name) {
+		return file.Name
+	}
+	var sb strings.Builder
+	for _, ch := range []byte(file.Name) {
+		if ch < 0x80 {
+			sb.WriteByte(ch)
+		} else {
+			sb.WriteRune(cp437[ch-0x80])
+		}
+	}
+	return sb.String()
+}
+
+// cp437 maps the upper half of code page 437 to Unicode
+var cp437 = func() [128]rune {
+	var t [128]rune
+	copy(t[:], []rune("ÇüéâäàåçêëèïîìÄÅÉæÆôöòûùÿÖÜ¢£¥₧ƒáíóúñÑªº¿⌐¬½¼¡«»░▒▓│┤╡╢╖╕╣║╗╝╜╛┐└┴┬├─┼╞╟╚╔╩╦╠═╬╧╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀αßΓπΣσµτΦΘΩδ∞φε∩≡±≥≤⌠⌡÷≈°∙·√ⁿ²■\u00a0"))
+	return t
+}()
+
+// repairMojibake undoes UTF-8 read as Latin-1, as in the names of PDF
+// attachments and property sets written as UTF-8 while claiming a single-byte
+// encoding: a name that only has Latin-1 characters and whose bytes form UTF-8
+// with some multi-byte character is taken to be that UTF-8
+func repairMojibake(name string) string {
+	b := make([]byte, 0, len(name))
+	for _, r := range name {
+		if r > 0xFF {
+			return name
+		}
+		b = append(b, byte(r))
+	}
+	if len(b) == len(name) || !utf8.Valid(b) {
+		return name
+	}
+	return string(b)
+}
+
+// windowsReservedNames are the device names Windows doesn't allow as file
+// names, with or without an extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFileName keeps letters, digits and a few punctuation characters of a
+// candidate name so it is safe to use on any file system; the name is repaired
+// and composed to NFC first, and device names of Windows get an underscore
+func sanitizeFileName(name, ext string) string {
+	name = norm.NFC.String(repairMojibake(strings.TrimSpace(name)))
+	if ext != "" && strings.HasSuffix(strings.ToLower(name), "."+ext) {
+		name = name[:len(name)-len(ext)-1]
+	}
+
+	var sb strings.Builder
+	lastUnderscore := false
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '.':
+			sb.WriteRune(r)
+			lastUnderscore = false
+		case !lastUnderscore:
+			sb.WriteByte('_')
+			lastUnderscore = true
+		}
+	}
+
+	clean := strings.Trim(sb.String(), "._-")
+	if runes := []rune(clean); len(runes) > maxOriginalNameLength {
+		clean = strings.TrimRight(string(runes[:maxOriginalNameLength]), "._-")
+	}
+	stem, rest, _ := strings.Cut(clean, ".")
+	if windowsReservedNames[strings.ToUpper(stem)] {
+		clean = stem + "_"
+		if rest != "" {
+			clean += "." + rest
+		}
+	}
+	return clean
+}
+
+// claimName returns stem+tail, or stem_2+tail, stem_3+tail... when it is taken
+// in used or by taken (if not nil), and marks it taken; names are compared
+// regardless of case, as on Windows and macOS file systems
+func claimName(used map[string]bool, stem, tail string, taken func(name string) bool) string {
+	name := stem + tail
+	for n := 2; used[strings.ToLower(name)] || taken != nil && taken(name); n++ {
+		name = fmt.Sprintf("%s_%d%s", stem, n, tail)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// OutputNames keeps the names of the output files of a run apart: from each
+// other, and for a local output directory from the files already in it, so
+// that carving several inputs into one directory doesn't overwrite files
+type OutputNames struct {
+	dir   string
+	local bool
+
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+// NewOutputNames creates the names of a run writing into dir; only a local
+// directory is checked for existing files
+func NewOutputNames(dir string, local bool) *OutputNames {
+	return &OutputNames{dir: dir, local: local, used: make(map[string]bool)}
+}
+
+// Claim returns name, which ends with tail, or a name numbered before tail
+// when it is taken. In a local directory the file is created empty to claim
+// it, so another run carving into the directory at the same time can't get
+// the name as well; the write of the file then fills it.
+func (n *OutputNames) Claim(name, tail string) string {
+	return n.claim(name, tail, func(path string) error {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+		}
+		return err
+	})
+}
+
+// ClaimDir is Claim for the name of a directory, which is created to claim it
+func (n *OutputNames) ClaimDir(name, tail string) string {
+	return n.claim(name, tail, func(path string) error {
+		return os.Mkdir(path, 0755)
+	})
+}
+
+// claim claims name with create, which must fail with fs.ErrExist when the
+// path is taken
+func (n *OutputNames) claim(name, tail string, create func(path string) error) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var taken func(string) bool
+	if n.local {
+		taken = func(name string) bool {
+			path := fileutils.OutputPath(n.dir, name)
+			err := create(path)
+			if errors.Is(err, fs.ErrNotExist) && os.MkdirAll(filepath.Dir(path), 0755) == nil {
+				err = create(path)
+			}
+			// Other errors are left to the write of the file to report
+			return errors.Is(err, fs.ErrExist)
+		}
+	}
+	return claimName(n.used, strings.TrimSuffix(name, tail), tail, taken)
+}
+
+// CheckIdentifier rejects case and evidence identifiers that can't be part of
+// a file name
+func CheckIdentifier(id string) error {
+	if len(id) > maxOriginalNameLength {
+		return fmt.Errorf("identifier %q is longer than %d characters", id, maxOriginalNameLength)
+	}
+	for _, c := range []byte(id) {
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._", c) != -1) {
+			return fmt.Errorf("identifier %q may only contain ASCII letters, digits, '-', '.' and '_'", id)
+		}
+	}
+	return nil
+}
+
+// identifierPrefix is the start of output file names carrying the case and
+// evidence identifiers, such as "2024-017_HDD01_"
+func identifierPrefix(caseID, evidenceID string) string {
+	var prefix string
+	for _, id := range []string{caseID, evidenceID} {
+		if id != "" {
+			prefix += id + "_"
+		}
+	}
+	return prefix
+}
+
+// ObjectsDir is the subdirectory of the output holding content-addressed files
+const ObjectsDir = "objects"
+
+// objectFileName is the path of a content-addressed file below the output,
+// objects/ab/cd/<sha256>.<extension>
+func objectFileName(hash, ext string) string {
+	return fmt.Sprintf("%s/%s/%s/%s.%s", ObjectsDir, hash[:2], hash[2:4], hash, ext)
+}
+
+// CarveMapFileName is the name of a file extracted again from a carve map, the
+// one the run that carved it at start gave it
+func CarveMapFileName(start int64, ext string) string {
+	return fmt.Sprintf("file_%04d.%s", start+1, sanitizeFileName(ext, ""))
+}
+
+// outputFileName builds the name of an extracted file; the counter keeps names
+// unique when several files recover the same original name
+func outputFileName(result *models.ExtractionResult, counter int64, useOriginalName bool) string {
+	if useOriginalName && result.OriginalName != "" {
+		return fmt.Sprintf("%s_%04d.%s", result.OriginalName, counter, result.Extension)
+	}
+	return fmt.Sprintf("file_%04d.%s", counter, result.Extension)
+}
@@ -0,0 +1,96 @@
+package extractor
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"splitter-files/internal/models"
+)
+
+// NestedDirSuffix is appended to the name of an archive, without its extension,
+// to name the directory the files carved from its entries are written to
+const NestedDirSuffix = ".carved"
+
+// NestingLimits bounds recursive carving: how many levels of archives inside
+// archives are opened, how many bytes may be decompressed from all of them,
+// and which archives were already opened, so that one that contains itself, or
+// turns up again deeper down, isn't carved again. It is shared by the runs over
+// the entries of all archives of an input.
+type NestingLimits struct {
+	// MaxDepth is the number of levels of archives that are opened: 1 carves
+	// the entries of the archives found in the input, but not those of the
+	// archives found in these entries
+	MaxDepth int
+	// MaxExpanded is the number of bytes that may be decompressed from the
+	// entries of all archives (0 - no limit)
+	MaxExpanded int64
+
+	expanded atomic.Int64
+	opened   sync.Map
+}
+
+var errExpansionBudget = errors.New("expansion budget exhausted")
+
+// open reports whether an archive wasn't opened before, and records it
+func (l *NestingLimits) open(data []byte) bool {
+	_, opened := l.opened.LoadOrStore(sha256.Sum256(data), true)
+	return !opened
+}
+
+// read decompresses an entry, whose declared size is taken from the budget up
+// front; the archive reader rejects entries that turn out larger than declared
+func (l *NestingLimits) read(file *zip.File) ([]byte, error) {
+	size := int64(file.UncompressedSize64)
+	if size < 0 {
+		return nil, errExpansionBudget
+	}
+	if l.MaxExpanded > 0 && l.expanded.Add(size) > l.MaxExpanded {
+		// Smaller entries may still fit
+		l.expanded.Add(-size)
+		return nil, errExpansionBudget
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, size+1))
+}
+
+// carveEntries passes the content of each entry of an archive to carve, within
+// the limits; directories and encrypted entries are left out, and so are the
+// entries that can't be read. The reason is set when the limits kept the
+// archive, or some of its entries, from being opened.
+func (l *NestingLimits) carveEntries(data []byte, depth int, carve func(name string, content []byte)) string {
+	if depth >= l.MaxDepth {
+		return models.NotCarvedDepth
+	}
+	zipReader, err := openZip(data)
+	if err != nil {
+		return ""
+	}
+	if !l.open(data) {
+		return models.NotCarvedRepeated
+	}
+
+	var reason string
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() || file.Flags&zipFlagEncrypted != 0 {
+			continue
+		}
+		content, err := l.read(file)
+		if errors.Is(err, errExpansionBudget) {
+			reason = models.NotCarvedBudget
+			continue
+		}
+		if err != nil || len(content) == 0 {
+			continue
+		}
+		carve(zipEntryName(file), content)
+	}
+	return reason
+}
@@ -3,10 +3,12 @@ package extractor
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/binary"
 	"encoding/xml"
-	"io/ioutil"
+	"errors"
 	"splitter-files/internal/models"
 	"strings"
+	"time"
 )
 
 // ContentTypes represents [Content_Types].xml in Office Open XML
@@ -42,115 +44,378 @@ func validateMSOfficeFile(data []byte) bool {
 	return true
 }
 
-func validateOfficeOpenXML(expectedContent string, expectedType models.OfficeFileType) func([]byte) bool {
-	return func(data []byte) bool {
-		if !validateZipFile(data) {
-			return false
-		}
+// officeExtensions maps the extensions of Office formats to their document family
+var officeExtensions = map[string]models.OfficeFileType{
+	"doc":  models.WordDocument,
+	"docx": models.WordDocument,
+	"docm": models.WordDocument,
+	"dot":  models.WordDocument,
+	"dotx": models.WordDocument,
+	"dotm": models.WordDocument,
+	"xls":  models.ExcelDocument,
+	"xlsx": models.ExcelDocument,
+	"xlsm": models.ExcelDocument,
+	"xlsb": models.ExcelDocument,
+	"xlt":  models.ExcelDocument,
+	"xltx": models.ExcelDocument,
+	"xltm": models.ExcelDocument,
+	"ppt":  models.PowerPointDocument,
+	"pptx": models.PowerPointDocument,
+	"pptm": models.PowerPointDocument,
+	"pot":  models.PowerPointDocument,
+	"potx": models.PowerPointDocument,
+	"potm": models.PowerPointDocument,
+	"vsd":  models.VisioDocument,
+	"vsdx": models.VisioDocument,
+	"pub":  models.PublisherDocument,
+	"mpp":  models.ProjectDocument,
+	"wps":  models.WorksDocument,
+	"xlr":  models.WorksDocument,
 
-		zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
-		if err != nil {
-			return false
+	// Encrypted packages don't tell which application they belong to
+	"ooxml": models.UnknownOffice,
+}
+
+// IsOfficeExtension reports whether ext belongs to a Microsoft Office format
+func IsOfficeExtension(ext string) bool {
+	_, ok := officeExtensions[ext]
+	return ok
+}
+
+// Root storage CLSIDs of compound files written by Office applications
+var cfbCLSIDs = map[[16]byte]string{
+	guidBytes("00020900-0000-0000-C000-000000000046"): "doc", // Word 6.0-7.0
+	guidBytes("00020906-0000-0000-C000-000000000046"): "doc", // Word 97-2003
+	guidBytes("00020810-0000-0000-C000-000000000046"): "xls", // Excel 5.0-7.0
+	guidBytes("00020820-0000-0000-C000-000000000046"): "xls", // Excel 97-2003
+	guidBytes("64818D10-4F9B-11CF-86EA-00AA00B929E8"): "ppt", // PowerPoint 97-2003
+	guidBytes("00021A13-0000-0000-C000-000000000046"): "vsd", // Visio 2000-2002
+	guidBytes("00021A14-0000-0000-C000-000000000046"): "vsd", // Visio 2003-2010
+	guidBytes("00021201-0000-0000-00C0-000000000046"): "pub", // Publisher
+	guidBytes("74B78F3A-C8C8-11D1-BE11-00C04FB6FAF1"): "mpp", // Project 98-2003
+}
+
+// cfbContainer tells apart the formats built on compound files
+var cfbContainer = &containerClassifier{classify: cfbDocumentExtension}
+
+func cfbDocumentExtension(data []byte) string {
+	c, _ := parseCFB(data)
+	return cfbFileExtension(c, data)
+}
+
+// cfbFileExtension identifies the application that created a compound file
+// from the root CLSID and the top-level streams; c is nil if data could not be
+// parsed
+func cfbFileExtension(c *cfbFile, data []byte) string {
+	if c == nil {
+		// Damaged directory, fall back to searching for stream names
+		switch {
+		case bytes.Contains(data, []byte("V\x00i\x00s\x00i\x00o\x00D\x00o\x00c\x00u\x00m\x00e\x00n\x00t")):
+			return "vsd"
+		case bytes.Contains(data, []byte("Q\x00u\x00i\x00l\x00l\x00S\x00u\x00b")):
+			return "pub"
+		case bytes.Contains(data, []byte("P\x00r\x00o\x00p\x00s\x001")), bytes.Contains(data, []byte("P\x00r\x00o\x00p\x00s\x009")):
+			return "mpp"
+		case bytes.Contains(data, []byte("WordDocument")), bytes.Contains(data, []byte("W\x00o\x00r\x00d\x00D\x00o\x00c\x00u\x00m\x00e\x00n\x00t")):
+			return "doc"
+		case bytes.Contains(data, []byte("Workbook")), bytes.Contains(data, []byte("W\x00o\x00r\x00k\x00b\x00o\x00o\x00k")):
+			return "xls"
+		case bytes.Contains(data, []byte("PowerPoint")), bytes.Contains(data, []byte("P\x00o\x00w\x00e\x00r\x00P\x00o\x00i\x00n\x00t")):
+			return "ppt"
 		}
+		return ""
+	}
+
+	ext := cfbApplicationExtension(c)
+	if template, ok := cfbTemplateExtensions[ext]; ok && cfbIsTemplate(c, ext) {
+		return template
+	}
+	return ext
+}
+
+// cfbApplicationExtension identifies the application of a compound file
+func cfbApplicationExtension(c *cfbFile) string {
+	if ext, ok := cfbCLSIDs[c.Root().CLSID]; ok {
+		return ext
+	}
+
+	switch {
+	case c.Lookup("EncryptionInfo") != nil && c.Lookup("EncryptedPackage") != nil:
+		return "ooxml"
+	case c.Lookup("VisioDocument") != nil:
+		return "vsd"
+	case c.Lookup("MatOST") != nil:
+		// Works documents also have CONTENTS and Escher streams, like Publisher
+		return "wps"
+	case c.Lookup("WksSSWorkBook") != nil:
+		return "xlr"
+	case c.Lookup("Quill") != nil, c.Lookup("Escher") != nil && c.Lookup("Contents") != nil:
+		// Publisher keeps text in Quill/QuillSub/CONTENTS and drawings in Escher/EscherStm
+		return "pub"
+	case projectPropsVersion(c) != "":
+		return "mpp"
+	case c.Lookup("WordDocument") != nil:
+		return "doc"
+	case c.Lookup("Workbook") != nil, c.Lookup("Book") != nil:
+		return "xls"
+	case c.Lookup("PowerPoint Document") != nil:
+		return "ppt"
+	}
+	return ""
+}
 
-		var contentTypes struct {
-			XMLName xml.Name `xml:"Types"`
-			Default []struct {
-				Extension   string `xml:"Extension,attr"`
-				ContentType string `xml:"ContentType,attr"`
-			} `xml:"Default"`
-			Override []struct {
-				PartName    string `xml:"PartName,attr"`
-				ContentType string `xml:"ContentType,attr"`
-			} `xml:"Override"`
+// cfbTemplateExtensions maps the extensions of legacy documents to the ones of
+// their templates
+var cfbTemplateExtensions = map[string]string{
+	"doc": "dot",
+	"xls": "xlt",
+	"ppt": "pot",
+}
+
+// fibFlagDot marks a Word document as a template
+const fibFlagDot = 0x0001
+
+// cfbIsTemplate reports whether a legacy document of the format ext is a
+// template: the fDot flag of the Word FIB, a TEMPLATE record in the Excel
+// workbook globals, or else a "*.Template" ProgID in the CompObj stream
+func cfbIsTemplate(c *cfbFile, ext string) bool {
+	switch ext {
+	case "doc":
+		if word, err := c.ReadStream(c.Lookup("WordDocument")); err == nil && len(word) >= fibBaseSize &&
+			binary.LittleEndian.Uint16(word[fibFlagsOffset:])&fibFlagDot != 0 {
+			return true
 		}
+	case "xls":
+		if biffGlobalsHaveRecord(c, biffTemplate) {
+			return true
+		}
+	}
+
+	compObj, err := c.ReadStream(c.Lookup("\x01CompObj"))
+	return err == nil && bytes.Contains(compObj, []byte(".Template"))
+}
+
+// BIFF records and PowerPoint tokens telling that a legacy document is encrypted
+// or a template
+const (
+	biffFilePass          = 0x002F
+	biffTemplate          = 0x0060
+	biffEOF               = 0x000A
+	pptEncryptedUserToken = 0xF3D1C4DF
+)
+
+// biffGlobalsHaveRecord reports whether the workbook globals substream of an
+// Excel file holds a record of a type
+func biffGlobalsHaveRecord(c *cfbFile, recordType uint16) bool {
+	found := false
+	walkBIFFGlobals(c, func(t uint16, body []byte) bool {
+		found = t == recordType
+		return !found
+	})
+	return found
+}
+
+// walkBIFFGlobals calls visit with the records of the workbook globals substream
+// of an Excel file, which runs up to the first EOF record, until it returns false
+func walkBIFFGlobals(c *cfbFile, visit func(recordType uint16, body []byte) bool) {
+	workbook := c.Lookup("Workbook")
+	if workbook == nil {
+		workbook = c.Lookup("Book")
+	}
+	stream, err := c.ReadStream(workbook)
+	if err != nil {
+		return
+	}
 
-		var hasContentTypes bool
-		officeInfo := models.OfficeDocumentInfo{}
-
-		for _, file := range zipReader.File {
-			switch file.Name {
-			case "[Content_Types].xml":
-				// ... парсинг XML ...
-				for _, override := range contentTypes.Override {
-					switch {
-					case strings.Contains(override.ContentType, "wordprocessing"):
-						officeInfo.Type = models.WordDocument
-					case strings.Contains(override.ContentType, "spreadsheet"):
-						officeInfo.Type = models.ExcelDocument
-					case strings.Contains(override.ContentType, "presentation"):
-						officeInfo.Type = models.PowerPointDocument
-					}
-				}
-
-				// ... обработка других файлов ...
-			}
+	for offset := 0; offset+4 <= len(stream); {
+		recordType := binary.LittleEndian.Uint16(stream[offset:])
+		body := offset + 4
+		end := body + int(binary.LittleEndian.Uint16(stream[offset+2:]))
+		if recordType == biffEOF || end > len(stream) || !visit(recordType, stream[body:end]) {
+			return
 		}
+		offset = end
+	}
+}
+
+// cfbDocumentEncrypted reports whether a compound file holds a password-protected
+// document: an encrypted Open XML package, a Word document whose FIB has the
+// fEncrypted flag, a workbook with a FILEPASS record or a presentation whose
+// current user atom has the encrypted token; c is nil if data could not be parsed
+func cfbDocumentEncrypted(c *cfbFile, data []byte) bool {
+	if c == nil {
+		// Damaged directory, fall back to searching for the stream names of
+		// encrypted packages
+		return bytes.Contains(data, []byte("E\x00n\x00c\x00r\x00y\x00p\x00t\x00i\x00o\x00n\x00I\x00n\x00f\x00o")) &&
+			bytes.Contains(data, []byte("E\x00n\x00c\x00r\x00y\x00p\x00t\x00e\x00d\x00P\x00a\x00c\x00k\x00a\x00g\x00e"))
+	}
+
+	if c.Lookup("EncryptionInfo") != nil && c.Lookup("EncryptedPackage") != nil {
+		return true
+	}
+
+	if word, err := c.ReadStream(c.Lookup("WordDocument")); err == nil && len(word) >= fibBaseSize {
+		return binary.LittleEndian.Uint16(word[fibFlagsOffset:])&fibFlagEncrypted != 0
+	}
+
+	if c.Lookup("Workbook") != nil || c.Lookup("Book") != nil {
+		// FILEPASS follows the BOF record of the workbook globals
+		return biffGlobalsHaveRecord(c, biffFilePass)
+	}
 
-		if !hasContentTypes {
-			return false
+	if c.Lookup("EncryptedSummary") != nil {
+		return true
+	}
+	if user, err := c.ReadStream(c.Lookup("Current User")); err == nil && len(user) >= 16 {
+		return binary.LittleEndian.Uint32(user[12:]) == pptEncryptedUserToken
+	}
+	return false
+}
+
+// projectPropsVersion returns the file format version encoded in the name of the
+// Project properties stream (Props9 - Project 2000-2003, Props12 - 2007, Props14 - 2010+)
+func projectPropsVersion(c *cfbFile) string {
+	for _, version := range []string{"14", "12", "9", "8"} {
+		if c.Lookup("Props"+version) != nil {
+			return version
 		}
+	}
+	if c.Lookup("Props") != nil && c.Root().CLSID == guidBytes("74B78F3A-C8C8-11D1-BE11-00C04FB6FAF1") {
+		return "8"
+	}
+	return ""
+}
 
-		if officeInfo.Type != expectedType {
-			return false
+// readContentTypes parses [Content_Types].xml of an OPC package
+func readContentTypes(pkg *zipPackage) (*ContentTypes, error) {
+	for _, file := range pkg.File {
+		if file.Name != "[Content_Types].xml" {
+			continue
 		}
 
-		for _, defaultType := range contentTypes.Default {
-			if strings.Contains(defaultType.ContentType, expectedContent) {
-				return true
-			}
+		rc, err := pkg.open(file)
+		if err != nil {
+			return nil, err
 		}
+		defer rc.Close()
 
-		return false
+		var contentTypes ContentTypes
+		if err := xml.NewDecoder(rc).Decode(&contentTypes); err != nil {
+			return nil, err
+		}
+		return &contentTypes, nil
 	}
+	return nil, errors.New("missing [Content_Types].xml")
 }
 
-func validateOpenDocument(data []byte) bool {
-	if !validateZipFile(data) {
-		if bytes.HasPrefix(data, []byte("<?xml version=\"1.0\"?>")) {
-			return bytes.Contains(data, []byte("office:document"))
+// opcMainContentType returns the content type of the main part of an OPC package
+// (binary workbooks have a ".main" type without "+xml")
+func opcMainContentType(contentTypes *ContentTypes) string {
+	for _, override := range contentTypes.Override {
+		if strings.HasSuffix(override.ContentType, ".main+xml") || strings.HasSuffix(override.ContentType, ".main") {
+			return override.ContentType
 		}
-		return false
 	}
+	return ""
+}
+
+// opcDocumentType determines the document family from the content type of the
+// main part; macro-enabled documents use the ms-word, ms-excel and
+// ms-powerpoint types instead of the OOXML ones
+func opcDocumentType(contentTypes *ContentTypes) models.OfficeFileType {
+	contentType := opcMainContentType(contentTypes)
+	switch {
+	case strings.Contains(contentType, "wordprocessingml"), strings.Contains(contentType, "ms-word."):
+		return models.WordDocument
+	case strings.Contains(contentType, "spreadsheetml"), strings.Contains(contentType, "ms-excel."):
+		return models.ExcelDocument
+	case strings.Contains(contentType, "presentationml"), strings.Contains(contentType, "ms-powerpoint."):
+		return models.PowerPointDocument
+	case strings.Contains(contentType, "ms-visio.drawing"):
+		return models.VisioDocument
+	}
+	return models.UnknownOffice
+}
 
-	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+// opcMacroEnabled reports whether the main part of an OPC package has a
+// macroEnabled content type, as in .docm, .xlsm and .pptm documents
+func opcMacroEnabled(contentTypes *ContentTypes) bool {
+	return strings.Contains(strings.ToLower(opcMainContentType(contentTypes)), ".macroenabled") && !opcBinaryWorkbook(contentTypes)
+}
+
+// opcTemplate reports whether the main part of an OPC package has a template
+// content type, as in .dotx, .xltx and .potx and their macro-enabled variants
+func opcTemplate(contentTypes *ContentTypes) bool {
+	return strings.Contains(strings.ToLower(opcMainContentType(contentTypes)), ".template.")
+}
+
+// opcBinaryWorkbook reports whether an OPC package is an Excel binary workbook
+// (.xlsb), whose main part xl/workbook.bin has a macroEnabled content type
+// whether or not the workbook has macros
+func opcBinaryWorkbook(contentTypes *ContentTypes) bool {
+	return strings.Contains(strings.ToLower(opcMainContentType(contentTypes)), ".sheet.binary.")
+}
+
+// coreProperties is docProps/core.xml of an OPC package (Dublin Core elements are
+// matched by local name)
+type coreProperties struct {
+	Title          string `xml:"title"`
+	Creator        string `xml:"creator"`
+	LastModifiedBy string `xml:"lastModifiedBy"`
+	Created        string `xml:"created"`
+	Modified       string `xml:"modified"`
+}
+
+// readOOXMLPackage fills the document properties and the embedded objects of an
+// OOXML package into info; no further part is read once deadline has passed
+func readOOXMLPackage(data []byte, info *models.OfficeDocumentInfo, deadline time.Time) {
+	zipReader, err := openZip(data)
 	if err != nil {
-		return false
+		return
 	}
 
-	var hasMimetype, hasContent bool
-	var mimeType string
+	readCoreProperties(zipReader, info)
+	readEmbeddedObjects(zipReader, info, deadline)
+	if info.Type == models.ExcelDocument {
+		readWorkbookIndicators(zipReader, info, deadline)
+	}
+	readPackageIndicators(zipReader, info, deadline)
+}
 
+// readCoreProperties fills the document properties from docProps/core.xml
+func readCoreProperties(zipReader *zip.Reader, info *models.OfficeDocumentInfo) {
 	for _, file := range zipReader.File {
-		switch file.Name {
-		case "mimetype":
-			rc, err := file.Open()
-			if err != nil {
-				continue
-			}
-			defer rc.Close()
-
-			mimeData, err := ioutil.ReadAll(rc)
-			if err != nil {
-				continue
-			}
-
-			mimeType = string(mimeData)
-			switch {
-			case strings.Contains(mimeType, "application/vnd.oasis.opendocument.text"):
-				hasMimetype = true
-			case strings.Contains(mimeType, "application/vnd.oasis.opendocument.spreadsheet"):
-				hasMimetype = true
-			case strings.Contains(mimeType, "application/vnd.oasis.opendocument.presentation"):
-				hasMimetype = true
-			case strings.Contains(mimeType, "application/vnd.oasis.opendocument.spreadsheet-template"):
-				hasMimetype = true
-			}
-
-		case "content.xml", "styles.xml":
-			hasContent = true
+		if file.Name != "docProps/core.xml" {
+			continue
+		}
+
+		rc, err := openEntry(file)
+		if err != nil {
+			return
+		}
+		defer rc.Close()
+
+		var props coreProperties
+		if err := xml.NewDecoder(rc).Decode(&props); err != nil {
+			return
 		}
+
+		info.Title = strings.TrimSpace(props.Title)
+		info.Creator = strings.TrimSpace(props.Creator)
+		info.LastModifiedBy = strings.TrimSpace(props.LastModifiedBy)
+		info.Created = parseW3CDTF(props.Created)
+		info.Modified = parseW3CDTF(props.Modified)
+		return
 	}
+}
 
-	return hasMimetype && hasContent
+// parseW3CDTF parses the dcterms:W3CDTF timestamps used in core.xml
+func parseW3CDTF(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
 }
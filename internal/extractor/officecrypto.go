@@ -0,0 +1,746 @@
+package extractor
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Decryption of password-protected Office documents (MS-OFFCRYPTO): Agile and
+// Standard encryption of Open XML packages, RC4 and RC4 CryptoAPI encryption of
+// Word 97-2003 documents
+
+var errWrongPassword = errors.New("no password matched")
+
+// LoadPasswords reads a wordlist with a password per line
+func LoadPasswords(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var passwords []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			passwords = append(passwords, line)
+		}
+	}
+	if len(passwords) == 0 {
+		return nil, fmt.Errorf("no passwords in %s", path)
+	}
+	return passwords, nil
+}
+
+// decryptCFB decrypts the document of a parsed compound file, nil if it could
+// not be parsed; no further password is tried once deadline has passed
+func decryptCFB(c *cfbFile, passwords []string, deadline time.Time) ([]byte, string, error) {
+	switch {
+	case c == nil:
+		return nil, "", errors.New("not a compound file")
+	case c.Lookup("EncryptionInfo") != nil && c.Lookup("EncryptedPackage") != nil:
+		return decryptOOXML(c, passwords, deadline)
+	case c.Lookup("WordDocument") != nil:
+		return decryptWord97(c, passwords, deadline)
+	case c.Lookup("Workbook") != nil:
+		return decryptExcel97(c, passwords, deadline)
+	}
+	return nil, "", errors.New("unsupported encryption")
+}
+
+// ooxmlEncryption is the encryption of an Open XML package stored in the
+// EncryptedPackage stream of a compound file
+type ooxmlEncryption interface {
+	// key returns the package key, or nil if the password is wrong or deadline
+	// passes while the password is hashed
+	key(password string, deadline time.Time) []byte
+	// decrypt returns the package from the EncryptedPackage stream
+	decrypt(key, stream []byte) ([]byte, error)
+}
+
+func decryptOOXML(c *cfbFile, passwords []string, deadline time.Time) ([]byte, string, error) {
+	info, err := c.ReadStream(c.Lookup("EncryptionInfo"))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(info) < 8 {
+		return nil, "", errors.New("EncryptionInfo is truncated")
+	}
+
+	var enc ooxmlEncryption
+	major, minor := binary.LittleEndian.Uint16(info[0:2]), binary.LittleEndian.Uint16(info[2:4])
+	switch {
+	case major == 4 && minor == 4:
+		enc, err = parseAgileEncryption(info[8:])
+	case (major == 3 || major == 4) && minor == 2:
+		enc, err = parseStandardEncryption(info[8:])
+	default:
+		err = fmt.Errorf("unsupported encryption version %d.%d", major, minor)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	stream, err := c.ReadStream(c.Lookup("EncryptedPackage"))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(stream) < 8 {
+		return nil, "", errors.New("EncryptedPackage is truncated")
+	}
+
+	for _, password := range passwords {
+		if deadlinePassed(deadline) {
+			return nil, "", errDeadline
+		}
+		if key := enc.key(password, deadline); key != nil {
+			plain, err := enc.decrypt(key, stream)
+			return plain, password, err
+		}
+	}
+	return nil, "", errWrongPassword
+}
+
+// Agile encryption (Office 2010 and later): XML parameters, an iterated hash of
+// the password and AES-CBC in 4096-byte segments
+
+// Block keys of the values encrypted with the password key
+var (
+	agileVerifierInputBlock = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	agileVerifierValueBlock = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+	agileKeyValueBlock      = []byte{0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6}
+)
+
+const (
+	agileSegmentSize = 4096
+	// agileMaxSpinCount is the most hash iterations of a password MS-OFFCRYPTO
+	// allows; a larger count would make every password take minutes
+	agileMaxSpinCount = 10000000
+	// spinDeadlineCheck is the number of hash iterations between checks of
+	// the deadline, a few milliseconds' worth
+	spinDeadlineCheck = 16384
+)
+
+// agileKeyBits are the AES key sizes the encryption may use
+var agileKeyBits = map[int]bool{128: true, 192: true, 256: true}
+
+type agileParams struct {
+	SaltSize        int    `xml:"saltSize,attr"`
+	BlockSize       int    `xml:"blockSize,attr"`
+	KeyBits         int    `xml:"keyBits,attr"`
+	HashSize        int    `xml:"hashSize,attr"`
+	CipherAlgorithm string `xml:"cipherAlgorithm,attr"`
+	CipherChaining  string `xml:"cipherChaining,attr"`
+	HashAlgorithm   string `xml:"hashAlgorithm,attr"`
+	SaltValue       string `xml:"saltValue,attr"`
+}
+
+type agileEncryption struct {
+	KeyData       agileParams `xml:"keyData"`
+	KeyEncryptors []struct {
+		URI          string `xml:"uri,attr"`
+		EncryptedKey struct {
+			agileParams
+			SpinCount                  int    `xml:"spinCount,attr"`
+			EncryptedVerifierHashInput string `xml:"encryptedVerifierHashInput,attr"`
+			EncryptedVerifierHashValue string `xml:"encryptedVerifierHashValue,attr"`
+			EncryptedKeyValue          string `xml:"encryptedKeyValue,attr"`
+		} `xml:"encryptedKey"`
+	} `xml:"keyEncryptors>keyEncryptor"`
+
+	// Decoded parameters of the password key encryptor
+	keySalt, passwordSalt                  []byte
+	verifierInput, verifierValue, keyValue []byte
+	spinCount, passwordKeyBits, keyBits    int
+	keyHash, passwordHash                  func() hash.Hash
+}
+
+func parseAgileEncryption(data []byte) (*agileEncryption, error) {
+	var a agileEncryption
+	if err := xml.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	if a.KeyData.CipherAlgorithm != "AES" || a.KeyData.CipherChaining != "ChainingModeCBC" {
+		return nil, fmt.Errorf("unsupported cipher %s %s", a.KeyData.CipherAlgorithm, a.KeyData.CipherChaining)
+	}
+
+	for _, encryptor := range a.KeyEncryptors {
+		if !strings.HasSuffix(encryptor.URI, "/password") {
+			continue
+		}
+		k := encryptor.EncryptedKey
+		if k.CipherAlgorithm != "AES" || k.CipherChaining != "ChainingModeCBC" {
+			return nil, fmt.Errorf("unsupported cipher %s %s", k.CipherAlgorithm, k.CipherChaining)
+		}
+
+		var err error
+		decode := func(s string) []byte {
+			b, decodeErr := base64.StdEncoding.DecodeString(s)
+			if decodeErr != nil {
+				err = decodeErr
+			}
+			return b
+		}
+		a.keySalt = decode(a.KeyData.SaltValue)
+		a.passwordSalt = decode(k.SaltValue)
+		a.verifierInput = decode(k.EncryptedVerifierHashInput)
+		a.verifierValue = decode(k.EncryptedVerifierHashValue)
+		a.keyValue = decode(k.EncryptedKeyValue)
+		if err != nil {
+			return nil, err
+		}
+		a.spinCount, a.passwordKeyBits, a.keyBits = k.SpinCount, k.KeyBits, a.KeyData.KeyBits
+		if a.spinCount < 0 || a.spinCount > agileMaxSpinCount {
+			return nil, fmt.Errorf("spin count %d out of range", a.spinCount)
+		}
+		if !agileKeyBits[a.passwordKeyBits] || !agileKeyBits[a.keyBits] {
+			return nil, fmt.Errorf("unsupported key size %d/%d bits", a.passwordKeyBits, a.keyBits)
+		}
+		a.keyHash, a.passwordHash = hashAlgorithm(a.KeyData.HashAlgorithm), hashAlgorithm(k.HashAlgorithm)
+		if a.keyHash == nil || a.passwordHash == nil {
+			return nil, fmt.Errorf("unsupported hash algorithm %s", k.HashAlgorithm)
+		}
+		return &a, nil
+	}
+	return nil, errors.New("no password key encryptor")
+}
+
+func (a *agileEncryption) key(password string, deadline time.Time) []byte {
+	h := hashOf(a.passwordHash, a.passwordSalt, utf16LE(password))
+	iterator := make([]byte, 4)
+	for i := 0; i < a.spinCount; i++ {
+		if i%spinDeadlineCheck == 0 && deadlinePassed(deadline) {
+			return nil
+		}
+		binary.LittleEndian.PutUint32(iterator, uint32(i))
+		h = hashOf(a.passwordHash, iterator, h)
+	}
+
+	keySize := a.passwordKeyBits / 8
+	iv := resize(a.passwordSalt, aes.BlockSize)
+	decrypt := func(block, data []byte) []byte {
+		plain, err := aesCBCDecrypt(resize(hashOf(a.passwordHash, h, block), keySize), iv, data)
+		if err != nil {
+			return nil
+		}
+		return plain
+	}
+
+	input := decrypt(agileVerifierInputBlock, a.verifierInput)
+	value := decrypt(agileVerifierValueBlock, a.verifierValue)
+	if len(input) < len(a.passwordSalt) || value == nil {
+		return nil
+	}
+	expected := hashOf(a.passwordHash, input[:len(a.passwordSalt)])
+	if len(value) < len(expected) || !bytes.Equal(value[:len(expected)], expected) {
+		return nil
+	}
+
+	key := decrypt(agileKeyValueBlock, a.keyValue)
+	if len(key) < a.keyBits/8 {
+		return nil
+	}
+	return key[:a.keyBits/8]
+}
+
+func (a *agileEncryption) decrypt(key, stream []byte) ([]byte, error) {
+	size := binary.LittleEndian.Uint64(stream[:8])
+	data := stream[8:]
+
+	plain := make([]byte, 0, len(data))
+	index := make([]byte, 4)
+	for i := 0; len(data) > 0; i++ {
+		segment := data
+		if len(segment) > agileSegmentSize {
+			segment = segment[:agileSegmentSize]
+		}
+		data = data[len(segment):]
+
+		binary.LittleEndian.PutUint32(index, uint32(i))
+		iv := resize(hashOf(a.keyHash, a.keySalt, index), aes.BlockSize)
+		dec, err := aesCBCDecrypt(key, iv, segment[:len(segment)-len(segment)%aes.BlockSize])
+		if err != nil {
+			return nil, err
+		}
+		plain = append(plain, dec...)
+	}
+	if uint64(len(plain)) < size {
+		return nil, errors.New("EncryptedPackage is truncated")
+	}
+	return plain[:size], nil
+}
+
+// Standard encryption (Office 2007): binary parameters, 50000 SHA-1 iterations
+// of the password and AES-ECB
+
+const (
+	standardSpinCount = 50000
+	algAES128         = 0x660E
+	algAES192         = 0x660F
+	algAES256         = 0x6610
+)
+
+// standardKeySizes are the key sizes in bytes of the AES algorithms
+var standardKeySizes = map[uint32]int{algAES128: 16, algAES192: 24, algAES256: 32}
+
+type standardEncryption struct {
+	keySize                      int
+	salt, verifier, verifierHash []byte
+	verifierHashSize             int
+}
+
+func parseStandardEncryption(data []byte) (*standardEncryption, error) {
+	if len(data) < 4 {
+		return nil, errors.New("EncryptionInfo is truncated")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	header := data[4:]
+	if headerSize < 32 || len(header) < headerSize+72 {
+		return nil, errors.New("EncryptionInfo is truncated")
+	}
+
+	s := &standardEncryption{}
+	algID := binary.LittleEndian.Uint32(header[8:12])
+	keySize, ok := standardKeySizes[algID]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm 0x%04X", algID)
+	}
+	// The key size of the header must be the one of the algorithm: the derived
+	// key has 40 bytes at most
+	if keyBits := binary.LittleEndian.Uint32(header[16:20]); keyBits != uint32(keySize)*8 {
+		return nil, fmt.Errorf("key size %d bits doesn't match algorithm 0x%04X", keyBits, algID)
+	}
+	s.keySize = keySize
+
+	verifier := header[headerSize:]
+	if binary.LittleEndian.Uint32(verifier[0:4]) != 16 {
+		return nil, errors.New("unsupported salt size")
+	}
+	s.salt = verifier[4:20]
+	s.verifier = verifier[20:36]
+	s.verifierHashSize = int(binary.LittleEndian.Uint32(verifier[36:40]))
+	s.verifierHash = verifier[40:72]
+	if s.verifierHashSize > len(s.verifierHash) {
+		return nil, errors.New("invalid verifier hash size")
+	}
+	return s, nil
+}
+
+func (s *standardEncryption) key(password string, deadline time.Time) []byte {
+	h := hashOf(sha1.New, s.salt, utf16LE(password))
+	iterator := make([]byte, 4)
+	for i := 0; i < standardSpinCount; i++ {
+		if i%spinDeadlineCheck == 0 && deadlinePassed(deadline) {
+			return nil
+		}
+		binary.LittleEndian.PutUint32(iterator, uint32(i))
+		h = hashOf(sha1.New, iterator, h)
+	}
+	h = hashOf(sha1.New, h, []byte{0, 0, 0, 0})
+
+	// Key derivation of CryptDeriveKey
+	buf1, buf2 := bytes.Repeat([]byte{0x36}, 64), bytes.Repeat([]byte{0x5C}, 64)
+	for i := range h {
+		buf1[i] ^= h[i]
+		buf2[i] ^= h[i]
+	}
+	key := append(hashOf(sha1.New, buf1), hashOf(sha1.New, buf2)...)[:s.keySize]
+
+	verifier, err := aesECBDecrypt(key, s.verifier)
+	if err != nil {
+		return nil
+	}
+	verifierHash, err := aesECBDecrypt(key, s.verifierHash)
+	if err != nil || !bytes.Equal(hashOf(sha1.New, verifier), verifierHash[:s.verifierHashSize]) {
+		return nil
+	}
+	return key
+}
+
+func (s *standardEncryption) decrypt(key, stream []byte) ([]byte, error) {
+	size := binary.LittleEndian.Uint64(stream[:8])
+	data := stream[8:]
+	plain, err := aesECBDecrypt(key, data[:len(data)-len(data)%aes.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(plain)) < size {
+		return nil, errors.New("EncryptedPackage is truncated")
+	}
+	return plain[:size], nil
+}
+
+// Word 97-2003 encryption: the FIB flags the document as encrypted and the
+// table stream starts with the encryption header; the WordDocument (but its
+// FIB), table and Data streams are encrypted with RC4 in 512-byte blocks
+
+const (
+	fibFlagsOffset   = 0x0A
+	fibKeyOffset     = 0x0E
+	fibBaseSize      = 0x44
+	fibFlagEncrypted = 0x0100
+	fibFlagWhichTbl  = 0x0200
+	fibFlagObfuscate = 0x8000
+	rc4BlockSize     = 512
+)
+
+// rc4BlockKey returns the key of a block of an RC4-encrypted stream
+type rc4BlockKey func(block uint32) []byte
+
+func decryptWord97(c *cfbFile, passwords []string, deadline time.Time) ([]byte, string, error) {
+	wordEntry := c.Lookup("WordDocument")
+	word, err := c.ReadStream(wordEntry)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(word) < fibBaseSize {
+		return nil, "", errors.New("WordDocument is truncated")
+	}
+	flags := binary.LittleEndian.Uint16(word[fibFlagsOffset:])
+	if flags&fibFlagEncrypted == 0 {
+		return nil, "", errors.New("not encrypted")
+	}
+	if flags&fibFlagObfuscate != 0 {
+		return nil, "", errors.New("unsupported XOR obfuscation")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(word[fibKeyOffset:]))
+
+	tableName := "0Table"
+	if flags&fibFlagWhichTbl != 0 {
+		tableName = "1Table"
+	}
+	tableEntry := c.Lookup(tableName)
+	table, err := c.ReadStream(tableEntry)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(table) < headerSize || headerSize < 4 {
+		return nil, "", errors.New("encryption header is truncated")
+	}
+
+	newKey, err := parseRC4Header(table[:headerSize])
+	if err != nil {
+		return nil, "", err
+	}
+	for _, password := range passwords {
+		if deadlinePassed(deadline) {
+			return nil, "", errDeadline
+		}
+		blockKey := newKey(password)
+		if blockKey == nil {
+			continue
+		}
+
+		// The streams keep their size, so the decrypted content is written over
+		// the encrypted one in a copy of the file
+		out := append([]byte(nil), c.data...)
+		fib := append([]byte(nil), word[:fibBaseSize]...)
+		decryptRC4Stream(word, blockKey, rc4BlockSize)
+		copy(word, fib)
+		binary.LittleEndian.PutUint16(word[fibFlagsOffset:], flags&^(fibFlagEncrypted|fibFlagObfuscate))
+		encryptionHeader := append([]byte(nil), table[:headerSize]...)
+		decryptRC4Stream(table, blockKey, rc4BlockSize)
+		copy(table, encryptionHeader)
+		streams := map[*cfbEntry][]byte{wordEntry: word, tableEntry: table}
+		if dataEntry := c.Lookup("Data"); dataEntry != nil {
+			if data, err := c.ReadStream(dataEntry); err == nil {
+				decryptRC4Stream(data, blockKey, rc4BlockSize)
+				streams[dataEntry] = data
+			}
+		}
+		for entry, content := range streams {
+			if err := c.writeStream(out, entry, content); err != nil {
+				return nil, "", err
+			}
+		}
+		return out, password, nil
+	}
+	return nil, "", errWrongPassword
+}
+
+// Excel 97-2003 encryption: a FILEPASS record after the BOF record of the
+// workbook globals holds the encryption header, and the data of the records
+// of the Workbook stream are encrypted with RC4 in 1024-byte blocks of the
+// stream; the record headers and a few records are left as they are, but
+// the key stream runs over them
+
+const (
+	biffBOF          = 0x0809
+	biffBoundSheet   = 0x0085
+	biffRC4          = 0x0001
+	biffRC4BlockSize = 1024
+	// biffPadding replaces the FILEPASS record in a decrypted workbook: the
+	// records can't be moved, as some hold the positions of others, and
+	// readers skip records of an unknown type
+	biffPadding = 0x0000
+)
+
+// biffUnencrypted are the records left unencrypted in an encrypted workbook:
+// BOF, FILEPASS, UsrExcl, FileLock, InterfaceHdr, RRDInfo and RRDHead
+var biffUnencrypted = map[uint16]bool{
+	biffBOF: true, biffFilePass: true, 0x0194: true, 0x0195: true, 0x00E1: true, 0x0196: true, 0x0138: true,
+}
+
+func decryptExcel97(c *cfbFile, passwords []string, deadline time.Time) ([]byte, string, error) {
+	workbookEntry := c.Lookup("Workbook")
+	workbook, err := c.ReadStream(workbookEntry)
+	if err != nil {
+		return nil, "", err
+	}
+	plain, password, err := decryptWorkbook(workbook, passwords, deadline)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The stream keeps its size, so the decrypted content is written over the
+	// encrypted one in a copy of the file
+	out := append([]byte(nil), c.data...)
+	if err := c.writeStream(out, workbookEntry, plain); err != nil {
+		return nil, "", err
+	}
+	return out, password, nil
+}
+
+// decryptWorkbook decrypts a Workbook stream with the first of the passwords
+// that opens it
+func decryptWorkbook(workbook []byte, passwords []string, deadline time.Time) ([]byte, string, error) {
+	var header []byte
+	filePass := -1
+	for offset := 0; offset+4 <= len(workbook); {
+		recordType := binary.LittleEndian.Uint16(workbook[offset:])
+		end := offset + 4 + int(binary.LittleEndian.Uint16(workbook[offset+2:]))
+		if recordType == biffEOF || end > len(workbook) {
+			break
+		}
+		if recordType == biffFilePass {
+			filePass, header = offset, workbook[offset+4:end]
+			break
+		}
+		offset = end
+	}
+	if filePass == -1 {
+		return nil, "", errors.New("not encrypted")
+	}
+	if len(header) < 2 || binary.LittleEndian.Uint16(header) != biffRC4 {
+		return nil, "", errors.New("unsupported XOR obfuscation")
+	}
+	if len(header) < 6 {
+		return nil, "", errors.New("encryption header is truncated")
+	}
+
+	newKey, err := parseRC4Header(header[2:])
+	if err != nil {
+		return nil, "", err
+	}
+	for _, password := range passwords {
+		if deadlinePassed(deadline) {
+			return nil, "", errDeadline
+		}
+		blockKey := newKey(password)
+		if blockKey == nil {
+			continue
+		}
+
+		plain := append([]byte(nil), workbook...)
+		decryptRC4Stream(plain, blockKey, biffRC4BlockSize)
+		// What was not encrypted is taken back from the stream: the record
+		// headers, the records left unencrypted, the position of the sheet in
+		// BoundSheet8 records, and anything after the last whole record
+		offset := 0
+		for offset+4 <= len(workbook) {
+			recordType := binary.LittleEndian.Uint16(workbook[offset:])
+			end := offset + 4 + int(binary.LittleEndian.Uint16(workbook[offset+2:]))
+			if end > len(workbook) {
+				break
+			}
+			copy(plain[offset:offset+4], workbook[offset:])
+			switch {
+			case biffUnencrypted[recordType]:
+				copy(plain[offset:end], workbook[offset:end])
+			case recordType == biffBoundSheet:
+				copy(plain[offset+4:min(offset+8, end)], workbook[offset+4:])
+			}
+			offset = end
+		}
+		copy(plain[offset:], workbook[offset:])
+
+		binary.LittleEndian.PutUint16(plain[filePass:], biffPadding)
+		clear(plain[filePass+4 : filePass+4+len(header)])
+		return plain, password, nil
+	}
+	return nil, "", errWrongPassword
+}
+
+// parseRC4Header reads the RC4 or RC4 CryptoAPI encryption header and returns
+// a function giving the block keys of a password, or nil if it is wrong
+func parseRC4Header(header []byte) (func(password string) rc4BlockKey, error) {
+	major, minor := binary.LittleEndian.Uint16(header[0:2]), binary.LittleEndian.Uint16(header[2:4])
+	switch {
+	case major == 1 && minor == 1:
+		if len(header) < 52 {
+			return nil, errors.New("encryption header is truncated")
+		}
+		salt, verifier, verifierHash := header[4:20], header[20:36], header[36:52]
+		return func(password string) rc4BlockKey {
+			h := md5.Sum(utf16LE(password))
+			intermediate := bytes.Repeat(append(h[:5:5], salt...), 16)
+			h = md5.Sum(intermediate)
+			truncated := h[:5]
+			blockKey := func(block uint32) []byte {
+				key := md5.Sum(binary.LittleEndian.AppendUint32(append([]byte(nil), truncated...), block))
+				return key[:]
+			}
+			if !checkRC4Verifier(blockKey(0), verifier, verifierHash, md5.New) {
+				return nil
+			}
+			return blockKey
+		}, nil
+
+	case (major == 2 || major == 3 || major == 4) && minor == 2:
+		if len(header) < 12 {
+			return nil, errors.New("encryption header is truncated")
+		}
+		headerSize := int(binary.LittleEndian.Uint32(header[8:12]))
+		if headerSize < 20 || len(header) < 12+headerSize+60 {
+			return nil, errors.New("encryption header is truncated")
+		}
+		keyBits := int(binary.LittleEndian.Uint32(header[12+16:]))
+		if keyBits == 0 {
+			keyBits = 40
+		}
+		// RC4 CryptoAPI keys are 40 to 128 bits, cut from a 160-bit SHA-1 hash
+		if keyBits < 40 || keyBits > 128 || keyBits%8 != 0 {
+			return nil, fmt.Errorf("unsupported key size %d bits", keyBits)
+		}
+		verifierData := header[12+headerSize:]
+		salt, verifier, verifierHash := verifierData[4:20], verifierData[20:36], verifierData[40:60]
+		return func(password string) rc4BlockKey {
+			h := hashOf(sha1.New, salt, utf16LE(password))
+			blockKey := func(block uint32) []byte {
+				key := hashOf(sha1.New, h, binary.LittleEndian.AppendUint32(nil, block))
+				if keyBits == 40 {
+					// 40-bit keys are padded to 128 bits
+					return append(key[:5:5], make([]byte, 11)...)
+				}
+				return key[:keyBits/8]
+			}
+			if !checkRC4Verifier(blockKey(0), verifier, verifierHash, sha1.New) {
+				return nil
+			}
+			return blockKey
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported encryption version %d.%d", major, minor)
+}
+
+// checkRC4Verifier decrypts the verifier and its hash with one RC4 key stream
+// and compares them
+func checkRC4Verifier(key, verifier, verifierHash []byte, newHash func() hash.Hash) bool {
+	stream, err := rc4.NewCipher(key)
+	if err != nil {
+		return false
+	}
+	plain := make([]byte, len(verifier)+len(verifierHash))
+	stream.XORKeyStream(plain, append(append([]byte(nil), verifier...), verifierHash...))
+	expected := hashOf(newHash, plain[:len(verifier)])
+	return bytes.Equal(expected, plain[len(verifier):len(verifier)+len(expected)])
+}
+
+// decryptRC4Stream decrypts a stream in place, restarting RC4 every blockSize
+// bytes
+func decryptRC4Stream(data []byte, blockKey rc4BlockKey, blockSize int) {
+	for offset := 0; offset < len(data); offset += blockSize {
+		end := offset + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		stream, err := rc4.NewCipher(blockKey(uint32(offset / blockSize)))
+		if err != nil {
+			return
+		}
+		stream.XORKeyStream(data[offset:end], data[offset:end])
+	}
+}
+
+func hashAlgorithm(name string) func() hash.Hash {
+	switch name {
+	case "SHA1", "SHA-1":
+		return sha1.New
+	case "SHA256", "SHA-256":
+		return sha256.New
+	case "SHA384", "SHA-384":
+		return sha512.New384
+	case "SHA512", "SHA-512":
+		return sha512.New
+	case "MD5":
+		return md5.New
+	}
+	return nil
+}
+
+func hashOf(newHash func() hash.Hash, parts ...[]byte) []byte {
+	h := newHash()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// resize truncates b or pads it with 0x36 bytes to size bytes
+func resize(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b[:size]
+	}
+	return append(append([]byte(nil), b...), bytes.Repeat([]byte{0x36}, size-len(b))...)
+}
+
+func utf16LE(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+func aesCBCDecrypt(key, iv, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("data is not a multiple of the block size")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	return plain, nil
+}
+
+func aesECBDecrypt(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("data is not a multiple of the block size")
+	}
+	plain := make([]byte, len(data))
+	for i := 0; i < len(data); i += aes.BlockSize {
+		block.Decrypt(plain[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
+	}
+	return plain, nil
+}
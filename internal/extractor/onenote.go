@@ -0,0 +1,51 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Revision store file type GUIDs as stored on disk (little-endian fields)
+var (
+	// {7B5C52E4-D88C-4DA7-AEB1-5378D02996D3}
+	oneNoteSectionGUID = []byte{0xE4, 0x52, 0x5C, 0x7B, 0x8C, 0xD8, 0xA7, 0x4D, 0xAE, 0xB1, 0x53, 0x78, 0xD0, 0x29, 0x96, 0xD3}
+	// {43FF2FA1-EFD9-4C76-9EE2-10EA5722765F}
+	oneNoteTOCGUID = []byte{0xA1, 0x2F, 0xFF, 0x43, 0xD9, 0xEF, 0x76, 0x4C, 0x9E, 0xE2, 0x10, 0xEA, 0x57, 0x22, 0x76, 0x5F}
+	// guidFileFormat {109ADD3F-911B-49F5-A5D0-1791EDC8AED8}
+	oneNoteFileFormatGUID = []byte{0x3F, 0xDD, 0x9A, 0x10, 0x1B, 0x91, 0xF5, 0x49, 0xA5, 0xD0, 0x17, 0x91, 0xED, 0xC8, 0xAE, 0xD8}
+)
+
+const (
+	oneNoteHeaderSize = 1024
+	oneNoteMaxSize    = 4 * 1024 * 1024 * 1024
+)
+
+func validateOneNote(data []byte) bool {
+	if len(data) < oneNoteHeaderSize {
+		return false
+	}
+
+	if !bytes.HasPrefix(data, oneNoteSectionGUID) && !bytes.HasPrefix(data, oneNoteTOCGUID) {
+		return false
+	}
+
+	if !bytes.Equal(data[48:64], oneNoteFileFormatGUID) {
+		return false
+	}
+
+	size := oneNoteFileSize(data)
+	return size >= oneNoteHeaderSize
+}
+
+// oneNoteFileSize returns cbExpectedFileLength from the revision store header
+func oneNoteFileSize(data []byte) int {
+	if len(data) < oneNoteHeaderSize {
+		return 0
+	}
+
+	size := binary.LittleEndian.Uint64(data[196:204])
+	if size > oneNoteMaxSize {
+		return 0
+	}
+	return int(size)
+}
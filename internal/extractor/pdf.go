@@ -0,0 +1,541 @@
+package extractor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	// maxPDFEOFMarkers bounds the %%EOF markers tried when looking for the end of a PDF
+	maxPDFEOFMarkers = 1024
+	// pdfHeaderWindow is how far into a file the %PDF- header may be; readers
+	// accept up to 1 KB of junk before it
+	pdfHeaderWindow = 1024
+	// maxPDFXrefDict bounds the stream dictionary searched for /Type /XRef, so
+	// that each of the %%EOF markers tried doesn't scan the rest of the input
+	maxPDFXrefDict = 64 << 10
+	// maxPDFObjectStreamBytes bounds what is decompressed of the object streams
+	// of a PDF in all
+	maxPDFObjectStreamBytes = 64 << 20
+	// maxPDFNesting bounds the dictionaries and arrays nested in a value; a
+	// deeper value is taken to run to the end of the data rather than recursing
+	// as deep as a crafted file asks
+	maxPDFNesting = 256
+)
+
+var (
+	pdfEOF = []byte("%%EOF")
+	// pdfStartXref is the pointer to the last cross-reference section that
+	// precedes every %%EOF
+	pdfStartXref = regexp.MustCompile(`startxref\s+(\d+)\s*$`)
+	// pdfObjectHeader is the "12 0 obj" starting an indirect object
+	pdfObjectHeader = regexp.MustCompile(`^\s*\d+\s+\d+\s+obj\b`)
+	pdfXrefStream   = regexp.MustCompile(`/Type\s*/XRef\b`)
+)
+
+// pdfHeaderOffset returns the position of the %PDF- header, -1 if it isn't in
+// the first pdfHeaderWindow bytes
+func pdfHeaderOffset(data []byte) int {
+	if len(data) > pdfHeaderWindow+len("%PDF-") {
+		data = data[:pdfHeaderWindow+len("%PDF-")]
+	}
+	return bytes.Index(data, []byte("%PDF-"))
+}
+
+// PDFStart returns where the PDF whose header is at data[pos] starts: at the
+// header, or up to pdfHeaderWindow bytes before it when the file begins with junk
+// its offsets count, which shows as a startxref pointing past its cross-reference
+// section by the length of the junk
+func PDFStart(data []byte, pos int) int {
+	for i, tries := pos, 0; tries < maxPDFEOFMarkers; tries++ {
+		k := bytes.Index(data[i:], pdfEOF)
+		if k < 0 {
+			break
+		}
+		eof := i + k
+		i = eof + len(pdfEOF)
+		offset, ok := pdfStartXrefOffset(data[pos:], eof-pos)
+		if !ok {
+			continue
+		}
+
+		// The first startxref decides; the section starts a token, unlike the
+		// xref of startxref or the 2 of 12 0 obj
+		for shift := 0; shift <= pdfHeaderWindow && shift <= pos; shift++ {
+			section := pos - shift + offset
+			if section < eof && pdfIsDelimiter(data[section-1]) && pdfIsCrossReference(data[section:eof]) {
+				return pos - shift
+			}
+		}
+		break
+	}
+	return pos
+}
+
+// pdfHasCrossReference reports whether a PDF has a cross-reference table (the
+// xref keyword at the start of a line) or, from PDF 1.5 on, a cross-reference
+// stream, an object of type XRef that may replace the table
+func pdfHasCrossReference(data []byte) bool {
+	return bytes.Contains(data, []byte("\nxref")) || bytes.Contains(data, []byte("\rxref")) ||
+		pdfXrefStream.Match(data)
+}
+
+// pdfEnd returns the end of the PDF at the start of data, after the last %%EOF
+// whose startxref points at a cross-reference table or stream of this file, so
+// that a PDF carved from the middle of the input doesn't swallow the ones that
+// follow it. Without such a marker it falls back to the last %%EOF; 0 means there
+// is none.
+func pdfEnd(data []byte) int {
+	last := bytes.LastIndex(data, pdfEOF)
+	if last < 0 {
+		return 0
+	}
+
+	for eof, tries := last, 0; eof >= 0 && tries < maxPDFEOFMarkers; tries++ {
+		if pdfStartXrefValid(data, eof) {
+			return pdfMarkerEnd(data, eof)
+		}
+		eof = bytes.LastIndex(data[:eof], pdfEOF)
+	}
+	return pdfMarkerEnd(data, last)
+}
+
+// pdfRevisionEnds returns the ends of the earlier revisions of a PDF updated
+// incrementally: every revision ends with a %%EOF whose startxref points at its
+// cross-reference section, and an update appends the next one after it. The
+// first-page trailer of a linearized PDF, before the main cross-reference table
+// (at offset T), doesn't end a revision.
+func pdfRevisionEnds(data []byte) []int {
+	firstPageEnd := 0
+	if dict := pdfLinearizationDict(data); dict != nil {
+		firstPageEnd, _ = strconv.Atoi(string(pdfDictGet(dict, "T")))
+	}
+
+	var ends []int
+	for i, tries := 0, 0; tries < maxPDFEOFMarkers; tries++ {
+		k := bytes.Index(data[i:], pdfEOF)
+		if k < 0 {
+			break
+		}
+		eof := i + k
+		i = eof + len(pdfEOF)
+		if eof < firstPageEnd || !pdfStartXrefValid(data, eof) {
+			continue
+		}
+		// The last revision is the whole file
+		if end := pdfMarkerEnd(data, eof); pdfSkipSpace(data, end) < len(data) {
+			ends = append(ends, end)
+		}
+	}
+	return ends
+}
+
+// pdfStartXrefValid reports whether the startxref before the %%EOF at eof points
+// at a cross-reference section
+func pdfStartXrefValid(data []byte, eof int) bool {
+	offset, ok := pdfStartXrefOffset(data, eof)
+	if !ok || offset >= eof {
+		return false
+	}
+	return pdfIsCrossReference(data[offset:eof])
+}
+
+// pdfStartXrefOffset returns the offset of the startxref before the %%EOF at eof
+func pdfStartXrefOffset(data []byte, eof int) (int, bool) {
+	from := eof - 64
+	if from < 0 {
+		from = 0
+	}
+	m := pdfStartXref.FindSubmatch(data[from:eof])
+	if m == nil {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(string(m[1]))
+	return offset, err == nil && offset > 0
+}
+
+// pdfIsCrossReference reports whether section starts with a cross-reference
+// table or a cross-reference stream object
+func pdfIsCrossReference(section []byte) bool {
+	if bytes.HasPrefix(bytes.TrimLeft(section, " \t\r\n"), []byte("xref")) {
+		return true
+	}
+	if !pdfObjectHeader.Match(section) {
+		return false
+	}
+	// The stream dictionary follows the object header
+	if len(section) > maxPDFXrefDict {
+		section = section[:maxPDFXrefDict]
+	}
+	if end := bytes.Index(section, []byte("stream")); end > 0 {
+		section = section[:end]
+	}
+	return pdfXrefStream.Match(section)
+}
+
+// pdfMarkerEnd returns the position after the %%EOF at eof and its end of line
+func pdfMarkerEnd(data []byte, eof int) int {
+	end := eof + len(pdfEOF)
+	if end < len(data) {
+		if data[end] == '\r' && end+1 < len(data) && data[end+1] == '\n' {
+			end += 2
+		} else if data[end] == '\r' || data[end] == '\n' {
+			end++
+		}
+	}
+	return end
+}
+
+// pdfObject is an indirect object of a PDF: its value, which is the dictionary
+// for streams, and the undecoded stream data, nil if it isn't a stream
+type pdfObject struct {
+	value  []byte
+	stream []byte
+}
+
+// pdfObjectHeaders finds the "12 0 obj" headers anywhere in a file
+var pdfObjectHeaders = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+
+// parsePDFObjects returns the objects of a PDF by number, including those stored
+// in object streams (PDF 1.5+); when an incremental update redefines an object,
+// the last definition wins. Object streams are decompressed up to
+// maxPDFObjectStreamBytes in all and not after deadline (if set), so that a
+// file of many large streams can't hold up its candidate.
+func parsePDFObjects(data []byte, deadline time.Time) map[int]pdfObject {
+	objects := make(map[int]pdfObject)
+	streamEnd := 0
+	for _, m := range pdfObjectHeaders.FindAllSubmatchIndex(data, -1) {
+		// Stream data may happen to contain an object header
+		if m[0] < streamEnd {
+			continue
+		}
+		if deadlinePassed(deadline) {
+			break
+		}
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		start := pdfSkipSpace(data, m[1])
+		end := pdfValueEnd(data, start)
+		if end <= start {
+			continue
+		}
+		obj := pdfObject{value: data[start:end]}
+		if bytes.HasPrefix(obj.value, []byte("<<")) {
+			obj.stream, streamEnd = pdfStreamData(data, pdfSkipSpace(data, end), obj.value)
+		}
+		objects[num] = obj
+	}
+
+	budget := int64(maxPDFObjectStreamBytes)
+	for _, obj := range objects {
+		if obj.stream == nil || !bytes.Equal(pdfDictGet(obj.value, "Type"), []byte("/ObjStm")) {
+			continue
+		}
+		if budget <= 0 || deadlinePassed(deadline) {
+			break
+		}
+		content, err := pdfDecodeStream(obj, min(budget, maxPreviewStreamSize))
+		if err != nil {
+			continue
+		}
+		budget -= int64(len(content))
+		for num, value := range pdfObjectStreamObjects(obj.value, content) {
+			if _, defined := objects[num]; !defined {
+				objects[num] = pdfObject{value: value}
+			}
+		}
+	}
+	return objects
+}
+
+// pdfTrailerEntry returns the value of a key of the last trailer that has it,
+// the one of the latest revision, or else of the latest cross-reference stream,
+// which holds the trailer entries of PDF 1.5+ files without a trailer
+func pdfTrailerEntry(data []byte, objects map[int]pdfObject, key string) []byte {
+	var value []byte
+	for i, tries := 0, 0; tries < maxPDFEOFMarkers; tries++ {
+		k := bytes.Index(data[i:], []byte("trailer"))
+		if k < 0 {
+			break
+		}
+		i += k + len("trailer")
+		start := pdfSkipSpace(data, i)
+		if v := pdfDictGet(data[start:pdfValueEnd(data, start)], key); v != nil {
+			value = v
+		}
+	}
+	if value != nil {
+		return value
+	}
+
+	xrefNum := -1
+	for num, obj := range objects {
+		if !bytes.Equal(pdfDictGet(obj.value, "Type"), []byte("/XRef")) {
+			continue
+		}
+		if v := pdfDictGet(obj.value, key); v != nil && num > xrefNum {
+			value, xrefNum = v, num
+		}
+	}
+	return value
+}
+
+// pdfStreamData returns the data of the stream whose "stream" keyword is at
+// data[i], using its Length when it is direct and consistent, or else the
+// following endstream keyword, and the position where the data ends
+func pdfStreamData(data []byte, i int, dict []byte) ([]byte, int) {
+	if !bytes.HasPrefix(data[i:], []byte("stream")) {
+		return nil, i
+	}
+	i += len("stream")
+	if i < len(data) && data[i] == '\r' {
+		i++
+	}
+	if i < len(data) && data[i] == '\n' {
+		i++
+	}
+
+	if length, err := strconv.Atoi(string(pdfDictGet(dict, "Length"))); err == nil && length >= 0 && i+length <= len(data) {
+		if bytes.HasPrefix(data[pdfSkipSpace(data, i+length):], []byte("endstream")) {
+			return data[i : i+length], i + length
+		}
+	}
+	end := bytes.Index(data[i:], []byte("endstream"))
+	if end < 0 {
+		return nil, i
+	}
+	return bytes.TrimRight(data[i:i+end], "\r\n"), i + end
+}
+
+// pdfObjectStreamObjects splits the decoded content of an object stream: N pairs
+// of object numbers and offsets, relative to First, followed by the objects
+func pdfObjectStreamObjects(dict, content []byte) map[int][]byte {
+	n, err1 := strconv.Atoi(string(pdfDictGet(dict, "N")))
+	first, err2 := strconv.Atoi(string(pdfDictGet(dict, "First")))
+	if err1 != nil || err2 != nil || first < 0 || first > len(content) {
+		return nil
+	}
+
+	fields := bytes.Fields(content[:first])
+	objects := make(map[int][]byte)
+	for k := 0; k < n && 2*k+1 < len(fields); k++ {
+		num, err1 := strconv.Atoi(string(fields[2*k]))
+		offset, err2 := strconv.Atoi(string(fields[2*k+1]))
+		if err1 != nil || err2 != nil || first+offset >= len(content) || offset < 0 {
+			continue
+		}
+		start := pdfSkipSpace(content, first+offset)
+		if end := pdfValueEnd(content, start); end > start {
+			objects[num] = content[start:end]
+		}
+	}
+	return objects
+}
+
+// pdfDecodeStream returns the decoded data of a stream object, up to limit
+// bytes; only FlateDecode (the filter of nearly all non-image streams) is supported
+func pdfDecodeStream(obj pdfObject, limit int64) ([]byte, error) {
+	filter := pdfDictGet(obj.value, "Filter")
+	switch {
+	case filter == nil:
+		return obj.stream, nil
+	case bytes.Equal(bytes.Trim(filter, "[] \r\n"), []byte("/FlateDecode")):
+		zr, err := zlib.NewReader(bytes.NewReader(obj.stream))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		content, err := io.ReadAll(io.LimitReader(zr, limit))
+		if len(content) == 0 && err != nil {
+			return nil, err
+		}
+		// Truncated streams still give what was decompressed
+		return content, nil
+	}
+	return nil, errors.New("unsupported stream filter " + string(filter))
+}
+
+// pdfResolve follows an indirect reference ("12 0 R") to the object it points
+// at; other values are returned as they are
+func pdfResolve(objects map[int]pdfObject, value []byte) (pdfObject, bool) {
+	if num, ok := pdfRefNumber(value); ok {
+		obj, ok := objects[num]
+		return obj, ok
+	}
+	return pdfObject{value: value}, value != nil
+}
+
+// pdfRefNumber returns the object number of an indirect reference
+func pdfRefNumber(value []byte) (int, bool) {
+	fields := bytes.Fields(value)
+	if len(fields) != 3 || !bytes.Equal(fields[2], []byte("R")) {
+		return 0, false
+	}
+	num, err := strconv.Atoi(string(fields[0]))
+	return num, err == nil
+}
+
+// pdfDictGet returns the raw value of a key of a dictionary, nil if it is missing
+func pdfDictGet(dict []byte, key string) []byte {
+	if !bytes.HasPrefix(dict, []byte("<<")) {
+		return nil
+	}
+	for i := 2; ; {
+		i = pdfSkipSpace(dict, i)
+		if i >= len(dict) || bytes.HasPrefix(dict[i:], []byte(">>")) || dict[i] != '/' {
+			return nil
+		}
+		nameEnd := pdfValueEnd(dict, i)
+		valueStart := pdfSkipSpace(dict, nameEnd)
+		valueEnd := pdfValueEnd(dict, valueStart)
+		if valueEnd <= valueStart {
+			return nil
+		}
+		if string(dict[i+1:nameEnd]) == key {
+			return dict[valueStart:valueEnd]
+		}
+		i = valueEnd
+	}
+}
+
+// pdfSkipSpace skips white space and comments
+func pdfSkipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\r', '\n', '\f', 0:
+			i++
+		case '%':
+			for i < len(data) && data[i] != '\r' && data[i] != '\n' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func pdfIsDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// pdfValueEnd returns the end of the value starting at data[i]: a dictionary,
+// array, string, name, number, indirect reference or keyword
+func pdfValueEnd(data []byte, i int) int {
+	return pdfNestedValueEnd(data, i, 0)
+}
+
+// pdfNestedValueEnd is pdfValueEnd for a value inside depth containers
+func pdfNestedValueEnd(data []byte, i, depth int) int {
+	if i >= len(data) {
+		return i
+	}
+	switch c := data[i]; {
+	case c == '<' && i+1 < len(data) && data[i+1] == '<':
+		return pdfContainerEnd(data, i+2, ">>", depth+1)
+	case c == '[':
+		return pdfContainerEnd(data, i+1, "]", depth+1)
+	case c == '<':
+		if end := bytes.IndexByte(data[i:], '>'); end >= 0 {
+			return i + end + 1
+		}
+		return len(data)
+	case c == '(':
+		_, end := pdfLiteralString(data, i)
+		return end
+	case c == '/':
+		i++
+		for i < len(data) && !pdfIsDelimiter(data[i]) {
+			i++
+		}
+		return i
+	case pdfIsDelimiter(c):
+		// Stray closing delimiter
+		return i
+	}
+
+	end := i
+	for end < len(data) && !pdfIsDelimiter(data[end]) {
+		end++
+	}
+	// "12 0 R" is a single value
+	if _, err := strconv.Atoi(string(data[i:end])); err == nil {
+		genStart := pdfSkipSpace(data, end)
+		genEnd := genStart
+		for genEnd < len(data) && data[genEnd] >= '0' && data[genEnd] <= '9' {
+			genEnd++
+		}
+		if genEnd > genStart {
+			r := pdfSkipSpace(data, genEnd)
+			if r < len(data) && data[r] == 'R' && (r+1 == len(data) || pdfIsDelimiter(data[r+1])) {
+				return r + 1
+			}
+		}
+	}
+	return end
+}
+
+// pdfContainerEnd returns the end of a dictionary or array whose values start
+// at data[i], nested in depth containers counting itself
+func pdfContainerEnd(data []byte, i int, closing string, depth int) int {
+	if depth > maxPDFNesting {
+		return len(data)
+	}
+	for {
+		i = pdfSkipSpace(data, i)
+		if i >= len(data) {
+			return len(data)
+		}
+		if bytes.HasPrefix(data[i:], []byte(closing)) {
+			return i + len(closing)
+		}
+		end := pdfNestedValueEnd(data, i, depth)
+		if end <= i {
+			// Unbalanced delimiter
+			end = i + 1
+		}
+		i = end
+	}
+}
+
+// pdfStringValue decodes a literal or hexadecimal string value
+func pdfStringValue(value []byte) []byte {
+	switch {
+	case bytes.HasPrefix(value, []byte("(")):
+		s, _ := pdfLiteralString(value, 0)
+		return s
+	case bytes.HasPrefix(value, []byte("<")) && !bytes.HasPrefix(value, []byte("<<")):
+		s, _ := pdfHexString(value, 0)
+		return s
+	}
+	return nil
+}
+
+// pdfTextString converts a PDF text string, UTF-16BE if it starts with a byte
+// order mark and PDFDocEncoding (read as Latin-1) otherwise
+func pdfTextString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		units := make([]uint16, 0, len(s)/2)
+		for k := 2; k+1 < len(s); k += 2 {
+			units = append(units, uint16(s[k])<<8|uint16(s[k+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(s))
+	for k, c := range s {
+		runes[k] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,61 @@
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pdfEncryption reports whether a PDF is encrypted, that is its trailer (or
+// cross-reference stream) has an Encrypt dictionary, and returns the security
+// handler and cipher it names, such as "Standard AESV3". Documents that open
+// without a password and only restrict printing or copying are encrypted too.
+func pdfEncryption(data []byte, objects map[int]pdfObject) (string, bool) {
+	if !bytes.Contains(data, []byte("/Encrypt")) {
+		return "", false
+	}
+	ref := pdfTrailerEntry(data, objects, "Encrypt")
+	if ref == nil {
+		return "", false
+	}
+	enc, ok := pdfResolve(objects, ref)
+	if !ok {
+		// The dictionary was cut off, the document is encrypted all the same
+		return "", true
+	}
+	return pdfEncryptionScheme(enc.value), true
+}
+
+// pdfEncryptionScheme describes an Encrypt dictionary: its Filter (the security
+// handler, Standard for passwords and Adobe.PubSec for certificates) and the
+// cipher, RC4 up to version 3 and the method of the crypt filter of streams from
+// version 4 on (V2 for RC4, AESV2 for AES-128, AESV3 for AES-256)
+func pdfEncryptionScheme(dict []byte) string {
+	var parts []string
+	if filter := strings.TrimPrefix(string(pdfDictGet(dict, "Filter")), "/"); filter != "" {
+		parts = append(parts, filter)
+	}
+
+	version, _ := strconv.Atoi(string(pdfDictGet(dict, "V")))
+	switch {
+	case version >= 4:
+		name := strings.TrimPrefix(string(pdfDictGet(dict, "StmF")), "/")
+		method := strings.TrimPrefix(string(pdfDictGet(pdfDictGet(pdfDictGet(dict, "CF"), name), "CFM")), "/")
+		switch {
+		case method == "V2":
+			parts = append(parts, "RC4")
+		case method != "" && method != "None":
+			parts = append(parts, method)
+		case version == 5:
+			parts = append(parts, "AESV3")
+		}
+	case version >= 1:
+		bits := 40
+		if length, err := strconv.Atoi(string(pdfDictGet(dict, "Length"))); err == nil && version > 1 && length > 0 {
+			bits = length
+		}
+		parts = append(parts, fmt.Sprintf("RC4 %d-bit", bits))
+	}
+	return strings.Join(parts, " ")
+}
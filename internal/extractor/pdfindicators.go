@@ -0,0 +1,73 @@
+package extractor
+
+import "splitter-files/internal/models"
+
+// pdfIndicatorNames maps the names that mark weaponized PDFs to their indicators
+var pdfIndicatorNames = map[string]string{
+	"JavaScript": models.PDFIndicatorJavaScript,
+	"JS":         models.PDFIndicatorJavaScript,
+	"OpenAction": models.PDFIndicatorOpenAction,
+	"Launch":     models.PDFIndicatorLaunch,
+}
+
+// pdfIndicators returns the indicators of a PDF, in the order of the
+// PDFIndicator* constants, from the names used in its objects (parsed by
+// parsePDFObjects, so including those in object streams). Names are compared after decoding #xx escapes, which
+// malicious documents use to hide them (/J#61vaScript); strings and stream data
+// are not searched.
+func pdfIndicators(objects map[int]pdfObject) []string {
+	found := make(map[string]bool)
+	for _, obj := range objects {
+		for _, name := range pdfNames(obj.value) {
+			if indicator, ok := pdfIndicatorNames[name]; ok {
+				found[indicator] = true
+			}
+		}
+	}
+
+	var indicators []string
+	for _, indicator := range []string{models.PDFIndicatorJavaScript, models.PDFIndicatorOpenAction, models.PDFIndicatorLaunch} {
+		if found[indicator] {
+			indicators = append(indicators, indicator)
+		}
+	}
+	return indicators
+}
+
+// pdfNames returns the decoded names of a value, skipping strings
+func pdfNames(value []byte) []string {
+	var names []string
+	for i := 0; i < len(value); {
+		switch c := value[i]; {
+		case c == '(':
+			_, i = pdfLiteralString(value, i)
+		case c == '<' && i+1 < len(value) && value[i+1] == '<':
+			i += 2
+		case c == '<':
+			_, i = pdfHexString(value, i)
+		case c == '/':
+			end := pdfValueEnd(value, i)
+			names = append(names, pdfDecodeName(value[i+1:end]))
+			i = end
+		default:
+			i++
+		}
+	}
+	return names
+}
+
+// pdfDecodeName decodes the #xx escapes of a name
+func pdfDecodeName(name []byte) string {
+	decoded := make([]byte, 0, len(name))
+	for k := 0; k < len(name); k++ {
+		if name[k] == '#' && k+2 < len(name) {
+			if hi, lo := hexDigit(name[k+1]), hexDigit(name[k+2]); hi >= 0 && lo >= 0 {
+				decoded = append(decoded, byte(hi<<4|lo))
+				k += 2
+				continue
+			}
+		}
+		decoded = append(decoded, name[k])
+	}
+	return string(decoded)
+}
@@ -0,0 +1,93 @@
+package extractor
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// readPDFInfo reads the version, page count, linearization, encryption and
+// indicators of a PDF; its object streams are read until deadline
+func readPDFInfo(data []byte, deadline time.Time) *models.PDFDocumentInfo {
+	objects := parsePDFObjects(data, deadline)
+	catalog, _ := pdfResolve(objects, pdfTrailerEntry(data, objects, "Root"))
+
+	info := &models.PDFDocumentInfo{
+		Version:    pdfVersion(data, catalog.value),
+		Pages:      pdfPageCount(objects, catalog.value),
+		Linearized: pdfLinearized(data),
+	}
+	info.Encryption, info.IsEncrypted = pdfEncryption(data, objects)
+	info.Indicators = pdfIndicators(objects)
+	return info
+}
+
+// pdfVersion returns the version of a PDF: the one in its header, unless the
+// Version entry of the catalog, which incremental updates use to upgrade a
+// document, names a later one
+func pdfVersion(data, catalog []byte) string {
+	var version string
+	if header := pdfHeaderOffset(data); header >= 0 && header+8 <= len(data) {
+		version = string(data[header+5 : header+8])
+	}
+	if v := strings.TrimPrefix(string(pdfDictGet(catalog, "Version")), "/"); len(v) == 3 && v[1] == '.' && v > version {
+		version = v
+	}
+	return version
+}
+
+// pdfPageCount returns the Count of the root of the page tree, or the number of
+// page objects when the tree can't be followed (a repaired or damaged file)
+func pdfPageCount(objects map[int]pdfObject, catalog []byte) int {
+	if pages, ok := pdfResolve(objects, pdfDictGet(catalog, "Pages")); ok {
+		if count, ok := pdfResolve(objects, pdfDictGet(pages.value, "Count")); ok {
+			if n, err := strconv.Atoi(string(count.value)); err == nil && n >= 0 {
+				return n
+			}
+		}
+	}
+
+	n := 0
+	for _, obj := range objects {
+		if bytes.Equal(pdfDictGet(obj.value, "Type"), []byte("/Page")) {
+			n++
+		}
+	}
+	return n
+}
+
+// pdfLinearized reports whether a PDF is linearized ("fast web view"): its first
+// object is a linearization dictionary whose L is the length of the file. Once an
+// incremental update has been appended the length no longer matches and viewers
+// no longer treat the file as linearized.
+func pdfLinearized(data []byte) bool {
+	dict := pdfLinearizationDict(data)
+	if dict == nil {
+		return false
+	}
+	length, err := strconv.Atoi(string(pdfDictGet(dict, "L")))
+	return err == nil && length == len(data)
+}
+
+// pdfLinearizationDict returns the linearization dictionary of a PDF, the first
+// object of linearized files, nil if there is none
+func pdfLinearizationDict(data []byte) []byte {
+	header := pdfHeaderOffset(data)
+	if header < 0 {
+		return nil
+	}
+	pos := pdfSkipSpace(data, header)
+	m := pdfObjectStart.FindIndex(data[pos:])
+	if m == nil {
+		return nil
+	}
+	start := pdfSkipSpace(data, pos+m[1])
+	dict := data[start:pdfValueEnd(data, start)]
+	if pdfDictGet(dict, "Linearized") == nil {
+		return nil
+	}
+	return dict
+}
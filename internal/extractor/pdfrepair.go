@@ -0,0 +1,216 @@
+package extractor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// maxPDFObjectNumber is the largest object number a PDF may use
+const maxPDFObjectNumber = 8388607
+
+// pdfObjectStart is the "12 0 obj" header of the object at the current position
+var pdfObjectStart = regexp.MustCompile(`^(\d+)\s+(\d+)\s+obj\b`)
+
+// detectTruncatedPDF carves a PDF the validator rejected because it is cut off
+// before its cross-reference section or %%EOF: the complete objects after the
+// header are kept, and a cross-reference table and a trailer pointing at the
+// catalog are appended to them. The result covers the bytes taken from the input
+// while the returned content is the repaired copy. Its object streams are read
+// until deadline.
+func detectTruncatedPDF(data []byte, allowedExtensions map[string]bool, deadline time.Time) (*models.ExtractionResult, []byte, error) {
+	const minFileSize = 2 * 1024
+
+	if len(allowedExtensions) > 0 && !allowedExtensions["pdf"] {
+		return nil, nil, errNoSignature
+	}
+	// Without a startxref there is no telling whether junk before the header
+	// belongs to the file
+	if len(data) < 8 || !bytes.HasPrefix(data, []byte("%PDF-")) || string(data[5:8]) < "1.0" || string(data[5:8]) > "2.0" {
+		return nil, nil, errors.New("not a PDF")
+	}
+
+	walk := walkPDF(data)
+	if walk.root < 0 {
+		return nil, nil, errors.New("truncated PDF without a catalog")
+	}
+	end := walk.end
+	if end < minFileSize {
+		return nil, nil, fmt.Errorf("%w (%d bytes, less than %d)", errTooSmall, end, minFileSize)
+	}
+
+	repaired := pdfRebuildTrailer(data[:end], walk)
+	info := readPDFInfo(repaired, deadline)
+	info.Repaired = true
+	return &models.ExtractionResult{
+		Size:      end,
+		End:       end,
+		FileType:  "PDF Document",
+		Extension: "pdf",
+		PDFInfo:   info,
+	}, repaired, nil
+}
+
+// pdfObjectOffset is where an object of a PDF starts, and its generation
+type pdfObjectOffset struct {
+	offset     int
+	generation int
+}
+
+// pdfWalk is what walkPDF found of a PDF
+type pdfWalk struct {
+	// end is the end of the last complete object or trailer part
+	end     int
+	offsets map[int]pdfObjectOffset
+	// root is the number of the catalog, -1 if there is none
+	root int
+	// trailer is the last trailer dictionary, nil if there is none
+	trailer []byte
+	// complete is set when the last part walked is a startxref, as in whole PDFs
+	complete bool
+}
+
+// walkPDF walks the objects that follow each other from the header of a PDF,
+// along with the cross-reference tables and trailers between them, and stops at
+// the first one that is cut off or isn't part of a PDF
+func walkPDF(data []byte) pdfWalk {
+	walk := pdfWalk{offsets: make(map[int]pdfObjectOffset), root: -1}
+	if header := pdfHeaderOffset(data); header > 0 {
+		walk.end = header
+	}
+	// %%EOF markers are skipped as comments
+	for pos := pdfSkipSpace(data, walk.end); pos < len(data); pos = pdfSkipSpace(data, walk.end) {
+		switch {
+		case bytes.HasPrefix(data[pos:], []byte("xref")):
+			// The entries, numbers and n or f, run up to the trailer
+			i := bytes.Index(data[pos:], []byte("trailer"))
+			if i < 0 || len(bytes.Trim(data[pos+len("xref"):pos+i], "0123456789nf \t\r\n")) > 0 {
+				return walk
+			}
+			walk.end = pos + i
+			walk.complete = false
+			continue
+		case bytes.HasPrefix(data[pos:], []byte("trailer")):
+			i := pdfSkipSpace(data, pos+len("trailer"))
+			dictEnd := pdfValueEnd(data, i)
+			if !bytes.HasPrefix(data[i:], []byte("<<")) || dictEnd >= len(data) {
+				return walk
+			}
+			walk.trailer = data[i:dictEnd]
+			walk.end = dictEnd
+			walk.complete = false
+			continue
+		case bytes.HasPrefix(data[pos:], []byte("startxref")):
+			i := pdfSkipSpace(data, pos+len("startxref"))
+			numEnd := pdfValueEnd(data, i)
+			if _, err := strconv.Atoi(string(data[i:numEnd])); err != nil {
+				return walk
+			}
+			walk.end = numEnd
+			walk.complete = true
+			continue
+		}
+
+		m := pdfObjectStart.FindSubmatchIndex(data[pos:])
+		if m == nil {
+			break
+		}
+		num, err1 := strconv.Atoi(string(data[pos+m[2] : pos+m[3]]))
+		generation, err2 := strconv.Atoi(string(data[pos+m[4] : pos+m[5]]))
+		if err1 != nil || err2 != nil || num > maxPDFObjectNumber || generation > 65535 {
+			break
+		}
+
+		valueStart := pdfSkipSpace(data, pos+m[1])
+		valueEnd := pdfValueEnd(data, valueStart)
+		if valueEnd <= valueStart || valueEnd >= len(data) {
+			break
+		}
+		value := data[valueStart:valueEnd]
+
+		i := pdfSkipSpace(data, valueEnd)
+		if bytes.HasPrefix(data[i:], []byte("stream")) {
+			stream, streamEnd := pdfStreamData(data, i, value)
+			if stream == nil {
+				break
+			}
+			// Without a consistent Length the stream ends at the next endstream,
+			// which must not belong to a file that follows
+			length, err := strconv.Atoi(string(pdfDictGet(value, "Length")))
+			if (err != nil || length != len(stream)) && bytes.Contains(stream, []byte("%PDF-")) {
+				break
+			}
+			i = pdfSkipSpace(data, streamEnd)
+			if !bytes.HasPrefix(data[i:], []byte("endstream")) {
+				break
+			}
+			i = pdfSkipSpace(data, i+len("endstream"))
+		}
+		if !bytes.HasPrefix(data[i:], []byte("endobj")) {
+			break
+		}
+
+		walk.offsets[num] = pdfObjectOffset{offset: pos, generation: generation}
+		if bytes.Equal(pdfDictGet(value, "Type"), []byte("/Catalog")) {
+			walk.root = num
+		}
+		// The trailer of cross-reference streams is their dictionary
+		if bytes.Equal(pdfDictGet(value, "Type"), []byte("/XRef")) {
+			walk.trailer = value
+		}
+		walk.end = i + len("endobj")
+		walk.complete = false
+	}
+	return walk
+}
+
+// pdfCutOff reports whether a carved PDF is in fact cut off and runs into
+// another PDF, up to the %%EOF of that one: its structure breaks off, other than
+// after a startxref, before the header of the next
+func pdfCutOff(data []byte) bool {
+	walk := walkPDF(data)
+	return !walk.complete && pdfSkipSpace(data, walk.end) < len(data) && bytes.Contains(data[walk.end:], []byte("%PDF-"))
+}
+
+// pdfRebuildTrailer appends a cross-reference table of the objects walked, a
+// trailer and the startxref and %%EOF markers to the objects of a truncated PDF.
+// The trailer points at the catalog and keeps the Info, Encrypt and ID entries
+// of the last trailer found, without which encrypted documents can't be opened.
+func pdfRebuildTrailer(objects []byte, walk pdfWalk) []byte {
+	offsets := walk.offsets
+	size := 0
+	for num := range offsets {
+		if num >= size {
+			size = num + 1
+		}
+	}
+
+	var b bytes.Buffer
+	b.Write(objects)
+	b.WriteByte('\n')
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n", size)
+	for num := 0; num < size; num++ {
+		switch obj, ok := offsets[num]; {
+		case ok:
+			fmt.Fprintf(&b, "%010d %05d n \n", obj.offset, obj.generation)
+		case num == 0:
+			b.WriteString("0000000000 65535 f \n")
+		default:
+			b.WriteString("0000000000 00000 f \n")
+		}
+	}
+	fmt.Fprintf(&b, "trailer\n<</Size %d/Root %d %d R", size, walk.root, offsets[walk.root].generation)
+	for _, key := range []string{"Info", "Encrypt", "ID"} {
+		if value := pdfDictGet(walk.trailer, key); value != nil {
+			fmt.Fprintf(&b, "/%s %s", key, value)
+		}
+	}
+	fmt.Fprintf(&b, ">>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return b.Bytes()
+}
@@ -0,0 +1,463 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+var pgpArmorMagic = []byte("-----BEGIN PGP ")
+
+const pgpMaxArmorSize = 16 * 1024 * 1024
+
+// pgpArmorLabels maps armor header types to their descriptions
+var pgpArmorLabels = map[string]string{
+	"MESSAGE":           "PGP Message (ASCII armor)",
+	"PUBLIC KEY BLOCK":  "PGP Public Key (ASCII armor)",
+	"PRIVATE KEY BLOCK": "PGP Private Key (ASCII armor)",
+	"SIGNATURE":         "PGP Signature (ASCII armor)",
+	"SIGNED MESSAGE":    "PGP Signed Message",
+}
+
+// OpenPGP packet tags (RFC 4880)
+const (
+	pgpTagPKESK         = 1
+	pgpTagSignature     = 2
+	pgpTagSKESK         = 3
+	pgpTagSecretKey     = 5
+	pgpTagPublicKey     = 6
+	pgpTagSecretSubkey  = 7
+	pgpTagCompressed    = 8
+	pgpTagSymEncrypted  = 9
+	pgpTagTrust         = 12
+	pgpTagUserID        = 13
+	pgpTagPublicSubkey  = 14
+	pgpTagUserAttribute = 17
+	pgpTagSEIPD         = 18
+	pgpTagAEAD          = 20
+)
+
+var pgpKeyTags = map[int]bool{
+	pgpTagSignature: true, pgpTagSecretKey: true, pgpTagPublicKey: true, pgpTagSecretSubkey: true,
+	pgpTagTrust: true, pgpTagUserID: true, pgpTagPublicSubkey: true, pgpTagUserAttribute: true,
+}
+
+func pgpArmorLabel(data []byte) string {
+	if !bytes.HasPrefix(data, pgpArmorMagic) {
+		return ""
+	}
+
+	rest := data[len(pgpArmorMagic):]
+	if len(rest) > 32 {
+		rest = rest[:32]
+	}
+	idx := bytes.Index(rest, []byte("-----"))
+	if idx == -1 {
+		return ""
+	}
+	return string(rest[:idx])
+}
+
+// pgpArmorSize returns the length of the armored block up to and including its END line
+func pgpArmorSize(data []byte) int {
+	label := pgpArmorLabel(data)
+	if _, ok := pgpArmorLabels[label]; !ok {
+		return 0
+	}
+
+	// Clear-signed text is closed by the signature block
+	endLabel := label
+	if label == "SIGNED MESSAGE" {
+		endLabel = "SIGNATURE"
+	}
+	endLine := []byte("-----END PGP " + endLabel + "-----")
+
+	window := data
+	if len(window) > pgpMaxArmorSize {
+		window = window[:pgpMaxArmorSize]
+	}
+	idx := bytes.Index(window, endLine)
+	if idx == -1 {
+		return 0
+	}
+
+	end := idx + len(endLine)
+	if end < len(data) && data[end] == '\r' {
+		end++
+	}
+	if end < len(data) && data[end] == '\n' {
+		end++
+	}
+	return end
+}
+
+func validatePGPArmor(data []byte) bool {
+	return pgpArmorSize(data) > 0
+}
+
+// pgpPacket parses an OpenPGP packet header. For partial body lengths the
+// returned length is the first chunk only.
+func pgpPacket(data []byte) (tag int, headerLen int, bodyLen int, partial bool, ok bool) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, 0, 0, false, false
+	}
+
+	if data[0]&0x40 == 0 {
+		// Old format: tag in bits 5-2, length type in bits 1-0
+		tag = int(data[0]>>2) & 0x0F
+		switch data[0] & 0x03 {
+		case 0:
+			return tag, 2, int(data[1]), false, true
+		case 1:
+			if len(data) < 3 {
+				return 0, 0, 0, false, false
+			}
+			return tag, 3, int(binary.BigEndian.Uint16(data[1:3])), false, true
+		case 2:
+			if len(data) < 5 {
+				return 0, 0, 0, false, false
+			}
+			return tag, 5, int(binary.BigEndian.Uint32(data[1:5])), false, true
+		}
+		// Indeterminate length can't be carved reliably
+		return 0, 0, 0, false, false
+	}
+
+	tag = int(data[0] & 0x3F)
+	length, lengthSize, partial, ok := pgpNewLength(data[1:])
+	return tag, 1 + lengthSize, length, partial, ok
+}
+
+// pgpNewLength decodes a new-format body length
+func pgpNewLength(data []byte) (int, int, bool, bool) {
+	if len(data) < 1 {
+		return 0, 0, false, false
+	}
+
+	switch first := int(data[0]); {
+	case first < 192:
+		return first, 1, false, true
+	case first < 224:
+		if len(data) < 2 {
+			return 0, 0, false, false
+		}
+		return (first-192)<<8 + int(data[1]) + 192, 2, false, true
+	case first < 255:
+		return 1 << (first & 0x1F), 1, true, true
+	default:
+		if len(data) < 5 {
+			return 0, 0, false, false
+		}
+		return int(binary.BigEndian.Uint32(data[1:5])), 5, false, true
+	}
+}
+
+// pgpPacketsSize walks consecutive packets with the allowed tags and returns
+// the length of the sequence
+func pgpPacketsSize(data []byte, allowedTags map[int]bool) int {
+	pos := 0
+	for pos < len(data) {
+		tag, headerLen, bodyLen, partial, ok := pgpPacket(data[pos:])
+		if !ok || !allowedTags[tag] {
+			break
+		}
+
+		end := pos + headerLen + bodyLen
+		for partial {
+			if end > len(data) {
+				return pos
+			}
+			var lengthSize int
+			bodyLen, lengthSize, partial, ok = pgpNewLength(data[end:])
+			if !ok {
+				return pos
+			}
+			end += lengthSize + bodyLen
+		}
+
+		if end > len(data) {
+			break
+		}
+		pos = end
+	}
+	return pos
+}
+
+// pgpFirstPacket returns the tag and body of the first packet
+func pgpFirstPacket(data []byte) (int, []byte, bool) {
+	tag, headerLen, bodyLen, partial, ok := pgpPacket(data)
+	if !ok || partial || headerLen+bodyLen > len(data) {
+		return 0, nil, false
+	}
+	return tag, data[headerLen : headerLen+bodyLen], true
+}
+
+func validatePGPBinaryKey(data []byte) bool {
+	tag, body, ok := pgpFirstPacket(data)
+	if !ok || (tag != pgpTagPublicKey && tag != pgpTagSecretKey) || len(body) < 6 {
+		return false
+	}
+
+	// version, creation time, public key algorithm
+	if body[0] != 4 && body[0] != 5 {
+		return false
+	}
+	switch body[5] {
+	case 1, 2, 3, 16, 17, 18, 19, 22, 25, 26, 27, 28:
+	default:
+		return false
+	}
+
+	// A transferable key carries at least one user ID after the primary key
+	size := pgpPacketsSize(data, pgpKeyTags)
+	return hasPGPUserID(data[:size])
+}
+
+// hasPGPUserID reports whether a key sequence contains a user ID packet
+func hasPGPUserID(data []byte) bool {
+	for pos := 0; pos < len(data); {
+		tag, headerLen, bodyLen, _, ok := pgpPacket(data[pos:])
+		if !ok {
+			return false
+		}
+		if tag == pgpTagUserID {
+			return true
+		}
+		pos += headerLen + bodyLen
+	}
+	return false
+}
+
+func validatePGPMessage(data []byte) bool {
+	return pgpMessageSize(data) > 0
+}
+
+// pgpMessageSize returns the length of an encrypted message: session key
+// packets of known algorithms followed by one encrypted data packet that ends
+// within data, or 0 if data doesn't start with such a message
+func pgpMessageSize(data []byte) int {
+	pos := 0
+	for pos < len(data) {
+		tag, headerLen, bodyLen, partial, ok := pgpPacket(data[pos:])
+		end := pos + headerLen + bodyLen
+		if !ok || partial || end > len(data) {
+			break
+		}
+		body := data[pos+headerLen : end]
+		if !(tag == pgpTagPKESK && validPGPPKESK(body)) && !(tag == pgpTagSKESK && validPGPSKESK(body)) {
+			break
+		}
+		pos = end
+	}
+	if pos == 0 {
+		return 0
+	}
+	return pgpEncryptedDataEnd(data, pos)
+}
+
+// validPGPPKESK checks a version 3 public-key encrypted session key packet:
+// key ID, a known algorithm and the encrypted key filling the rest exactly
+func validPGPPKESK(body []byte) bool {
+	if len(body) < 12 || body[0] != 3 {
+		return false
+	}
+
+	rest := body[10:]
+	switch body[9] {
+	case 1, 2: // RSA: m^e mod n
+		n, ok := pgpMPISize(rest)
+		return ok && n == len(rest)
+	case 16: // Elgamal: g^k mod p, m * y^k mod p
+		n, ok := pgpMPISize(rest)
+		if !ok {
+			return false
+		}
+		m, ok := pgpMPISize(rest[n:])
+		return ok && n+m == len(rest)
+	case 18: // ECDH: ephemeral point, then the wrapped key with its length
+		n, ok := pgpMPISize(rest)
+		return ok && n < len(rest) && n+1+int(rest[n]) == len(rest) && rest[n] > 0
+	}
+	return false
+}
+
+// validPGPSKESK checks a symmetric-key encrypted session key packet, version 4
+// (RFC 4880) or 5 (AEAD, RFC 4880bis): a known cipher and string-to-key
+// specifier, and for version 5 an AEAD mode with its IV and tag
+func validPGPSKESK(body []byte) bool {
+	if len(body) < 4 || !pgpCiphers[body[1]] {
+		return false
+	}
+
+	switch body[0] {
+	case 4:
+		n := pgpS2KSize(body[2:])
+		return n > 0 && 2+n <= len(body)
+	case 5:
+		ivSize := pgpAEADIVSizes[body[2]]
+		n := pgpS2KSize(body[3:])
+		// The encrypted session key and the authentication tag follow the IV
+		return ivSize > 0 && n > 0 && 3+n+ivSize+32 <= len(body)
+	}
+	return false
+}
+
+var (
+	// pgpCiphers are the symmetric algorithms: IDEA, 3DES, CAST5, Blowfish,
+	// AES-128/192/256, Twofish and Camellia-128/192/256
+	pgpCiphers = map[byte]bool{1: true, 2: true, 3: true, 4: true, 7: true, 8: true, 9: true, 10: true, 11: true, 12: true, 13: true}
+	// pgpHashes are the hash algorithms: MD5, SHA-1, RIPEMD-160 and SHA-2
+	pgpHashes = map[byte]bool{1: true, 2: true, 3: true, 8: true, 9: true, 10: true, 11: true}
+	// pgpAEADIVSizes maps the AEAD modes EAX, OCB and GCM to their IV sizes
+	pgpAEADIVSizes = map[byte]int{1: 16, 2: 15, 3: 12}
+)
+
+// pgpS2KSize returns the length of a simple, salted or iterated and salted
+// string-to-key specifier with a known hash, or 0
+func pgpS2KSize(data []byte) int {
+	if len(data) < 2 || !pgpHashes[data[1]] {
+		return 0
+	}
+	size := map[byte]int{0: 2, 1: 10, 3: 11}[data[0]]
+	if size > len(data) {
+		return 0
+	}
+	return size
+}
+
+// pgpMPISize returns the length of a multiprecision integer: a bit count and
+// the bytes holding them, whose first byte has the top bit of the count set
+func pgpMPISize(data []byte) (int, bool) {
+	if len(data) < 3 {
+		return 0, false
+	}
+	bits := int(binary.BigEndian.Uint16(data))
+	size := 2 + (bits+7)/8
+	if bits == 0 || size > len(data) || data[2]>>((bits-1)%8) != 1 {
+		return 0, false
+	}
+	return size, true
+}
+
+// pgpEncryptedDataEnd returns the end of the encrypted data packet at pos -
+// symmetrically encrypted, integrity protected (version 1 or 2) or AEAD - after
+// following its partial body chunks, or 0 if there is none or it runs past data
+func pgpEncryptedDataEnd(data []byte, pos int) int {
+	tag, headerLen, bodyLen, partial, ok := pgpPacket(data[pos:])
+	if !ok || pos+headerLen >= len(data) {
+		return 0
+	}
+	switch version := data[pos+headerLen]; tag {
+	case pgpTagSymEncrypted:
+	case pgpTagSEIPD:
+		if version != 1 && version != 2 {
+			return 0
+		}
+	case pgpTagAEAD:
+		if version != 1 {
+			return 0
+		}
+	default:
+		return 0
+	}
+	// The first chunk of a partial body is at least 512 bytes (RFC 4880 4.2.2.4)
+	if partial && bodyLen < 512 {
+		return 0
+	}
+
+	end := pos + headerLen + bodyLen
+	for partial {
+		if end >= len(data) {
+			return 0
+		}
+		var lengthSize int
+		bodyLen, lengthSize, partial, ok = pgpNewLength(data[end:])
+		if !ok {
+			return 0
+		}
+		end += lengthSize + bodyLen
+	}
+	if end > len(data) {
+		return 0
+	}
+	return end
+}
+
+// pgpBinarySize returns the length of the packet sequence and its description
+func pgpBinarySize(data []byte) (int, string) {
+	tag, _, _ := pgpFirstPacket(data)
+	switch tag {
+	case pgpTagPublicKey:
+		return pgpPacketsSize(data, pgpKeyTags), "OpenPGP Public Key"
+	case pgpTagSecretKey:
+		return pgpPacketsSize(data, pgpKeyTags), "OpenPGP Secret Key"
+	}
+	return pgpMessageSize(data), "OpenPGP Encrypted Message"
+}
+
+// Binary OpenPGP data has no magic number beyond its first packet header, a
+// single byte far too common to search for. Its signatures match the header,
+// the length and the version of the first packet as written for the key
+// types in use instead: packets of other lengths, such as keys of unusual
+// sizes or a key whose integers happen to be a byte short, are not found.
+var (
+	// pgpPublicKeyMagics start version 4 public keys, old (0x98, 0x99) and new
+	// (0xC6) format: RSA 1024/2048/3072/4096 with e = 65537, Ed25519 and ECDSA
+	// P-256/P-384/P-521
+	pgpPublicKeyMagics = [][]byte{
+		{0x98, 0x8D, 0x04}, {0x99, 0x01, 0x0D, 0x04}, {0x99, 0x01, 0x8D, 0x04}, {0x99, 0x02, 0x0D, 0x04},
+		{0x98, 0x33, 0x04}, {0x98, 0x52, 0x04}, {0x98, 0x6F, 0x04}, {0x98, 0x93, 0x04},
+		{0xC6, 0x8D, 0x04}, {0xC6, 0xC0, 0x4D, 0x04}, {0xC6, 0xC0, 0xCD, 0x04}, {0xC6, 0xC1, 0x4D, 0x04},
+		{0xC6, 0x33, 0x04}, {0xC6, 0x52, 0x04}, {0xC6, 0x6F, 0x04}, {0xC6, 0x93, 0x04},
+	}
+	// pgpSecretKeyMagics start secret keys, whose length depends on their
+	// protection: RSA 2048-4096 keys of 768 to 2047 bytes by the header and
+	// high length byte, and Ed25519 and ECDSA P-256 keys with and without a
+	// passphrase by their exact length and version
+	pgpSecretKeyMagics = [][]byte{
+		{0x95, 0x03}, {0x95, 0x04}, {0x95, 0x05}, {0x95, 0x06}, {0x95, 0x07},
+		{0xC5, 0xC2}, {0xC5, 0xC3}, {0xC5, 0xC4}, {0xC5, 0xC5}, {0xC5, 0xC6}, {0xC5, 0xC7},
+		{0x94, 0x58, 0x04}, {0x94, 0x86, 0x04}, {0x94, 0x77, 0x04}, {0x94, 0xA5, 0x04},
+		{0xC5, 0x58, 0x04}, {0xC5, 0x86, 0x04}, {0xC5, 0x77, 0x04}, {0xC5, 0xA5, 0x04},
+	}
+	// pgpMessageMagics start encrypted messages: version 3 public-key session
+	// keys for RSA 1024/2048/3072/4096 and ECDH Curve25519/P-256/P-384/P-521,
+	// version 4 symmetric session keys with simple, salted and iterated
+	// string-to-key, and version 5 ones with AES-128/256 in EAX or OCB mode
+	pgpMessageMagics = [][]byte{
+		{0x84, 0x8C, 0x03}, {0x85, 0x01, 0x0C, 0x03}, {0x85, 0x01, 0x8C, 0x03}, {0x85, 0x02, 0x0C, 0x03},
+		{0x84, 0x5E, 0x03}, {0x84, 0x7E, 0x03}, {0x84, 0x9E, 0x03}, {0x84, 0xC2, 0x03},
+		{0xC1, 0x8C, 0x03}, {0xC1, 0xC0, 0x4C, 0x03}, {0xC1, 0xC0, 0xCC, 0x03}, {0xC1, 0xC1, 0x4C, 0x03},
+		{0xC1, 0x5E, 0x03}, {0xC1, 0x7E, 0x03}, {0xC1, 0x9E, 0x03}, {0xC1, 0xC0, 0x02, 0x03},
+		{0x8C, 0x04, 0x04}, {0x8C, 0x0C, 0x04}, {0x8C, 0x0D, 0x04},
+		{0xC3, 0x04, 0x04}, {0xC3, 0x0C, 0x04}, {0xC3, 0x0D, 0x04},
+		{0xC3, 0x3D, 0x05}, {0xC3, 0x3E, 0x05}, {0xC3, 0x4D, 0x05}, {0xC3, 0x4E, 0x05},
+	}
+)
+
+// Minimum sizes of binary OpenPGP data: a key with its user ID and
+// self-signature, and a session key with a little encrypted data
+const (
+	pgpKeyMinSize     = 192
+	pgpMessageMinSize = 128
+)
+
+// pgpSignatures returns the gpg signatures, a signature per magic
+func pgpSignatures() []FileSignature {
+	var sigs []FileSignature
+	add := func(magics [][]byte, validator func([]byte) bool, minSize int) {
+		for _, magic := range magics {
+			sigs = append(sigs, FileSignature{
+				Extension:   "gpg",
+				MagicNumber: magic,
+				Offset:      0,
+				Validator:   validator,
+				MinSize:     minSize,
+				WeakMagic:   true,
+			})
+		}
+	}
+	add(pgpPublicKeyMagics, validatePGPBinaryKey, pgpKeyMinSize)
+	add(pgpSecretKeyMagics, validatePGPBinaryKey, pgpKeyMinSize)
+	add(pgpMessageMagics, validatePGPMessage, pgpMessageMinSize)
+	return sigs
+}
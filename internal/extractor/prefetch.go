@@ -0,0 +1,73 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+var (
+	prefetchMagic           = []byte("SCCA")
+	prefetchCompressedMagic = []byte{'M', 'A', 'M', 0x04}
+)
+
+const (
+	prefetchHeaderSize        = 84
+	prefetchMaxSize           = 16 * 1024 * 1024
+	prefetchCompressedHdrSize = 8
+)
+
+// validatePrefetch checks the uncompressed SCCA header (XP up to Windows 11)
+func validatePrefetch(data []byte) bool {
+	if len(data) < prefetchHeaderSize || !bytes.Equal(data[4:8], prefetchMagic) {
+		return false
+	}
+
+	switch binary.LittleEndian.Uint32(data[0:4]) {
+	case 17, 23, 26, 30, 31:
+	default:
+		return false
+	}
+
+	size := binary.LittleEndian.Uint32(data[12:16])
+	if size < prefetchHeaderSize || size > prefetchMaxSize {
+		return false
+	}
+
+	// Executable name is a NUL-terminated UTF-16LE string of ASCII-ish characters
+	name := data[16:76]
+	if name[0] == 0 || name[1] != 0 {
+		return false
+	}
+	for i := 0; i+1 < len(name); i += 2 {
+		if name[i] == 0 && name[i+1] == 0 {
+			break
+		}
+		if name[i] < 0x20 || name[i+1] != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateCompressedPrefetch checks the MAM header used by Windows 10+ (Xpress Huffman)
+func validateCompressedPrefetch(data []byte) bool {
+	if len(data) < prefetchCompressedHdrSize || !bytes.HasPrefix(data, prefetchCompressedMagic) {
+		return false
+	}
+
+	size := binary.LittleEndian.Uint32(data[4:8])
+	return size >= prefetchHeaderSize && size <= prefetchMaxSize
+}
+
+// prefetchFileSize returns the declared size of an uncompressed prefetch file or,
+// for a compressed one, the upper bound given by the decompressed size
+func prefetchFileSize(data []byte) int {
+	switch {
+	case validatePrefetch(data):
+		return int(binary.LittleEndian.Uint32(data[12:16]))
+	case validateCompressedPrefetch(data):
+		return int(binary.LittleEndian.Uint32(data[4:8])) + prefetchCompressedHdrSize
+	}
+	return 0
+}
@@ -0,0 +1,816 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"html"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PreviewLength is the number of characters kept in a text preview
+const PreviewLength = 500
+
+// maxPreviewStreamSize bounds the decompressed size of a PDF content stream
+// read for a preview
+const maxPreviewStreamSize = 4 << 20
+
+// TextPreview returns the first PreviewLength characters of the text of a
+// document, with runs of whitespace collapsed, or "" if its format has no
+// readable text
+func TextPreview(ext string, data []byte) string {
+	p := &previewText{}
+	switch ext {
+	case "docx", "docm", "dotx", "dotm":
+		zipPartsText(p, data, "t", func(name string) bool { return name == "word/document.xml" })
+	case "xlsx", "xlsm", "xltx", "xltm":
+		zipPartsText(p, data, "t", func(name string) bool { return name == "xl/sharedStrings.xml" })
+	case "pptx", "pptm", "potx", "potm":
+		zipPartsText(p, data, "t", isSlidePart)
+	case "odt", "ods", "ots", "odp":
+		zipPartsText(p, data, "", func(name string) bool { return name == "content.xml" })
+	case "fods":
+		xmlText(p, bytes.NewReader(data), "")
+	case "doc", "dot":
+		wordBinaryText(p, data)
+	case "ppt", "pot":
+		pptBinaryText(p, data)
+	case "xls", "xlt":
+		biffSharedStringsText(p, data)
+	case "pdf":
+		pdfText(p, data)
+	case "rtf":
+		rtfText(p, data)
+	case "html":
+		htmlText(p, data)
+	}
+	return p.String()
+}
+
+// previewText accumulates the text of a preview up to PreviewLength characters;
+// whitespace and control characters become single spaces between words
+type previewText struct {
+	b     strings.Builder
+	n     int
+	space bool
+}
+
+func (p *previewText) full() bool {
+	return p.n >= PreviewLength
+}
+
+func (p *previewText) WriteRune(r rune) {
+	switch {
+	case p.full() || r == utf8.RuneError:
+	case unicode.IsSpace(r) || unicode.IsControl(r):
+		p.space = p.n > 0
+	case unicode.IsPrint(r):
+		if p.space {
+			p.b.WriteByte(' ')
+			p.n++
+			p.space = false
+		}
+		p.b.WriteRune(r)
+		p.n++
+	}
+}
+
+func (p *previewText) WriteString(s string) {
+	for _, r := range s {
+		p.WriteRune(r)
+	}
+}
+
+// WriteLatin1 writes single-byte text (Windows-1252 or PDFDocEncoding, read as
+// Latin-1)
+func (p *previewText) WriteLatin1(b []byte) {
+	for _, c := range b {
+		p.WriteRune(rune(c))
+	}
+}
+
+func (p *previewText) WriteUTF16LE(b []byte) {
+	p.WriteString(string(decodeUTF16(b, nil)))
+}
+
+// Break separates the words written before and after it
+func (p *previewText) Break() {
+	p.space = p.n > 0
+}
+
+func (p *previewText) String() string {
+	return p.b.String()
+}
+
+// zipPartsText writes the text of the XML parts of a package selected by
+// wanted, in the order of their names
+func zipPartsText(p *previewText, data []byte, textElement string, wanted func(name string) bool) {
+	zipReader, err := openZip(data)
+	if err != nil {
+		return
+	}
+
+	var parts []*zip.File
+	for _, file := range zipReader.File {
+		if wanted(file.Name) {
+			parts = append(parts, file)
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return partNumber(parts[i].Name) < partNumber(parts[j].Name) })
+
+	for _, part := range parts {
+		if p.full() {
+			return
+		}
+		rc, err := openEntry(part)
+		if err != nil {
+			continue
+		}
+		xmlText(p, rc, textElement)
+		rc.Close()
+		p.Break()
+	}
+}
+
+// isSlidePart matches the slides of a PresentationML package, ppt/slides/slideN.xml
+func isSlidePart(name string) bool {
+	return strings.HasPrefix(name, "ppt/slides/slide") && strings.HasSuffix(name, ".xml")
+}
+
+// partNumber returns the number in a part name like ppt/slides/slide12.xml,
+// so that slide10 comes after slide9
+func partNumber(name string) int {
+	base := strings.TrimSuffix(path.Base(name), ".xml")
+	end := len(base)
+	for end > 0 && base[end-1] >= '0' && base[end-1] <= '9' {
+		end--
+	}
+	n, _ := strconv.Atoi(base[end:])
+	return n
+}
+
+// xmlText writes the character data of an XML document: all of it, or only that
+// of the elements with the local name textElement (w:t, a:t); paragraphs, table
+// cells, line breaks and tabs separate words
+func xmlText(p *previewText, r io.Reader, textElement string) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	inText := textElement == ""
+	for !p.full() {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case textElement:
+				inText = true
+			case "tab", "br", "s", "line-break":
+				p.Break()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case textElement:
+				inText = textElement == ""
+			case "p", "h", "si", "tc", "table-cell":
+				p.Break()
+			}
+		case xml.CharData:
+			if inText {
+				p.WriteString(string(t))
+			}
+		}
+	}
+}
+
+// FIB offsets of a Word 97 document: csw starts the variable part whose
+// FibRgFcLcb97 holds the location of the piece table (fcClx/lcbClx, pair 33)
+const (
+	fibCswOffset = 0x20
+	fibClxPair   = 33
+	fibCcpText   = 3
+)
+
+// wordBinaryText writes the main document text of a Word 97-2003 file, which
+// the piece table in the table stream assembles from pieces of the WordDocument
+// stream
+func wordBinaryText(p *previewText, data []byte) {
+	c, err := parseCFB(data)
+	if err != nil {
+		return
+	}
+	word, err := c.ReadStream(c.Lookup("WordDocument"))
+	if err != nil || len(word) < fibBaseSize {
+		return
+	}
+	flags := binary.LittleEndian.Uint16(word[fibFlagsOffset:])
+	if flags&fibFlagEncrypted != 0 {
+		return
+	}
+	tableName := "0Table"
+	if flags&fibFlagWhichTbl != 0 {
+		tableName = "1Table"
+	}
+	table, err := c.ReadStream(c.Lookup(tableName))
+	if err != nil {
+		return
+	}
+
+	// FibBase, csw + fibRgW, cslw + fibRgLw, cbRgFcLcb + fibRgFcLcbBlob
+	offset := fibCswOffset
+	if offset+2 > len(word) {
+		return
+	}
+	offset += 2 + 2*int(binary.LittleEndian.Uint16(word[offset:]))
+	if offset+2 > len(word) {
+		return
+	}
+	rgLw := offset + 2
+	offset = rgLw + 4*int(binary.LittleEndian.Uint16(word[offset:]))
+	if offset+2+fibClxPair*8+8 > len(word) || rgLw+4*fibCcpText+4 > len(word) {
+		return
+	}
+	ccpText := binary.LittleEndian.Uint32(word[rgLw+4*fibCcpText:])
+	pair := offset + 2 + fibClxPair*8
+	fcClx := binary.LittleEndian.Uint32(word[pair:])
+	lcbClx := binary.LittleEndian.Uint32(word[pair+4:])
+	if uint64(fcClx)+uint64(lcbClx) > uint64(len(table)) {
+		return
+	}
+	clx := table[fcClx : fcClx+lcbClx]
+
+	// Prc entries (formatting) precede the Pcdt holding the PlcPcd
+	for i := 0; i < len(clx); {
+		switch clx[i] {
+		case 0x01:
+			if i+3 > len(clx) {
+				return
+			}
+			i += 3 + int(binary.LittleEndian.Uint16(clx[i+1:]))
+			continue
+		case 0x02:
+			if i+5 > len(clx) {
+				return
+			}
+			lcb := int(binary.LittleEndian.Uint32(clx[i+1:]))
+			if lcb < 4 || i+5+lcb > len(clx) {
+				return
+			}
+			wordPiecesText(p, word, clx[i+5:i+5+lcb], ccpText)
+		}
+		return
+	}
+}
+
+// wordPiecesText writes the pieces of a PlcPcd: n+1 character positions followed
+// by n 8-byte piece descriptors, whose fc locates the text in the WordDocument
+// stream, compressed (one byte per character) if bit 30 is set
+func wordPiecesText(p *previewText, word, plc []byte, ccpText uint32) {
+	n := (len(plc) - 4) / 12
+	for i := 0; i < n && !p.full(); i++ {
+		cpStart := binary.LittleEndian.Uint32(plc[i*4:])
+		cpEnd := binary.LittleEndian.Uint32(plc[i*4+4:])
+		if cpStart >= ccpText || cpEnd <= cpStart {
+			return
+		}
+		if cpEnd > ccpText {
+			cpEnd = ccpText
+		}
+		count := uint64(cpEnd - cpStart)
+
+		fc := binary.LittleEndian.Uint32(plc[(n+1)*4+i*8+2:])
+		if fc&0x40000000 != 0 {
+			start := uint64(fc&^0x40000000) / 2
+			if start+count > uint64(len(word)) {
+				return
+			}
+			p.WriteLatin1(word[start : start+count])
+		} else {
+			start := uint64(fc)
+			if start+2*count > uint64(len(word)) {
+				return
+			}
+			p.WriteUTF16LE(word[start : start+2*count])
+		}
+	}
+}
+
+// PowerPoint 97-2003 record types holding text
+const (
+	pptMainMaster     = 0x03F8
+	pptTextCharsAtom  = 0x0FA0
+	pptTextBytesAtom  = 0x0FA8
+	pptContainerVer   = 0x0F
+	pptRecordHeadSize = 8
+)
+
+// pptBinaryText writes the text atoms of the PowerPoint Document stream, leaving
+// out the masters and their placeholder text
+func pptBinaryText(p *previewText, data []byte) {
+	c, err := parseCFB(data)
+	if err != nil {
+		return
+	}
+	stream, err := c.ReadStream(c.Lookup("PowerPoint Document"))
+	if err != nil {
+		return
+	}
+
+	for offset := 0; offset+pptRecordHeadSize <= len(stream) && !p.full(); {
+		ver := binary.LittleEndian.Uint16(stream[offset:]) & 0x0F
+		recType := binary.LittleEndian.Uint16(stream[offset+2:])
+		length := int(binary.LittleEndian.Uint32(stream[offset+4:]))
+		body := offset + pptRecordHeadSize
+		if length < 0 || body+length > len(stream) {
+			return
+		}
+
+		switch {
+		case recType == pptMainMaster:
+			offset = body + length
+			continue
+		case ver == pptContainerVer:
+			// Containers are walked into
+			offset = body
+			continue
+		case recType == pptTextCharsAtom:
+			p.WriteUTF16LE(stream[body : body+length])
+			p.Break()
+		case recType == pptTextBytesAtom:
+			p.WriteLatin1(stream[body : body+length])
+			p.Break()
+		}
+		offset = body + length
+	}
+}
+
+// biffSST is the shared string table record of an Excel workbook
+const biffSST = 0x00FC
+
+// biffSharedStringsText writes the strings of the shared string table, which
+// holds the text of all cells; strings continued in a CONTINUE record are left out
+func biffSharedStringsText(p *previewText, data []byte) {
+	c, err := parseCFB(data)
+	if err != nil {
+		return
+	}
+
+	walkBIFFGlobals(c, func(recordType uint16, body []byte) bool {
+		switch recordType {
+		case biffFilePass:
+			return false
+		case biffSST:
+			sharedStrings(p, body)
+			return false
+		}
+		return true
+	})
+}
+
+// sharedStrings writes the XLUnicodeRichExtendedString entries of an SST record
+func sharedStrings(p *previewText, sst []byte) {
+	for i := 8; i+3 <= len(sst) && !p.full(); {
+		count := int(binary.LittleEndian.Uint16(sst[i:]))
+		flags := sst[i+2]
+		i += 3
+
+		var runs, ext int
+		if flags&0x08 != 0 {
+			if i+2 > len(sst) {
+				return
+			}
+			runs = int(binary.LittleEndian.Uint16(sst[i:]))
+			i += 2
+		}
+		if flags&0x04 != 0 {
+			if i+4 > len(sst) {
+				return
+			}
+			ext = int(binary.LittleEndian.Uint32(sst[i:]))
+			i += 4
+		}
+
+		size := count
+		if flags&0x01 != 0 {
+			size *= 2
+		}
+		if i+size > len(sst) {
+			return
+		}
+		if flags&0x01 != 0 {
+			p.WriteUTF16LE(sst[i : i+size])
+		} else {
+			p.WriteLatin1(sst[i : i+size])
+		}
+		p.Break()
+		i += size + 4*runs + ext
+	}
+}
+
+// pdfText writes the strings shown by the text operators of the content
+// streams of a PDF; text drawn with embedded-font glyph codes comes out as
+// whatever the codes map to in Latin-1
+func pdfText(p *previewText, data []byte) {
+	// Strings of encrypted documents are ciphertext
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return
+	}
+
+	for offset := 0; !p.full(); {
+		idx := bytes.Index(data[offset:], []byte("stream"))
+		if idx < 0 {
+			return
+		}
+		start := offset + idx
+		offset = start + len("stream")
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			continue
+		}
+
+		body := offset
+		if body < len(data) && data[body] == '\r' {
+			body++
+		}
+		if body < len(data) && data[body] == '\n' {
+			body++
+		}
+		end := bytes.Index(data[body:], []byte("endstream"))
+		if end < 0 {
+			return
+		}
+		offset = body + end
+
+		// Only page content streams, whose dictionary has nothing but the
+		// length and the filters; fonts, images, forms and object streams
+		// have a type or a subtype
+		dictStart := bytes.LastIndex(data[:start], []byte("obj"))
+		if dictStart < 0 {
+			continue
+		}
+		dict := data[dictStart:start]
+		if bytes.Contains(dict, []byte("/Type")) || bytes.Contains(dict, []byte("/Subtype")) || bytes.Contains(dict, []byte("/Length1")) {
+			continue
+		}
+
+		content := data[body:offset]
+		if bytes.Contains(dict, []byte("/Filter")) {
+			if !bytes.Contains(dict, []byte("/FlateDecode")) || bytes.Contains(dict, []byte("/DecodeParms")) {
+				continue
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			// Truncated streams still give what was decompressed
+			content, _ = io.ReadAll(io.LimitReader(zr, maxPreviewStreamSize))
+			zr.Close()
+		}
+		pdfContentText(p, content)
+	}
+}
+
+// pdfWordSpacing is the TJ adjustment, in thousandths of the font size, taken
+// for a space between words
+const pdfWordSpacing = 200
+
+// pdfContentText writes the string operands found between the BT and ET
+// operators of a content stream; text positioning operators separate words
+func pdfContentText(p *previewText, content []byte) {
+	inText := false
+	for i := 0; i < len(content) && !p.full(); {
+		ch := content[i]
+		switch {
+		case ch == '%':
+			for i < len(content) && content[i] != '\r' && content[i] != '\n' {
+				i++
+			}
+		case ch == '(' && inText:
+			var s []byte
+			s, i = pdfLiteralString(content, i)
+			writePDFString(p, s)
+		case ch == '<' && inText && (i+1 >= len(content) || content[i+1] != '<'):
+			var s []byte
+			s, i = pdfHexString(content, i)
+			writePDFString(p, s)
+		case ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch == '\'' || ch == '"' || ch == '*':
+			j := i
+			for j < len(content) && (content[j] >= 'A' && content[j] <= 'Z' || content[j] >= 'a' && content[j] <= 'z' || content[j] == '\'' || content[j] == '"' || content[j] == '*') {
+				j++
+			}
+			switch string(content[i:j]) {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				p.Break()
+			case "Td", "TD", "Tm", "T*", "'", "\"":
+				p.Break()
+			}
+			i = j
+		case ch == '(':
+			// Strings outside text objects (marked content properties) are skipped
+			_, i = pdfLiteralString(content, i)
+		case ch == '-' || ch == '.' || ch >= '0' && ch <= '9':
+			j := i + 1
+			for j < len(content) && (content[j] == '.' || content[j] >= '0' && content[j] <= '9') {
+				j++
+			}
+			// Generators like pdfTeX space words with the position adjustments
+			// of TJ arrays rather than space characters
+			if v, err := strconv.ParseFloat(string(content[i:j]), 64); err == nil && inText && v <= -pdfWordSpacing {
+				p.Break()
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+}
+
+// pdfLiteralString decodes the (string) starting at content[i] and returns it
+// with the offset following it
+func pdfLiteralString(content []byte, i int) ([]byte, int) {
+	var s []byte
+	depth := 0
+	for i < len(content) {
+		ch := content[i]
+		i++
+		switch ch {
+		case '(':
+			if depth > 0 {
+				s = append(s, ch)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s, i
+			}
+			s = append(s, ch)
+		case '\\':
+			if i >= len(content) {
+				return s, i
+			}
+			esc := content[i]
+			i++
+			switch esc {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '\r':
+				if i < len(content) && content[i] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if esc >= '0' && esc <= '7' {
+					v := int(esc - '0')
+					for k := 0; k < 2 && i < len(content) && content[i] >= '0' && content[i] <= '7'; k++ {
+						v = v*8 + int(content[i]-'0')
+						i++
+					}
+					s = append(s, byte(v))
+				} else {
+					s = append(s, esc)
+				}
+			}
+		default:
+			s = append(s, ch)
+		}
+	}
+	return s, i
+}
+
+// pdfHexString decodes the <hex string> starting at content[i]
+func pdfHexString(content []byte, i int) ([]byte, int) {
+	var s []byte
+	var digits []byte
+	for i++; i < len(content) && content[i] != '>'; i++ {
+		if v := hexDigit(content[i]); v >= 0 {
+			digits = append(digits, byte(v))
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, 0)
+	}
+	for k := 0; k+1 < len(digits); k += 2 {
+		s = append(s, digits[k]<<4|digits[k+1])
+	}
+	return s, i + 1
+}
+
+func hexDigit(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// writePDFString writes a PDF text string
+func writePDFString(p *previewText, s []byte) {
+	p.WriteString(pdfTextString(s))
+}
+
+// rtfSkippedDestinations are the RTF groups that hold no document text
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true,
+	"pict": true, "object": true, "themedata": true, "colorschememapping": true,
+	"datastore": true, "latentstyles": true, "listtable": true, "listoverridetable": true,
+	"rsidtbl": true, "generator": true, "xmlnstbl": true, "filetbl": true,
+	"revtbl": true, "header": true, "footer": true, "fldinst": true,
+}
+
+// rtfText writes the text of an RTF document: control words and the groups of
+// non-text destinations are left out, \'hh and \uN escapes are decoded
+func rtfText(p *previewText, data []byte) {
+	type groupState struct {
+		skip      bool
+		ucSkip    int
+		firstWord bool
+	}
+	state := groupState{ucSkip: 1}
+	var stack []groupState
+	pendingSkip := 0
+
+	for i := 0; i < len(data) && !p.full(); {
+		ch := data[i]
+		switch ch {
+		case '{':
+			stack = append(stack, state)
+			state.firstWord = true
+			i++
+			continue
+		case '}':
+			if len(stack) == 0 {
+				return
+			}
+			state = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			i++
+			continue
+		case '\r', '\n':
+			i++
+			continue
+		case '\\':
+		default:
+			if pendingSkip > 0 {
+				pendingSkip--
+			} else if !state.skip {
+				p.WriteRune(rune(ch))
+			}
+			state.firstWord = false
+			i++
+			continue
+		}
+
+		// Control symbol or control word
+		i++
+		if i >= len(data) {
+			return
+		}
+		firstWord := state.firstWord
+		state.firstWord = false
+
+		sym := data[i]
+		if !(sym >= 'a' && sym <= 'z' || sym >= 'A' && sym <= 'Z') {
+			i++
+			switch sym {
+			case '*':
+				// \* marks destinations an RTF reader may ignore
+				if firstWord {
+					state.skip = true
+				}
+			case '\'':
+				if i+2 <= len(data) {
+					v := hexDigit(data[i])<<4 | hexDigit(data[i+1])
+					i += 2
+					if pendingSkip > 0 {
+						pendingSkip--
+					} else if !state.skip && v >= 0 {
+						p.WriteRune(rune(v))
+					}
+				}
+			case '~':
+				if !state.skip {
+					p.Break()
+				}
+			case '_':
+				if !state.skip {
+					p.WriteRune('-')
+				}
+			case '\\', '{', '}':
+				if !state.skip {
+					p.WriteRune(rune(sym))
+				}
+			}
+			continue
+		}
+
+		start := i
+		for i < len(data) && (data[i] >= 'a' && data[i] <= 'z' || data[i] >= 'A' && data[i] <= 'Z') {
+			i++
+		}
+		word := string(data[start:i])
+		paramStart := i
+		if i < len(data) && data[i] == '-' {
+			i++
+		}
+		for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+			i++
+		}
+		param, hasParam := 0, i > paramStart
+		if hasParam {
+			param, _ = strconv.Atoi(string(data[paramStart:i]))
+		}
+		if i < len(data) && data[i] == ' ' {
+			i++
+		}
+
+		switch {
+		case firstWord && rtfSkippedDestinations[word]:
+			state.skip = true
+		case word == "bin" && hasParam:
+			if param > 0 {
+				i += param
+			}
+		case word == "uc" && hasParam:
+			state.ucSkip = param
+		case word == "u" && hasParam:
+			if param < 0 {
+				param += 0x10000
+			}
+			if !state.skip {
+				p.WriteRune(rune(param))
+			}
+			pendingSkip = state.ucSkip
+		case word == "par" || word == "line" || word == "tab" || word == "cell" || word == "row" || word == "sect" || word == "page":
+			if !state.skip {
+				p.Break()
+			}
+		}
+	}
+}
+
+// htmlText writes the text of an HTML document outside of tags, comments,
+// scripts and style sheets, with character references decoded
+func htmlText(p *previewText, data []byte) {
+	lower := bytes.ToLower(data)
+	for i := 0; i < len(data) && !p.full(); {
+		lt := bytes.IndexByte(data[i:], '<')
+		if lt < 0 {
+			p.WriteString(html.UnescapeString(string(data[i:])))
+			return
+		}
+		p.WriteString(html.UnescapeString(string(data[i : i+lt])))
+		i += lt
+
+		if bytes.HasPrefix(data[i:], []byte("<!--")) {
+			end := bytes.Index(data[i:], []byte("-->"))
+			if end < 0 {
+				return
+			}
+			i += end + len("-->")
+			continue
+		}
+
+		var closing string
+		switch {
+		case bytes.HasPrefix(lower[i:], []byte("<script")):
+			closing = "</script"
+		case bytes.HasPrefix(lower[i:], []byte("<style")):
+			closing = "</style"
+		}
+		if closing != "" {
+			end := bytes.Index(lower[i+1:], []byte(closing))
+			if end < 0 {
+				return
+			}
+			i += 1 + end + len(closing)
+		}
+
+		gt := bytes.IndexByte(data[i:], '>')
+		if gt < 0 {
+			return
+		}
+		i += gt + 1
+		p.Break()
+	}
+}
@@ -0,0 +1,63 @@
+package extractor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// signaturePriorities overrides the order in which signatures matching at the
+// same position are preferred; extensions missing from it have priority 0
+var signaturePriorities map[string]int
+
+// SetSignaturePriorities sets the priorities used to choose between signatures
+// matching at one position, e.g. a ZIP that is also a DOCX: the highest wins and
+// ties keep the built-in order (see SignatureOrder). It must be called before
+// extraction starts.
+func SetSignaturePriorities(priorities map[string]int) {
+	signaturePriorities = priorities
+}
+
+// ParsePriorities parses rules such as "zip:10, docx:-5" separated by commas or
+// new lines
+func ParsePriorities(s string) (map[string]int, error) {
+	priorities := make(map[string]int)
+	for _, rule := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		ext, value, ok := strings.Cut(rule, ":")
+		priority, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid priority %q (expected ext:number)", rule)
+		}
+		priorities[strings.ToLower(strings.TrimSpace(ext))] = priority
+	}
+	return priorities, nil
+}
+
+// SignatureOrder lists the extensions in the order signatures are tried, which
+// decides between signatures of equal priority
+func SignatureOrder() []string {
+	var order []string
+	seen := make(map[string]bool)
+	for _, sig := range fileSignatures {
+		if !seen[sig.Extension] {
+			seen[sig.Extension] = true
+			order = append(order, sig.Extension)
+		}
+	}
+	return order
+}
+
+// sortByPriority orders matching signatures from the most to the least preferred
+func sortByPriority(found []FileSignature) {
+	if len(signaturePriorities) == 0 || len(found) < 2 {
+		return
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return signaturePriorities[found[i].Extension] > signaturePriorities[found[j].Extension]
+	})
+}
@@ -2,12 +2,16 @@ package extractor
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
+	"path"
 	"splitter-files/internal/models"
+	"splitter-files/pkg/fileutils"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -20,138 +24,716 @@ const (
 type OfficeFileType int
 
 type FileProcessor interface {
-	Process(data []byte, outputDir string, counter int32, startPos int, allowedExtensions map[string]bool) (int, int, string, string, *models.OfficeDocumentInfo, error)
+	Process(data []byte, outputDir string, counter int64, startPos int, allowedExtensions map[string]bool) (*models.ExtractionResult, error)
 }
 
-type DefaultFileProcessor struct{}
+type DefaultFileProcessor struct {
+	// OriginalNames names output files after the original name recovered from
+	// their metadata when there is one
+	OriginalNames bool
+	// SetTimes sets the modification and access times of output files to the
+	// timestamp recovered from their metadata
+	SetTimes bool
+	// DumpVBA writes the VBA macro source of macro-enabled documents into a
+	// "<output file>.vba" directory next to them
+	DumpVBA bool
+	// Index holds the signature positions of the whole input; data passed to
+	// Process must then run from startPos to the end of the input
+	Index *SignatureIndex
+	// Budget bounds the size of the files being written at once
+	Budget *fileutils.MemoryBudget
+	// Writer writes output files asynchronously; if nil they are written before
+	// Process returns
+	Writer *fileutils.AsyncWriter
+	// Sink stores the files written before Process returns (nil - plain files
+	// on the local file system)
+	Sink fileutils.Sink
+	// CaseID and EvidenceID are recorded in the results and prefixed to the
+	// output file names
+	CaseID, EvidenceID string
+	// Objects switches to content-addressed output: files are stored once under
+	// objects/ab/cd/<sha256>.<extension>, and the map holds the hashes stored so far
+	Objects *sync.Map
+	// Names keeps the names of output files unique (nil - names are used as
+	// built); content-addressed files are named by their content instead
+	Names *OutputNames
+	// Validation is how much of the structure of a file validators require
+	Validation ValidationLevel
+	// Timeout gives up on a candidate whose detection and analysis take longer
+	// (0 - no limit)
+	Timeout time.Duration
+	// Running counts the analyses run in the background under Timeout, which
+	// may outlive the Process call that gave up on them; the caller waits for
+	// them before it releases the data (nil - not counted)
+	Running *sync.WaitGroup
+	// Files limits the number of output files: the extracted files and those
+	// written next to them, a directory of VBA source counting as one. A
+	// candidate that finds the limit reached is dropped with ErrFileLimit
+	// before anything of it is written.
+	Files *fileutils.FileLimit
+	// Shards places output files in numbered subdirectories of the output
+	Shards *fileutils.Shards
+	// NameSuffix is appended to output file names, e.g. ".gz" when the sink
+	// compresses them
+	NameSuffix string
+	// Hash records the SHA-256 of each output file in the result
+	Hash bool
+	// Passwords are tried on encrypted Office documents; a decrypted copy is
+	// written next to the documents one of them opens
+	Passwords []string
+	// ExtractMedia writes the media parts of OOXML documents into a
+	// "<output file>.media" directory next to them
+	ExtractMedia bool
+	// ExtractAttachments writes the files embedded in PDFs into a
+	// "<output file>.attachments" directory next to them
+	ExtractAttachments bool
+	// PDFRevisions writes the earlier revisions of incrementally updated PDFs as
+	// "<output file>.rev<N>.pdf" next to them
+	PDFRevisions bool
+	// RepairPDF saves PDFs cut off before their trailer with a rebuilt
+	// cross-reference table instead of rejecting them
+	RepairPDF bool
+	// RepairZip saves ZIP archives without a readable central directory rebuilt
+	// from their local file headers
+	RepairZip bool
+	// TextPreview records the beginning of the text of documents in the result
+	TextPreview bool
+	// SelfVerify checks every carved file once more, as the verify command
+	// does, before it is written, and marks the files that fail as suspect;
+	// Strict drops them instead
+	SelfVerify bool
+	Strict     bool
+	// SizeFilter drops files whose size is outside the range set for their format
+	SizeFilter SizeFilter
+	// OnlyEncrypted drops everything but password-protected Office documents
+	OnlyEncrypted bool
+	// OnlyMacros drops everything but Office documents with a VBA project
+	OnlyMacros bool
+	// After and Before drop files whose embedded timestamp (EXIF capture time,
+	// Office last-saved time) is outside [After, Before); files without one are kept
+	After, Before time.Time
+	// Grep drops files whose content matches none of its patterns and records
+	// the matches of the others
+	Grep *ContentFilter
+	// Nesting enables recursive carving: the entries of ZIP archives are carved
+	// by Carve into a "<output file>.carved/<entry>" directory next to them,
+	// within its limits. Depth is the number of archives the data passed to
+	// Process was taken from.
+	Nesting *NestingLimits
+	Depth   int
+	Carve   func(data []byte, outputDir string) []models.ExtractionResult
+}
+
+func (p *DefaultFileProcessor) Process(data []byte, outputDir string, counter int64, startPos int, allowedExtensions map[string]bool) (*models.ExtractionResult, error) {
+	return extractFile(data, outputDir, counter, startPos, allowedExtensions, *p)
+}
+
+func ExtractFile(data []byte, outputDir string, counter int64, startPos int, allowedExtensions map[string]bool) (*models.ExtractionResult, error) {
+	return extractFile(data, outputDir, counter, startPos, allowedExtensions, DefaultFileProcessor{})
+}
+
+func extractFile(data []byte, outputDir string, counter int64, startPos int, allowedExtensions map[string]bool, opts DefaultFileProcessor) (*models.ExtractionResult, error) {
+	// The time of the candidate runs from here, for its analysis as for what is
+	// decrypted and unpacked of it after
+	cand := &candidate{data: data, deadline: opts.deadline()}
+	result, fileData, err := opts.analyze(data, startPos, allowedExtensions, cand)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.Files.Take() {
+		return nil, ErrFileLimit
+	}
+
+	var name string
+	if opts.Objects != nil {
+		name = objectFileName(result.SHA256, result.Extension) + opts.NameSuffix
+	} else {
+		name = identifierPrefix(opts.CaseID, opts.EvidenceID) + outputFileName(result, counter, opts.OriginalNames) + opts.NameSuffix
+		if opts.Shards != nil {
+			name = opts.Shards.Assign(len(fileData)) + "/" + name
+		}
+		if opts.Names != nil {
+			name = opts.Names.Claim(name, "."+result.Extension+opts.NameSuffix)
+		}
+	}
+	filename := fileutils.OutputPath(outputDir, name)
+
+	job := fileutils.WriteJob{Path: filename, Data: fileData}
+	if opts.SetTimes {
+		job.ModTime = result.ModTime
+	}
+
+	var duplicate bool
+	if opts.Objects != nil {
+		_, duplicate = opts.Objects.LoadOrStore(result.SHA256, true)
+	}
+
+	// Only the first of identical content-addressed files is written
+	if !duplicate {
+		if err := opts.store(job); err != nil {
+			return nil, &CandidateError{Kind: models.FailureWrite, Extension: result.Extension, Start: startPos + result.Start, Err: err}
+		}
+		if len(opts.Passwords) > 0 && result.OfficeInfo != nil && result.OfficeInfo.IsEncrypted {
+			opts.storeDecrypted(result, cand.compoundFile(), outputDir, name, cand.deadline)
+		}
+		if opts.ExtractMedia && ooxmlMediaExtensions[result.Extension] {
+			opts.storeMedia(result, fileData, outputDir, name)
+		}
+		if opts.ExtractAttachments && result.Extension == "pdf" {
+			opts.storeAttachments(result, fileData, outputDir, name, cand.deadline)
+		}
+		if opts.PDFRevisions && result.Extension == "pdf" {
+			opts.storeRevisions(result, fileData, outputDir, name)
+		}
+		if result.SFXInfo != nil {
+			opts.storeSFX(result, fileData, outputDir, name)
+		}
+		if opts.Nesting != nil && opts.Carve != nil && result.Extension == "zip" {
+			opts.storeNested(result, fileData, outputDir, name)
+		}
+	}
+
+	if opts.DumpVBA && result.OfficeInfo != nil && result.OfficeInfo.IsMacro && opts.Files.Take() {
+		if n, err := DumpVBASource(fileData, fileutils.OutputPath(outputDir, opts.claimDir(name+".vba", ".vba"))); err == nil {
+			result.OfficeInfo.VBAModules = n
+		}
+	}
 
-func (p *DefaultFileProcessor) Process(data []byte, outputDir string, counter int32, startPos int, allowedExtensions map[string]bool) (int, int, string, string, *models.OfficeDocumentInfo, error) {
-	return ExtractFile(data, outputDir, counter, startPos, allowedExtensions)
+	result.Filename = filename
+	result.Start += startPos
+	result.End += startPos
+	result.Counter = counter
+	result.CaseID = opts.CaseID
+	result.EvidenceID = opts.EvidenceID
+	return result, nil
 }
 
-func ExtractFile(data []byte, outputDir string, counter int32, startPos int, allowedExtensions map[string]bool) (int, int, string, string, *models.OfficeDocumentInfo, error) {
+// analyzeFile detects the file at the start of data and gathers what is
+// recorded about it; nothing is written yet
+func analyzeFile(data []byte, startPos int, allowedExtensions map[string]bool, cand *candidate, opts DefaultFileProcessor) (*models.ExtractionResult, []byte, error) {
+	result, fileData, err := detectFile(data, allowedExtensions, opts.Validation, opts.Index, startPos, cand)
+	// A PDF that is cut off fails validation, or runs up to the %%EOF of the next
+	// PDF in the input
+	if opts.RepairPDF && (err != nil || result.Extension == "pdf" && pdfCutOff(fileData)) {
+		if repaired, repairedData, repairErr := detectTruncatedPDF(data, allowedExtensions, cand.deadline); repairErr == nil {
+			result, fileData, err = repaired, repairedData, nil
+		}
+	}
+	// A ZIP archive without a central directory of its own fails validation as
+	// an Office package, and runs up to the central directory of whatever
+	// archive follows it in the input
+	if opts.RepairZip && bytes.HasPrefix(data, zipLocalHeaderMagic) && (err != nil || zipBroken(fileData)) {
+		if rebuilt, rebuiltData, rebuildErr := detectBrokenZip(data, allowedExtensions); rebuildErr == nil {
+			result, fileData, err = rebuilt, rebuiltData, nil
+		}
+	}
+	if cand.expired() {
+		return nil, nil, opts.timeoutError(data, startPos, allowedExtensions)
+	}
+	if err != nil {
+		kind, ext := models.FailureValidation, magicExtension(data, allowedExtensions)
+		if errors.Is(err, errTooSmall) {
+			kind = models.FailureTooSmall
+		} else if errors.Is(err, errNoSignature) && ext != "" {
+			err = fmt.Errorf("not a valid %s file", ext)
+		}
+		return nil, nil, &CandidateError{Kind: kind, Extension: ext, Start: startPos, Err: err}
+	}
+	if reason := opts.skipReason(result, fileData); reason != "" {
+		return nil, nil, &SkipError{Extension: result.Extension, Start: startPos + result.Start, Size: len(fileData), Reason: reason}
+	}
+	if opts.Grep != nil {
+		if result.Matches = opts.Grep.Match(fileData); len(result.Matches) == 0 {
+			return nil, nil, &SkipError{Extension: result.Extension, Start: startPos + result.Start, Size: len(fileData), Reason: "no content match"}
+		}
+	}
+	if opts.SelfVerify {
+		if err := VerifyFile(fileData, result.Extension); err != nil {
+			if opts.Strict {
+				return nil, nil, &CandidateError{Kind: models.FailureVerification, Extension: result.Extension, Start: startPos + result.Start, Err: err}
+			}
+			result.Suspect = err.Error()
+		}
+	}
+
+	if cand.expired() {
+		return nil, nil, opts.timeoutError(data, startPos, allowedExtensions)
+	}
+
+	result.OriginalName = originalName(result, fileData)
+	if opts.TextPreview && !result.IsEncrypted && (result.OfficeInfo == nil || !result.OfficeInfo.IsEncrypted) && (result.PDFInfo == nil || !result.PDFInfo.IsEncrypted) {
+		result.Preview = TextPreview(result.Extension, fileData)
+	}
+	result.Entropy = fileutils.Entropy(fileData)
+	if opts.Hash || opts.Objects != nil {
+		sum := sha256.Sum256(fileData)
+		result.SHA256 = hex.EncodeToString(sum[:])
+	}
+	return result, fileData, nil
+}
+
+// claim returns the name of a file written next to an extracted file, kept
+// apart from the other output files like the names of extracted files
+func (opts DefaultFileProcessor) claim(name, tail string) string {
+	if opts.Names == nil {
+		return name
+	}
+	return opts.Names.Claim(name, tail)
+}
+
+// claimDir is claim for a directory written next to an extracted file
+func (opts DefaultFileProcessor) claimDir(name, tail string) string {
+	if opts.Names == nil {
+		return name
+	}
+	return opts.Names.ClaimDir(name, tail)
+}
+
+// store writes an output file with the asynchronous writer or the sink
+func (opts DefaultFileProcessor) store(job fileutils.WriteJob) error {
+	if opts.Writer != nil {
+		opts.Writer.Write(job)
+		return nil
+	}
+
+	opts.Budget.Acquire(int64(len(job.Data)))
+	defer opts.Budget.Release(int64(len(job.Data)))
+	sink := opts.Sink
+	if sink == nil {
+		sink = &fileutils.DirSink{}
+	}
+	return sink.Put(job)
+}
+
+// storeDecrypted writes the decrypted copy of an encrypted Office document as
+// "<name>.decrypted.<extension>" when one of the passwords opens it; the original
+// is kept whether or not it succeeds, and no password is tried after deadline
+func (opts DefaultFileProcessor) storeDecrypted(result *models.ExtractionResult, c *cfbFile, outputDir, name string, deadline time.Time) {
+	plain, password, err := decryptCFB(c, opts.Passwords, deadline)
+	if err != nil {
+		return
+	}
+
+	// Encrypted packages don't tell whether they hold a document, a workbook or
+	// a presentation
+	ext := result.Extension
+	if ext == "ooxml" {
+		ext = "zip"
+		if sigs := FindFileSignatures(plain, nil, ValidationNormal); len(sigs) > 0 {
+			ext = sigs[0].Extension
+			if officeType, ok := officeExtensions[ext]; ok {
+				result.OfficeInfo.Type = officeType
+			}
+		}
+	}
+
+	if !opts.Files.Take() {
+		return
+	}
+	base := strings.TrimSuffix(strings.TrimSuffix(name, opts.NameSuffix), "."+result.Extension)
+	tail := ".decrypted." + ext + opts.NameSuffix
+	job := fileutils.WriteJob{
+		Path: fileutils.OutputPath(outputDir, opts.claim(base+tail, tail)),
+		Data: plain,
+	}
+	if opts.SetTimes {
+		job.ModTime = result.ModTime
+	}
+	if err := opts.store(job); err != nil {
+		return
+	}
+
+	result.OfficeInfo.Password = password
+	result.OfficeInfo.DecryptedFile = job.Path
+	if opts.Hash {
+		sum := sha256.Sum256(plain)
+		result.OfficeInfo.DecryptedSHA256 = hex.EncodeToString(sum[:])
+	}
+}
+
+// storeMedia writes the media parts of an OOXML document into the
+// "<name>.media" directory and lists them in the result
+func (opts DefaultFileProcessor) storeMedia(result *models.ExtractionResult, fileData []byte, outputDir, name string) {
+	parts, err := ooxmlMedia(fileData)
+	if err != nil {
+		return
+	}
+
+	dir := strings.TrimSuffix(strings.TrimSuffix(name, opts.NameSuffix), "."+result.Extension) + MediaDirSuffix
+	used := make(map[string]bool)
+	for i, part := range parts {
+		// Word, Excel and PowerPoint keep all media in a single folder, but their
+		// names may still be the same once sanitized
+		mediaName := sanitizeFileName(path.Base(part.Name), "")
+		if mediaName == "" {
+			mediaName = fmt.Sprintf("media_%d", i+1)
+		}
+		ext := path.Ext(mediaName)
+		mediaName = claimName(used, strings.TrimSuffix(mediaName, ext), ext, nil)
+		if !opts.Files.Take() {
+			return
+		}
+		job := fileutils.WriteJob{
+			Path: fileutils.OutputPath(outputDir, opts.claim(dir+"/"+mediaName+opts.NameSuffix, ext+opts.NameSuffix)),
+			Data: part.Data,
+		}
+		if err := opts.store(job); err != nil {
+			continue
+		}
+
+		media := models.MediaFile{Part: part.Name, File: job.Path, Size: len(part.Data)}
+		if opts.Hash {
+			sum := sha256.Sum256(part.Data)
+			media.SHA256 = hex.EncodeToString(sum[:])
+		}
+		result.OfficeInfo.Media = append(result.OfficeInfo.Media, media)
+	}
+}
+
+// storeAttachments writes the files embedded in a PDF into the
+// "<name>.attachments" directory and lists them in the result
+func (opts DefaultFileProcessor) storeAttachments(result *models.ExtractionResult, fileData []byte, outputDir, name string, deadline time.Time) {
+	attachments, err := pdfAttachments(fileData, deadline)
+	if err != nil {
+		return
+	}
+
+	if result.PDFInfo == nil {
+		result.PDFInfo = &models.PDFDocumentInfo{}
+	}
+	dir := strings.TrimSuffix(strings.TrimSuffix(name, opts.NameSuffix), "."+result.Extension) + AttachmentsDirSuffix
+	used := make(map[string]bool)
+	for i, attachment := range attachments {
+		// Attachments may share a name
+		fileName := sanitizeFileName(attachment.Name, "")
+		if fileName == "" {
+			fileName = fmt.Sprintf("attachment_%d", i+1)
+		}
+		ext := path.Ext(fileName)
+		fileName = claimName(used, strings.TrimSuffix(fileName, ext), ext, nil)
+		if !opts.Files.Take() {
+			return
+		}
+
+		job := fileutils.WriteJob{
+			Path: fileutils.OutputPath(outputDir, opts.claim(dir+"/"+fileName+opts.NameSuffix, ext+opts.NameSuffix)),
+			Data: attachment.Data,
+		}
+		if err := opts.store(job); err != nil {
+			continue
+		}
+
+		file := models.AttachedFile{Name: attachment.Name, File: job.Path, Size: len(attachment.Data)}
+		if opts.Hash {
+			sum := sha256.Sum256(attachment.Data)
+			file.SHA256 = hex.EncodeToString(sum[:])
+		}
+		result.PDFInfo.Attachments = append(result.PDFInfo.Attachments, file)
+	}
+}
+
+// storeSFX writes the stub and the archive of a self-extracting archive into
+// the "<name>.sfx" directory as stub.exe and archive.<format>
+func (opts DefaultFileProcessor) storeSFX(result *models.ExtractionResult, fileData []byte, outputDir, name string) {
+	layout, ok := parseSFX(fileData)
+	if !ok {
+		return
+	}
+
+	dir := strings.TrimSuffix(strings.TrimSuffix(name, opts.NameSuffix), "."+result.Extension) + SFXDirSuffix
+	info := result.SFXInfo
+	stub := fileData[:layout.stubEnd]
+	archive := fileData[layout.archiveStart:layout.archiveEnd]
+	if !opts.Files.Take() {
+		return
+	}
+	stubJob := fileutils.WriteJob{Path: fileutils.OutputPath(outputDir, opts.claim(dir+"/stub.exe"+opts.NameSuffix, ".exe"+opts.NameSuffix)), Data: stub}
+	if err := opts.store(stubJob); err == nil {
+		info.StubFile = stubJob.Path
+		if opts.Hash {
+			sum := sha256.Sum256(stub)
+			info.StubSHA256 = hex.EncodeToString(sum[:])
+		}
+	}
+	if !opts.Files.Take() {
+		return
+	}
+	archiveTail := "." + layout.archive + opts.NameSuffix
+	archiveJob := fileutils.WriteJob{Path: fileutils.OutputPath(outputDir, opts.claim(dir+"/archive"+archiveTail, archiveTail)), Data: archive}
+	if err := opts.store(archiveJob); err == nil {
+		info.ArchiveFile = archiveJob.Path
+		if opts.Hash {
+			sum := sha256.Sum256(archive)
+			info.ArchiveSHA256 = hex.EncodeToString(sum[:])
+		}
+	}
+}
+
+// storeNested carves the entries of an archive into the "<name>.carved"
+// directory, each into a directory of its own, and lists what was found in the
+// result
+func (opts DefaultFileProcessor) storeNested(result *models.ExtractionResult, fileData []byte, outputDir, name string) {
+	dir := strings.TrimSuffix(strings.TrimSuffix(name, opts.NameSuffix), "."+result.Extension) + NestedDirSuffix
+	var carved []models.ExtractionResult
+	n := 0
+	reason := opts.Nesting.carveEntries(fileData, opts.Depth, func(entryName string, content []byte) {
+		// Entries in different folders may share a name
+		n++
+		entryDir := fmt.Sprintf("%d", n)
+		if clean := sanitizeFileName(path.Base(entryName), ""); clean != "" {
+			entryDir += "_" + clean
+		}
+		carved = append(carved, opts.Carve(content, fileutils.OutputPath(outputDir, dir+"/"+entryDir))...)
+	})
+	if len(carved) == 0 && reason == "" {
+		return
+	}
+
+	if result.ZipInfo == nil {
+		result.ZipInfo = &models.ZipArchiveInfo{}
+	}
+	result.ZipInfo.Carved = carved
+	if len(carved) > 0 {
+		result.ZipInfo.CarvedDir = fileutils.OutputPath(outputDir, dir)
+	}
+	result.ZipInfo.NotCarved = reason
+}
+
+// storeRevisions writes the earlier revisions of an incrementally updated PDF
+// as "<name>.rev<N>.pdf", numbered from the oldest, and lists them in the result
+func (opts DefaultFileProcessor) storeRevisions(result *models.ExtractionResult, fileData []byte, outputDir, name string) {
+	base := strings.TrimSuffix(strings.TrimSuffix(name, opts.NameSuffix), "."+result.Extension)
+	for i, end := range pdfRevisionEnds(fileData) {
+		if !opts.Files.Take() {
+			return
+		}
+		tail := fmt.Sprintf(".rev%d.pdf%s", i+1, opts.NameSuffix)
+		job := fileutils.WriteJob{
+			Path: fileutils.OutputPath(outputDir, opts.claim(base+tail, tail)),
+			Data: fileData[:end],
+		}
+		if opts.SetTimes {
+			job.ModTime = result.ModTime
+		}
+		if err := opts.store(job); err != nil {
+			continue
+		}
+
+		if result.PDFInfo == nil {
+			result.PDFInfo = &models.PDFDocumentInfo{}
+		}
+		revision := models.PDFRevision{File: job.Path, Size: end}
+		if opts.Hash {
+			sum := sha256.Sum256(fileData[:end])
+			revision.SHA256 = hex.EncodeToString(sum[:])
+		}
+		result.PDFInfo.Revisions = append(result.PDFInfo.Revisions, revision)
+	}
+}
+
+// skipReason tells why a detected file is not extracted, or returns "" if it passes the filters
+func (opts DefaultFileProcessor) skipReason(result *models.ExtractionResult, fileData []byte) string {
+	if !opts.SizeFilter.Allows(result.Extension, len(fileData)) {
+		return "outside the size filter"
+	}
+	if opts.OnlyEncrypted && (result.OfficeInfo == nil || !result.OfficeInfo.IsEncrypted) {
+		return "not an encrypted document"
+	}
+	if opts.OnlyMacros && (result.OfficeInfo == nil || !result.OfficeInfo.IsMacro) {
+		return "not a document with macros"
+	}
+	if !result.ModTime.IsZero() {
+		if !opts.After.IsZero() && result.ModTime.Before(opts.After) {
+			return "dated " + result.ModTime.Format(time.RFC3339) + ", before the -after date"
+		}
+		if !opts.Before.IsZero() && !result.ModTime.Before(opts.Before) {
+			return "dated " + result.ModTime.Format(time.RFC3339) + ", not before the -before date"
+		}
+	}
+	return ""
+}
+
+// upTo returns data up to end, or all of it when a header declares a file
+// longer than what is left of the input, as with a truncated database
+func upTo(data []byte, end int) []byte {
+	return data[:min(end, len(data))]
+}
+
+// detectFile identifies the file at the start of data, validated at level, and
+// returns its description (with positions relative to data) together with the
+// content to be saved; index, if not nil, is used instead of searching the rest
+// of the input at position pos. cand is the candidate at the start of data the
+// caller checks, with its deadline; if nil, the detection has no time limit.
+func detectFile(data []byte, allowedExtensions map[string]bool, level ValidationLevel, index *SignatureIndex, pos int, cand *candidate) (*models.ExtractionResult, []byte, error) {
 	const minFileSize = 2 * 1024
 
-	foundSigs := FindFileSignatures(data, allowedExtensions)
+	if cand == nil {
+		cand = &candidate{data: data}
+	}
+	// The compound file formats are told apart with the same parse the checks
+	// below read
+	classified := make(map[*containerClassifier]string)
+	if bytes.HasPrefix(data, cfbMagic) {
+		classified[cfbContainer] = cfbFileExtension(cand.compoundFile(), data)
+	}
+	foundSigs := findFileSignatures(data, allowedExtensions, level, classified, cand.deadline)
+	if cand.expired() {
+		return nil, nil, errDeadline
+	}
 	if len(foundSigs) == 0 {
-		return 0, 0, "", "", nil, errors.New("no known file signatures found")
+		return nil, nil, errNoSignature
 	}
 
 	sig := foundSigs[0]
 	ext := sig.Extension
+	if ext == "cache" {
+		return detectChromeCacheEntry(data)
+	}
+
 	fileType := strings.ToUpper(ext)
 
 	var officeInfo *models.OfficeDocumentInfo
+	var pdfInfo *models.PDFDocumentInfo
+	var zipInfo *models.ZipArchiveInfo
+	var sfxInfo *models.SFXInfo
+	var highPriority, isEncrypted bool
+	var location *models.GeoLocation
+	var modTime time.Time
 
-	if strings.HasPrefix(ext, "doc") || strings.HasPrefix(ext, "xls") || strings.HasPrefix(ext, "ppt") {
-		officeInfo = &models.OfficeDocumentInfo{}
-
-		if ext == "doc" || ext == "xls" || ext == "ppt" {
-			if bytes.Contains(data, []byte("WordDocument")) {
-				officeInfo.Type = models.WordDocument
-			} else if bytes.Contains(data, []byte("Workbook")) {
-				officeInfo.Type = models.ExcelDocument
-			} else if bytes.Contains(data, []byte("PowerPoint")) {
-				officeInfo.Type = models.PowerPointDocument
-			}
-
-			if bytes.Contains(data, []byte("_VBA_PROJECT")) {
-				officeInfo.IsMacro = true
-			}
+	if officeType, ok := officeExtensions[ext]; ok {
+		officeInfo = &models.OfficeDocumentInfo{Type: officeType}
 
-			if officeInfo.Type == models.WordDocument || officeInfo.Type == models.ExcelDocument || officeInfo.Type == models.PowerPointDocument {
-				hasEncryptedMarker := bytes.Contains(data, []byte("E\x00n\x00c\x00r\x00y\x00p\x00t\x00"))
-				hasEncryptionHeader := false
-				if len(data) > 512 {
-					if bytes.HasPrefix(data[512:], []byte{0xFE, 0xFF, 0xFF, 0xFF}) {
-						hasEncryptionHeader = true
-					}
-					if bytes.Contains(data[:512], []byte("E\x00n\x00c\x00r\x00y\x00p\x00t\x00P\x00a\x00c\x00k\x00a\x00g\x00e")) {
-						hasEncryptionHeader = true
-					}
-				}
-
-				isEncrypted := false
-				if len(data) > 0x200 {
-					var protectionFlagOffset int
-					switch officeInfo.Type {
-					case models.WordDocument:
-						protectionFlagOffset = 0x0B
-					case models.ExcelDocument:
-						protectionFlagOffset = 0x2F
-					case models.PowerPointDocument:
-						protectionFlagOffset = 0x0F
-					}
-
-					if protectionFlagOffset > 0 && len(data) > protectionFlagOffset {
-						protectionFlag := data[protectionFlagOffset]
-						isEncrypted = (protectionFlag & 0x01) != 0
-					}
-				}
-
-				hasEncryptionStream := bytes.Contains(data, []byte("E\x00n\x00c\x00r\x00y\x00p\x00t\x00i\x00o\x00n\x00I\x00n\x00f\x00o"))
-
-				officeInfo.IsEncrypted = hasEncryptedMarker || hasEncryptionHeader || isEncrypted || hasEncryptionStream
-
-				if officeInfo.IsMacro && bytes.Contains(data, []byte("D\x00e\x00f\x00a\x00u\x00l\x00t\x00P\x00a\x00s\x00s\x00w\x00o\x00r\x00d")) {
-					officeInfo.IsEncrypted = true
-				}
+		if c := cand.compoundFile(); c != nil {
+			readSummaryInformation(c, officeInfo)
+			officeInfo.IsMacro = findVBAStorage(c) != nil
+			officeInfo.IsEncrypted = cfbDocumentEncrypted(c, data)
+			if officeType == models.ExcelDocument {
+				readBIFFIndicators(c, officeInfo)
 			}
+			readCFBIndicators(c, officeInfo)
+		} else if bytes.HasPrefix(data, cfbMagic) {
+			// Damaged directory, fall back to searching for the stream names
+			officeInfo.IsMacro = bytes.Contains(data, []byte("_VBA_PROJECT"))
+			officeInfo.IsEncrypted = cfbDocumentEncrypted(nil, data)
 		}
 	}
 
 	fileEnd := len(data)
+	// exactEnd is set when the format gives the length, which may be all of data
+	exactEnd := false
 	for i := 1; i < len(fileSignatures); i++ {
 		otherSig := fileSignatures[i]
-		if len(otherSig.MagicNumber) == 0 {
+		if len(otherSig.MagicNumber) == 0 || otherSig.WeakMagic {
 			continue
 		}
 
-		idx := bytes.Index(data, otherSig.MagicNumber)
-		if idx != -1 && idx < fileEnd && idx > 0 {
+		// Signatures located past the start of a file begin Offset bytes before their magic
+		idx := index.index(data, pos, otherSig.MagicNumber) - otherSig.Offset
+		if idx >= 0 && idx < fileEnd && idx > 0 {
 			fileEnd = idx
 		}
 	}
 
 	switch ext {
 	case "jpg", "jpeg":
-		// Improved JPEG end detection
-		for i := len(data) - 2; i >= 0; i-- {
-			if data[i] == 0xFF && data[i+1] == 0xD9 { // EOI marker
-				fileEnd = i + 2
-				break
-			}
+		if end := jpegEnd(data); end > 0 {
+			fileEnd = end
 		}
 		fileType = "JPEG Image"
+		location = jpegGPSLocation(upTo(data, fileEnd))
+		modTime = jpegCaptureTime(upTo(data, fileEnd))
+	case "mp4", "mov":
+		if size := mp4FileSize(data); size > 0 {
+			fileEnd = size
+			exactEnd = true
+		}
+		fileType = "MPEG-4 Video"
+		if ext == "mov" {
+			fileType = "QuickTime Movie"
+		}
+		location = mp4Location(upTo(data, fileEnd))
 	case "pdf":
-		if idx := bytes.LastIndex(data, []byte("%%EOF")); idx != -1 {
-			fileEnd = idx + 5
-			if fileEnd < len(data) {
-				trailer := data[fileEnd]
-				if trailer == '\r' || trailer == '\n' {
-					fileEnd++
-				} else if fileEnd+1 < len(data) && data[fileEnd] == '\r' && data[fileEnd+1] == '\n' {
-					fileEnd += 2
-				}
-			}
+		if end := pdfEnd(data); end > 0 {
+			fileEnd = end
 		}
 		fileType = "PDF Document"
-	case "zip", "docx", "xlsx", "pptx", "odt":
-		if idx := bytes.LastIndex(data, []byte{0x50, 0x4B, 0x05, 0x06}); idx != -1 {
+		pdfInfo = readPDFInfo(upTo(data, fileEnd), cand.deadline)
+	case "zip", "docx", "docm", "dotx", "dotm", "xlsx", "xlsm", "xltx", "xltm", "xlsb", "pptx", "pptm", "potx", "potm", "vsdx", "odt", "ods", "ots", "odp", "epub", "jar", "apk":
+		if end := zipArchiveEnd(data); end > 0 {
+			fileEnd = end
+			exactEnd = true
+		} else if end := zipWalkedArchiveEnd(data); end > 0 {
+			fileEnd = end
+			exactEnd = true
+		} else if idx := bytes.LastIndex(data, []byte{0x50, 0x4B, 0x05, 0x06}); idx != -1 {
 			fileEnd = idx + 22
 		}
+		if segment, segments := zipSpannedSegment(upTo(data, fileEnd)); segment > 0 {
+			zipInfo = &models.ZipArchiveInfo{Segment: segment, Segments: segments}
+		}
+		if scheme, encrypted := zipEncryption(upTo(data, fileEnd)); encrypted {
+			if officeInfo != nil {
+				officeInfo.IsEncrypted = true
+			} else {
+				isEncrypted = true
+			}
+			if zipInfo == nil {
+				zipInfo = &models.ZipArchiveInfo{}
+			}
+			zipInfo.Encryption = scheme
+		}
+		if entries := zipEntryList(upTo(data, fileEnd)); len(entries) > 0 {
+			if zipInfo == nil {
+				zipInfo = &models.ZipArchiveInfo{}
+			}
+			zipInfo.Entries = entries
+		}
 
 		switch ext {
 		case "docx":
 			fileType = "Word Document (Open XML)"
+		case "docm":
+			fileType = "Word Macro-Enabled Document (Open XML)"
+			officeInfo.IsMacro = true
+		case "dotx":
+			fileType = "Word Template (Open XML)"
+		case "dotm":
+			fileType = "Word Macro-Enabled Template (Open XML)"
+			officeInfo.IsMacro = true
 		case "xlsx":
 			fileType = "Excel Workbook (Open XML)"
+		case "xlsm":
+			fileType = "Excel Macro-Enabled Workbook (Open XML)"
+			officeInfo.IsMacro = true
+		case "xltx":
+			fileType = "Excel Template (Open XML)"
+		case "xltm":
+			fileType = "Excel Macro-Enabled Template (Open XML)"
+			officeInfo.IsMacro = true
+		case "xlsb":
+			fileType = "Excel Binary Workbook"
 		case "pptx":
 			fileType = "PowerPoint Presentation (Open XML)"
+		case "pptm":
+			fileType = "PowerPoint Macro-Enabled Presentation (Open XML)"
+			officeInfo.IsMacro = true
+		case "potx":
+			fileType = "PowerPoint Template (Open XML)"
+		case "potm":
+			fileType = "PowerPoint Macro-Enabled Template (Open XML)"
+			officeInfo.IsMacro = true
+		case "vsdx":
+			fileType = "Visio Drawing (Open XML)"
 		case "odt":
 			fileType = "OpenDocument Text"
 		case "zip":
 			fileType = "ZIP Archive"
+		case "epub":
+			fileType = "EPUB eBook"
+		case "jar":
+			fileType = "Java Archive"
+		case "apk":
+			fileType = "Android Package"
 		case "ods", "ots":
 			fileType = "OpenDocument Spreadsheet"
 		case "fods":
@@ -159,21 +741,158 @@ func ExtractFile(data []byte, outputDir string, counter int32, startPos int, all
 		case "odp":
 			fileType = "OpenDocument Presentation"
 		}
+	case "exe":
+		if layout, ok := parseSFX(data); ok {
+			fileEnd = layout.end
+			exactEnd = true
+			fileType = "Self-Extracting Archive (" + sfxArchiveLabels[layout.archive] + ")"
+			modTime = peLinkTime(data)
+			sfxInfo = &models.SFXInfo{Archive: layout.archive, StubSize: layout.stubEnd, ArchiveSize: layout.archiveEnd - layout.archiveStart, Linked: modTime}
+		}
 	case "doc":
 		fileType = "Word Document (Binary)"
+	case "dot":
+		fileType = "Word Template (Binary)"
 	case "xls":
 		fileType = "Excel Workbook (Binary)"
+	case "xlt":
+		fileType = "Excel Template (Binary)"
 	case "ppt":
 		fileType = "PowerPoint Presentation (Binary)"
+	case "pot":
+		fileType = "PowerPoint Template (Binary)"
+	case "ooxml":
+		fileType = "Encrypted Office Open XML Document"
+		officeInfo.IsEncrypted = true
+	case "vsd":
+		fileType = "Visio Drawing (Binary)"
+	case "pub":
+		fileType = "Publisher Document"
+	case "mpp":
+		fileType = "Project Plan"
+		if officeInfo != nil {
+			if c := cand.compoundFile(); c != nil {
+				officeInfo.Version = projectPropsVersion(c)
+			}
+		}
+	case "wps":
+		fileType = "Works Document"
+	case "xlr":
+		fileType = "Works Spreadsheet"
 	case "rtf":
 		fileType = "Rich Text Format"
+	case "wpd":
+		fileType = "WordPerfect Document"
+		isEncrypted = isEncryptedWordPerfect(data)
+	case "wri":
+		if size := writeFileSize(data); size > 0 {
+			fileEnd = size
+		}
+		fileType = "Write Document"
 	case "html":
 		fileType = "HTML Document"
+	case "sqlite":
+		if size := sqliteDatabaseSize(data); size > 0 {
+			fileEnd = size
+		} else if pageSize := sqlitePageSize(data); fileEnd > pageSize {
+			fileEnd -= fileEnd % pageSize
+		}
+		fileType = "SQLite Database"
+		if isSQLiteWallet(upTo(data, fileEnd)) {
+			fileType = "Bitcoin Core Wallet (SQLite)"
+			highPriority = true
+		}
+	case "pf":
+		// Compressed prefetch only declares the decompressed size, which is an upper bound
+		size := prefetchFileSize(data)
+		if bytes.HasPrefix(data, prefetchCompressedMagic) {
+			if size > 0 && size < fileEnd {
+				fileEnd = size
+			}
+			fileType = "Windows Prefetch (compressed)"
+		} else {
+			if size > 0 {
+				fileEnd = size
+			}
+			fileType = "Windows Prefetch"
+		}
+	case "edb":
+		// The database is a sequence of whole pages, the header doesn't record their count
+		if pageSize := esePageSize(data); pageSize > 0 && fileEnd > pageSize {
+			fileEnd -= fileEnd % pageSize
+		}
+		fileType = "ESE Database"
+	case "pem":
+		if size := pemBlockSize(data); size > 0 {
+			fileEnd = size
+		}
+		fileType = pemFileType(data)
+		highPriority = strings.HasSuffix(pemLabel(data), "PRIVATE KEY")
+	case "ssh":
+		if bytes.HasPrefix(data, opensshPrivateKeyMagic) {
+			fileEnd = opensshPrivateKeySize(data)
+			fileType = "OpenSSH Private Key"
+			highPriority = true
+		} else {
+			fileEnd = sshPublicKeysSize(data)
+			fileType = "SSH Public Keys (authorized_keys)"
+		}
+	case "cer":
+		if size := derObjectSize(data); size > 0 {
+			fileEnd = size
+		}
+		fileType = "X.509 Certificate (DER)"
+	case "p12":
+		if size := derObjectSize(data); size > 0 {
+			fileEnd = size
+		}
+		fileType = "PKCS#12 Keystore"
+		isEncrypted = isEncryptedPKCS12(upTo(data, fileEnd))
+		highPriority = true
+	case "wallet":
+		switch {
+		case bytes.HasPrefix(data, ethereumKeystoreMagic):
+			fileEnd = ethereumKeystoreSize(data)
+			fileType = "Ethereum Keystore (JSON)"
+		case bytes.HasPrefix(data, electrumEncryptedMagic):
+			fileEnd = electrumWalletSize(data)
+			fileType = "Electrum Wallet (encrypted)"
+		default:
+			if size := bdbFileSize(data); size > 0 {
+				fileEnd = size
+			}
+			fileType = "Bitcoin Core Wallet (Berkeley DB)"
+		}
+		highPriority = true
+	case "asc":
+		if size := pgpArmorSize(data); size > 0 {
+			fileEnd = size
+		}
+		fileType = pgpArmorLabels[pgpArmorLabel(data)]
+	case "gpg":
+		size, label := pgpBinarySize(data)
+		if size > 0 {
+			fileEnd = size
+		}
+		fileType = label
+	case "one", "onetoc2":
+		if size := oneNoteFileSize(data); size > 0 {
+			fileEnd = size
+		}
+		if ext == "one" {
+			fileType = "OneNote Section"
+		} else {
+			fileType = "OneNote Table of Contents"
+		}
 	}
 
-	if fileEnd == len(data) {
+	if cand.expired() {
+		return nil, nil, errDeadline
+	}
+
+	if fileEnd == len(data) && !exactEnd {
 		if len(data) > 100 {
-			nextSig := bytes.Index(data[1:], sig.MagicNumber)
+			nextSig := index.index(data[1:], pos+1, sig.MagicNumber)
 			if nextSig != -1 {
 				fileEnd = nextSig + 1
 			}
@@ -184,17 +903,48 @@ func ExtractFile(data []byte, outputDir string, counter int32, startPos int, all
 		fileEnd = len(data)
 	}
 
-	if fileEnd < minFileSize {
-		return 0, 0, "", "", nil, fmt.Errorf("file too small (less than %d bytes)", minFileSize)
+	// Firefox stores the cached content first and its metadata right after it,
+	// which gives the exact content length
+	consumedEnd := fileEnd
+	var cacheInfo *models.CacheEntryInfo
+	if entry := findFirefoxCacheEntry(data, fileEnd); entry != nil {
+		fileEnd = entry.contentLength
+		consumedEnd = entry.end
+		cacheInfo = &models.CacheEntryInfo{Browser: "Firefox", URL: entry.url}
 	}
 
-	fileData := data[:fileEnd]
+	if officeInfo != nil && validateZipFile(data) {
+		readOOXMLPackage(data[:fileEnd], officeInfo, cand.deadline)
+	}
 
-	filename := filepath.Join(outputDir, fmt.Sprintf("file_%04d.%s", counter, ext))
-	err := ioutil.WriteFile(filename, fileData, 0644)
-	if err != nil {
-		return 0, 0, "", "", nil, fmt.Errorf("failed to write file %s: %v", filename, err)
+	if officeInfo != nil {
+		modTime = officeInfo.Modified
+		if modTime.IsZero() {
+			modTime = officeInfo.Created
+		}
+	}
+
+	minSize := minFileSize
+	if sig.MinSize > 0 {
+		minSize = sig.MinSize
+	}
+	if fileEnd < minSize {
+		return nil, nil, fmt.Errorf("%w (%d bytes, less than %d)", errTooSmall, fileEnd, minSize)
 	}
 
-	return fileEnd, startPos + fileEnd, filename, fileType, officeInfo, nil
+	return &models.ExtractionResult{
+		Size:         fileEnd,
+		End:          consumedEnd,
+		FileType:     fileType,
+		Extension:    ext,
+		HighPriority: highPriority,
+		IsEncrypted:  isEncrypted,
+		OfficeInfo:   officeInfo,
+		PDFInfo:      pdfInfo,
+		ZipInfo:      zipInfo,
+		SFXInfo:      sfxInfo,
+		CacheInfo:    cacheInfo,
+		Location:     location,
+		ModTime:      modTime,
+	}, data[:fileEnd], nil
 }
@@ -0,0 +1,225 @@
+package extractor
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"splitter-files/internal/models"
+)
+
+// OLE property sets ([MS-OLEPS]) stored in the \x05SummaryInformation stream
+
+const summaryInformationStream = "\x05SummaryInformation"
+
+var summaryInformationFMTID = guidBytes("F29F85E0-4FF9-1068-AB91-08002B27B3D9")
+
+const (
+	pidCodepage    = 0x01
+	pidTitle       = 0x02
+	pidAuthor      = 0x04
+	pidLastAuthor  = 0x08
+	pidCreateTime  = 0x0C
+	pidLastSave    = 0x0D
+	pidAppName     = 0x12
+	pidDocSecurity = 0x13
+
+	vtI2       = 0x02
+	vtI4       = 0x03
+	vtLPSTR    = 0x1E
+	vtLPWSTR   = 0x1F
+	vtFileTime = 0x40
+
+	// Seconds between 1601-01-01 (FILETIME epoch) and 1970-01-01
+	fileTimeEpochOffset = 11644473600
+)
+
+type propertyValue struct {
+	vt    uint16
+	data  []byte
+	value uint32
+}
+
+// readSummaryInformation fills the document properties of a legacy Office file into info
+func readSummaryInformation(c *cfbFile, info *models.OfficeDocumentInfo) {
+	stream, err := c.ReadStream(c.Lookup(summaryInformationStream))
+	if err != nil {
+		return
+	}
+
+	props := parsePropertySet(stream, summaryInformationFMTID)
+	if props == nil {
+		return
+	}
+
+	codepage := 1252
+	if p, ok := props[pidCodepage]; ok && p.vt == vtI2 {
+		codepage = int(uint16(p.value))
+	}
+
+	info.Title = propertyString(props[pidTitle], codepage)
+	info.Creator = propertyString(props[pidAuthor], codepage)
+	info.LastModifiedBy = propertyString(props[pidLastAuthor], codepage)
+	info.Application = propertyString(props[pidAppName], codepage)
+	info.Created = propertyTime(props[pidCreateTime])
+	info.Modified = propertyTime(props[pidLastSave])
+
+	// Bit 0 of PIDSI_DOC_SECURITY: the document is password protected
+	if p, ok := props[pidDocSecurity]; ok && (p.vt == vtI4 || p.vt == vtI2) && p.value&0x01 != 0 {
+		info.IsEncrypted = true
+	}
+}
+
+// parsePropertySet returns the properties of the section with the given format ID
+func parsePropertySet(data []byte, fmtid [16]byte) map[uint32]propertyValue {
+	if len(data) < 28 || binary.LittleEndian.Uint16(data[0:2]) != 0xFFFE {
+		return nil
+	}
+
+	numSections := int(binary.LittleEndian.Uint32(data[24:28]))
+	for i := 0; i < numSections; i++ {
+		pos := 28 + i*20
+		if pos+20 > len(data) {
+			return nil
+		}
+
+		var id [16]byte
+		copy(id[:], data[pos:pos+16])
+		if id != fmtid {
+			continue
+		}
+
+		offset := int(binary.LittleEndian.Uint32(data[pos+16 : pos+20]))
+		return parsePropertySection(data, offset)
+	}
+	return nil
+}
+
+func parsePropertySection(data []byte, offset int) map[uint32]propertyValue {
+	if offset < 0 || offset+8 > len(data) {
+		return nil
+	}
+
+	section := data[offset:]
+	size := int(binary.LittleEndian.Uint32(section[0:4]))
+	if size >= 8 && size < len(section) {
+		section = section[:size]
+	}
+
+	count := int(binary.LittleEndian.Uint32(section[4:8]))
+	props := make(map[uint32]propertyValue)
+	for i := 0; i < count; i++ {
+		pos := 8 + i*8
+		if pos+8 > len(section) {
+			break
+		}
+
+		pid := binary.LittleEndian.Uint32(section[pos : pos+4])
+		valueOffset := int(binary.LittleEndian.Uint32(section[pos+4 : pos+8]))
+		if valueOffset+8 > len(section) {
+			continue
+		}
+
+		value := section[valueOffset:]
+		p := propertyValue{
+			vt:    binary.LittleEndian.Uint16(value[0:2]),
+			data:  value[4:],
+			value: binary.LittleEndian.Uint32(value[4:8]),
+		}
+		props[pid] = p
+	}
+	return props
+}
+
+// propertyString decodes a VT_LPSTR (in the property set code page) or VT_LPWSTR value
+func propertyString(p propertyValue, codepage int) string {
+	if len(p.data) < 4 {
+		return ""
+	}
+
+	length := int(binary.LittleEndian.Uint32(p.data[0:4]))
+	raw := p.data[4:]
+
+	var s string
+	switch p.vt {
+	case vtLPSTR:
+		if length > len(raw) {
+			return ""
+		}
+		s = decodeCodepage(raw[:length], codepage)
+	case vtLPWSTR:
+		if length*2 > len(raw) {
+			return ""
+		}
+		units := make([]uint16, length)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(raw[i*2 : i*2+2])
+		}
+		s = string(utf16.Decode(units))
+	default:
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+}
+
+// propertyTime converts a VT_FILETIME value
+func propertyTime(p propertyValue) time.Time {
+	if p.vt != vtFileTime || len(p.data) < 8 {
+		return time.Time{}
+	}
+	return fileTimeToTime(binary.LittleEndian.Uint64(p.data[0:8]))
+}
+
+// fileTimeToTime converts a Windows FILETIME (100 ns intervals since 1601) into time.Time
+func fileTimeToTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	secs := int64(ft/10000000) - fileTimeEpochOffset
+	nsecs := int64(ft%10000000) * 100
+	return time.Unix(secs, nsecs).UTC()
+}
+
+// decodeCodepage converts 8-bit text from the code pages common in Office documents to UTF-8
+func decodeCodepage(b []byte, codepage int) string {
+	switch codepage {
+	case 65001:
+		if utf8.Valid(b) {
+			return string(b)
+		}
+	case 1200:
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(b[i*2 : i*2+2])
+		}
+		return string(utf16.Decode(units))
+	}
+
+	var sb strings.Builder
+	for _, ch := range b {
+		switch {
+		case ch < 0x80:
+			sb.WriteByte(ch)
+		case codepage == 1251:
+			sb.WriteRune(cp1251[ch-0x80])
+		default:
+			// Windows-1252 and unknown code pages are approximated by Latin-1
+			sb.WriteRune(rune(ch))
+		}
+	}
+	return sb.String()
+}
+
+// cp1251 maps the upper half of Windows-1251 to Unicode
+var cp1251 = func() [128]rune {
+	var t [128]rune
+	upper := []rune("ЂЃ‚ѓ„…†‡€‰Љ‹ЊЌЋЏђ‘’“”•–—�™љ›њќћџ ЎўЈ¤Ґ¦§Ё©Є«¬­®Ї°±Ііґµ¶·ё№є»јЅѕї")
+	copy(t[:], upper)
+	for i := 0; i < 64; i++ {
+		t[0x40+i] = rune(0x0410 + i)
+	}
+	return t
+}()
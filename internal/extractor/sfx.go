@@ -0,0 +1,402 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path"
+	"strings"
+	"time"
+)
+
+// SFXDirSuffix is appended to the name of a self-extracting archive, without
+// its extension, to name the directory its stub and archive are written to
+const SFXDirSuffix = ".sfx"
+
+var (
+	peMagic       = []byte("MZ")
+	peSignature   = []byte("PE\x00\x00")
+	rar4Magic     = []byte("Rar!\x1A\x07\x00")
+	rar5Magic     = []byte("Rar!\x1A\x07\x01\x00")
+	sevenZipMagic = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+)
+
+const (
+	peCOFFHeaderSize    = 20
+	peSectionHeaderSize = 40
+	// peSecurityDirectory is the data directory of the Authenticode
+	// certificate table, which signing appends to the end of the file
+	peSecurityDirectory = 4
+	// peExportDirectorySize is the size of IMAGE_EXPORT_DIRECTORY, whose Name
+	// is the file name the DLL or executable was linked as
+	peExportDirectorySize = 40
+	// peMaxExportName bounds the name read from the export directory
+	peMaxExportName = 256
+	// sfxArchiveWindow is how far past the end of the PE image the archive of
+	// a self-extracting archive may start, after the padding some stubs add
+	sfxArchiveWindow = 4096
+	// sevenZipStartHeaderSize is the size of the signature header of a 7z
+	// archive, which gives the position and size of the header at its end
+	sevenZipStartHeaderSize = 32
+	// peFirstYear is the year of the first PE executables (Windows NT 3.1)
+	peFirstYear = 1993
+)
+
+// sfxArchives are the formats of the archives of self-extracting archives
+var sfxArchives = []struct {
+	format string
+	magic  []byte
+}{
+	{"zip", zipLocalHeaderMagic},
+	{"rar", rar4Magic},
+	{"rar5", rar5Magic},
+	{"7z", sevenZipMagic},
+}
+
+// sfxArchiveLabels name the formats of the archives in file types
+var sfxArchiveLabels = map[string]string{"zip": "ZIP", "rar": "RAR", "7z": "7z"}
+
+// sfxLayout describes a self-extracting archive: a PE executable, the stub that
+// unpacks the archive appended to its image, optionally followed by the
+// Authenticode signature of the whole
+type sfxLayout struct {
+	// stubEnd is the end of the sections of the executable
+	stubEnd int
+	// archive is the format of the archive: "zip", "rar" or "7z"
+	archive                  string
+	archiveStart, archiveEnd int
+	// end is the end of the file
+	end int
+}
+
+// validateSFX accepts PE executables followed by a complete archive
+func validateSFX(data []byte) bool {
+	_, ok := parseSFX(data)
+	return ok
+}
+
+// parseSFX finds the archive appended to the PE executable at the start of data
+func parseSFX(data []byte) (sfxLayout, bool) {
+	stubEnd, certStart, certEnd := peLayout(data)
+	if stubEnd == 0 {
+		return sfxLayout{}, false
+	}
+
+	// The signature covers the archive, which then ends where it starts
+	limit := len(data)
+	if certStart >= stubEnd {
+		limit = certStart
+	}
+
+	window := data[stubEnd:min(limit, stubEnd+sfxArchiveWindow)]
+	layout := sfxLayout{stubEnd: stubEnd, archiveStart: -1}
+	for _, archive := range sfxArchives {
+		if i := bytes.Index(window, archive.magic); i >= 0 && (layout.archiveStart < 0 || stubEnd+i < layout.archiveStart) {
+			layout.archive = archive.format
+			layout.archiveStart = stubEnd + i
+		}
+	}
+	if layout.archiveStart < 0 {
+		return sfxLayout{}, false
+	}
+
+	archive := data[layout.archiveStart:limit]
+	var size int
+	switch layout.archive {
+	case "zip":
+		size = zipArchiveEnd(archive)
+		// Stubs that open the archive as themselves have its offsets shifted
+		// by the length of the stub
+		if size == 0 {
+			if end := zipArchiveEnd(data[:limit]); end > layout.archiveStart {
+				size = end - layout.archiveStart
+			}
+		}
+	case "rar":
+		size = rar4ArchiveSize(archive)
+	case "rar5":
+		layout.archive = "rar"
+		size = rar5ArchiveSize(archive)
+	case "7z":
+		size = sevenZipArchiveSize(archive)
+	}
+	if size == 0 {
+		return sfxLayout{}, false
+	}
+	layout.archiveEnd = layout.archiveStart + size
+
+	layout.end = layout.archiveEnd
+	if certStart >= stubEnd {
+		if layout.archiveEnd != certStart {
+			return sfxLayout{}, false
+		}
+		layout.end = certEnd
+	}
+	return layout, true
+}
+
+// peLayout returns the end of the sections of the PE executable at the start
+// of data and the position of its certificate table, which is 0 when it has
+// none; the end is 0 if data doesn't start with an executable
+func peLayout(data []byte) (imageEnd, certStart, certEnd int) {
+	if len(data) < 0x40 || !bytes.HasPrefix(data, peMagic) {
+		return 0, 0, 0
+	}
+	header := int(binary.LittleEndian.Uint32(data[0x3C:0x40]))
+	if header < 0x40 || header > len(data)-len(peSignature)-peCOFFHeaderSize || !bytes.Equal(data[header:header+4], peSignature) {
+		return 0, 0, 0
+	}
+
+	coff := data[header+4 : header+4+peCOFFHeaderSize]
+	sections := int(binary.LittleEndian.Uint16(coff[2:4]))
+	optionalSize := int(binary.LittleEndian.Uint16(coff[16:18]))
+	optional := header + 4 + peCOFFHeaderSize
+	table := optional + optionalSize
+	if sections == 0 || sections > 96 || optionalSize < 64 || table+sections*peSectionHeaderSize > len(data) {
+		return 0, 0, 0
+	}
+
+	// The headers come before the sections, and sections without raw data
+	// take no room in the file
+	imageEnd = int(binary.LittleEndian.Uint32(data[optional+60 : optional+64]))
+	for i := 0; i < sections; i++ {
+		section := data[table+i*peSectionHeaderSize : table+(i+1)*peSectionHeaderSize]
+		size := int64(binary.LittleEndian.Uint32(section[16:20]))
+		offset := int64(binary.LittleEndian.Uint32(section[20:24]))
+		if size == 0 {
+			continue
+		}
+		if offset+size > int64(len(data)) {
+			return 0, 0, 0
+		}
+		imageEnd = max(imageEnd, int(offset+size))
+	}
+	if imageEnd < table+sections*peSectionHeaderSize || imageEnd > len(data) {
+		return 0, 0, 0
+	}
+
+	// The data directories follow the fields of the optional header, which are
+	// longer in PE32+
+	var directories int
+	switch binary.LittleEndian.Uint16(data[optional : optional+2]) {
+	case 0x10B:
+		directories = optional + 96
+	case 0x20B:
+		directories = optional + 112
+	default:
+		return 0, 0, 0
+	}
+	security := directories + peSecurityDirectory*8
+	if security+8 <= table && security+8 <= len(data) {
+		start := int64(binary.LittleEndian.Uint32(data[security : security+4]))
+		size := int64(binary.LittleEndian.Uint32(data[security+4 : security+8]))
+		if start > 0 && size > 0 && start+size <= int64(len(data)) {
+			certStart, certEnd = int(start), int(start+size)
+		}
+	}
+	return imageEnd, certStart, certEnd
+}
+
+// peExportName returns the name the PE executable at the start of data was
+// linked as, from the Name of its export directory, without its extension; it
+// is empty for executables that export nothing, as most programs
+func peExportName(data []byte) string {
+	if len(data) < 0x40 || !bytes.HasPrefix(data, peMagic) {
+		return ""
+	}
+	header := int(binary.LittleEndian.Uint32(data[0x3C:0x40]))
+	if header < 0x40 || header > len(data)-len(peSignature)-peCOFFHeaderSize || !bytes.Equal(data[header:header+4], peSignature) {
+		return ""
+	}
+	coff := data[header+4 : header+4+peCOFFHeaderSize]
+	sections := int(binary.LittleEndian.Uint16(coff[2:4]))
+	optional := header + 4 + peCOFFHeaderSize
+	table := optional + int(binary.LittleEndian.Uint16(coff[16:18]))
+	if sections == 0 || sections > 96 || table+sections*peSectionHeaderSize > len(data) {
+		return ""
+	}
+
+	// The export table is the first data directory
+	var directories int
+	switch binary.LittleEndian.Uint16(data[optional : optional+2]) {
+	case 0x10B:
+		directories = optional + 96
+	case 0x20B:
+		directories = optional + 112
+	default:
+		return ""
+	}
+	if directories+8 > table || binary.LittleEndian.Uint32(data[directories-4:directories]) == 0 {
+		return ""
+	}
+
+	// Addresses in the image are mapped back to the file through the section
+	// that holds them
+	fileOffset := func(rva uint32) int {
+		for i := 0; i < sections; i++ {
+			section := data[table+i*peSectionHeaderSize : table+(i+1)*peSectionHeaderSize]
+			address := binary.LittleEndian.Uint32(section[12:16])
+			size := max(binary.LittleEndian.Uint32(section[8:12]), binary.LittleEndian.Uint32(section[16:20]))
+			if rva >= address && rva-address < size {
+				offset := int64(binary.LittleEndian.Uint32(section[20:24])) + int64(rva-address)
+				if offset < int64(len(data)) {
+					return int(offset)
+				}
+			}
+		}
+		return -1
+	}
+
+	exports := fileOffset(binary.LittleEndian.Uint32(data[directories : directories+4]))
+	if exports < 0 || exports+peExportDirectorySize > len(data) {
+		return ""
+	}
+	start := fileOffset(binary.LittleEndian.Uint32(data[exports+12 : exports+16]))
+	if start < 0 {
+		return ""
+	}
+	end := bytes.IndexByte(data[start:min(len(data), start+peMaxExportName)], 0)
+	if end <= 0 {
+		return ""
+	}
+	name := data[start : start+end]
+	for _, ch := range name {
+		if ch < 0x20 || ch >= 0x7F {
+			return ""
+		}
+	}
+	return strings.TrimSuffix(string(name), path.Ext(string(name)))
+}
+
+// peLinkTime returns the time the linker recorded in the COFF header of the PE
+// executable at the start of data; it is zero when the executable has none or
+// a hash takes its place, as in reproducible builds, which is mostly a time
+// before the format existed or in the future
+func peLinkTime(data []byte) time.Time {
+	header := int(binary.LittleEndian.Uint32(data[0x3C:0x40]))
+	stamp := int64(binary.LittleEndian.Uint32(data[header+8 : header+12]))
+	t := time.Unix(stamp, 0).UTC()
+	if t.Year() < peFirstYear || t.After(time.Now()) {
+		return time.Time{}
+	}
+	return t
+}
+
+// sevenZipArchiveSize returns the size of the 7z archive at the start of data,
+// which ends with the header its signature header points at, or 0
+func sevenZipArchiveSize(data []byte) int {
+	if len(data) < sevenZipStartHeaderSize || !bytes.HasPrefix(data, sevenZipMagic) {
+		return 0
+	}
+	offset := binary.LittleEndian.Uint64(data[12:20])
+	size := binary.LittleEndian.Uint64(data[20:28])
+	if size == 0 || offset > uint64(len(data)) || size > uint64(len(data)) {
+		return 0
+	}
+	end := sevenZipStartHeaderSize + offset + size
+	if end > uint64(len(data)) {
+		return 0
+	}
+	return int(end)
+}
+
+// rar4ArchiveSize returns the size of the RAR 1.5-4.x archive at the start of
+// data, walking its blocks up to the end-of-archive block, or 0
+func rar4ArchiveSize(data []byte) int {
+	const (
+		blockEndArchive = 0x7B
+		flagLongBlock   = 0x8000
+		blockFile       = 0x74
+	)
+	pos := len(rar4Magic)
+	for pos+7 <= len(data) {
+		blockType := data[pos+2]
+		flags := binary.LittleEndian.Uint16(data[pos+3 : pos+5])
+		size := int64(binary.LittleEndian.Uint16(data[pos+5 : pos+7]))
+		if size < 7 {
+			return 0
+		}
+		// File blocks are followed by their packed data, other blocks by as
+		// many bytes as their long block flag says
+		if flags&flagLongBlock != 0 || blockType == blockFile {
+			if pos+11 > len(data) {
+				return 0
+			}
+			size += int64(binary.LittleEndian.Uint32(data[pos+7 : pos+11]))
+		}
+		if int64(pos)+size > int64(len(data)) {
+			return 0
+		}
+		pos += int(size)
+		if blockType == blockEndArchive {
+			return pos
+		}
+	}
+	return 0
+}
+
+// rar5ArchiveSize returns the size of the RAR 5 archive at the start of data,
+// walking its blocks up to the end-of-archive block, or 0
+func rar5ArchiveSize(data []byte) int {
+	const (
+		blockEndArchive = 5
+		flagExtraArea   = 0x01
+		flagDataArea    = 0x02
+	)
+	pos := len(rar5Magic)
+	for pos+4 < len(data) {
+		// CRC32, then the size of the rest of the header
+		headerSize, n := rar5Vint(data[pos+4:])
+		if n == 0 || headerSize == 0 || headerSize > uint64(len(data)) {
+			return 0
+		}
+		header := pos + 4 + n
+		if uint64(header)+headerSize > uint64(len(data)) {
+			return 0
+		}
+		fields := data[header : header+int(headerSize)]
+		blockType, n := rar5Vint(fields)
+		if n == 0 {
+			return 0
+		}
+		flags, m := rar5Vint(fields[n:])
+		if m == 0 {
+			return 0
+		}
+		fields = fields[n+m:]
+		if flags&flagExtraArea != 0 {
+			if _, n = rar5Vint(fields); n == 0 {
+				return 0
+			}
+			fields = fields[n:]
+		}
+		var dataSize uint64
+		if flags&flagDataArea != 0 {
+			if dataSize, n = rar5Vint(fields); n == 0 {
+				return 0
+			}
+		}
+
+		end := uint64(header) + headerSize + dataSize
+		if end > uint64(len(data)) {
+			return 0
+		}
+		pos = int(end)
+		if blockType == blockEndArchive {
+			return pos
+		}
+	}
+	return 0
+}
+
+// rar5Vint decodes a variable-length integer of RAR 5, 7 bits per byte, and
+// returns it with its length, which is 0 if it's cut off or too long
+func rar5Vint(data []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		v |= uint64(data[i]&0x7F) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -2,7 +2,8 @@ package extractor
 
 import (
 	"bytes"
-	"splitter-files/internal/models"
+	"strings"
+	"time"
 )
 
 type FileSignature struct {
@@ -10,50 +11,203 @@ type FileSignature struct {
 	MagicNumber []byte
 	Offset      int
 	Validator   func([]byte) bool
+	// Strict is checked after Validator at the strict validation level, and
+	// Lenient replaces it at the lenient level
+	Strict, Lenient func([]byte) bool
+	// MinSize overrides the default minimum size of an extracted file
+	MinSize int
+	// WeakMagic marks magic numbers too common to be treated as the start of the next file
+	WeakMagic bool
+	// Window lets the magic number start up to Window bytes after Offset, for
+	// formats whose readers skip junk before the header
+	Window int
+	// Container replaces Validator for formats built on a container format:
+	// the signature matches when its classifier names the extension
+	Container *containerClassifier
 }
 
 var fileSignatures = []FileSignature{
 	// DOC (Microsoft Word Document)
 	{
 		Extension:   "doc",
-		MagicNumber: []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1},
+		MagicNumber: cfbMagic,
 		Offset:      0,
-		Validator:   validateMSOfficeFile,
+		Container:   cfbContainer,
 	},
 	// DOCX (Office Open XML)
 	{
 		Extension:   "docx",
 		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
 		Offset:      0,
-		Validator:   validateOfficeOpenXML("word/", models.WordDocument),
+		Container:   zipContainer,
+	},
+	// DOCM (Word Macro-Enabled Document)
+	{
+		Extension:   "docm",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// DOT (Microsoft Word Template)
+	{
+		Extension:   "dot",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
+	},
+	// DOTX (Office Open XML Template)
+	{
+		Extension:   "dotx",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// DOTM (Word Macro-Enabled Template)
+	{
+		Extension:   "dotm",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
 	},
 	// PPT (Microsoft PowerPoint)
 	{
 		Extension:   "ppt",
-		MagicNumber: []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1},
+		MagicNumber: cfbMagic,
 		Offset:      0,
-		Validator:   validateMSOfficeFile,
+		Container:   cfbContainer,
 	},
 	// PPTX (Office Open XML Presentation)
 	{
 		Extension:   "pptx",
 		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
 		Offset:      0,
-		Validator:   validateOfficeOpenXML("ppt/", models.PowerPointDocument),
+		Container:   zipContainer,
+	},
+	// PPTM (PowerPoint Macro-Enabled Presentation)
+	{
+		Extension:   "pptm",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// POT (Microsoft PowerPoint Template)
+	{
+		Extension:   "pot",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
+	},
+	// POTX (Office Open XML Presentation Template)
+	{
+		Extension:   "potx",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// POTM (PowerPoint Macro-Enabled Template)
+	{
+		Extension:   "potm",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
 	},
 	// XLS (Microsoft Excel)
 	{
 		Extension:   "xls",
-		MagicNumber: []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1},
+		MagicNumber: cfbMagic,
 		Offset:      0,
-		Validator:   validateMSOfficeFile,
+		Container:   cfbContainer,
 	},
 	// XLSX (Office Open XML Workbook)
 	{
 		Extension:   "xlsx",
 		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
 		Offset:      0,
-		Validator:   validateOfficeOpenXML("xl/", models.ExcelDocument),
+		Container:   zipContainer,
+	},
+	// XLSM (Excel Macro-Enabled Workbook)
+	{
+		Extension:   "xlsm",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// XLT (Microsoft Excel Template)
+	{
+		Extension:   "xlt",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
+	},
+	// XLTX (Office Open XML Workbook Template)
+	{
+		Extension:   "xltx",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// XLTM (Excel Macro-Enabled Template)
+	{
+		Extension:   "xltm",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// XLSB (Excel Binary Workbook)
+	{
+		Extension:   "xlsb",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// Password-protected Office Open XML document, stored encrypted in a compound file
+	{
+		Extension:   "ooxml",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
+	},
+	// VSD (Microsoft Visio Drawing)
+	{
+		Extension:   "vsd",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
+	},
+	// VSDX (Office Open XML Drawing)
+	{
+		Extension:   "vsdx",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// PUB (Microsoft Publisher)
+	{
+		Extension:   "pub",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
+	},
+	// MPP (Microsoft Project)
+	{
+		Extension:   "mpp",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
+	},
+	// WPS (Microsoft Works word processor)
+	{
+		Extension:   "wps",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
+	},
+	// XLR (Microsoft Works spreadsheet)
+	{
+		Extension:   "xlr",
+		MagicNumber: cfbMagic,
+		Offset:      0,
+		Container:   cfbContainer,
 	},
 	// JPEG (improved validation)
 	{
@@ -61,12 +215,30 @@ var fileSignatures = []FileSignature{
 		MagicNumber: []byte{0xFF, 0xD8, 0xFF},
 		Offset:      0,
 		Validator:   validateJpegImproved,
+		Strict:      validateJpegStrict,
+		Lenient:     validateJpegLenient,
 	},
 	{
 		Extension:   "jpeg",
 		MagicNumber: []byte{0xFF, 0xD8, 0xFF},
 		Offset:      0,
 		Validator:   validateJpegImproved,
+		Strict:      validateJpegStrict,
+		Lenient:     validateJpegLenient,
+	},
+	// MP4 (MPEG-4 video)
+	{
+		Extension:   "mp4",
+		MagicNumber: mp4FtypMagic,
+		Offset:      4,
+		Validator:   validateMP4,
+	},
+	// MOV (QuickTime movie)
+	{
+		Extension:   "mov",
+		MagicNumber: mp4FtypMagic,
+		Offset:      4,
+		Validator:   validateMOV,
 	},
 	// PDF (improved validation)
 	{
@@ -74,6 +246,9 @@ var fileSignatures = []FileSignature{
 		MagicNumber: []byte{0x25, 0x50, 0x44, 0x46},
 		Offset:      0,
 		Validator:   validatePdf,
+		Strict:      validatePdfStrict,
+		Lenient:     validatePdfLenient,
+		Window:      pdfHeaderWindow,
 	},
 	// RTF (Rich Text Format)
 	{
@@ -81,26 +256,50 @@ var fileSignatures = []FileSignature{
 		MagicNumber: []byte{0x7B, 0x5C, 0x72, 0x74, 0x66, 0x31},
 		Offset:      0,
 	},
+	// WPD (WordPerfect document)
+	{
+		Extension:   "wpd",
+		MagicNumber: wordPerfectMagic,
+		Offset:      0,
+		Validator:   validateWordPerfect,
+		MinSize:     512,
+	},
+	// WRI (Windows Write, Word for DOS)
+	{
+		Extension:   "wri",
+		MagicNumber: writeMagic,
+		Offset:      0,
+		Validator:   validateWrite,
+		MinSize:     256,
+	},
+	// WRI with OLE objects
+	{
+		Extension:   "wri",
+		MagicNumber: writeOLEMagic,
+		Offset:      0,
+		Validator:   validateWrite,
+		MinSize:     256,
+	},
 	// ODT (OpenDocument Text)
 	{
 		Extension:   "odt",
 		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
 		Offset:      0,
-		Validator:   validateOpenDocument,
+		Container:   zipContainer,
 	},
 	// ODS (OpenDocument Spreadsheet)
 	{
 		Extension:   "ods",
 		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
 		Offset:      0,
-		Validator:   validateOpenDocument,
+		Container:   zipContainer,
 	},
 	// OTS (OpenDocument Spreadsheet Template)
 	{
 		Extension:   "ots",
 		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
 		Offset:      0,
-		Validator:   validateOpenDocument,
+		Container:   zipContainer,
 	},
 	// FODS (Flat XML OpenDocument Spreadsheet)
 	{
@@ -113,7 +312,28 @@ var fileSignatures = []FileSignature{
 		Extension:   "odp",
 		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
 		Offset:      0,
-		Validator:   validateOpenDocument,
+		Container:   zipContainer,
+	},
+	// EPUB (OCF container)
+	{
+		Extension:   "epub",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// APK (Android package)
+	{
+		Extension:   "apk",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
+	},
+	// JAR (Java archive)
+	{
+		Extension:   "jar",
+		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
+		Offset:      0,
+		Container:   zipContainer,
 	},
 	// ZIP
 	{
@@ -121,6 +341,196 @@ var fileSignatures = []FileSignature{
 		MagicNumber: []byte{0x50, 0x4B, 0x03, 0x04},
 		Offset:      0,
 		Validator:   validateZipFile,
+		Strict:      validateZipStrict,
+		Window:      len(zipSpanMarker),
+	},
+	// EXE (self-extracting archive: Windows executable with an appended ZIP,
+	// RAR or 7z archive)
+	{
+		Extension:   "exe",
+		MagicNumber: peMagic,
+		Offset:      0,
+		Validator:   validateSFX,
+		WeakMagic:   true,
+	},
+	// SQLite 3 database
+	{
+		Extension:   "sqlite",
+		MagicNumber: sqliteMagic,
+		Offset:      0,
+		Validator:   validateSQLite,
+	},
+	// PF (Windows Prefetch)
+	{
+		Extension:   "pf",
+		MagicNumber: prefetchMagic,
+		Offset:      4,
+		Validator:   validatePrefetch,
+	},
+	// PF (Windows 10+ compressed prefetch)
+	{
+		Extension:   "pf",
+		MagicNumber: prefetchCompressedMagic,
+		Offset:      0,
+		Validator:   validateCompressedPrefetch,
+	},
+	// Chrome simple cache entry (payload is re-typed on extraction)
+	{
+		Extension:   "cache",
+		MagicNumber: chromeCacheMagic,
+		Offset:      0,
+		Validator:   validateChromeCacheEntry,
+	},
+	// EDB (Extensible Storage Engine database)
+	{
+		Extension:   "edb",
+		MagicNumber: eseMagic,
+		Offset:      4,
+		Validator:   validateESE,
+	},
+	// ONE (OneNote section)
+	{
+		Extension:   "one",
+		MagicNumber: oneNoteSectionGUID,
+		Offset:      0,
+		Validator:   validateOneNote,
+	},
+	// ONETOC2 (OneNote table of contents)
+	{
+		Extension:   "onetoc2",
+		MagicNumber: oneNoteTOCGUID,
+		Offset:      0,
+		Validator:   validateOneNote,
+	},
+	// WALLET (Bitcoin Core wallet.dat, Berkeley DB)
+	{
+		Extension:   "wallet",
+		MagicNumber: bdbBtreeMagic,
+		Offset:      12,
+		Validator:   validateBDBWallet,
+	},
+	// WALLET (Ethereum Web3 keystore)
+	{
+		Extension:   "wallet",
+		MagicNumber: ethereumKeystoreMagic,
+		Offset:      0,
+		Validator:   validateEthereumKeystore,
+		MinSize:     256,
+	},
+	// WALLET (encrypted Electrum wallet)
+	{
+		Extension:   "wallet",
+		MagicNumber: electrumEncryptedMagic,
+		Offset:      0,
+		Validator:   validateElectrumWallet,
+		MinSize:     128,
+	},
+	// SSH (OpenSSH private key)
+	{
+		Extension:   "ssh",
+		MagicNumber: opensshPrivateKeyMagic,
+		Offset:      0,
+		Validator:   validateOpenSSHPrivateKey,
+		MinSize:     64,
+	},
+	// SSH (public key lines, authorized_keys)
+	{
+		Extension:   "ssh",
+		MagicNumber: []byte("ssh-rsa "),
+		Offset:      0,
+		Validator:   validateSSHPublicKey,
+		MinSize:     64,
+		WeakMagic:   true,
+	},
+	{
+		Extension:   "ssh",
+		MagicNumber: []byte("ssh-ed25519 "),
+		Offset:      0,
+		Validator:   validateSSHPublicKey,
+		MinSize:     64,
+		WeakMagic:   true,
+	},
+	{
+		Extension:   "ssh",
+		MagicNumber: []byte("ssh-dss "),
+		Offset:      0,
+		Validator:   validateSSHPublicKey,
+		MinSize:     64,
+		WeakMagic:   true,
+	},
+	{
+		Extension:   "ssh",
+		MagicNumber: []byte("ecdsa-sha2-nistp256 "),
+		Offset:      0,
+		Validator:   validateSSHPublicKey,
+		MinSize:     64,
+		WeakMagic:   true,
+	},
+	{
+		Extension:   "ssh",
+		MagicNumber: []byte("ecdsa-sha2-nistp384 "),
+		Offset:      0,
+		Validator:   validateSSHPublicKey,
+		MinSize:     64,
+		WeakMagic:   true,
+	},
+	{
+		Extension:   "ssh",
+		MagicNumber: []byte("ecdsa-sha2-nistp521 "),
+		Offset:      0,
+		Validator:   validateSSHPublicKey,
+		MinSize:     64,
+		WeakMagic:   true,
+	},
+	{
+		Extension:   "ssh",
+		MagicNumber: []byte("sk-ssh-ed25519@openssh.com "),
+		Offset:      0,
+		Validator:   validateSSHPublicKey,
+		MinSize:     64,
+		WeakMagic:   true,
+	},
+	{
+		Extension:   "ssh",
+		MagicNumber: []byte("sk-ecdsa-sha2-nistp256@openssh.com "),
+		Offset:      0,
+		Validator:   validateSSHPublicKey,
+		MinSize:     64,
+		WeakMagic:   true,
+	},
+	// PEM (certificates and keys)
+	{
+		Extension:   "pem",
+		MagicNumber: pemMagic,
+		Offset:      0,
+		Validator:   validatePEM,
+		MinSize:     64,
+	},
+	// P12 (PKCS#12 keystore)
+	{
+		Extension:   "p12",
+		MagicNumber: []byte{0x30, 0x82},
+		Offset:      0,
+		Validator:   validatePKCS12,
+		MinSize:     128,
+		WeakMagic:   true,
+	},
+	// CER (DER-encoded X.509 certificate)
+	{
+		Extension:   "cer",
+		MagicNumber: []byte{0x30, 0x82},
+		Offset:      0,
+		Validator:   validateDERCertificate,
+		MinSize:     128,
+		WeakMagic:   true,
+	},
+	// ASC (ASCII-armored PGP block)
+	{
+		Extension:   "asc",
+		MagicNumber: pgpArmorMagic,
+		Offset:      0,
+		Validator:   validatePGPArmor,
+		MinSize:     64,
 	},
 	// HTML
 	{
@@ -140,7 +550,31 @@ var fileSignatures = []FileSignature{
 	},
 }
 
-func FindFileSignatures(data []byte, allowedExtensions map[string]bool) []FileSignature {
+// The gpg signatures, one per magic of the tables in pgp.go, follow asc
+func init() {
+	for i, sig := range fileSignatures {
+		if sig.Extension == "asc" {
+			rest := append(pgpSignatures(), fileSignatures[i+1:]...)
+			fileSignatures = append(fileSignatures[:i+1], rest...)
+			return
+		}
+	}
+}
+
+func FindFileSignatures(data []byte, allowedExtensions map[string]bool, level ValidationLevel) []FileSignature {
+	return findFileSignatures(data, allowedExtensions, level, make(map[*containerClassifier]string), time.Time{})
+}
+
+// FindFileSignaturesBefore is FindFileSignatures for a candidate given up at
+// deadline: no signature is tried after it, and nil is returned
+func FindFileSignaturesBefore(data []byte, allowedExtensions map[string]bool, level ValidationLevel, deadline time.Time) []FileSignature {
+	return findFileSignatures(data, allowedExtensions, level, make(map[*containerClassifier]string), deadline)
+}
+
+// findFileSignatures finds the signatures matching data; classified holds the
+// formats of the containers already told apart, and gets those it tells apart.
+// Once deadline (if set) has passed it gives up and returns nil.
+func findFileSignatures(data []byte, allowedExtensions map[string]bool, level ValidationLevel, classified map[*containerClassifier]string, deadline time.Time) []FileSignature {
 	var found []FileSignature
 
 	for _, sig := range fileSignatures {
@@ -148,21 +582,23 @@ func FindFileSignatures(data []byte, allowedExtensions map[string]bool) []FileSi
 		if len(allowedExtensions) > 0 && !allowedExtensions[sig.Extension] {
 			continue
 		}
-
-		if len(sig.MagicNumber) == 0 {
-			continue
-		}
-
-		offset := sig.Offset
-		end := offset + len(sig.MagicNumber)
-
-		if end > len(data) {
-			continue
+		if deadlinePassed(deadline) {
+			return nil
 		}
 
-		if bytes.Equal(data[offset:end], sig.MagicNumber) {
+		if sig.matchesMagic(data) {
+			if sig.Container != nil {
+				ext, ok := classified[sig.Container]
+				if !ok {
+					ext = sig.Container.classify(data)
+					classified[sig.Container] = ext
+				}
+				if ext != sig.Extension {
+					continue
+				}
+			}
 			if sig.Validator != nil {
-				if !sig.Validator(data) {
+				if !sig.validate(data, level) {
 					continue
 				}
 			}
@@ -170,9 +606,59 @@ func FindFileSignatures(data []byte, allowedExtensions map[string]bool) []FileSi
 		}
 	}
 
+	sortByPriority(found)
 	return found
 }
 
+// validate runs the validator of the signature at the validation level
+func (sig FileSignature) validate(data []byte, level ValidationLevel) bool {
+	switch {
+	case level == ValidationLenient && sig.Lenient != nil:
+		return sig.Lenient(data)
+	case !sig.Validator(data):
+		return false
+	case level == ValidationStrict && sig.Strict != nil:
+		return sig.Strict(data)
+	}
+	return true
+}
+
+// matchesMagic reports whether data starts with the magic number of the
+// signature, at its offset or within its window
+func (sig FileSignature) matchesMagic(data []byte) bool {
+	if len(sig.MagicNumber) == 0 {
+		return false
+	}
+
+	offset := sig.Offset
+	end := offset + len(sig.MagicNumber)
+
+	if end > len(data) {
+		return false
+	}
+
+	match := bytes.Equal(data[offset:end], sig.MagicNumber)
+	if !match && sig.Window > 0 {
+		windowEnd := end + sig.Window
+		if windowEnd > len(data) {
+			windowEnd = len(data)
+		}
+		match = bytes.Contains(data[offset:windowEnd], sig.MagicNumber)
+	}
+	return match
+}
+
+// magicExtension returns the extension of the first allowed signature whose
+// magic number data starts with, whether or not the data passes its checks
+func magicExtension(data []byte, allowedExtensions map[string]bool) string {
+	for _, sig := range fileSignatures {
+		if (len(allowedExtensions) == 0 || allowedExtensions[sig.Extension]) && sig.matchesMagic(data) {
+			return sig.Extension
+		}
+	}
+	return ""
+}
+
 func GetSupportedExtensions() []string {
 	exts := make([]string, 0, len(fileSignatures))
 	for _, sig := range fileSignatures {
@@ -180,3 +666,62 @@ func GetSupportedExtensions() []string {
 	}
 	return exts
 }
+
+// Categories group the extensions of related formats so they can be selected together
+var Categories = map[string][]string{
+	"images":    {"jpg", "jpeg"},
+	"documents": {"doc", "docx", "docm", "dot", "dotx", "dotm", "ppt", "pptx", "pptm", "pot", "potx", "potm", "xls", "xlsx", "xlsm", "xlt", "xltx", "xltm", "xlsb", "ooxml", "vsd", "vsdx", "pub", "mpp", "wps", "xlr", "pdf", "rtf", "wpd", "wri", "odt", "ods", "ots", "fods", "odp", "epub", "one", "onetoc2"},
+	"archives":  {"zip", "jar", "apk", "exe"},
+	"office":    {"doc", "docx", "docm", "dot", "dotx", "dotm", "ppt", "pptx", "pptm", "pot", "potx", "potm", "xls", "xlsx", "xlsm", "xlt", "xltx", "xltm", "xlsb", "ooxml", "vsd", "vsdx", "pub", "mpp", "one", "onetoc2"},
+}
+
+// AddCategory adds the extensions of a category to an allowed set
+func AddCategory(allowed map[string]bool, category string) {
+	for _, ext := range Categories[category] {
+		allowed[ext] = true
+	}
+}
+
+// ParseExtensions turns a comma-separated list of extensions (or "all") into the
+// set of allowed extensions; an empty set allows every format
+func ParseExtensions(extStr string) map[string]bool {
+	allowed := make(map[string]bool)
+	if extStr == "all" {
+		for _, ext := range GetSupportedExtensions() {
+			allowed[ext] = true
+		}
+		return allowed
+	}
+
+	exts := strings.Split(extStr, ",")
+	for _, ext := range exts {
+		ext = strings.TrimSpace(strings.ToLower(ext))
+		if ext != "" {
+			allowed[ext] = true
+		}
+	}
+	return allowed
+}
+
+// ExcludeExtensions removes a comma-separated list of extensions from the allowed
+// set, starting from every format if the set is empty; jpg and jpeg are one format
+func ExcludeExtensions(allowed map[string]bool, excludeStr string) map[string]bool {
+	excluded := ParseExtensions(excludeStr)
+	if len(excluded) == 0 {
+		return allowed
+	}
+	if excluded["jpg"] || excluded["jpeg"] {
+		excluded["jpg"], excluded["jpeg"] = true, true
+	}
+
+	result := make(map[string]bool)
+	if len(allowed) == 0 {
+		allowed = ParseExtensions("all")
+	}
+	for ext := range allowed {
+		if !excluded[ext] {
+			result[ext] = true
+		}
+	}
+	return result
+}
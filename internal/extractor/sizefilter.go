@@ -0,0 +1,89 @@
+package extractor
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"splitter-files/pkg/fileutils"
+)
+
+// SizeRange bounds the size of carved files of one format (Max 0 - no upper bound)
+type SizeRange struct {
+	Min, Max int64
+}
+
+// SizeFilter maps extensions to the sizes their carved files may have; files of
+// other formats are not filtered
+type SizeFilter map[string]SizeRange
+
+// ParseSizeFilter parses rules such as "jpg:100KB-20MB, pdf:10KB-" separated by
+// commas or new lines; text after # is a comment
+func ParseSizeFilter(s string) (SizeFilter, error) {
+	filter := make(SizeFilter)
+	for _, line := range strings.Split(s, "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		for _, rule := range strings.Split(line, ",") {
+			rule = strings.TrimSpace(rule)
+			if rule == "" {
+				continue
+			}
+			if err := filter.add(rule); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return filter, nil
+}
+
+// LoadSizeFilter reads the rules of a size filter file, one or more per line
+func LoadSizeFilter(path string) (SizeFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSizeFilter(string(data))
+}
+
+func (f SizeFilter) add(rule string) error {
+	ext, bounds, ok := strings.Cut(rule, ":")
+	minStr, maxStr, hasDash := strings.Cut(bounds, "-")
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !ok || !hasDash || ext == "" {
+		return fmt.Errorf("invalid size rule %q (expected ext:min-max)", rule)
+	}
+
+	var r SizeRange
+	var err error
+	if minStr = strings.TrimSpace(minStr); minStr != "" {
+		if r.Min, err = fileutils.ParseSize(minStr); err != nil {
+			return fmt.Errorf("invalid size rule %q: %v", rule, err)
+		}
+	}
+	if maxStr = strings.TrimSpace(maxStr); maxStr != "" {
+		if r.Max, err = fileutils.ParseSize(maxStr); err != nil {
+			return fmt.Errorf("invalid size rule %q: %v", rule, err)
+		}
+		if r.Max < r.Min {
+			return fmt.Errorf("invalid size rule %q: maximum below minimum", rule)
+		}
+	}
+
+	f[ext] = r
+	if ext == "jpg" || ext == "jpeg" {
+		f["jpg"], f["jpeg"] = r, r
+	}
+	return nil
+}
+
+// Allows reports whether a carved file of size bytes with extension ext passes
+// the filter
+func (f SizeFilter) Allows(ext string, size int) bool {
+	r, ok := f[ext]
+	if !ok {
+		return true
+	}
+	return int64(size) >= r.Min && (r.Max == 0 || int64(size) <= r.Max)
+}
@@ -0,0 +1,16 @@
+package extractor
+
+import "fmt"
+
+// SkipError is returned for a file that was found but not written because it
+// does not pass one of the extraction filters
+type SkipError struct {
+	Extension string
+	Start     int
+	Size      int
+	Reason    string
+}
+
+func (e *SkipError) Error() string {
+	return fmt.Sprintf("skipped %s at %d (%d bytes): %s", e.Extension, e.Start, e.Size, e.Reason)
+}
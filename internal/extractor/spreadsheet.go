@@ -0,0 +1,191 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// Workbooks reaching outside of themselves: links to other workbooks, DDE links
+// that start programs and WEBSERVICE formulas that fetch URLs are staples of
+// phishing spreadsheets
+
+// BIFF8 records and supporting link markers
+const (
+	biffName         = 0x0018
+	biffExternName   = 0x0023
+	biffSupBook      = 0x01AE
+	supBookSelf      = 0x0401
+	supBookAddIn     = 0x3A01
+	supBookDDEMarker = 0x03 // separates the server and the topic of a DDE link
+)
+
+// maxWorksheetSize bounds the decompressed size of a worksheet searched for formulas
+const maxWorksheetSize = 64 << 20
+
+var (
+	// ddeFormula matches a DDE reference typed in a formula, server|'topic'!item
+	ddeFormula        = regexp.MustCompile(`[A-Za-z0-9_.-]+\|'`)
+	webServiceFormula = regexp.MustCompile(`(?i)\bWEBSERVICE\s*\(`)
+)
+
+// addIndicator records an indicator once
+func addIndicator(info *models.OfficeDocumentInfo, indicator string) {
+	for _, existing := range info.Indicators {
+		if existing == indicator {
+			return
+		}
+	}
+	info.Indicators = append(info.Indicators, indicator)
+}
+
+// readWorkbookIndicators looks for external links, DDE links and WEBSERVICE
+// formulas in the external link parts and the formulas of an SpreadsheetML
+// package, until deadline
+func readWorkbookIndicators(zipReader *zip.Reader, info *models.OfficeDocumentInfo, deadline time.Time) {
+	for _, file := range zipReader.File {
+		if deadlinePassed(deadline) {
+			return
+		}
+		switch dir := path.Dir(file.Name); {
+		case dir == "xl/externalLinks":
+			externalLinkIndicators(file, info)
+		case (dir == "xl/worksheets" || dir == "xl/macrosheets") && strings.HasSuffix(file.Name, ".xml"):
+			formulaIndicators(file, info)
+		}
+	}
+}
+
+// externalLinkIndicators classifies an xl/externalLinks part: a link to another
+// workbook or a DDE link; binary (XLSB) parts are taken as workbook links
+func externalLinkIndicators(file *zip.File, info *models.OfficeDocumentInfo) {
+	if !strings.HasSuffix(file.Name, ".xml") {
+		addIndicator(info, models.IndicatorExternalLink)
+		return
+	}
+
+	rc, err := openEntry(file)
+	if err != nil {
+		return
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "externalBook":
+				addIndicator(info, models.IndicatorExternalLink)
+				return
+			case "ddeLink":
+				addIndicator(info, models.IndicatorDDE)
+				return
+			}
+		}
+	}
+}
+
+// formulaIndicators searches the formulas (f elements) of a worksheet for DDE
+// references and WEBSERVICE calls
+func formulaIndicators(file *zip.File, info *models.OfficeDocumentInfo) {
+	rc, err := file.Open()
+	if err != nil {
+		return
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxWorksheetSize))
+	inFormula := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			inFormula = t.Name.Local == "f"
+		case xml.EndElement:
+			inFormula = false
+		case xml.CharData:
+			if !inFormula {
+				continue
+			}
+			if ddeFormula.Match(t) {
+				addIndicator(info, models.IndicatorDDE)
+			}
+			if webServiceFormula.Match(t) {
+				addIndicator(info, models.IndicatorWebService)
+			}
+		}
+	}
+}
+
+// readBIFFIndicators looks for the same traits in an Excel 97-2003 workbook:
+// SUPBOOK records of other workbooks and DDE servers, and the _xlfn.WEBSERVICE
+// name newer functions are stored under
+func readBIFFIndicators(c *cfbFile, info *models.OfficeDocumentInfo) {
+	webService := []byte("WEBSERVICE")
+	walkBIFFGlobals(c, func(recordType uint16, body []byte) bool {
+		switch recordType {
+		case biffFilePass:
+			// The records that follow are encrypted
+			return false
+		case biffSupBook:
+			if indicator := supBookIndicator(body); indicator != "" {
+				addIndicator(info, indicator)
+			}
+		case biffName, biffExternName:
+			upper := bytes.ToUpper(body)
+			if bytes.Contains(upper, webService) || bytes.Contains(upper, utf16LE(string(webService))) {
+				addIndicator(info, models.IndicatorWebService)
+			}
+		}
+		return true
+	})
+}
+
+// supBookIndicator classifies a SUPBOOK record: ctab, cch and a virtual path of
+// cch characters; external workbooks list their sheets (ctab > 0), DDE links have
+// no sheets and a "server\x03topic" path
+func supBookIndicator(body []byte) string {
+	if len(body) < 5 {
+		return ""
+	}
+	sheets := binary.LittleEndian.Uint16(body)
+	cch := int(binary.LittleEndian.Uint16(body[2:]))
+	if cch == supBookSelf || cch == supBookAddIn {
+		return ""
+	}
+	if sheets > 0 {
+		return models.IndicatorExternalLink
+	}
+
+	chars := body[5:]
+	if body[4]&0x01 != 0 {
+		for i := 0; i+1 < len(chars) && i < 2*cch; i += 2 {
+			if binary.LittleEndian.Uint16(chars[i:]) == supBookDDEMarker {
+				return models.IndicatorDDE
+			}
+		}
+		return ""
+	}
+	if len(chars) > cch {
+		chars = chars[:cch]
+	}
+	if bytes.IndexByte(chars, supBookDDEMarker) >= 0 {
+		return models.IndicatorDDE
+	}
+	return ""
+}
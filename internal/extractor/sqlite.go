@@ -0,0 +1,76 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+const sqliteHeaderSize = 100
+
+// sqlitePageSize returns the database page size declared in the header or 0 if it is invalid
+func sqlitePageSize(data []byte) int {
+	if len(data) < sqliteHeaderSize {
+		return 0
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize > 65536 || pageSize&(pageSize-1) != 0 {
+		return 0
+	}
+	return pageSize
+}
+
+func validateSQLite(data []byte) bool {
+	// The header is followed by the b-tree header of page 1
+	if len(data) <= sqliteHeaderSize || !bytes.HasPrefix(data, sqliteMagic) {
+		return false
+	}
+
+	if sqlitePageSize(data) == 0 {
+		return false
+	}
+
+	// File format write/read versions: 1 - legacy, 2 - WAL
+	if data[18] < 1 || data[18] > 2 || data[19] < 1 || data[19] > 2 {
+		return false
+	}
+
+	// Payload fractions are fixed by the file format
+	if data[21] != 64 || data[22] != 32 || data[23] != 32 {
+		return false
+	}
+
+	// Page 1 starts with the b-tree header of sqlite_schema right after the file
+	// header, which is a table b-tree: a leaf or an interior page
+	switch data[sqliteHeaderSize] {
+	case 0x05, 0x0D:
+	default:
+		return false
+	}
+
+	return true
+}
+
+// sqliteDatabaseSize calculates the database length as page size × page count.
+// Returns 0 if the in-header page count can't be trusted.
+func sqliteDatabaseSize(data []byte) int {
+	pageSize := sqlitePageSize(data)
+	if pageSize == 0 {
+		return 0
+	}
+
+	// The page count is only valid if it was written by the same transaction as the change counter
+	changeCounter := binary.BigEndian.Uint32(data[24:28])
+	versionValidFor := binary.BigEndian.Uint32(data[92:96])
+	pageCount := int(binary.BigEndian.Uint32(data[28:32]))
+	if changeCounter != versionValidFor || pageCount == 0 {
+		return 0
+	}
+
+	return pageSize * pageCount
+}
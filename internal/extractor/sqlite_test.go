@@ -0,0 +1,59 @@
+package extractor
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// sqliteHeader returns a database header of pages pages of pageSize bytes,
+// followed by the start of a leaf table b-tree page, padded to size bytes
+func sqliteHeader(pageSize, pages, size int) []byte {
+	data := make([]byte, size)
+	copy(data, sqliteMagic)
+	binary.BigEndian.PutUint16(data[16:18], uint16(pageSize))
+	data[18], data[19] = 1, 1
+	data[21], data[22], data[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(data[24:28], 1)
+	binary.BigEndian.PutUint32(data[28:32], uint32(pages))
+	binary.BigEndian.PutUint32(data[92:96], 1)
+	if size > sqliteHeaderSize {
+		data[sqliteHeaderSize] = 0x0D
+	}
+	return data
+}
+
+func TestDetectTruncatedSQLite(t *testing.T) {
+	// The header declares 400 KiB, of which only 8 KiB were recovered
+	data := sqliteHeader(4096, 100, 8192)
+	result, fileData, err := detectFile(data, nil, ValidationNormal, nil, 0, nil)
+	if err != nil {
+		t.Fatalf("detectFile: %v", err)
+	}
+	if result.FileType != "SQLite Database" {
+		t.Errorf("file type %q, want SQLite Database", result.FileType)
+	}
+	if len(fileData) != len(data) {
+		t.Errorf("carved %d bytes, want the %d bytes left", len(fileData), len(data))
+	}
+}
+
+func TestValidateSQLite(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"leaf table page", sqliteHeader(4096, 1, 4096), true},
+		{"header only", sqliteHeader(4096, 1, sqliteHeaderSize), false},
+		{"index page", func() []byte {
+			data := sqliteHeader(4096, 1, 4096)
+			data[sqliteHeaderSize] = 0x0A
+			return data
+		}(), false},
+	}
+	for _, tt := range tests {
+		if got := validateSQLite(tt.data); got != tt.want {
+			t.Errorf("%s: validateSQLite = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,30 +2,38 @@ package extractor
 
 import (
 	"bytes"
-	"splitter-files/internal/models"
+	"encoding/binary"
+	"fmt"
 )
 
-type FileValidator interface {
-	Validate(data []byte) bool
-}
-
-type MSOfficeValidator struct{}
-type OfficeOpenXMLValidator struct {
-	expectedContent string
-	expectedType    models.OfficeFileType
-}
-type JPEGValidator struct{}
-
-func (v *MSOfficeValidator) Validate(data []byte) bool {
-	return validateMSOfficeFile(data)
-}
-
-func (v *OfficeOpenXMLValidator) Validate(data []byte) bool {
-	return validateOfficeOpenXML(v.expectedContent, v.expectedType)(data)
-}
+// ValidationLevel is how much of the structure of a file validators require
+type ValidationLevel int
+
+const (
+	// ValidationNormal runs the checks of each format as they are
+	ValidationNormal ValidationLevel = iota
+	// ValidationStrict also requires the structures a reader can't do without:
+	// the marker segments of a JPEG up to its image data, a %%EOF whose
+	// startxref points at a cross-reference section of the PDF, the central
+	// directory of a ZIP archive
+	ValidationStrict
+	// ValidationLenient accepts files whose end is missing, for data recovery:
+	// JPEGs without an End of Image marker and PDFs without a cross-reference
+	// section, startxref or %%EOF
+	ValidationLenient
+)
 
-func (v *JPEGValidator) Validate(data []byte) bool {
-	return validateJpegImproved(data)
+// ParseValidationLevel parses a validation level: strict, normal or lenient
+func ParseValidationLevel(s string) (ValidationLevel, error) {
+	switch s {
+	case "normal", "":
+		return ValidationNormal, nil
+	case "strict":
+		return ValidationStrict, nil
+	case "lenient":
+		return ValidationLenient, nil
+	}
+	return 0, fmt.Errorf("unknown validation level %q (strict, normal or lenient)", s)
 }
 
 // Improved JPEG validation
@@ -41,36 +49,73 @@ func validateJpegImproved(data []byte) bool {
 	}
 
 	// Check for End of Image (EOI) marker
-	hasEOI := false
+	return jpegEnd(data) > 0
+}
+
+// validateJpegLenient only checks that the Start of Image marker is followed
+// by another marker, so that JPEGs cut off before their End of Image are kept
+func validateJpegLenient(data []byte) bool {
+	return len(data) >= 4 && bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}) && data[3] >= 0xC0 && data[3] != 0xFF
+}
+
+// validateJpegStrict walks the marker segments of a JPEG from the Start of
+// Image to the Start of Scan, which must follow a Start of Frame, and requires
+// an End of Image after the scan before any other Start of Image
+func validateJpegStrict(data []byte) bool {
+	frame := false
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return false
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte
+			pos++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			// Markers without a length
+			pos += 2
+			continue
+		case marker == 0xD8 || marker == 0xD9:
+			return false
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return false
+		}
+		switch {
+		case marker == 0xDA:
+			// Entropy-coded data can't hold other markers than restarts
+			scan := data[pos+2+length:]
+			end := bytes.Index(scan, []byte{0xFF, 0xD9})
+			next := bytes.Index(scan, []byte{0xFF, 0xD8})
+			return frame && end >= 0 && (next < 0 || end < next)
+		case marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			frame = true
+		}
+		pos += 2 + length
+	}
+	return false
+}
+
+// jpegEnd returns the end of the last End of Image (EOI) marker in data, or 0
+func jpegEnd(data []byte) int {
 	for i := len(data) - 2; i >= 0; i-- {
 		if data[i] == 0xFF && data[i+1] == 0xD9 {
-			hasEOI = true
-			break
+			return i + 2
 		}
 	}
-	if !hasEOI {
-		return false
-	}
-	return true
+	return 0
 }
 
 func validatePdf(data []byte) bool {
-	if len(data) < 100 {
-		return false
-	}
-
-	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+	if !pdfHeaderValid(data) {
 		return false
 	}
 
-	if len(data) >= 8 {
-		version := string(data[5:8])
-		if version < "1.0" || version > "2.0" {
-			return false
-		}
-	}
-
-	if !bytes.Contains(data, []byte("xref")) {
+	if !pdfHasCrossReference(data) {
 		return false
 	}
 
@@ -97,6 +142,57 @@ func validatePdf(data []byte) bool {
 	return true
 }
 
+// pdfHeaderValid checks the %PDF- header and its version
+func pdfHeaderValid(data []byte) bool {
+	if len(data) < 100 {
+		return false
+	}
+
+	// Junk before the header is only part of the file when its offsets count it
+	header := pdfHeaderOffset(data)
+	if header < 0 || header > 0 && PDFStart(data, header) != 0 {
+		return false
+	}
+
+	if len(data) >= header+8 {
+		version := string(data[header+5 : header+8])
+		if version < "1.0" || version > "2.0" {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePdfLenient accepts a PDF header followed by an object, for PDFs cut
+// off before their cross-reference section, startxref or %%EOF
+func validatePdfLenient(data []byte) bool {
+	return pdfHeaderValid(data) && (bytes.Contains(data, []byte(" 0 obj")) || bytes.Contains(data, []byte("\n0 obj")))
+}
+
+// validatePdfStrict requires a %%EOF whose startxref points at a
+// cross-reference section of the PDF
+func validatePdfStrict(data []byte) bool {
+	for i, tries := 0, 0; tries < maxPDFEOFMarkers; tries++ {
+		k := bytes.Index(data[i:], pdfEOF)
+		if k < 0 {
+			return false
+		}
+		if pdfStartXrefValid(data, i+k) {
+			return true
+		}
+		i += k + len(pdfEOF)
+	}
+	return false
+}
+
+// validateZipStrict requires the end of central directory record of the
+// archive, which readers open it by
+func validateZipStrict(data []byte) bool {
+	return zipArchiveEnd(bytes.TrimPrefix(data, zipSpanMarker)) > 0
+}
+
 func validateZipFile(data []byte) bool {
-	return bytes.Equal(data[:4], []byte{0x50, 0x4B, 0x03, 0x04})
+	// The first segment of a spanned or split archive begins with a marker
+	data = bytes.TrimPrefix(data, zipSpanMarker)
+	return len(data) >= 4 && bytes.Equal(data[:4], []byte{0x50, 0x4B, 0x03, 0x04})
 }
@@ -0,0 +1,309 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VBA projects ([MS-OVBA]): a "VBA" storage with a compressed "dir" stream that
+// lists the modules and the offset of the compressed source in each module stream
+
+const (
+	vbaChunkSize = 4096
+
+	vbaRecordCodePage         = 0x0003
+	vbaRecordProjectVersion   = 0x0009
+	vbaRecordModuleName       = 0x0019
+	vbaRecordModuleStreamName = 0x001A
+	vbaRecordModuleProcedural = 0x0021
+	vbaRecordModuleClass      = 0x0022
+	vbaRecordModuleOffset     = 0x0031
+)
+
+type vbaModule struct {
+	Name       string
+	StreamName string
+	Offset     uint32
+	IsClass    bool
+}
+
+// VBAModuleSource is the decompressed source of one module of a VBA project
+type VBAModuleSource struct {
+	Name    string
+	IsClass bool
+	Source  string
+}
+
+// ExtractVBASource returns the module sources of the VBA project of a binary
+// (compound file) or Open XML Office document
+func ExtractVBASource(data []byte) ([]VBAModuleSource, error) {
+	if validateZipFile(data) {
+		project, err := ooxmlVBAProject(data)
+		if err != nil {
+			return nil, err
+		}
+		data = project
+	}
+
+	c, err := parseCFB(data)
+	if err != nil {
+		return nil, err
+	}
+
+	vba := findVBAStorage(c)
+	if vba == nil {
+		return nil, errors.New("no VBA project")
+	}
+
+	var dirEntry *cfbEntry
+	for _, child := range c.Children(vba) {
+		if strings.EqualFold(child.Name, "dir") {
+			dirEntry = child
+		}
+	}
+	compressedDir, err := c.ReadStream(dirEntry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VBA dir stream: %v", err)
+	}
+
+	dir, err := ovbaDecompress(compressedDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress VBA dir stream: %v", err)
+	}
+
+	modules, codepage := parseVBADir(dir)
+	var sources []VBAModuleSource
+	for _, module := range modules {
+		var stream *cfbEntry
+		for _, child := range c.Children(vba) {
+			if strings.EqualFold(child.Name, module.StreamName) {
+				stream = child
+			}
+		}
+
+		raw, err := c.ReadStream(stream)
+		if err != nil || int(module.Offset) >= len(raw) {
+			continue
+		}
+
+		source, err := ovbaDecompress(raw[module.Offset:])
+		if err != nil {
+			continue
+		}
+
+		sources = append(sources, VBAModuleSource{
+			Name:    module.Name,
+			IsClass: module.IsClass,
+			Source:  decodeCodepage(source, codepage),
+		})
+	}
+
+	if len(sources) == 0 {
+		return nil, errors.New("VBA project has no readable modules")
+	}
+	return sources, nil
+}
+
+// DumpVBASource writes the VBA modules of a document into dir as .bas (standard
+// modules) and .cls (class and document modules) files and returns their number
+func DumpVBASource(data []byte, dir string) (int, error) {
+	sources, err := ExtractVBASource(data)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	used := make(map[string]bool)
+	for i, module := range sources {
+		name := sanitizeFileName(module.Name, "")
+		if name == "" {
+			name = fmt.Sprintf("module_%d", i+1)
+		}
+
+		ext := ".bas"
+		if module.IsClass {
+			ext = ".cls"
+		}
+		name = claimName(used, name, ext, nil)
+
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(module.Source), 0644); err != nil {
+			return i, err
+		}
+	}
+	return len(sources), nil
+}
+
+// ooxmlVBAProject returns vbaProject.bin of a macro-enabled Open XML document
+func ooxmlVBAProject(data []byte) ([]byte, error) {
+	zipReader, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range zipReader.File {
+		if strings.HasSuffix(file.Name, "/vbaProject.bin") {
+			return readZipFile(file)
+		}
+	}
+	return nil, errors.New("no vbaProject.bin")
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := openEntry(file)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// findVBAStorage returns the "VBA" storage that holds the dir stream (Macros/VBA
+// in Word, _VBA_PROJECT_CUR/VBA in Excel, VBA at the root of vbaProject.bin)
+func findVBAStorage(c *cfbFile) *cfbEntry {
+	for i := range c.entries {
+		e := &c.entries[i]
+		if e.Type != cfbTypeStorage || !strings.EqualFold(e.Name, "VBA") {
+			continue
+		}
+		for _, child := range c.Children(e) {
+			if child.Type == cfbTypeStream && strings.EqualFold(child.Name, "dir") {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// parseVBADir reads the module records and the project code page from the decompressed dir stream
+func parseVBADir(dir []byte) ([]vbaModule, int) {
+	var modules []vbaModule
+	var current *vbaModule
+	codepage := 1252
+
+	pos := 0
+	for pos+6 <= len(dir) {
+		id := binary.LittleEndian.Uint16(dir[pos : pos+2])
+		size := int(binary.LittleEndian.Uint32(dir[pos+2 : pos+6]))
+		pos += 6
+
+		// PROJECTVERSION declares 4 bytes but is followed by 6
+		if id == vbaRecordProjectVersion {
+			size = 6
+		}
+		if size < 0 || pos+size > len(dir) {
+			break
+		}
+		value := dir[pos : pos+size]
+		pos += size
+
+		switch id {
+		case vbaRecordCodePage:
+			if size == 2 {
+				codepage = int(binary.LittleEndian.Uint16(value))
+			}
+		case vbaRecordModuleName:
+			modules = append(modules, vbaModule{Name: string(value)})
+			current = &modules[len(modules)-1]
+		case vbaRecordModuleStreamName:
+			if current != nil {
+				current.StreamName = string(value)
+			}
+		case vbaRecordModuleOffset:
+			if current != nil && size == 4 {
+				current.Offset = binary.LittleEndian.Uint32(value)
+			}
+		case vbaRecordModuleClass:
+			if current != nil {
+				current.IsClass = true
+			}
+		case vbaRecordModuleProcedural:
+			if current != nil {
+				current.IsClass = false
+			}
+		}
+	}
+
+	return modules, codepage
+}
+
+// ovbaDecompress decompresses a CompressedContainer ([MS-OVBA] 2.4.1)
+func ovbaDecompress(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != 0x01 {
+		return nil, errors.New("invalid compressed container signature")
+	}
+
+	var out bytes.Buffer
+	pos := 1
+	for pos+2 <= len(data) {
+		header := binary.LittleEndian.Uint16(data[pos : pos+2])
+		chunkEnd := pos + int(header&0x0FFF) + 3
+		if chunkEnd > len(data) {
+			chunkEnd = len(data)
+		}
+		pos += 2
+
+		if header&0x8000 == 0 {
+			// Raw chunk of 4096 bytes
+			end := pos + vbaChunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			out.Write(data[pos:end])
+			pos = end
+			continue
+		}
+
+		chunkStart := out.Len()
+		for pos < chunkEnd {
+			flags := data[pos]
+			pos++
+
+			for bit := uint(0); bit < 8 && pos < chunkEnd; bit++ {
+				if flags&(1<<bit) == 0 {
+					out.WriteByte(data[pos])
+					pos++
+					continue
+				}
+
+				if pos+2 > chunkEnd {
+					return nil, errors.New("truncated copy token")
+				}
+				token := int(binary.LittleEndian.Uint16(data[pos : pos+2]))
+				pos += 2
+
+				bitCount := 4
+				for (1 << uint(bitCount)) < out.Len()-chunkStart {
+					bitCount++
+				}
+				if bitCount > 12 {
+					bitCount = 12
+				}
+
+				lengthMask := 0xFFFF >> uint(bitCount)
+				length := token&lengthMask + 3
+				offset := token>>uint(16-bitCount) + 1
+
+				src := out.Len() - offset
+				if src < chunkStart {
+					return nil, errors.New("copy token points before the chunk")
+				}
+				for i := 0; i < length; i++ {
+					out.WriteByte(out.Bytes()[src+i])
+				}
+			}
+		}
+		pos = chunkEnd
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// VerifyFile checks that data is a whole file of the format with extension ext,
+// the way it would have been carved: the format validator accepts it, the
+// detected end of the file is not before the end of data, and ZIP archives and
+// PDFs open the way a reader opens them
+func VerifyFile(data []byte, ext string) error {
+	if ext == "jpeg" {
+		ext = "jpg"
+	}
+
+	// A file running up to the end of the input is cut at the next occurrence of
+	// its signature; the carved file was followed by more input, so a padding byte
+	// stands in for it
+	padded := make([]byte, len(data)+1)
+	copy(padded, data)
+
+	_, fileData, err := detectFile(padded, map[string]bool{ext: true}, ValidationNormal, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	if len(fileData) < len(data) {
+		return fmt.Errorf("%s data ends at %d of %d bytes", ext, len(fileData), len(data))
+	}
+	return openFile(data, ext)
+}
+
+// openFile opens ZIP archives by their central directory, reading the entries
+// that fit in the package limits to check their CRC, and PDFs by the catalog
+// their trailer points at; other formats, the segments of spanned archives and
+// encrypted PDFs aren't checked
+func openFile(data []byte, ext string) error {
+	switch {
+	case bytes.HasPrefix(data, zipLocalHeaderMagic):
+		return openZipFile(data)
+	case ext == "pdf":
+		return openPDFFile(data)
+	}
+	return nil
+}
+
+// openZipFile opens the archive the whole of data is, as archive readers do,
+// from the end of central directory record at its end
+func openZipFile(data []byte) error {
+	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("archive doesn't open: %v", err)
+	}
+	if len(zipReader.File) > maxPackageEntries {
+		return nil
+	}
+
+	pkg := &zipPackage{Reader: zipReader, remaining: maxPackageRead}
+	for _, file := range zipReader.File {
+		if _, err := file.DataOffset(); err != nil {
+			return fmt.Errorf("entry %s: no local header at its offset", file.Name)
+		}
+		if file.FileInfo().IsDir() || file.Flags&zipFlagEncrypted != 0 || file.UncompressedSize64 > maxPackageEntryRead {
+			continue
+		}
+		rc, err := pkg.open(file)
+		if err == nil {
+			_, err = io.Copy(io.Discard, rc)
+			rc.Close()
+		}
+		if errors.Is(err, errZipLimit) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("entry %s: %v", file.Name, err)
+		}
+	}
+	return nil
+}
+
+// openPDFFile checks that the last startxref of a PDF points at a
+// cross-reference section and that its trailer names a catalog
+func openPDFFile(data []byte) error {
+	eof := bytes.LastIndex(data, []byte("%%EOF"))
+	if eof < 0 {
+		return errors.New("no %%EOF")
+	}
+	if !pdfStartXrefValid(data, eof) {
+		return errors.New("startxref doesn't point at a cross-reference section")
+	}
+
+	objects := parsePDFObjects(data, time.Time{})
+	if _, encrypted := pdfEncryption(data, objects); encrypted {
+		return nil
+	}
+	if _, ok := pdfResolve(objects, pdfTrailerEntry(data, objects, "Root")); !ok {
+		return errors.New("trailer doesn't point at a catalog")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// Berkeley DB btree metadata page magic used by Bitcoin Core wallet.dat
+var bdbBtreeMagic = []byte{0x62, 0x31, 0x05, 0x00}
+
+var (
+	ethereumKeystoreMagic = []byte(`{"address":"`)
+	// Base64 of "BIE1", the ECIES prefix of an encrypted Electrum wallet
+	electrumEncryptedMagic = []byte("QklFMQ")
+)
+
+const (
+	bdbMetaSize        = 72
+	walletMaxJSONSize  = 64 * 1024
+	walletMaxArmorSize = 16 * 1024 * 1024
+)
+
+// Record keys written by Bitcoin-derived wallets (length-prefixed strings)
+var bdbWalletKeys = [][]byte{
+	[]byte("\x04mkey"), []byte("\x04ckey"), []byte("\x03key"), []byte("\x0adefaultkey"),
+	[]byte("\x0aminversion"), []byte("\x07keymeta"), []byte("\x10walletdescriptor"),
+}
+
+// bdbPageSize returns the page size of a Berkeley DB btree file or 0 if the header is invalid
+func bdbPageSize(data []byte) int {
+	if len(data) < bdbMetaSize || !bytes.Equal(data[12:16], bdbBtreeMagic) {
+		return 0
+	}
+
+	pageSize := int(binary.LittleEndian.Uint32(data[20:24]))
+	if pageSize < 512 || pageSize > 65536 || pageSize&(pageSize-1) != 0 {
+		return 0
+	}
+	return pageSize
+}
+
+// bdbFileSize derives the file length from the last page number in the metadata page
+func bdbFileSize(data []byte) int {
+	pageSize := bdbPageSize(data)
+	if pageSize == 0 {
+		return 0
+	}
+	lastPage := int(binary.LittleEndian.Uint32(data[32:36]))
+	return (lastPage + 1) * pageSize
+}
+
+func validateBDBWallet(data []byte) bool {
+	size := bdbFileSize(data)
+	if size == 0 {
+		return false
+	}
+	if size > len(data) {
+		size = len(data)
+	}
+
+	for _, key := range bdbWalletKeys {
+		if bytes.Contains(data[:size], key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ethereumKeystoreSize returns the length of a Web3 Secret Storage (v3) JSON keystore
+func ethereumKeystoreSize(data []byte) int {
+	window := data
+	if len(window) > walletMaxJSONSize {
+		window = window[:walletMaxJSONSize]
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(window))
+	var keystore struct {
+		Address string          `json:"address"`
+		Crypto  json.RawMessage `json:"crypto"`
+		Version int             `json:"version"`
+	}
+	if err := decoder.Decode(&keystore); err != nil {
+		return 0
+	}
+	if keystore.Version != 3 || len(keystore.Crypto) == 0 || !bytes.Contains(keystore.Crypto, []byte(`"kdf"`)) {
+		return 0
+	}
+	return int(decoder.InputOffset())
+}
+
+func validateEthereumKeystore(data []byte) bool {
+	return ethereumKeystoreSize(data) > 0
+}
+
+// electrumWalletSize returns the length of the base64 blob of an encrypted Electrum wallet
+func electrumWalletSize(data []byte) int {
+	end := 0
+	for end < len(data) && end < walletMaxArmorSize && isBase64Char(data[end]) {
+		end++
+	}
+	if end < 128 || end%4 != 0 {
+		return 0
+	}
+	return end
+}
+
+func validateElectrumWallet(data []byte) bool {
+	return electrumWalletSize(data) > 0
+}
+
+func isBase64Char(b byte) bool {
+	return b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '+' || b == '/' || b == '='
+}
+
+// isSQLiteWallet recognizes Bitcoin Core descriptor wallets stored in SQLite
+func isSQLiteWallet(data []byte) bool {
+	return bytes.Contains(data, []byte("\x10walletdescriptor")) || bytes.Contains(data, []byte("\x0aminversion"))
+}
@@ -0,0 +1,72 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Legacy word processor formats: Windows Write and Word for DOS, WordPerfect
+
+var (
+	// wIdent (0xBE31, or 0xBE32 with OLE objects), dty 0 and wTool 0xAB00
+	writeMagic       = []byte{0x31, 0xBE, 0x00, 0x00, 0x00, 0xAB}
+	writeOLEMagic    = []byte{0x32, 0xBE, 0x00, 0x00, 0x00, 0xAB}
+	wordPerfectMagic = []byte{0xFF, 0x57, 0x50, 0x43}
+)
+
+const (
+	writePageSize   = 128
+	writeHeaderSize = 128
+
+	wordPerfectHeaderSize   = 16
+	wordPerfectProduct      = 1    // WordPerfect
+	wordPerfectDocumentType = 0x0A // as opposed to macros, dictionaries, ...
+)
+
+// validateWrite checks the header of a Write or Word for DOS document: the text
+// starts on the second 128-byte page and the paragraph table follows it
+func validateWrite(data []byte) bool {
+	if len(data) < writeHeaderSize || !bytes.HasPrefix(data, writeMagic) && !bytes.HasPrefix(data, writeOLEMagic) {
+		return false
+	}
+	for _, b := range data[6:14] {
+		if b != 0 {
+			return false
+		}
+	}
+
+	fcMac := binary.LittleEndian.Uint32(data[14:18])
+	pnPara := binary.LittleEndian.Uint16(data[18:20])
+	if fcMac < writeHeaderSize || uint32(pnPara) != (fcMac+writePageSize-1)/writePageSize {
+		return false
+	}
+	return writeFileSize(data) >= int(fcMac)
+}
+
+// writeFileSize returns the size of a Write document from pnMac, its number of
+// 128-byte pages
+func writeFileSize(data []byte) int {
+	if len(data) < writeHeaderSize {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(data[0x60:0x62])) * writePageSize
+}
+
+// validateWordPerfect checks the prefix of a WordPerfect 5.x-X9 document
+func validateWordPerfect(data []byte) bool {
+	if len(data) < wordPerfectHeaderSize || !bytes.HasPrefix(data, wordPerfectMagic) {
+		return false
+	}
+	if data[8] != wordPerfectProduct || data[9] != wordPerfectDocumentType {
+		return false
+	}
+	// The document area starts after the prefix and the index headers
+	docStart := binary.LittleEndian.Uint32(data[4:8])
+	return docStart >= wordPerfectHeaderSize && int64(docStart) < int64(len(data))
+}
+
+// isEncryptedWordPerfect reports whether a WordPerfect document is password
+// protected (non-zero encryption key in the prefix)
+func isEncryptedWordPerfect(data []byte) bool {
+	return len(data) >= wordPerfectHeaderSize && binary.LittleEndian.Uint16(data[12:14]) != 0
+}
@@ -0,0 +1,423 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"splitter-files/internal/models"
+)
+
+const (
+	zipLocalHeaderSize   = 30
+	zipEOCDSize          = 22
+	zip64EOCDLocatorSize = 20
+	zip64EOCDMinSize     = 56
+	zip64ExtraID         = 0x0001
+	zipAESExtraID        = 0x9901
+	zipMethodAES         = 99
+	zip64SizePlaceholder = 0xFFFFFFFF
+)
+
+var (
+	zipLocalHeaderMagic = []byte{0x50, 0x4B, 0x03, 0x04}
+	// zipSpanMarker begins the first segment of a spanned or split archive
+	zipSpanMarker = []byte{0x50, 0x4B, 0x07, 0x08}
+	// zipDataDescriptorMagic is the optional signature of data descriptors, the
+	// same as the spanning marker
+	zipDataDescriptorMagic = []byte{0x50, 0x4B, 0x07, 0x08}
+	zipEOCDMagic           = []byte{0x50, 0x4B, 0x05, 0x06}
+	zip64EOCDMagic         = []byte{0x50, 0x4B, 0x06, 0x06}
+	zip64EOCDLocatorMagic  = []byte{0x50, 0x4B, 0x06, 0x07}
+)
+
+// zipArchiveEnd finds the end-of-central-directory record that belongs to the
+// archive at the start of data (its central directory ends right before it, or
+// before the ZIP64 records of archives over 4 GB or 65535 entries) and returns
+// the archive length, or 0 if there is none
+func zipArchiveEnd(data []byte) int {
+	for pos := 0; ; {
+		idx := bytes.Index(data[pos:], zipEOCDMagic)
+		if idx == -1 {
+			return 0
+		}
+		eocd := pos + idx
+		pos = eocd + 1
+
+		if eocd+zipEOCDSize > len(data) {
+			return 0
+		}
+
+		if !zip64CentralDirectoryEnds(data, eocd) {
+			cdSize := int(binary.LittleEndian.Uint32(data[eocd+12 : eocd+16]))
+			cdOffset := int(binary.LittleEndian.Uint32(data[eocd+16 : eocd+20]))
+			if cdOffset+cdSize != eocd {
+				continue
+			}
+		}
+
+		end := eocd + zipEOCDSize + int(binary.LittleEndian.Uint16(data[eocd+20:eocd+22]))
+		if end > len(data) {
+			end = len(data)
+		}
+		return end
+	}
+}
+
+// zipWalkedArchiveEnd finds the end of an archive whose central directory
+// doesn't give it, as when the archive was carved from the local header of one
+// of its later entries, which its offsets don't count from: its entries are
+// walked, through the data descriptors of those written while streaming, up to
+// the end-of-central-directory record of the central directory that follows
+// them. Without a complete central directory the archive ends with its last
+// complete entry. It returns 0 if data doesn't start with a complete entry.
+func zipWalkedArchiveEnd(data []byte) int {
+	walk := walkZipEntries(data)
+	if len(walk.entries) == 0 {
+		return 0
+	}
+	if !walk.centralDirectory || !zipCentralDirectoryComplete(data, walk.end) {
+		return walk.end
+	}
+
+	eocd := walk.end + bytes.Index(data[walk.end:], zipEOCDMagic)
+	end := eocd + zipEOCDSize + int(binary.LittleEndian.Uint16(data[eocd+20:eocd+22]))
+	if end > len(data) {
+		end = len(data)
+	}
+	return end
+}
+
+// zip64CentralDirectoryEnds reports whether the end-of-central-directory
+// record at eocd is preceded by a ZIP64 locator pointing at a ZIP64 record of
+// the archive at the start of data, right after its central directory
+func zip64CentralDirectoryEnds(data []byte, eocd int) bool {
+	locator := eocd - zip64EOCDLocatorSize
+	if locator < 0 || !bytes.Equal(data[locator:locator+4], zip64EOCDLocatorMagic) {
+		return false
+	}
+
+	record := binary.LittleEndian.Uint64(data[locator+8 : locator+16])
+	if locator < zip64EOCDMinSize || record > uint64(locator-zip64EOCDMinSize) || !bytes.Equal(data[record:record+4], zip64EOCDMagic) {
+		return false
+	}
+	cdSize := binary.LittleEndian.Uint64(data[record+40 : record+48])
+	cdOffset := binary.LittleEndian.Uint64(data[record+48 : record+56])
+	return cdOffset <= record && cdSize == record-cdOffset
+}
+
+// ZipStart returns where the ZIP archive whose first local header is at data[pos]
+// starts: at the spanning marker right before the header for the first segment
+// of a spanned or split archive, at the header otherwise
+func ZipStart(data []byte, pos int) int {
+	if start := pos - len(zipSpanMarker); start >= 0 && bytes.Equal(data[start:pos], zipSpanMarker) {
+		return start
+	}
+	return pos
+}
+
+// zipSpannedSegment returns which segment of a spanned or split archive an
+// archive is, counting from 1, and the number of segments when it is the last
+// one, or 0 for a whole archive. The first segment begins with the spanning
+// marker and the last one ends with an end-of-central-directory record (or ZIP64
+// locator) that counts more than one disk.
+func zipSpannedSegment(data []byte) (segment, segments int) {
+	if bytes.HasPrefix(data, zipSpanMarker) {
+		return 1, 0
+	}
+
+	eocd := bytes.LastIndex(data, zipEOCDMagic)
+	if eocd < 0 || eocd+zipEOCDSize > len(data) {
+		return 0, 0
+	}
+	if locator := eocd - zip64EOCDLocatorSize; locator >= 0 && bytes.Equal(data[locator:locator+4], zip64EOCDLocatorMagic) {
+		disks := int(binary.LittleEndian.Uint32(data[locator+16 : locator+20]))
+		if disks > 1 {
+			return disks, disks
+		}
+		return 0, 0
+	}
+	disk := int(binary.LittleEndian.Uint16(data[eocd+4 : eocd+6]))
+	cdDisk := int(binary.LittleEndian.Uint16(data[eocd+6 : eocd+8]))
+	if disk == 0 && cdDisk == 0 {
+		return 0, 0
+	}
+	return disk + 1, disk + 1
+}
+
+// General purpose flags of local file headers
+const (
+	zipFlagEncrypted       = 0x0001
+	zipFlagDataDescriptor  = 0x0008
+	zipFlagStrongEncrypted = 0x0040
+)
+
+// zipEncryption reports whether entries of a ZIP archive are password-protected,
+// walking its local file headers, and returns the scheme of the first one:
+// ZipCrypto (the traditional PKWARE cipher), AES-128 to AES-256 (WinZip AE-1
+// and AE-2, which name the key size in an AES extra field) or PKWARE strong
+// encryption
+func zipEncryption(data []byte) (string, bool) {
+	data = bytes.TrimPrefix(data, zipSpanMarker)
+	for pos := 0; pos+zipLocalHeaderSize <= len(data) && bytes.Equal(data[pos:pos+4], zipLocalHeaderMagic); {
+		flags := binary.LittleEndian.Uint16(data[pos+6 : pos+8])
+		method := binary.LittleEndian.Uint16(data[pos+8 : pos+10])
+		compSize := uint64(binary.LittleEndian.Uint32(data[pos+18 : pos+22]))
+		nameLen := int(binary.LittleEndian.Uint16(data[pos+26 : pos+28]))
+		extraLen := int(binary.LittleEndian.Uint16(data[pos+28 : pos+30]))
+		dataStart := pos + zipLocalHeaderSize + nameLen + extraLen
+		if dataStart > len(data) {
+			break
+		}
+		extra := data[pos+zipLocalHeaderSize+nameLen : dataStart]
+
+		if flags&zipFlagEncrypted != 0 {
+			switch {
+			case method == zipMethodAES:
+				return zipAESScheme(extra), true
+			case flags&zipFlagStrongEncrypted != 0:
+				return "PKWARE strong encryption", true
+			default:
+				return "ZipCrypto", true
+			}
+		}
+
+		if compSize == zip64SizePlaceholder {
+			compSize = zip64CompressedSize(extra)
+		}
+		// Entries written while streaming have their sizes in a data descriptor
+		// after the data, the next entry is found by its header instead
+		if flags&zipFlagDataDescriptor != 0 && compSize == 0 {
+			next := bytes.Index(data[dataStart:], zipLocalHeaderMagic)
+			if next < 0 {
+				break
+			}
+			pos = dataStart + next
+			continue
+		}
+		if compSize > uint64(len(data)-dataStart) {
+			break
+		}
+		pos = dataStart + int(compSize)
+		if flags&zipFlagDataDescriptor != 0 {
+			pos += zipDataDescriptorSize(data[pos:])
+		}
+	}
+	return "", false
+}
+
+// zipAESScheme names the key size of a WinZip AES entry from its extra field,
+// whose strength is 1, 2 or 3 for AES-128, AES-192 and AES-256
+func zipAESScheme(extra []byte) string {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if size > len(extra)-4 {
+			break
+		}
+		if id == zipAESExtraID && size >= 7 && extra[8] >= 1 && extra[8] <= 3 {
+			return fmt.Sprintf("AES-%d", 64+64*int(extra[8]))
+		}
+		extra = extra[4+size:]
+	}
+	return "AES"
+}
+
+// zipDataDescriptorSize returns the length of the data descriptor at the start
+// of data: the CRC and sizes, optionally preceded by their signature. The sizes
+// of ZIP64 entries take 8 bytes each, which shows as the next header starting 8
+// bytes later.
+func zipDataDescriptorSize(data []byte) int {
+	size := 12
+	if bytes.HasPrefix(data, zipDataDescriptorMagic) {
+		size += 4
+	}
+	if !bytes.HasPrefix(data[min(size, len(data)):], zipLocalHeaderMagic) && bytes.HasPrefix(data[min(size+8, len(data)):], zipLocalHeaderMagic) {
+		size += 8
+	}
+	return size
+}
+
+// openZip opens the archive at the start of data, ignoring whatever follows it
+func openZip(data []byte) (*zip.Reader, error) {
+	end := zipArchiveEnd(data)
+	if end == 0 {
+		return nil, errors.New("no central directory for an archive at this position")
+	}
+	return zip.NewReader(bytes.NewReader(data[:end]), int64(end))
+}
+
+// zipEntryList lists the entries of the archive at the start of data from its
+// central directory, without decompressing them
+func zipEntryList(data []byte) []models.ZipEntry {
+	zipReader, err := openZip(data)
+	if err != nil {
+		return nil
+	}
+	entries := make([]models.ZipEntry, 0, len(zipReader.File))
+	for _, file := range zipReader.File {
+		entries = append(entries, models.ZipEntry{
+			Name:           file.Name,
+			Size:           file.UncompressedSize64,
+			CompressedSize: file.CompressedSize64,
+			CRC32:          file.CRC32,
+		})
+	}
+	return entries
+}
+
+// Limits of the archives validators open, so that a zip bomb in the input can't
+// exhaust memory: packages with more entries are rejected, and reading more than
+// maxPackageEntryRead bytes of an entry or maxPackageRead bytes of all entries
+// fails
+const (
+	maxPackageEntries   = 1 << 16
+	maxPackageEntryRead = 16 << 20
+	maxPackageRead      = 64 << 20
+)
+
+var errZipLimit = errors.New("archive exceeds the decompression limits")
+
+// openEntry opens an entry outside of a zipPackage, such as the XML parts
+// metadata is read from, cut off after maxPackageEntryRead bytes so that a
+// decoder can't be made to read a zip bomb to its end
+func openEntry(file *zip.File) (io.ReadCloser, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(rc, maxPackageEntryRead), rc}, nil
+}
+
+// zipPackage is an archive opened by a validator, whose entries are read within
+// the package limits
+type zipPackage struct {
+	*zip.Reader
+	// remaining is what may still be decompressed from all entries
+	remaining int64
+}
+
+// openZipPackage opens the archive at the start of data for a validator
+func openZipPackage(data []byte) (*zipPackage, error) {
+	zipReader, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(zipReader.File) > maxPackageEntries {
+		return nil, errZipLimit
+	}
+	return &zipPackage{Reader: zipReader, remaining: maxPackageRead}, nil
+}
+
+// open opens an entry of the package; entries whose declared size is over the
+// limit aren't opened, and reads fail once the limits are reached whatever the
+// size says
+func (p *zipPackage) open(file *zip.File) (io.ReadCloser, error) {
+	if file.UncompressedSize64 > maxPackageEntryRead {
+		return nil, errZipLimit
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &zipLimitedReader{ReadCloser: rc, pkg: p, entryLeft: maxPackageEntryRead}, nil
+}
+
+// zipLimitedReader reads an entry of a package within its limits
+type zipLimitedReader struct {
+	io.ReadCloser
+	pkg       *zipPackage
+	entryLeft int64
+}
+
+func (r *zipLimitedReader) Read(b []byte) (int, error) {
+	limit := min(r.entryLeft, r.pkg.remaining)
+	if limit <= 0 {
+		return 0, errZipLimit
+	}
+	if int64(len(b)) > limit {
+		b = b[:limit]
+	}
+	n, err := r.ReadCloser.Read(b)
+	r.entryLeft -= int64(n)
+	r.pkg.remaining -= int64(n)
+	return n, err
+}
+
+// zipFirstEntry returns the name, compression method and raw data of the first
+// local file header of a PK archive
+func zipFirstEntry(data []byte) (string, uint16, []byte, bool) {
+	if len(data) < zipLocalHeaderSize || !bytes.HasPrefix(data, zipLocalHeaderMagic) {
+		return "", 0, nil, false
+	}
+
+	flags := binary.LittleEndian.Uint16(data[6:8])
+	method := binary.LittleEndian.Uint16(data[8:10])
+	compSize := uint64(binary.LittleEndian.Uint32(data[18:22]))
+	nameLen := int(binary.LittleEndian.Uint16(data[26:28]))
+	extraLen := int(binary.LittleEndian.Uint16(data[28:30]))
+
+	dataStart := zipLocalHeaderSize + nameLen + extraLen
+	if dataStart > len(data) {
+		return "", 0, nil, false
+	}
+	extra := data[zipLocalHeaderSize+nameLen : dataStart]
+	if compSize == zip64SizePlaceholder {
+		compSize = zip64CompressedSize(extra)
+	}
+	// Entries written while streaming have their sizes in the data descriptor
+	// after their data
+	if flags&zipFlagDataDescriptor != 0 && compSize == 0 {
+		fh := zip.FileHeader{Flags: flags, Method: method}
+		if _, ok := zipDescribedEntryEnd(data, dataStart, &fh, zipHasExtra(extra, zip64ExtraID)); !ok {
+			return "", 0, nil, false
+		}
+		compSize = fh.CompressedSize64
+	}
+	if compSize > uint64(len(data)-dataStart) {
+		return "", 0, nil, false
+	}
+
+	return string(data[zipLocalHeaderSize : zipLocalHeaderSize+nameLen]), method, data[dataStart : dataStart+int(compSize)], true
+}
+
+// zip64CompressedSize reads the compressed size from the ZIP64 extra field of a
+// local file header, which holds the uncompressed size and then the compressed
+// one; it returns zip64SizePlaceholder if there is none
+func zip64CompressedSize(extra []byte) uint64 {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if size > len(extra)-4 {
+			break
+		}
+		if id == zip64ExtraID && size >= 16 {
+			return binary.LittleEndian.Uint64(extra[12:20])
+		}
+		extra = extra[4+size:]
+	}
+	return zip64SizePlaceholder
+}
+
+// zip64UncompressedSize reads the uncompressed size from the ZIP64 extra field
+// of a local file header, or returns zip64SizePlaceholder if there is none
+func zip64UncompressedSize(extra []byte) uint64 {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if size > len(extra)-4 {
+			break
+		}
+		if id == zip64ExtraID && size >= 8 {
+			return binary.LittleEndian.Uint64(extra[4:12])
+		}
+		extra = extra[4+size:]
+	}
+	return zip64SizePlaceholder
+}
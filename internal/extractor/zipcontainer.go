@@ -0,0 +1,129 @@
+package extractor
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"splitter-files/internal/models"
+)
+
+// containerClassifier identifies which of the formats sharing the magic number
+// of a container format a file is; FindFileSignatures runs it once per
+// position for all the signatures that name it
+type containerClassifier struct {
+	classify func(data []byte) string
+}
+
+// zipContainer tells apart the formats built on ZIP archives
+var zipContainer = &containerClassifier{classify: zipContainerExtension}
+
+// openDocumentExtensions maps the mimetype of OpenDocument packages to their
+// extension
+var openDocumentExtensions = map[string]string{
+	"application/vnd.oasis.opendocument.text":                 "odt",
+	"application/vnd.oasis.opendocument.spreadsheet":          "ods",
+	"application/vnd.oasis.opendocument.spreadsheet-template": "ots",
+	"application/vnd.oasis.opendocument.presentation":         "odp",
+}
+
+// zipContainerExtension identifies the format of the ZIP-based file at the start
+// of data, opening the archive once: OPC packages by the content type of their
+// main part, EPUB and OpenDocument packages by their mimetype entry, Android and
+// Java packages by their manifest. Other archives are "zip", and data that
+// isn't an archive that can be opened within the decompression limits is "".
+func zipContainerExtension(data []byte) string {
+	if !validateZipFile(data) {
+		return ""
+	}
+
+	// EPUB and OpenDocument packages start with an uncompressed mimetype entry;
+	// EPUB readers don't need the central directory to check it
+	var mimeType string
+	if name, method, content, ok := zipFirstEntry(data); ok && name == "mimetype" && method == 0 {
+		mimeType = string(bytes.TrimSpace(content))
+	}
+	if mimeType == "application/epub+zip" {
+		return "epub"
+	}
+
+	pkg, err := openZipPackage(data)
+	if err != nil {
+		return ""
+	}
+
+	parts := make(map[string]bool, len(pkg.File))
+	for _, file := range pkg.File {
+		parts[file.Name] = true
+		if file.Name == "mimetype" && mimeType == "" {
+			if rc, err := pkg.open(file); err == nil {
+				content, _ := io.ReadAll(rc)
+				rc.Close()
+				mimeType = string(bytes.TrimSpace(content))
+			}
+		}
+	}
+
+	switch {
+	case parts["[Content_Types].xml"]:
+		if contentTypes, err := readContentTypes(pkg); err == nil {
+			if ext := opcExtension(contentTypes, pkg); ext != "" {
+				return ext
+			}
+		}
+	case parts["content.xml"] || parts["styles.xml"]:
+		if ext, ok := openDocumentExtensions[mimeType]; ok {
+			return ext
+		}
+	case parts["AndroidManifest.xml"] && parts["classes.dex"]:
+		return "apk"
+	case parts["META-INF/MANIFEST.MF"]:
+		return "jar"
+	}
+	return "zip"
+}
+
+// opcExtension returns the extension of an OPC package from the content type
+// of its main part, provided it has parts in the folder of its application
+func opcExtension(contentTypes *ContentTypes, pkg *zipPackage) string {
+	var ext, folder string
+	macro, template := opcMacroEnabled(contentTypes), opcTemplate(contentTypes)
+	switch opcDocumentType(contentTypes) {
+	case models.WordDocument:
+		ext, folder = opcVariant("doc", "dot", macro, template), "word/"
+	case models.ExcelDocument:
+		ext, folder = opcVariant("xls", "xlt", macro, template), "xl/"
+		if opcBinaryWorkbook(contentTypes) {
+			ext, folder = "xlsb", "xl/workbook.bin"
+		}
+	case models.PowerPointDocument:
+		ext, folder = opcVariant("ppt", "pot", macro, template), "ppt/"
+	case models.VisioDocument:
+		if macro || template {
+			return ""
+		}
+		ext, folder = "vsdx", "visio/"
+	default:
+		return ""
+	}
+
+	for _, file := range pkg.File {
+		if strings.HasPrefix(file.Name, folder) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// opcVariant builds the extension of an OOXML document, e.g. "dotm" for a
+// macro-enabled Word template
+func opcVariant(document, templateDocument string, macro, template bool) string {
+	ext := document
+	if template {
+		ext = templateDocument
+	}
+	if macro {
+		return ext + "m"
+	}
+	return ext + "x"
+}
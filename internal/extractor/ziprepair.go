@@ -0,0 +1,260 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"splitter-files/internal/models"
+)
+
+// zipCentralHeaderMagic starts each record of a central directory
+var zipCentralHeaderMagic = []byte{0x50, 0x4B, 0x01, 0x02}
+
+// detectBrokenZip carves a ZIP archive whose central directory is missing, cut
+// off or overwritten, or can't be read: the entries whose local header and data
+// are complete are walked from the start and written anew with a central
+// directory. The rebuilt archive is typed by its content like any other file, so
+// Office packages keep their format; the result covers the bytes taken from the
+// input while the returned content is the rebuilt copy.
+func detectBrokenZip(data []byte, allowedExtensions map[string]bool) (*models.ExtractionResult, []byte, error) {
+	walk := walkZipEntries(data)
+	if len(walk.entries) == 0 {
+		return nil, nil, errors.New("no complete ZIP entries")
+	}
+
+	rebuilt, err := zipRebuild(data, walk.entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	result, _, err := detectFile(rebuilt, allowedExtensions, ValidationNormal, nil, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result.Size = walk.end
+	result.End = walk.end
+	if result.ZipInfo == nil {
+		result.ZipInfo = &models.ZipArchiveInfo{}
+	}
+	result.ZipInfo.Reconstructed = true
+	return result, rebuilt, nil
+}
+
+// zipBroken reports whether a carved archive has no central directory of its
+// own or one that can't be read. Archives carved from the second entry of a whole
+// one aren't broken: their entries run into its complete central directory.
+func zipBroken(data []byte) bool {
+	if bytes.HasPrefix(data, zipSpanMarker) {
+		return false
+	}
+	if zipArchiveEnd(data) > 0 {
+		_, err := openZip(data)
+		return err != nil
+	}
+	walk := walkZipEntries(data)
+	return !walk.centralDirectory || !zipCentralDirectoryComplete(data, walk.end)
+}
+
+// zipCentralDirectoryComplete reports whether the central directory at data[cd]
+// runs up to the (ZIP64) end-of-central-directory record that follows it, which
+// gives its size
+func zipCentralDirectoryComplete(data []byte, cd int) bool {
+	i := bytes.Index(data[cd:], zipEOCDMagic)
+	if i < 0 || cd+i+zipEOCDSize > len(data) {
+		return false
+	}
+	eocd := cd + i
+	if record := bytes.Index(data[cd:eocd], zip64EOCDMagic); record >= 0 && record+zip64EOCDMinSize <= eocd-cd {
+		return binary.LittleEndian.Uint64(data[cd+record+40:cd+record+48]) == uint64(record)
+	}
+	return int(binary.LittleEndian.Uint32(data[eocd+12:eocd+16])) == i
+}
+
+// zipEntry is an entry of an archive found by its local file header
+type zipEntry struct {
+	header zip.FileHeader
+	// data is the compressed data, followed by the data descriptor if the
+	// entry has one
+	data []byte
+}
+
+// zipWalk is what walkZipEntries found of an archive
+type zipWalk struct {
+	entries []zipEntry
+	// end is the end of the last complete entry
+	end int
+	// centralDirectory is set when the entries are followed by a central
+	// directory record
+	centralDirectory bool
+}
+
+// walkZipEntries walks the entries that follow each other from the start of an
+// archive and stops at the first one that is cut off or isn't an entry. The data
+// of entries written while streaming, whose sizes are in a data descriptor after
+// it, ends where its deflate stream does, or else at a descriptor that gives its
+// length.
+func walkZipEntries(data []byte) zipWalk {
+	var walk zipWalk
+	pos := 0
+	for pos+zipLocalHeaderSize <= len(data) && bytes.Equal(data[pos:pos+4], zipLocalHeaderMagic) {
+		header := data[pos : pos+zipLocalHeaderSize]
+		nameLen := int(binary.LittleEndian.Uint16(header[26:28]))
+		extraLen := int(binary.LittleEndian.Uint16(header[28:30]))
+		dataStart := pos + zipLocalHeaderSize + nameLen + extraLen
+		if dataStart > len(data) {
+			break
+		}
+		extra := data[pos+zipLocalHeaderSize+nameLen : dataStart]
+
+		fh := zip.FileHeader{
+			Name:               string(data[pos+zipLocalHeaderSize : pos+zipLocalHeaderSize+nameLen]),
+			ReaderVersion:      binary.LittleEndian.Uint16(header[4:6]),
+			CreatorVersion:     binary.LittleEndian.Uint16(header[4:6]),
+			Flags:              binary.LittleEndian.Uint16(header[6:8]),
+			Method:             binary.LittleEndian.Uint16(header[8:10]),
+			ModifiedTime:       binary.LittleEndian.Uint16(header[10:12]),
+			ModifiedDate:       binary.LittleEndian.Uint16(header[12:14]),
+			CRC32:              binary.LittleEndian.Uint32(header[14:18]),
+			CompressedSize64:   uint64(binary.LittleEndian.Uint32(header[18:22])),
+			UncompressedSize64: uint64(binary.LittleEndian.Uint32(header[22:26])),
+			Extra:              zipStripExtra(extra, zip64ExtraID),
+		}
+		zip64 := zipHasExtra(extra, zip64ExtraID)
+		if fh.CompressedSize64 == zip64SizePlaceholder {
+			fh.CompressedSize64 = zip64CompressedSize(extra)
+		}
+		if fh.UncompressedSize64 == zip64SizePlaceholder && zip64 {
+			fh.UncompressedSize64 = zip64UncompressedSize(extra)
+		}
+
+		var end int
+		if fh.Flags&zipFlagDataDescriptor != 0 {
+			var ok bool
+			if end, ok = zipDescribedEntryEnd(data, dataStart, &fh, zip64); !ok {
+				break
+			}
+		} else {
+			if fh.CompressedSize64 > uint64(len(data)-dataStart) {
+				break
+			}
+			end = dataStart + int(fh.CompressedSize64)
+		}
+
+		walk.entries = append(walk.entries, zipEntry{header: fh, data: data[dataStart:end]})
+		walk.end = end
+		pos = end
+	}
+	walk.centralDirectory = bytes.HasPrefix(data[pos:], zipCentralHeaderMagic)
+	return walk
+}
+
+// zipDescribedEntryEnd finds the end of the data descriptor that follows the
+// data of an entry at dataStart and fills the CRC and sizes it gives into fh
+func zipDescribedEntryEnd(data []byte, dataStart int, fh *zip.FileHeader, zip64 bool) (int, bool) {
+	sizeLen := 4
+	if zip64 {
+		sizeLen = 8
+	}
+	descriptor := func(pos int) (int, bool) {
+		if bytes.HasPrefix(data[pos:], zipDataDescriptorMagic) {
+			pos += 4
+		}
+		if pos+4+2*sizeLen > len(data) {
+			return 0, false
+		}
+		fh.CRC32 = binary.LittleEndian.Uint32(data[pos : pos+4])
+		if zip64 {
+			fh.CompressedSize64 = binary.LittleEndian.Uint64(data[pos+4 : pos+12])
+			fh.UncompressedSize64 = binary.LittleEndian.Uint64(data[pos+12 : pos+20])
+		} else {
+			fh.CompressedSize64 = uint64(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+			fh.UncompressedSize64 = uint64(binary.LittleEndian.Uint32(data[pos+8 : pos+12]))
+		}
+		return pos + 4 + 2*sizeLen, true
+	}
+
+	// Plain deflate data ends with its final block, which the reader stops at
+	// when it can read byte by byte
+	if fh.Method == zip.Deflate && fh.Flags&zipFlagEncrypted == 0 {
+		r := bytes.NewReader(data[dataStart:])
+		crc := crc32.NewIEEE()
+		if _, err := io.Copy(crc, flate.NewReader(r)); err != nil {
+			return 0, false
+		}
+		dataEnd := len(data) - r.Len()
+		end, ok := descriptor(dataEnd)
+		return end, ok && fh.CRC32 == crc.Sum32() && fh.CompressedSize64 == uint64(dataEnd-dataStart)
+	}
+
+	// Otherwise the descriptor is the first one whose compressed size is the
+	// length of the data before it
+	for pos := dataStart; ; pos++ {
+		i := bytes.Index(data[pos:], zipDataDescriptorMagic)
+		if i < 0 {
+			return 0, false
+		}
+		pos += i
+		if end, ok := descriptor(pos); ok && fh.CompressedSize64 == uint64(pos-dataStart) {
+			return end, true
+		}
+	}
+}
+
+// zipRebuild writes the entries of a broken archive into a new one, copying
+// their data as is
+func zipRebuild(data []byte, entries []zipEntry) ([]byte, error) {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, entry := range entries {
+		fh := entry.header
+		fw, err := w.CreateRaw(&fh)
+		if err != nil {
+			return nil, err
+		}
+		// The writer appends the data descriptor itself
+		if _, err := fw.Write(entry.data[:fh.CompressedSize64]); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// zipHasExtra reports whether an extra field has a block with the given ID
+func zipHasExtra(extra []byte, id uint16) bool {
+	for len(extra) >= 4 {
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if size > len(extra)-4 {
+			break
+		}
+		if binary.LittleEndian.Uint16(extra[0:2]) == id {
+			return true
+		}
+		extra = extra[4+size:]
+	}
+	return false
+}
+
+// zipStripExtra returns an extra field without its blocks with the given ID,
+// such as the ZIP64 sizes the writer adds again when it needs them
+func zipStripExtra(extra []byte, id uint16) []byte {
+	var stripped []byte
+	for len(extra) >= 4 {
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if size > len(extra)-4 {
+			break
+		}
+		if binary.LittleEndian.Uint16(extra[0:2]) != id {
+			stripped = append(stripped, extra[:4+size]...)
+		}
+		extra = extra[4+size:]
+	}
+	return stripped
+}
@@ -0,0 +1,7 @@
+package models
+
+// CacheEntryInfo describes the browser cache entry a file was recovered from
+type CacheEntryInfo struct {
+	Browser string
+	URL     string
+}
@@ -0,0 +1,10 @@
+package models
+
+// ContainerCandidate is a high-entropy region without a known signature that may be
+// an encrypted volume (TrueCrypt/VeraCrypt, LUKS, ...)
+type ContainerCandidate struct {
+	Start   int
+	End     int
+	Entropy float64
+	Kind    string
+}
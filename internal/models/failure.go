@@ -0,0 +1,32 @@
+package models
+
+// Kinds of processing failures
+const (
+	// FailureValidation: the data at the candidate is not a valid file of the
+	// format whose magic number it starts with
+	FailureValidation = "validation failed"
+	// FailureTooSmall: the file is smaller than its format allows
+	FailureTooSmall = "too small"
+	// FailureWrite: the file was found but could not be written
+	FailureWrite = "write failed"
+	// FailureVerification: the file failed to verify as a whole file of its
+	// format, and strict verification drops such files
+	FailureVerification = "verification failed"
+	// FailurePanic: a parser panicked on the data of the candidate
+	FailurePanic = "crashed"
+	// FailureTimeout: the candidate took longer than the per-candidate time
+	// limit to detect and analyze
+	FailureTimeout = "timed out"
+)
+
+// ProcessingFailure is a candidate that was not extracted, and why
+type ProcessingFailure struct {
+	Kind string
+	// Extension is the format the candidate was taken for, if known
+	Extension string
+	// Offset is the position of the candidate in the input, -1 if unknown
+	Offset int
+	// Path is the output file that could not be written
+	Path   string
+	Reason string
+}
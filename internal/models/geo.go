@@ -0,0 +1,9 @@
+package models
+
+// GeoLocation is a place recorded in the metadata of a photo or video
+type GeoLocation struct {
+	Latitude    float64
+	Longitude   float64
+	Altitude    float64
+	HasAltitude bool
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type OfficeFileType int
 
 const (
@@ -7,6 +9,10 @@ const (
 	WordDocument
 	ExcelDocument
 	PowerPointDocument
+	VisioDocument
+	PublisherDocument
+	ProjectDocument
+	WorksDocument
 )
 
 type OfficeDocumentInfo struct {
@@ -14,4 +20,74 @@ type OfficeDocumentInfo struct {
 	Version     string
 	IsEncrypted bool
 	IsMacro     bool
+	// VBAModules is the number of macro modules whose source was dumped
+	VBAModules int
+	// Password opened an encrypted document, whose decrypted copy was written to
+	// DecryptedFile
+	Password        string
+	DecryptedFile   string
+	DecryptedSHA256 string
+	// Media lists the media parts extracted as files of their own
+	Media []MediaFile
+	// Indicators are the traits of the document commonly abused by phishing
+	// and exploit documents (Indicator* constants)
+	Indicators []string
+
+	// Document properties (docProps/core.xml or \x05SummaryInformation)
+	Title          string
+	Creator        string
+	LastModifiedBy string
+	Created        time.Time
+	Modified       time.Time
+	Application    string
+
+	// Embedded holds the objects stored inside an OOXML package and External the
+	// resources it references outside of it
+	Embedded []EmbeddedObject
+	External []ExternalReference
+}
+
+// Indicators of phishing and exploit documents
+const (
+	// IndicatorExternalLink - a workbook linking to cells of other workbooks,
+	// which Excel offers to update (and fetch) on opening
+	IndicatorExternalLink = "external workbook link"
+	// IndicatorDDE - a DDE link such as =cmd|'/c calc'!A0, which runs a program
+	IndicatorDDE = "DDE link"
+	// IndicatorWebService - a WEBSERVICE formula, which fetches a URL
+	IndicatorWebService = "WEBSERVICE formula"
+	// IndicatorEmbeddedOLE - a compound (OLE2) file embedded in an Open XML
+	// package, where exploits hide the objects they target
+	IndicatorEmbeddedOLE = "embedded OLE object"
+	// IndicatorEquationEditor - an Equation Editor object (CVE-2017-11882)
+	IndicatorEquationEditor = "Equation Editor object"
+	// IndicatorActiveX - an ActiveX control
+	IndicatorActiveX = "ActiveX control"
+	// IndicatorRemoteTemplate - a template loaded from outside the document on
+	// opening (template injection)
+	IndicatorRemoteTemplate = "remote template"
+	// IndicatorRemoteOLE - an OLE object linked to a remote file (CVE-2017-0199)
+	IndicatorRemoteOLE = "remote OLE object"
+)
+
+// EmbeddedObject is a part of an OOXML package holding an embedded object or image
+type EmbeddedObject struct {
+	Kind string // "OLE object", "package", "ActiveX control", "image"
+	Name string
+}
+
+// MediaFile is a media part of an OOXML document (word/media/, xl/media/,
+// ppt/media/) written as a file of its own
+type MediaFile struct {
+	Part   string // name in the package, e.g. "word/media/image1.png"
+	File   string
+	Size   int
+	SHA256 string
+}
+
+// ExternalReference is a relationship with TargetMode="External"
+type ExternalReference struct {
+	Type   string // relationship type without the namespace, e.g. "hyperlink", "attachedTemplate"
+	Target string
+	Source string // part the relationship belongs to
 }
@@ -0,0 +1,51 @@
+package models
+
+// PDFDocumentInfo describes a carved PDF
+type PDFDocumentInfo struct {
+	// Version is the PDF version, e.g. "1.7"
+	Version string
+	Pages   int
+	// Linearized is set for documents optimized for viewing while downloading
+	Linearized  bool
+	IsEncrypted bool
+	// Encryption is the security handler and cipher of an encrypted PDF, e.g.
+	// "Standard AESV3"
+	Encryption string
+	// Repaired is set when the PDF was cut off and was saved with a rebuilt
+	// cross-reference table and trailer
+	Repaired bool
+	// Indicators are the actions of the document that run on their own or run
+	// code (PDFIndicator* constants)
+	Indicators []string
+	// Revisions lists the earlier revisions of an incrementally updated PDF
+	// written as files of their own, oldest first
+	Revisions []PDFRevision
+	// Attachments lists the embedded files extracted as files of their own
+	Attachments []AttachedFile
+}
+
+// AttachedFile is a file embedded in a PDF written as a file of its own
+type AttachedFile struct {
+	Name   string // name in the file specification, e.g. "invoice.xlsm"
+	File   string
+	Size   int
+	SHA256 string
+}
+
+// PDFRevision is an earlier revision of a PDF, the file as it was before an
+// incremental update, written as a file of its own
+type PDFRevision struct {
+	File   string
+	Size   int
+	SHA256 string
+}
+
+// Indicators of weaponized PDFs
+const (
+	// PDFIndicatorJavaScript - JavaScript run by the viewer (JavaScript or JS entries)
+	PDFIndicatorJavaScript = "JavaScript"
+	// PDFIndicatorOpenAction - an action run when the document is opened
+	PDFIndicatorOpenAction = "OpenAction"
+	// PDFIndicatorLaunch - a Launch action, which starts a program or opens a file
+	PDFIndicatorLaunch = "Launch action"
+)
@@ -1,26 +1,89 @@
 package models
 
+import "time"
+
 // ExtractionResult contains the result of file extraction
 type ExtractionResult struct {
-	Filename   string
-	Size       int
-	Start      int
-	End        int
-	Counter    int32
-	Error      error
-	FileType   string
-	OfficeInfo *OfficeDocumentInfo
+	Filename  string
+	Size      int
+	Start     int
+	End       int
+	Counter   int64
+	Error     error
+	FileType  string
+	Extension string
+	// OriginalName is the sanitized name recovered from the file metadata, if any
+	OriginalName string
+	// HighPriority marks artifacts that need immediate attention during triage (wallets, keys)
+	HighPriority bool
+	// IsEncrypted is set for password-protected artifacts other than Office documents
+	IsEncrypted bool
+	// MalwareName is the antivirus detection name, empty if the file is clean or wasn't scanned
+	MalwareName string
+	OfficeInfo  *OfficeDocumentInfo
+	PDFInfo     *PDFDocumentInfo
+	ZipInfo     *ZipArchiveInfo
+	SFXInfo     *SFXInfo
+	CacheInfo   *CacheEntryInfo
+	// Location is where a photo was taken, from its GPS metadata
+	Location *GeoLocation
+	// ModTime is the creation or modification time recorded in the file metadata
+	ModTime time.Time
+	// SHA256 is the hex digest of the written file, if hashing was requested
+	SHA256 string
+	// Matches are the hits of the content filter in the file
+	Matches []ContentMatch
+	// Preview is the beginning of the text of a document, for triage
+	Preview string
+	// Entropy is the Shannon entropy of the file in bits per byte; near 8 in a
+	// format that isn't compressed or encrypted as a whole, it tells that the
+	// file runs into someone else's compressed data
+	Entropy float64
+	// Suspect is why the carved file failed to verify as a whole file of its
+	// format, empty if it passed or wasn't checked
+	Suspect string
+	// ScanError is why the antivirus scan or the quarantine of the file failed
+	ScanError string
+	// CaseID and EvidenceID identify the investigation and the evidence item
+	// the file was carved from
+	CaseID     string
+	EvidenceID string
+}
+
+// ContentMatch is a hit of a content filter pattern at Offset bytes into a file
+type ContentMatch struct {
+	Pattern string
+	Offset  int
+	// Encoding is the text decoding the pattern matched in, empty for raw bytes
+	Encoding string
 }
 
 type ExtractionStats struct {
 	TotalExtracted int
 	TotalSize      int64
 	InputSize      int64
+	// Skipped counts files that were found but left out by the extraction filters
+	Skipped int
+	// Ignored is the number of input bytes in ranges excluded from the scan
+	Ignored int
+	// StoppedEarly is why the run ended before the whole input was scanned, if it did
+	StoppedEarly   string
 	Overlaps       int
 	Coverage       float64
-	UncoveredAreas []struct {
-		Start int
-		End   int
-	}
-	FileTypes map[string]int
+	UncoveredAreas []UncoveredArea
+	// UncoveredDumped is the number of uncovered areas written out as files
+	UncoveredDumped    int
+	FileTypes          map[string]int
+	PossibleContainers []ContainerCandidate
+}
+
+// UncoveredArea is a scanned region no file was extracted from, with its end inclusive
+type UncoveredArea struct {
+	Start int
+	End   int
+	// Preview holds the first bytes of the area and Entropy the Shannon entropy
+	// of all of it in bits per byte, to tell zeros, text, compressed or
+	// encrypted data and unknown formats apart
+	Preview []byte
+	Entropy float64
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SFXInfo describes a self-extracting archive: a Windows executable, the stub
+// that unpacks the archive appended to it
+type SFXInfo struct {
+	// Archive is the format of the appended archive: "zip", "rar" or "7z"
+	Archive string
+	// StubFile and ArchiveFile are the stub and the archive written as files
+	// of their own
+	StubFile      string
+	StubSize      int
+	StubSHA256    string
+	ArchiveFile   string
+	ArchiveSize   int
+	ArchiveSHA256 string
+	// Linked is the link time recorded in the stub's PE header, if it is a time
+	Linked time.Time
+}
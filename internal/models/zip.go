@@ -0,0 +1,50 @@
+package models
+
+// ZipArchiveInfo describes a carved ZIP archive
+type ZipArchiveInfo struct {
+	// Segment is the number of the segment of a spanned or split archive
+	// (.z01, .z02, ..., .zip) the file holds, counting from 1; such a segment
+	// can't be opened without the others. 0 for whole archives.
+	Segment int
+	// Segments is the number of segments of the set, known from the last one
+	// only
+	Segments int
+	// Encryption is the scheme of the password-protected entries, e.g.
+	// "ZipCrypto" or "AES-256"
+	Encryption string
+	// Entries lists the entries of the archive as its central directory
+	// records them, for archives that can be opened
+	Entries []ZipEntry
+	// Reconstructed is set when the archive had no readable central directory
+	// and was saved rebuilt from its local file headers
+	Reconstructed bool
+	// Carved lists the files carved from the entries of the archive by
+	// recursive carving, written under CarvedDir
+	Carved    []ExtractionResult
+	CarvedDir string
+	// NotCarved tells why recursive carving left the archive, or some of its
+	// entries, unopened (NotCarved* constants)
+	NotCarved string
+}
+
+// ZipEntry is an entry of an archive
+type ZipEntry struct {
+	Name string
+	// Size and CompressedSize are the sizes of the data of the entry before
+	// and after compression
+	Size           uint64
+	CompressedSize uint64
+	CRC32          uint32
+}
+
+// Reasons recursive carving leaves archives unopened
+const (
+	// NotCarvedDepth - the archive is nested deeper than the depth limit
+	NotCarvedDepth = "depth limit"
+	// NotCarvedBudget - its entries would be decompressed past the expansion
+	// budget of the run
+	NotCarvedBudget = "expansion budget"
+	// NotCarvedRepeated - the same archive was already opened, higher up or
+	// elsewhere in the input
+	NotCarvedRepeated = "already carved"
+)
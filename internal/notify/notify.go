@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webhookTimeout bounds a webhook delivery, so that an unreachable endpoint
+// doesn't hold up the end of a run
+const webhookTimeout = 10 * time.Second
+
+// Run outcomes
+const (
+	StatusDone   = "done"
+	StatusFailed = "failed"
+)
+
+// Summary tells how a run or a job of the service ended
+type Summary struct {
+	Time time.Time `json:"time"`
+	// Job is the identifier of a job of the service, empty for a command line run
+	Job        string `json:"job,omitempty"`
+	CaseID     string `json:"case_id,omitempty"`
+	EvidenceID string `json:"evidence_id,omitempty"`
+	Input      string `json:"input"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+
+	Files    int     `json:"files"`
+	Failures int     `json:"failures"`
+	Skipped  int     `json:"skipped"`
+	Coverage float64 `json:"coverage"`
+	Duration float64 `json:"duration_seconds"`
+	// Output is where the files went and Report where their report is, if any
+	Output string `json:"output,omitempty"`
+	Report string `json:"report,omitempty"`
+}
+
+// String is the one-line form of the summary sent to syslog
+func (s Summary) String() string {
+	name := "run"
+	if s.Job != "" {
+		name = "job " + s.Job
+	}
+	if s.Status == StatusFailed {
+		return fmt.Sprintf("%s of %s failed after %d files: %s", name, s.Input, s.Files, s.Error)
+	}
+
+	msg := fmt.Sprintf("%s of %s done: %d files, %d failures, %d skipped, coverage %.2f%%, %s",
+		name, s.Input, s.Files, s.Failures, s.Skipped, s.Coverage, time.Duration(s.Duration*float64(time.Second)).Round(time.Millisecond))
+	if s.Error != "" {
+		msg += ", " + s.Error
+	}
+	if s.Report != "" {
+		msg += ", report " + s.Report
+	} else if s.Output != "" {
+		msg += ", output " + s.Output
+	}
+	return msg
+}
+
+// Notifier tells someone that a run ended
+type Notifier interface {
+	Notify(s Summary) error
+}
+
+// New creates the notifier of a target: an http(s):// webhook URL the summary
+// is posted to as JSON, "syslog" for the local syslog daemon, or
+// syslog://host:port (UDP) and syslog+tcp://host:port for a remote one
+func New(target string) (Notifier, error) {
+	if target == "syslog" {
+		return newSyslogNotifier("", "")
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification target %q", target)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			break
+		}
+		return &WebhookNotifier{URL: target, client: &http.Client{Timeout: webhookTimeout}}, nil
+	case "syslog", "syslog+udp":
+		if u.Host == "" {
+			break
+		}
+		return newSyslogNotifier("udp", u.Host)
+	case "syslog+tcp":
+		if u.Host == "" {
+			break
+		}
+		return newSyslogNotifier("tcp", u.Host)
+	}
+	return nil, fmt.Errorf("invalid notification target %q (use an http(s):// URL, syslog or syslog://host:port)", target)
+}
+
+// WebhookNotifier posts the summary as JSON to a URL
+type WebhookNotifier struct {
+	URL    string
+	client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(s Summary) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	resp, err := n.client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s answered %s", redact(n.URL), resp.Status)
+	}
+	return nil
+}
+
+// redact hides the credentials and query of a URL, which often carry a token
+func redact(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return strings.TrimSuffix(u.String(), "?")
+}
@@ -0,0 +1,32 @@
+//go:build !windows && !plan9
+
+package notify
+
+import "log/syslog"
+
+// syslogTag names the program in the syslog messages
+const syslogTag = "splitter-files"
+
+// SyslogNotifier logs the summary to syslog, at the error level for failed runs
+type SyslogNotifier struct {
+	network, addr string
+}
+
+// newSyslogNotifier logs to the daemon at addr, or to the local one if network
+// is empty; the connection is opened per notification, as they are rare
+func newSyslogNotifier(network, addr string) (Notifier, error) {
+	return &SyslogNotifier{network: network, addr: addr}, nil
+}
+
+func (n *SyslogNotifier) Notify(s Summary) error {
+	w, err := syslog.Dial(n.network, n.addr, syslog.LOG_INFO|syslog.LOG_DAEMON, syslogTag)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if s.Status == StatusFailed {
+		return w.Err(s.String())
+	}
+	return w.Info(s.String())
+}
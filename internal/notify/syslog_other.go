@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package notify
+
+import "errors"
+
+// newSyslogNotifier fails here, as there is no syslog support
+func newSyslogNotifier(network, addr string) (Notifier, error) {
+	return nil, errors.New("syslog notifications are not supported on this platform")
+}
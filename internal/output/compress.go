@@ -0,0 +1,125 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"splitter-files/pkg/fileutils"
+)
+
+// Compression methods of output files and the suffix they add to file names
+var compressionSuffixes = map[string]string{
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+// CompressionSuffix returns the file name suffix of a compression method
+func CompressionSuffix(method string) (string, error) {
+	suffix, ok := compressionSuffixes[method]
+	if !ok {
+		return "", fmt.Errorf("unsupported compression %q (use gzip or zstd)", method)
+	}
+	return suffix, nil
+}
+
+// NewCompressSink returns a sink compressing every file with method before
+// passing it to next
+func NewCompressSink(method string, next fileutils.Sink) (fileutils.Sink, error) {
+	switch method {
+	case "gzip":
+		return &GzipSink{Next: next}, nil
+	case "zstd":
+		return NewZstdSink(next)
+	}
+	_, err := CompressionSuffix(method)
+	return nil, err
+}
+
+// GzipSink compresses every file with gzip before passing it to Next; the
+// original name (without the suffix and any added after it) and modification
+// time are kept in the gzip header
+type GzipSink struct {
+	Next fileutils.Sink
+}
+
+func (s *GzipSink) Put(job fileutils.WriteJob) error {
+	var buf bytes.Buffer
+	buf.Grow(len(job.Data) / 2)
+	zw := gzip.NewWriter(&buf)
+	zw.Name = filepath.Base(job.Path)
+	if i := strings.LastIndex(zw.Name, ".gz"); i > 0 {
+		zw.Name = zw.Name[:i]
+	}
+	zw.ModTime = job.ModTime
+	if _, err := zw.Write(job.Data); err != nil {
+		return &fileutils.WriteError{Path: job.Path, Err: err}
+	}
+	if err := zw.Close(); err != nil {
+		return &fileutils.WriteError{Path: job.Path, Err: err}
+	}
+
+	job.Data = buf.Bytes()
+	return s.Next.Put(job)
+}
+
+// ZstdSink compresses every file with zstd before passing it to Next; frames
+// record the content size and a checksum of the content
+type ZstdSink struct {
+	Next    fileutils.Sink
+	encoder *zstd.Encoder
+}
+
+// NewZstdSink returns a ZstdSink; its encoder is shared by the goroutines
+// writing files, up to one per CPU at a time
+func NewZstdSink(next fileutils.Sink) (*ZstdSink, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ZstdSink{Next: next, encoder: encoder}, nil
+}
+
+func (s *ZstdSink) Put(job fileutils.WriteJob) error {
+	job.Data = s.encoder.EncodeAll(job.Data, make([]byte, 0, len(job.Data)/2))
+	return s.Next.Put(job)
+}
+
+// TrimCompressionSuffix removes the suffix -compress added to a file name
+func TrimCompressionSuffix(name string) string {
+	for _, suffix := range compressionSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
+
+// Decompress returns the content of a file written with -compress, which is
+// told by the suffix of its name; other files are returned as they are
+func Decompress(name string, data []byte) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(name, compressionSuffixes["gzip"]):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(zr); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case strings.HasSuffix(name, compressionSuffixes["zstd"]):
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(data, nil)
+	}
+	return data, nil
+}
@@ -0,0 +1,90 @@
+package output
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"splitter-files/pkg/fileutils"
+)
+
+// EncryptionSuffix is appended to the names of encrypted output files
+const EncryptionSuffix = ".enc"
+
+// KeySize is the size of AES-256 keys
+const KeySize = 32
+
+// LoadKey reads an AES-256 key from a file holding either 32 raw bytes or
+// 64 hex digits
+func LoadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == KeySize {
+		return data, nil
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("%s must hold a %d-byte key, raw or as %d hex digits", path, KeySize, 2*KeySize)
+	}
+	return key, nil
+}
+
+// EncryptSink encrypts every file with AES-256-GCM before passing it to Next,
+// so no plaintext reaches the output. An encrypted file is a random 12-byte
+// nonce followed by the ciphertext and the 16-byte authentication tag.
+type EncryptSink struct {
+	Next fileutils.Sink
+	aead cipher.AEAD
+}
+
+// NewEncryptSink creates a sink encrypting with a 32-byte key
+func NewEncryptSink(key []byte, next fileutils.Sink) (*EncryptSink, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptSink{Next: next, aead: aead}, nil
+}
+
+func (s *EncryptSink) Put(job fileutils.WriteJob) error {
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(job.Data)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return &fileutils.WriteError{Path: job.Path, Err: err}
+	}
+	job.Data = s.aead.Seal(nonce, nonce, job.Data, nil)
+	return s.Next.Put(job)
+}
+
+// Decrypt returns the content of a file written by EncryptSink
+func Decrypt(key, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("encrypted data too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: wrong key or modified file")
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes", KeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"archive/tar"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"splitter-files/pkg/fileutils"
+)
+
+// StdoutName is the output location that streams the files as a tar archive on
+// standard output
+const StdoutName = "-"
+
+// TarSink writes output files as entries of a tar stream, named by their path
+// below Root; Close must be called to end the archive
+type TarSink struct {
+	Root string
+
+	mu sync.Mutex
+	tw *tar.Writer
+}
+
+func NewTarSink(w io.Writer, root string) *TarSink {
+	return &TarSink{Root: root, tw: tar.NewWriter(w)}
+}
+
+func (s *TarSink) Put(job fileutils.WriteJob) error {
+	modTime := job.ModTime
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     strings.TrimPrefix(strings.TrimPrefix(job.Path, s.Root), "/"),
+		Size:     int64(len(job.Data)),
+		Mode:     0644,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.tw.WriteHeader(header); err != nil {
+		return &fileutils.WriteError{Path: job.Path, Err: err}
+	}
+	if _, err := s.tw.Write(job.Data); err != nil {
+		return &fileutils.WriteError{Path: job.Path, Err: err}
+	}
+	return nil
+}
+
+// Close writes the end of the archive
+func (s *TarSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tw.Close()
+}
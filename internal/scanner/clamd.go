@@ -0,0 +1,147 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	clamdChunkSize = 64 * 1024
+	clamdTimeout   = 2 * time.Minute
+)
+
+// ClamdClient talks to a clamd daemon over a unix or TCP socket
+type ClamdClient struct {
+	network string
+	address string
+}
+
+// NewClamdClient accepts "unix:/path/clamd.sock", "tcp:host:port", a socket path or host:port
+func NewClamdClient(addr string) (*ClamdClient, error) {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		return &ClamdClient{network: "unix", address: strings.TrimPrefix(addr, "unix:")}, nil
+	case strings.HasPrefix(addr, "tcp:"):
+		return &ClamdClient{network: "tcp", address: strings.TrimPrefix(addr, "tcp:")}, nil
+	case strings.HasPrefix(addr, "/"):
+		return &ClamdClient{network: "unix", address: addr}, nil
+	case strings.Contains(addr, ":"):
+		return &ClamdClient{network: "tcp", address: addr}, nil
+	}
+	return nil, fmt.Errorf("invalid clamd address %q", addr)
+}
+
+func (c *ClamdClient) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(c.network, c.address, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(clamdTimeout))
+	return conn, nil
+}
+
+// Ping checks that the daemon is reachable
+func (c *ClamdClient) Ping() error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+	return nil
+}
+
+// Scan streams r to clamd with INSTREAM and returns the detection name, or "" if clean
+func (c *ClamdClient) Scan(r io.Reader) (string, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			w.Write(size[:])
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return "", werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Zero-length chunk terminates the stream
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return "", err
+	}
+	return parseScanReply(reply)
+}
+
+// ScanFile scans a file on disk
+func (c *ClamdClient) ScanFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return c.Scan(f)
+}
+
+func readReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return "", err
+	}
+	return strings.TrimRight(reply, "\x00\n"), nil
+}
+
+// parseScanReply handles "stream: OK", "stream: <name> FOUND" and "... ERROR"
+func parseScanReply(reply string) (string, error) {
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return strings.TrimSuffix(reply, " FOUND"), nil
+	case strings.HasSuffix(reply, " ERROR"):
+		return "", errors.New(strings.TrimSuffix(reply, " ERROR"))
+	}
+	return "", fmt.Errorf("unexpected clamd reply %q", reply)
+}
@@ -0,0 +1,42 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/models"
+)
+
+// ClamdProcessor scans every carved file with clamd after the wrapped processor
+// has written it and optionally moves detections into a quarantine directory
+type ClamdProcessor struct {
+	Next          extractor.FileProcessor
+	Client        *ClamdClient
+	QuarantineDir string
+}
+
+func (p *ClamdProcessor) Process(data []byte, outputDir string, counter int64, startPos int, allowedExtensions map[string]bool) (*models.ExtractionResult, error) {
+	result, err := p.Next.Process(data, outputDir, counter, startPos, allowedExtensions)
+	if err != nil {
+		return nil, err
+	}
+
+	detection, err := p.Client.ScanFile(result.Filename)
+	if err != nil {
+		result.ScanError = "clamd scan failed: " + err.Error()
+		return result, nil
+	}
+	result.MalwareName = detection
+
+	if detection != "" && p.QuarantineDir != "" {
+		target := filepath.Join(p.QuarantineDir, filepath.Base(result.Filename))
+		if err := os.Rename(result.Filename, target); err != nil {
+			result.ScanError = "quarantine failed: " + err.Error()
+		} else {
+			result.Filename = target
+		}
+	}
+
+	return result, nil
+}
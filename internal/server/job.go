@@ -0,0 +1,216 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/models"
+	"splitter-files/internal/notify"
+	"splitter-files/internal/worker"
+)
+
+// Job states
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Job is a submitted input and the state of its extraction
+type Job struct {
+	ID string
+	// Input is the file to carve; uploaded inputs are removed once the job finishes
+	Input    string
+	uploaded bool
+	// OutputDir holds the carved artifacts
+	OutputDir         string
+	AllowedExtensions map[string]bool
+	// CaseID and EvidenceID are prefixed to the artifact names and recorded in the report
+	CaseID     string
+	EvidenceID string
+
+	mu        sync.Mutex
+	status    string
+	err       string
+	size      int64
+	position  int
+	extracted int
+	created   time.Time
+	started   time.Time
+	finished  time.Time
+	results   []models.ExtractionResult
+	stats     *models.ExtractionStats
+	failures  []models.ProcessingFailure
+	metrics   *metrics
+}
+
+// JobStatus is the JSON form of a job's progress
+type JobStatus struct {
+	ID         string     `json:"id"`
+	CaseID     string     `json:"case_id,omitempty"`
+	EvidenceID string     `json:"evidence_id,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	InputSize  int64      `json:"input_size"`
+	Progress   float64    `json:"progress"`
+	Extracted  int        `json:"extracted"`
+	Created    time.Time  `json:"created"`
+	Started    *time.Time `json:"started,omitempty"`
+	Finished   *time.Time `json:"finished,omitempty"`
+}
+
+// Status returns a snapshot of the job's progress
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := JobStatus{
+		ID:         j.ID,
+		CaseID:     j.CaseID,
+		EvidenceID: j.EvidenceID,
+		Status:     j.status,
+		Error:      j.err,
+		InputSize:  j.size,
+		Extracted:  j.extracted,
+		Created:    j.created,
+	}
+	if j.size > 0 {
+		s.Progress = float64(j.position) / float64(j.size) * 100
+	}
+	if j.status == StatusDone {
+		s.Progress = 100
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		s.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		s.Finished = &finished
+	}
+	return s
+}
+
+// done reports whether the job has finished, successfully or not
+func (j *Job) done() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status == StatusDone || j.status == StatusFailed
+}
+
+func (j *Job) fail(err error) {
+	j.mu.Lock()
+	j.status = StatusFailed
+	j.err = err.Error()
+	j.finished = time.Now()
+	j.mu.Unlock()
+}
+
+// summary is the notification of a finished job
+func (j *Job) summary() notify.Summary {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	s := notify.Summary{
+		Time:       j.finished.UTC(),
+		Job:        j.ID,
+		CaseID:     j.CaseID,
+		EvidenceID: j.EvidenceID,
+		Input:      j.Input,
+		Status:     notify.StatusDone,
+		Error:      j.err,
+		Files:      len(j.results),
+		Failures:   len(j.failures),
+		Output:     j.OutputDir,
+	}
+	if j.status == StatusFailed {
+		s.Status = notify.StatusFailed
+		s.Files = j.extracted
+	} else {
+		s.Report = "/jobs/" + j.ID + "/report"
+	}
+	if j.stats != nil {
+		s.Skipped = j.stats.Skipped
+		s.Coverage = j.stats.Coverage
+	}
+	if !j.started.IsZero() {
+		s.Duration = j.finished.Sub(j.started).Seconds()
+	}
+	return s
+}
+
+// Report is the JSON report of a finished job
+type Report struct {
+	JobStatus
+	Stats *ReportStats          `json:"stats,omitempty"`
+	Files []worker.ResultRecord `json:"files"`
+	// Errors are the candidates that were not extracted, and why
+	Errors []worker.ErrorRecord `json:"errors,omitempty"`
+}
+
+// ReportStats summarizes a run like the statistics printed by the command line tool
+type ReportStats struct {
+	InputSize          int64          `json:"input_size"`
+	TotalSize          int64          `json:"total_size"`
+	Coverage           float64        `json:"coverage"`
+	Overlaps           int            `json:"overlaps"`
+	Skipped            int            `json:"skipped"`
+	FileTypes          map[string]int `json:"file_types"`
+	UncoveredAreas     int            `json:"uncovered_areas"`
+	PossibleContainers int            `json:"possible_containers"`
+}
+
+func (j *Job) report() Report {
+	report := Report{JobStatus: j.Status(), Files: []worker.ResultRecord{}}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.stats != nil {
+		report.Stats = &ReportStats{
+			InputSize:          j.stats.InputSize,
+			TotalSize:          j.stats.TotalSize,
+			Coverage:           j.stats.Coverage,
+			Overlaps:           j.stats.Overlaps,
+			Skipped:            j.stats.Skipped,
+			FileTypes:          j.stats.FileTypes,
+			UncoveredAreas:     len(j.stats.UncoveredAreas),
+			PossibleContainers: len(j.stats.PossibleContainers),
+		}
+	}
+	for _, res := range j.results {
+		record := worker.NewResultRecord(res)
+		// Artifacts are fetched from /jobs/{id}/files/{name}
+		record.File = filepath.Base(res.Filename)
+		report.Files = append(report.Files, record)
+	}
+	for _, failure := range j.failures {
+		report.Errors = append(report.Errors, worker.NewErrorRecord(nil, failure))
+	}
+	return report
+}
+
+// Extracted and Failed make the job the worker.Reporter of its own run, which
+// tracks progress by the furthest input position reached and counts the files
+// and failures in the metrics of the server
+func (j *Job) Extracted(result models.ExtractionResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.extracted++
+	if result.End > j.position {
+		j.metrics.scanned(int64(result.End - j.position))
+		j.position = result.End
+	}
+	j.metrics.extracted(result.Extension)
+}
+
+func (j *Job) Failed(err error) {
+	var skipped *extractor.SkipError
+	if errors.As(err, &skipped) {
+		return
+	}
+	j.metrics.failed(worker.FailureKind(err))
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// writeLatencyBuckets are the upper bounds in seconds of the write latency histogram
+var writeLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// metrics counts the work of the server since it started, for GET /metrics
+type metrics struct {
+	mu           sync.Mutex
+	bytesScanned int64
+	// artifacts are counted by extension and failures by kind
+	artifacts map[string]int64
+	failures  map[string]int64
+	// writeCounts holds a count per bucket of writeLatencyBuckets and one for
+	// the writes slower than all of them
+	writeCounts []int64
+	writeSum    float64
+	writes      int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		artifacts:   make(map[string]int64),
+		failures:    make(map[string]int64),
+		writeCounts: make([]int64, len(writeLatencyBuckets)+1),
+	}
+}
+
+func (m *metrics) scanned(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytesScanned += n
+}
+
+func (m *metrics) extracted(ext string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.artifacts[ext]++
+}
+
+func (m *metrics) failed(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[kind]++
+}
+
+func (m *metrics) wrote(d time.Duration) {
+	seconds := d.Seconds()
+	bucket := sort.SearchFloat64s(writeLatencyBuckets, seconds)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writeCounts[bucket]++
+	m.writeSum += seconds
+	m.writes++
+}
+
+// handleMetrics writes the metrics in the Prometheus text exposition format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	jobs := make(map[string]int64)
+	for _, job := range s.jobs {
+		jobs[job.Status().Status]++
+	}
+	s.mu.Unlock()
+
+	m := s.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetric(w, "splitter_bytes_scanned_total", "counter", "Input bytes scanned by jobs.")
+	fmt.Fprintf(w, "splitter_bytes_scanned_total %d\n", m.bytesScanned)
+
+	writeMetric(w, "splitter_artifacts_total", "counter", "Files extracted by jobs, by extension.")
+	writeLabeled(w, "splitter_artifacts_total", "type", m.artifacts)
+
+	writeMetric(w, "splitter_candidate_failures_total", "counter", "Candidates that were not extracted, by kind of failure.")
+	writeLabeled(w, "splitter_candidate_failures_total", "kind", m.failures)
+
+	writeMetric(w, "splitter_queue_depth", "gauge", "Jobs waiting for a runner.")
+	fmt.Fprintf(w, "splitter_queue_depth %d\n", len(s.queue))
+
+	writeMetric(w, "splitter_jobs", "gauge", "Jobs by status.")
+	for _, status := range []string{StatusQueued, StatusRunning, StatusDone, StatusFailed} {
+		jobs[status] += 0
+	}
+	writeLabeled(w, "splitter_jobs", "status", jobs)
+
+	writeMetric(w, "splitter_write_latency_seconds", "histogram", "Time to store an extracted file.")
+	var cumulative int64
+	for i, bound := range writeLatencyBuckets {
+		cumulative += m.writeCounts[i]
+		fmt.Fprintf(w, "splitter_write_latency_seconds_bucket{le=\"%g\"} %d\n", bound, cumulative)
+	}
+	fmt.Fprintf(w, "splitter_write_latency_seconds_bucket{le=\"+Inf\"} %d\n", m.writes)
+	fmt.Fprintf(w, "splitter_write_latency_seconds_sum %g\n", m.writeSum)
+	fmt.Fprintf(w, "splitter_write_latency_seconds_count %d\n", m.writes)
+}
+
+func writeMetric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeLabeled writes a sample per label value, in order
+func writeLabeled(w io.Writer, name, label string, values map[string]int64) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, label, labelEscaper.Replace(key), values[key])
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,361 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/notify"
+	"splitter-files/internal/worker"
+	"splitter-files/pkg/fileutils"
+)
+
+// Config controls the REST server
+type Config struct {
+	// DataDir holds a directory per job with the uploaded input and carved files
+	DataDir string
+	// InputRoot allows submitting files under this directory by path; path
+	// submissions are refused if it is empty
+	InputRoot string
+	// MaxUpload is the largest accepted upload in bytes
+	MaxUpload int64
+	// Jobs is the number of jobs carved at once, each with NumWorkers workers
+	Jobs       int
+	NumWorkers int
+	// Notifier is told when a job finishes or fails, if set
+	Notifier notify.Notifier
+	// Logger logs the jobs as they are queued, start, end or fail; stderr if nil
+	Logger *log.Logger
+}
+
+// Server runs carving jobs submitted over HTTP:
+//
+//	POST /jobs                    submit a blob (request body) or {"path": ...}; ?ext=pdf,jpg&exclude=zip&case_id=...&evidence_id=...
+//	GET  /jobs                    list jobs
+//	GET  /jobs/{id}               job status and progress
+//	GET  /jobs/{id}/report        JSON report of a finished job
+//	GET  /jobs/{id}/files/{name}  carved artifact
+//	GET  /metrics                 Prometheus metrics
+type Server struct {
+	config  Config
+	queue   chan *Job
+	metrics *metrics
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// New starts the job runners of a server
+func New(config Config) *Server {
+	if config.Jobs < 1 {
+		config.Jobs = 1
+	}
+	if config.Logger == nil {
+		config.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	s := &Server{
+		config:  config,
+		queue:   make(chan *Job, 1024),
+		jobs:    make(map[string]*Job),
+		metrics: newMetrics(),
+	}
+	for i := 0; i < config.Jobs; i++ {
+		go s.runJobs()
+	}
+	return s
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleStatus)
+	mux.HandleFunc("GET /jobs/{id}/report", s.handleReport)
+	mux.HandleFunc("GET /jobs/{id}/files/{name}", s.handleFile)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	jobDir := filepath.Join(s.config.DataDir, id)
+	job := &Job{
+		ID:                id,
+		OutputDir:         filepath.Join(jobDir, "files"),
+		AllowedExtensions: extractor.ExcludeExtensions(extractor.ParseExtensions(r.URL.Query().Get("ext")), r.URL.Query().Get("exclude")),
+		CaseID:            r.URL.Query().Get("case_id"),
+		EvidenceID:        r.URL.Query().Get("evidence_id"),
+		status:            StatusQueued,
+		created:           time.Now(),
+		metrics:           s.metrics,
+	}
+	for _, id := range []string{job.CaseID, job.EvidenceID} {
+		if err := extractor.CheckIdentifier(id); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if err := os.MkdirAll(job.OutputDir, 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		job.Input, err = s.resolvePath(r.Body)
+		if err != nil {
+			os.RemoveAll(jobDir)
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		job.Input = filepath.Join(jobDir, "input.bin")
+		job.uploaded = true
+		if err := saveUpload(job.Input, http.MaxBytesReader(w, r.Body, s.config.MaxUpload)); err != nil {
+			os.RemoveAll(jobDir)
+			writeError(w, http.StatusBadRequest, fmt.Errorf("failed to receive upload: %v", err))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.jobs[id] = job
+	s.mu.Unlock()
+	s.queue <- job
+
+	s.config.Logger.Printf("Job %s queued (%s)", id, job.Input)
+	w.Header().Set("Location", "/jobs/"+id)
+	writeJSON(w, http.StatusAccepted, job.Status())
+}
+
+// resolvePath reads a {"path": ...} submission and checks that the file lies
+// under the configured input root
+func (s *Server) resolvePath(body io.Reader) (string, error) {
+	if s.config.InputRoot == "" {
+		return "", fmt.Errorf("submitting files by path is disabled")
+	}
+
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(body).Decode(&req); err != nil || req.Path == "" {
+		return "", fmt.Errorf("expected {\"path\": \"...\"}")
+	}
+
+	// Both sides are compared with their symlinks resolved, and the job reads
+	// the resolved path, so a link replaced after the check can't lead it out
+	root, err := filepath.Abs(s.config.InputRoot)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, filepath.Clean("/"+req.Path)))
+	if err != nil {
+		return "", fmt.Errorf("input not found: %s", req.Path)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("input outside of the input root: %s", req.Path)
+	}
+	return resolved, nil
+}
+
+func saveUpload(path string, body io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		statuses = append(statuses, job.Status())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Created.Before(statuses[j].Created) })
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	job := s.job(w, r)
+	if job == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, job.Status())
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	job := s.job(w, r)
+	if job == nil {
+		return
+	}
+	if !job.done() {
+		writeError(w, http.StatusConflict, fmt.Errorf("job %s has not finished", job.ID))
+		return
+	}
+	writeJSON(w, http.StatusOK, job.report())
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	job := s.job(w, r)
+	if job == nil {
+		return
+	}
+
+	name := r.PathValue("name")
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid file name %q", name))
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(job.OutputDir, name))
+}
+
+// job looks up the job of the request and answers 404 if there is none
+func (s *Server) job(w http.ResponseWriter, r *http.Request) *Job {
+	s.mu.Lock()
+	job := s.jobs[r.PathValue("id")]
+	s.mu.Unlock()
+
+	if job == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %s not found", r.PathValue("id")))
+	}
+	return job
+}
+
+func (s *Server) runJobs() {
+	for job := range s.queue {
+		s.run(job)
+	}
+}
+
+func (s *Server) run(job *Job) {
+	defer s.notify(job)
+	// Candidates recover from their own crashes; anything else that crashes
+	// fails the job rather than the service
+	defer func() {
+		if v := recover(); v != nil {
+			s.fail(job, fmt.Errorf("internal error: %v", v))
+		}
+	}()
+
+	if job.uploaded {
+		defer os.Remove(job.Input)
+	}
+	data, unmap, err := fileutils.MapFile(job.Input)
+	if err != nil {
+		s.fail(job, err)
+		return
+	}
+	// ProcessFile returns once nothing reads data any more, also when it panics
+	defer unmap()
+
+	job.mu.Lock()
+	job.status = StatusRunning
+	job.size = int64(len(data))
+	job.started = time.Now()
+	job.mu.Unlock()
+	s.config.Logger.Printf("Job %s started (%d bytes)", job.ID, len(data))
+
+	results, stats, err := worker.ProcessFile(data, job.OutputDir, worker.Options{
+		NumWorkers:        s.config.NumWorkers,
+		AllowedExtensions: job.AllowedExtensions,
+		CaseID:            job.CaseID,
+		EvidenceID:        job.EvidenceID,
+		Reporter:          job,
+		WriteLatency:      s.metrics.wrote,
+		Writers:           worker.DefaultWriters,
+		WriteQueue:        worker.DefaultWriteQueue,
+		// Uploads come from anyone who can reach the service
+		CandidateTimeout: worker.DefaultCandidateTimeout,
+	})
+
+	job.mu.Lock()
+	// The rest of the input was scanned without finding files
+	if rest := int(job.size) - job.position; rest > 0 {
+		job.metrics.scanned(int64(rest))
+		job.position = int(job.size)
+	}
+	job.results = results
+	job.stats = stats
+	job.status = StatusDone
+	// Candidates that turn out not to be files aren't failures of the job
+	var processingErr *worker.ProcessingError
+	if errors.As(err, &processingErr) {
+		job.failures = processingErr.Failures
+		if len(processingErr.WriteErrors) > 0 {
+			job.err = fmt.Sprintf("%d files could not be written: %v", len(processingErr.WriteErrors), processingErr.WriteErrors[0])
+		}
+		if processingErr.OutputFailure != "" {
+			job.err = fmt.Sprintf("%s, stopped after %d files: %v", processingErr.OutputFailure, len(results), processingErr.WriteErrors[0])
+		}
+	}
+	job.finished = time.Now()
+	job.mu.Unlock()
+	s.config.Logger.Printf("Job %s done: %d files in %s", job.ID, len(results), job.finished.Sub(job.started))
+}
+
+// fail marks a job failed and logs why
+func (s *Server) fail(job *Job, err error) {
+	job.fail(err)
+	s.config.Logger.Printf("Job %s failed: %v", job.ID, err)
+}
+
+// notify sends the summary of a finished job to the notifier of the server
+func (s *Server) notify(job *Job) {
+	if s.config.Notifier == nil {
+		return
+	}
+	if err := s.config.Notifier.Notify(job.summary()); err != nil {
+		s.config.Logger.Printf("Job %s: error sending notification: %v", job.ID, err)
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
@@ -0,0 +1,39 @@
+//go:build linux
+
+package tui
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// terminalSize returns the columns and rows of the terminal on fd
+func terminalSize(fd uintptr) (int, int, bool) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 {
+		return 0, 0, false
+	}
+	return int(ws.Col), int(ws.Row), ws.Col > 0 && ws.Row > 0
+}
+
+// readKeys switches the terminal on fd to unbuffered input without echo, so
+// keys arrive as they are pressed; Ctrl-C still interrupts. The returned
+// function restores the previous mode.
+func readKeys(fd uintptr) (func(), error) {
+	var old syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&old))); errno != 0 {
+		return nil, errno
+	}
+
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(&raw))); errno != 0 {
+		return nil, errno
+	}
+
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(&old)))
+	}, nil
+}
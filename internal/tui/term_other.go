@@ -0,0 +1,15 @@
+//go:build !linux
+
+package tui
+
+import "errors"
+
+// terminalSize is unknown here; the UI falls back to 80x24
+func terminalSize(fd uintptr) (int, int, bool) {
+	return 0, 0, false
+}
+
+// readKeys is not supported here, so the list of extractions can't be scrolled
+func readKeys(fd uintptr) (func(), error) {
+	return nil, errors.New("keyboard input is not supported on this platform")
+}
@@ -0,0 +1,302 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// refreshInterval is how often the screen is redrawn
+const refreshInterval = 200 * time.Millisecond
+
+// maxRecent is the number of extractions kept for the list
+const maxRecent = 1000
+
+// shades draw a coverage map cell from uncovered to fully covered
+var shades = []rune{' ', '░', '▒', '▓', '█'}
+
+// UI is a full-screen terminal view of a running extraction: a coverage map of
+// the input, per-type counters, throughput and a scrollable list of recent
+// extractions. It is the worker.Reporter of the run.
+type UI struct {
+	out       *os.File
+	inputName string
+	// offset is the position of the scanned region in the input
+	offset    int
+	inputSize int
+
+	mu        sync.Mutex
+	started   time.Time
+	position  int
+	extracted int
+	types     map[string]int
+	recent    []string
+	// extents are the input ranges of the extracted files
+	extents [][2]int
+	// cells holds the covered bytes of each coverage map cell
+	cells    []int
+	cellSize int
+	// scroll is the number of lines the list is scrolled up from the newest
+	scroll int
+
+	restore  func()
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// New creates the view of a region of inputSize bytes at offset of the input
+// drawn on out
+func New(out *os.File, inputName string, offset, inputSize int) *UI {
+	return &UI{
+		out:       out,
+		inputName: inputName,
+		offset:    offset,
+		inputSize: inputSize,
+		types:     make(map[string]int),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start switches to the alternate screen and redraws it until Stop
+func (ui *UI) Start() {
+	ui.started = time.Now()
+	if restore, err := readKeys(os.Stdin.Fd()); err == nil {
+		ui.restore = restore
+		go ui.handleKeys()
+	}
+
+	// Alternate screen, hidden cursor
+	fmt.Fprint(ui.out, "\x1b[?1049h\x1b[?25l")
+	go func() {
+		defer close(ui.done)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			ui.render(true)
+			select {
+			case <-ticker.C:
+			case <-ui.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop restores the terminal; the final state of the view stays on screen.
+// It may be called more than once, such as by a signal handler racing the end
+// of the run.
+func (ui *UI) Stop() {
+	ui.stopOnce.Do(func() {
+		close(ui.stop)
+		<-ui.done
+
+		fmt.Fprint(ui.out, "\x1b[?25h\x1b[?1049l")
+		if ui.restore != nil {
+			ui.restore()
+		}
+		ui.mu.Lock()
+		ui.scroll = 0
+		ui.mu.Unlock()
+		ui.render(false)
+	})
+}
+
+func (ui *UI) Extracted(result models.ExtractionResult) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.extracted++
+	ui.types[result.FileType]++
+	if end := result.End - ui.offset; end > ui.position {
+		ui.position = end
+	}
+	ui.recent = append(ui.recent, fmt.Sprintf("%-20s %-30s %10d bytes at %d",
+		filepath.Base(result.Filename), result.FileType, result.Size, result.Start))
+	if len(ui.recent) > maxRecent {
+		ui.recent = ui.recent[1:]
+	}
+	if ui.scroll > 0 {
+		// Keep the lines being read in place while new ones arrive
+		ui.scroll++
+	}
+	ui.extents = append(ui.extents, [2]int{result.Start - ui.offset, result.End - ui.offset})
+	ui.cover(result.Start-ui.offset, result.End-ui.offset)
+}
+
+// Failed shows nothing: most errors are candidates that turned out not to be files
+func (ui *UI) Failed(err error) {}
+
+// cover adds [start, end) to the coverage map cells
+func (ui *UI) cover(start, end int) {
+	if ui.cells == nil {
+		return
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > ui.inputSize {
+		end = ui.inputSize
+	}
+	for cell := start / ui.cellSize; cell < len(ui.cells) && cell*ui.cellSize < end; cell++ {
+		cellStart, cellEnd := cell*ui.cellSize, (cell+1)*ui.cellSize
+		if start > cellStart {
+			cellStart = start
+		}
+		if end < cellEnd {
+			cellEnd = end
+		}
+		ui.cells[cell] += cellEnd - cellStart
+	}
+}
+
+// render writes the view; on the full screen the list fills the terminal
+// height, otherwise only the newest lines are printed
+func (ui *UI) render(fullScreen bool) {
+	width, height, ok := terminalSize(ui.out.Fd())
+	if !ok {
+		width, height = 80, 24
+	}
+
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	barWidth := width - 2
+	if barWidth < 10 {
+		barWidth = 10
+	}
+	if len(ui.cells) != barWidth {
+		ui.resizeMap(barWidth)
+	}
+
+	var b strings.Builder
+	if fullScreen {
+		b.WriteString("\x1b[H\x1b[2J")
+	}
+
+	elapsed := time.Since(ui.started)
+	progress := 0.0
+	if ui.inputSize > 0 {
+		progress = float64(ui.position) / float64(ui.inputSize) * 100
+	}
+	if ui.offset > 0 {
+		fmt.Fprintf(&b, "%s (%d bytes at offset %d)\n", ui.inputName, ui.inputSize, ui.offset)
+	} else {
+		fmt.Fprintf(&b, "%s (%d bytes)\n", ui.inputName, ui.inputSize)
+	}
+	fmt.Fprintf(&b, "Elapsed %s | Position %.1f%% | %.2f MB/s | Extracted %d files\n\n",
+		elapsed.Round(time.Second), progress, float64(ui.position)/(1024*1024)/elapsed.Seconds(), ui.extracted)
+
+	b.WriteString("Coverage\n[")
+	for _, covered := range ui.cells {
+		level := covered * (len(shades) - 1) / ui.cellSize
+		if covered > 0 && level == 0 {
+			level = 1
+		}
+		if level >= len(shades) {
+			level = len(shades) - 1
+		}
+		b.WriteRune(shades[level])
+	}
+	b.WriteString("]\n\n")
+
+	types := make([]string, 0, len(ui.types))
+	for fileType := range ui.types {
+		types = append(types, fileType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if ui.types[types[i]] != ui.types[types[j]] {
+			return ui.types[types[i]] > ui.types[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	b.WriteString("File types\n")
+	lines := 7
+	for i := 0; i < len(types); i += 2 {
+		fmt.Fprintf(&b, "  %-30s %6d", types[i], ui.types[types[i]])
+		if i+1 < len(types) {
+			fmt.Fprintf(&b, "    %-30s %6d", types[i+1], ui.types[types[i+1]])
+		}
+		b.WriteString("\n")
+		lines++
+	}
+
+	listHeight := height - lines - 3
+	if !fullScreen || listHeight < 1 {
+		listHeight = 10
+	}
+	if maxScroll := len(ui.recent) - listHeight; ui.scroll > maxScroll {
+		ui.scroll = maxScroll
+	}
+	if ui.scroll < 0 {
+		ui.scroll = 0
+	}
+	end := len(ui.recent) - ui.scroll
+	start := end - listHeight
+	if start < 0 {
+		start = 0
+	}
+
+	b.WriteString("\nRecent extractions")
+	if fullScreen && ui.restore != nil {
+		b.WriteString(" (↑/↓, PgUp/PgDn to scroll)")
+	}
+	b.WriteString("\n")
+	for _, line := range ui.recent[start:end] {
+		if len(line) > width {
+			line = line[:width]
+		}
+		b.WriteString(line + "\n")
+	}
+
+	fmt.Fprint(ui.out, b.String())
+}
+
+// resizeMap rebuilds the coverage map with a new number of cells
+func (ui *UI) resizeMap(cells int) {
+	ui.cellSize = (ui.inputSize + cells - 1) / cells
+	if ui.cellSize < 1 {
+		ui.cellSize = 1
+	}
+	ui.cells = make([]int, cells)
+	for _, r := range ui.extents {
+		ui.cover(r[0], r[1])
+	}
+}
+
+// handleKeys scrolls the list of extractions
+func (ui *UI) handleKeys() {
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+
+		ui.mu.Lock()
+		switch key := string(buf[:n]); key {
+		case "\x1b[A", "k":
+			ui.scroll++
+		case "\x1b[B", "j":
+			ui.scroll--
+		case "\x1b[5~":
+			ui.scroll += 10
+		case "\x1b[6~":
+			ui.scroll -= 10
+		}
+		ui.mu.Unlock()
+
+		select {
+		case <-ui.stop:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"bytes"
+
+	"splitter-files/internal/models"
+	"splitter-files/pkg/fileutils"
+)
+
+const (
+	containerBlockSize = 64 * 1024
+	// Random data of a 64 KB block scores ~7.997 bits/byte, most compressed formats stay below
+	containerMinEntropy = 7.995
+	luksSearchWindow    = 2 * 1024 * 1024
+)
+
+var luksMagic = []byte{'L', 'U', 'K', 'S', 0xBA, 0xBE}
+
+// detectEncryptedContainers looks for runs of high-entropy blocks inside uncovered areas
+func detectEncryptedContainers(data []byte, areas []struct{ Start, End int }, minSize int) []models.ContainerCandidate {
+	var candidates []models.ContainerCandidate
+
+	for _, area := range areas {
+		if area.End-area.Start+1 < minSize {
+			continue
+		}
+
+		runStart := -1
+		var entropySum float64
+		var blocks int
+
+		flush := func(end int) {
+			if runStart != -1 && end-runStart >= minSize {
+				candidates = append(candidates, newContainerCandidate(data, area.Start, runStart, end, entropySum/float64(blocks)))
+			}
+			runStart = -1
+			entropySum = 0
+			blocks = 0
+		}
+
+		pos := area.Start
+		for ; pos+containerBlockSize <= area.End+1; pos += containerBlockSize {
+			entropy := fileutils.Entropy(data[pos : pos+containerBlockSize])
+			if entropy < containerMinEntropy {
+				flush(pos)
+				continue
+			}
+			if runStart == -1 {
+				runStart = pos
+			}
+			entropySum += entropy
+			blocks++
+		}
+		flush(pos)
+	}
+
+	return candidates
+}
+
+func newContainerCandidate(data []byte, areaStart, start, end int, entropy float64) models.ContainerCandidate {
+	candidate := models.ContainerCandidate{
+		Start:   start,
+		End:     end - 1,
+		Entropy: entropy,
+		Kind:    "TrueCrypt/VeraCrypt or other encrypted volume",
+	}
+
+	// LUKS keeps a plaintext header in front of the encrypted payload
+	windowStart := start - luksSearchWindow
+	if windowStart < areaStart {
+		windowStart = areaStart
+	}
+	if idx := bytes.LastIndex(data[windowStart:start], luksMagic); idx != -1 {
+		candidate.Start = windowStart + idx
+		candidate.Kind = "LUKS"
+	}
+
+	return candidate
+}
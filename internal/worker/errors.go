@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/models"
+	"splitter-files/pkg/fileutils"
+)
+
+// ProcessingError is returned by ProcessFile when some candidates were not extracted
+type ProcessingError struct {
+	// Rejected counts candidates that turned out not to be files of a known format
+	Rejected int
+	// WriteErrors are the files that were found but could not be written
+	WriteErrors []error
+	// Failures describe every candidate that was not extracted, in input order
+	Failures []models.ProcessingFailure
+	// OutputFailure is why the output could take no more files - it is full,
+	// read-only or not writable - if a write failed for such a reason; the run
+	// is stopped then
+	OutputFailure string
+}
+
+// Error counts the failures of each kind
+func (e *ProcessingError) Error() string {
+	counts := make(map[string]int)
+	for _, failure := range e.Failures {
+		counts[failure.Kind]++
+	}
+	var kinds []string
+	for _, kind := range []string{models.FailureValidation, models.FailureTooSmall, models.FailureVerification, models.FailurePanic, models.FailureTimeout, models.FailureWrite} {
+		if counts[kind] > 0 {
+			kinds = append(kinds, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return fmt.Sprintf("encountered %d processing errors (%s)", len(e.Failures), strings.Join(kinds, ", "))
+}
+
+// ValidationErrors counts the candidates that failed past their signature: the
+// files that didn't verify with -strict, and the candidates a parser crashed or
+// timed out on. Candidates that just aren't files of their format don't count.
+func (e *ProcessingError) ValidationErrors() int {
+	n := 0
+	for _, failure := range e.Failures {
+		switch failure.Kind {
+		case models.FailureVerification, models.FailurePanic, models.FailureTimeout:
+			n++
+		}
+	}
+	return n
+}
+
+// add records the failure of the candidate at offset, -1 if unknown
+func (e *ProcessingError) add(err error, offset int) {
+	failure := newFailure(err, offset)
+	if failure.Kind == models.FailureWrite {
+		e.WriteErrors = append(e.WriteErrors, err)
+		if e.OutputFailure == "" {
+			e.OutputFailure = fileutils.OutputFailure(err)
+		}
+	} else {
+		e.Rejected++
+	}
+	e.Failures = append(e.Failures, failure)
+}
+
+// newFailure describes the error of the candidate at offset, -1 if unknown,
+// unless the error tells the position itself; errors that don't tell their
+// kind are taken for failed validation
+func newFailure(err error, offset int) models.ProcessingFailure {
+	failure := models.ProcessingFailure{Kind: models.FailureValidation, Offset: offset, Reason: err.Error()}
+	var candidate *extractor.CandidateError
+	if errors.As(err, &candidate) {
+		failure.Kind, failure.Extension, failure.Offset = candidate.Kind, candidate.Extension, candidate.Start
+		failure.Reason = candidate.Err.Error()
+	}
+
+	var writeErr *fileutils.WriteError
+	if errors.As(err, &writeErr) {
+		failure.Kind, failure.Path, failure.Reason = models.FailureWrite, writeErr.Path, writeErr.Err.Error()
+	}
+	return failure
+}
+
+// FailureKind returns the models.Failure* kind of the error of a candidate
+func FailureKind(err error) string {
+	return newFailure(err, -1).Kind
+}
+
+// sort puts the failures in input order, those of unknown offset last
+func (e *ProcessingError) sort() {
+	sort.SliceStable(e.Failures, func(i, j int) bool {
+		a, b := e.Failures[i].Offset, e.Failures[j].Offset
+		if a < 0 || b < 0 {
+			return a >= 0 && b < 0
+		}
+		return a < b
+	})
+}
@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/models"
+)
+
+// ProgressInterval is how often an event stream gets a progress event
+const ProgressInterval = time.Second
+
+// Event types of an event stream
+const (
+	EventStart     = "start"
+	EventProgress  = "progress"
+	EventCandidate = "candidate"
+	EventFile      = "file"
+	EventSkipped   = "skipped"
+	EventError     = "error"
+	EventDone      = "done"
+)
+
+// Phases of a run in its progress events
+const (
+	// PhaseIndex is the search of the input for magic numbers
+	PhaseIndex = "index"
+	// PhaseScan is the validation of the candidates found and their extraction
+	PhaseScan = "scan"
+)
+
+// Event is a line of an event stream; only the fields of its type are set
+type Event struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+
+	// start: the scanned region of the input, absolute like all positions
+	Start   *int `json:"start,omitempty"`
+	End     *int `json:"end,omitempty"`
+	Workers int  `json:"workers,omitempty"`
+
+	// candidate: the position of a candidate handed to the workers
+	Offset *int `json:"offset,omitempty"`
+
+	// progress and done: the phase of the run, how far it got through the
+	// input, and the counts so far
+	Phase     string   `json:"phase,omitempty"`
+	Position  *int     `json:"position,omitempty"`
+	Percent   *float64 `json:"percent,omitempty"`
+	Files     *int     `json:"files,omitempty"`
+	Failures  *int     `json:"failures,omitempty"`
+	Skipped   *int     `json:"skipped,omitempty"`
+	Coverage  *float64 `json:"coverage,omitempty"`
+	StoppedBy string   `json:"stopped_by,omitempty"`
+
+	// file: the extracted file, as in the jsonl report
+	File *ResultRecord `json:"file,omitempty"`
+	// error and skipped: the candidate that was not extracted
+	Error *ErrorRecord `json:"error,omitempty"`
+}
+
+// EventStream writes a JSON event per step of a run - start, progress,
+// candidates, extracted files, errors and the end - one per line, for
+// orchestrators tracking the run as it goes. It gets the files and errors as
+// a Reporter of the run; files are reported once they are extracted, and with
+// asynchronous writes a later error event tells those that could not be
+// written.
+type EventStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	// Counts of the events so far, for the progress events
+	files, failures, skipped int
+}
+
+func NewEventStream(w io.Writer) *EventStream {
+	return &EventStream{enc: json.NewEncoder(w)}
+}
+
+func (s *EventStream) Extracted(result models.ExtractionResult) {
+	record := NewResultRecord(result)
+	s.emit(Event{Event: EventFile, File: &record}, func() { s.files++ })
+}
+
+func (s *EventStream) Failed(err error) {
+	var skipped *extractor.SkipError
+	if errors.As(err, &skipped) {
+		s.emit(Event{Event: EventSkipped, Error: &ErrorRecord{Error: err.Error()}}, func() { s.skipped++ })
+		return
+	}
+	record := NewErrorRecord(err, newFailure(err, -1))
+	s.emit(Event{Event: EventError, Error: &record}, func() { s.failures++ })
+}
+
+// emit writes an event, updating the counts with count first unless it is nil
+func (s *EventStream) emit(e Event, count func()) {
+	e.Time = time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if count != nil {
+		count()
+	}
+	s.enc.Encode(e)
+}
+
+func (s *EventStream) started(start, end, workers int) {
+	s.emit(Event{Event: EventStart, Start: &start, End: &end, Workers: workers}, nil)
+}
+
+func (s *EventStream) candidate(offset int) {
+	s.emit(Event{Event: EventCandidate, Offset: &offset}, nil)
+}
+
+// progress reports how far a phase got through a region of size bytes at
+// offset, with the counts so far
+func (s *EventStream) progress(offset int, phase string, position, size int) {
+	percent := 100.0
+	if size > 0 {
+		percent = float64(position) / float64(size) * 100
+	}
+	position += offset
+	s.mu.Lock()
+	files, failures, skipped := s.files, s.failures, s.skipped
+	s.mu.Unlock()
+	s.emit(Event{Event: EventProgress, Phase: phase, Position: &position, Percent: &percent, Files: &files, Failures: &failures, Skipped: &skipped}, nil)
+}
+
+func (s *EventStream) done(stats *models.ExtractionStats, failures int) {
+	files, skipped, coverage := stats.TotalExtracted, stats.Skipped, stats.Coverage
+	s.emit(Event{Event: EventDone, Files: &files, Failures: &failures, Skipped: &skipped, Coverage: &coverage, StoppedBy: stats.StoppedEarly}, nil)
+}
+
+// teeReporter passes the outcome of every candidate to two reporters
+type teeReporter struct {
+	first, second Reporter
+}
+
+func (r teeReporter) Extracted(result models.ExtractionResult) {
+	r.first.Extracted(result)
+	r.second.Extracted(result)
+}
+
+func (r teeReporter) Failed(err error) {
+	r.first.Failed(err)
+	r.second.Failed(err)
+}
@@ -0,0 +1,72 @@
+package worker
+
+import "sort"
+
+// interval is a half-open byte range [Start, End)
+type interval struct {
+	Start, End int
+}
+
+// intervalSet keeps sorted, non-overlapping byte ranges; it replaces a per-byte
+// coverage map so memory depends on the number of extracted files, not the input size
+type intervalSet struct {
+	ranges []interval
+}
+
+// Overlaps reports whether [start, end) intersects any range of the set
+func (s *intervalSet) Overlaps(start, end int) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool { return s.ranges[i].End > start })
+	return i < len(s.ranges) && s.ranges[i].Start < end
+}
+
+// Add inserts [start, end), merging it with the ranges it touches
+func (s *intervalSet) Add(start, end int) {
+	if start >= end {
+		return
+	}
+
+	i := sort.Search(len(s.ranges), func(i int) bool { return s.ranges[i].End >= start })
+	j := i
+	for j < len(s.ranges) && s.ranges[j].Start <= end {
+		if s.ranges[j].Start < start {
+			start = s.ranges[j].Start
+		}
+		if s.ranges[j].End > end {
+			end = s.ranges[j].End
+		}
+		j++
+	}
+
+	merged := append([]interval{{start, end}}, s.ranges[j:]...)
+	s.ranges = append(s.ranges[:i], merged...)
+}
+
+// Covered returns the number of bytes in the set
+func (s *intervalSet) Covered() int {
+	total := 0
+	for _, r := range s.ranges {
+		total += r.End - r.Start
+	}
+	return total
+}
+
+// Gaps returns the ranges of [0, size) outside the set with inclusive ends
+func (s *intervalSet) Gaps(size int) []struct{ Start, End int } {
+	var gaps []struct{ Start, End int }
+	pos := 0
+	for _, r := range s.ranges {
+		if r.Start >= size {
+			break
+		}
+		if r.Start > pos {
+			gaps = append(gaps, struct{ Start, End int }{pos, r.Start - 1})
+		}
+		if r.End > pos {
+			pos = r.End
+		}
+	}
+	if pos < size {
+		gaps = append(gaps, struct{ Start, End int }{pos, size - 1})
+	}
+	return gaps
+}
@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"time"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/scanner"
+	"splitter-files/pkg/fileutils"
+)
+
+// Options controls how ProcessFile scans and extracts the input
+type Options struct {
+	NumWorkers        int
+	AllowedExtensions map[string]bool
+	// Offset is the position of data in the whole input when only a region of it
+	// is scanned; reported positions and output names are shifted by it
+	Offset int
+	// IgnoreRanges are input positions (absolute, like Offset) where no file is
+	// looked for, e.g. what a previous run already covered
+	IgnoreRanges []fileutils.ByteRange
+	// Reporter is told about every extracted file and error (nil - nothing is reported)
+	Reporter Reporter
+	// Events gets the steps of the run as they happen, besides the Reporter
+	Events *EventStream
+	// OriginalNames names output files after the name recovered from their metadata
+	OriginalNames bool
+	// SetTimes applies the timestamp recovered from the metadata to each output file
+	SetTimes bool
+	// DumpVBA writes the macro source of macro-enabled documents next to them
+	DumpVBA bool
+	// Passwords are tried on encrypted Office documents, which get a decrypted
+	// copy next to them when one matches
+	Passwords []string
+	// ExtractMedia writes the images, audio and video of OOXML documents as
+	// files of their own next to them
+	ExtractMedia bool
+	// ExtractAttachments writes the files embedded in PDFs as files of their
+	// own next to them
+	ExtractAttachments bool
+	// PDFRevisions writes the earlier revisions of incrementally updated PDFs
+	// as files of their own next to them
+	PDFRevisions bool
+	// RepairPDF saves truncated PDFs with a rebuilt trailer instead of
+	// rejecting them
+	RepairPDF bool
+	// RepairZip saves ZIP archives without a readable central directory
+	// rebuilt from their local file headers
+	RepairZip bool
+	// TextPreview records the first characters of the text of documents in the
+	// results
+	TextPreview bool
+	// SelfVerify checks every carved file as a whole file of its format before
+	// it is written and marks the files that fail as suspect; with Strict they
+	// are dropped and reported as failures instead
+	SelfVerify bool
+	Strict     bool
+	// Validation is how much of the structure of a file validators require:
+	// strict trades recall for fewer false positives, lenient the other way
+	Validation extractor.ValidationLevel
+	// CandidateTimeout gives up on a candidate whose detection and analysis
+	// take longer, as crafted input may make them (0 - no limit)
+	CandidateTimeout time.Duration
+
+	// SizeFilter drops extracted files whose size is outside the range set for
+	// their format
+	SizeFilter extractor.SizeFilter
+	// OnlyEncrypted extracts only password-protected Office documents
+	OnlyEncrypted bool
+	// OnlyMacros extracts only Office documents with VBA macros
+	OnlyMacros bool
+	// After and Before extract only files whose embedded timestamp is in
+	// [After, Before); zero times are not checked
+	After, Before time.Time
+	// Grep extracts only files whose content matches one of its patterns
+	Grep *extractor.ContentFilter
+
+	// Recursive carves the entries of extracted ZIP archives, and of the
+	// archives found in them, into a directory next to each archive. MaxDepth
+	// is the number of levels of archives opened and MaxExpanded the number of
+	// bytes that may be decompressed from all of them (0 - no limit); an
+	// archive found again, inside itself or elsewhere, is opened once.
+	Recursive   bool
+	MaxDepth    int
+	MaxExpanded int64
+	// nesting holds the limits shared with the runs over the entries of
+	// archives, and depth the number of archives the data was taken from
+	nesting *extractor.NestingLimits
+	depth   int
+	// files is the limit of output files of the run the archives were found
+	// by, shared with it
+	files *fileutils.FileLimit
+
+	// MaxFiles and MaxDuration stop the run early once that many files were
+	// written or that much time has passed (0 - no limit). The files written
+	// next to the extracted files and the entries of archives carved with
+	// Recursive count too, and none is written past MaxFiles; files being
+	// carved when time runs out are finished
+	MaxFiles    int
+	MaxDuration time.Duration
+
+	// Hash computes the SHA-256 of every extracted file for the manifest
+	Hash bool
+
+	// MaxMemory caps the memory of the signature index and its candidates,
+	// which then cover a window of the input at a time, to a quarter of it and
+	// the size of the output files being written at once to the rest (0 -
+	// unlimited); the buffers used to analyze a file are not counted
+	MaxMemory int64
+
+	// Writers is the number of goroutines writing output files from a queue of
+	// WriteQueue files (0 - workers write synchronously); Fsync flushes every file
+	// to stable storage
+	Writers         int
+	WriteQueue      int
+	WriteBufferSize int
+	Fsync           bool
+	// CaseID and EvidenceID are prefixed to the output file names and recorded
+	// in the results (checked with extractor.CheckIdentifier)
+	CaseID, EvidenceID string
+	// ContentAddressed stores every distinct file once under
+	// objects/ab/cd/<sha256>.<extension> instead of numbered names; the results
+	// map the positions of all files to these objects
+	ContentAddressed bool
+	// ShardFiles and ShardBytes spread the output files over numbered
+	// subdirectories of at most this many files or bytes (0 - no limit)
+	ShardFiles int
+	ShardBytes int64
+	// Compress compresses every output file with this method ("gzip" or
+	// "zstd"), adding its suffix to the file names
+	Compress string
+	// EncryptKey encrypts every output file with AES-256-GCM after compression,
+	// adding output.EncryptionSuffix to the file names
+	EncryptKey []byte
+	// Sink stores the output files instead of the files being written to
+	// outputDir, which then only names them (see fileutils.OutputPath)
+	Sink fileutils.Sink
+	// WriteLatency is told how long storing each output file took, compression
+	// and encryption included
+	WriteLatency func(time.Duration)
+
+	// DetectContainers reports large high-entropy uncovered regions as possible
+	// encrypted containers instead of plain uncovered areas
+	DetectContainers bool
+	ContainerMinSize int
+	// DumpUncovered writes the uncovered areas of at least UncoveredMinSize
+	// bytes to UncoveredDir, for manual analysis or a pass of another tool
+	DumpUncovered    bool
+	UncoveredMinSize int
+
+	// Clamd scans every extracted file; detections are moved to QuarantineDir if it is set
+	Clamd         *scanner.ClamdClient
+	QuarantineDir string
+}
+
+// DefaultContainerMinSize is the smallest region reported as a possible encrypted container
+const DefaultContainerMinSize = 1024 * 1024
+
+// DefaultUncoveredMinSize is the smallest uncovered area DumpUncovered writes
+const DefaultUncoveredMinSize = 4096
+
+// DefaultMaxDepth is the number of levels of archives recursive carving opens
+const DefaultMaxDepth = 3
+
+// DefaultCandidateTimeout is the time limit per candidate of hardened runs
+const DefaultCandidateTimeout = 30 * time.Second
+
+// Defaults of the output writer pool
+const (
+	DefaultWriters    = 2
+	DefaultWriteQueue = 64
+)
@@ -2,35 +2,61 @@ package worker
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"splitter-files/internal/extractor"
 	"splitter-files/internal/models"
 )
 
 type WorkerPool struct {
-	numWorkers int
-	jobs       chan FileChunk
-	results    chan models.ExtractionResult
-	wg         *sync.WaitGroup
+	numWorkers   int
+	priorityJobs chan FileChunk
+	jobs         chan FileChunk
+	results      chan models.ExtractionResult
+	wg           *sync.WaitGroup
+	// cancelled makes workers drop the chunks still queued
+	cancelled atomic.Bool
 }
 
 func NewWorkerPool(numWorkers int) *WorkerPool {
 	return &WorkerPool{
-		numWorkers: numWorkers,
-		jobs:       make(chan FileChunk, numWorkers*2),
-		results:    make(chan models.ExtractionResult, numWorkers*2),
-		wg:         &sync.WaitGroup{},
+		numWorkers:   numWorkers,
+		priorityJobs: make(chan FileChunk, numWorkers*2),
+		jobs:         make(chan FileChunk, numWorkers*2),
+		results:      make(chan models.ExtractionResult, numWorkers*2),
+		wg:           &sync.WaitGroup{},
 	}
 }
 
-func (wp *WorkerPool) Start(outputDir string, allowedExtensions map[string]bool, processor extractor.FileProcessor) {
+func (wp *WorkerPool) Start(data []byte, outputDir string, allowedExtensions map[string]bool, processor extractor.FileProcessor) {
 	for i := 0; i < wp.numWorkers; i++ {
 		wp.wg.Add(1)
-		go worker(i, wp.jobs, wp.results, outputDir, wp.wg, allowedExtensions, processor)
+		go worker(i, data, wp.priorityJobs, wp.jobs, wp.results, outputDir, wp.wg, &wp.cancelled, allowedExtensions, processor)
 	}
 }
 
+// Submit queues a chunk, blocking until a worker has room for it; chunks with
+// a non-zero Priority are picked up before the regular ones
+func (wp *WorkerPool) Submit(chunk FileChunk) {
+	if chunk.Priority > 0 {
+		wp.priorityJobs <- chunk
+	} else {
+		wp.jobs <- chunk
+	}
+}
+
+// Cancel makes workers skip the queued chunks; the ones being processed are finished
+func (wp *WorkerPool) Cancel() {
+	wp.cancelled.Store(true)
+}
+
+// Cancelled reports whether Cancel was called
+func (wp *WorkerPool) Cancelled() bool {
+	return wp.cancelled.Load()
+}
+
 func (wp *WorkerPool) Stop() {
+	close(wp.priorityJobs)
 	close(wp.jobs)
 	wp.wg.Wait()
 	close(wp.results)
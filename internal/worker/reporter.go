@@ -0,0 +1,550 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/models"
+)
+
+// Reporter receives the outcome of every candidate as ProcessFile extracts it;
+// the calls come from a single goroutine
+type Reporter interface {
+	Extracted(result models.ExtractionResult)
+	Failed(err error)
+}
+
+// ConsoleReporter prints a human-readable line per extracted file
+type ConsoleReporter struct {
+	Out io.Writer
+}
+
+func (r *ConsoleReporter) Extracted(result models.ExtractionResult) {
+	fmt.Fprintln(r.Out, formatResult(result))
+}
+
+// Failed prints only files left out by the extraction filters: most errors are
+// candidates that turned out not to be files, and their number is reported with
+// the summary
+func (r *ConsoleReporter) Failed(err error) {
+	var skipped *extractor.SkipError
+	if errors.As(err, &skipped) {
+		fmt.Fprintf(r.Out, "Skipped %s at %d (%d bytes): %s\n", skipped.Extension, skipped.Start, skipped.Size, skipped.Reason)
+	}
+}
+
+// JSONReporter writes a JSON object per extracted file or error, one per line
+type JSONReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{enc: json.NewEncoder(w)}
+}
+
+func (r *JSONReporter) Extracted(result models.ExtractionResult) {
+	r.write(NewResultRecord(result))
+}
+
+// Failed writes candidates left out by the extraction filters as a bare error,
+// and the others with the kind of failure and the position of the candidate
+func (r *JSONReporter) Failed(err error) {
+	var skipped *extractor.SkipError
+	if errors.As(err, &skipped) {
+		r.write(ErrorRecord{Error: err.Error()})
+		return
+	}
+	r.write(NewErrorRecord(err, newFailure(err, -1)))
+}
+
+func (r *JSONReporter) write(v interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enc.Encode(v)
+}
+
+// SilentReporter discards everything, for library use and benchmarks
+type SilentReporter struct{}
+
+func (SilentReporter) Extracted(models.ExtractionResult) {}
+func (SilentReporter) Failed(error)                      {}
+
+// ResultRecord is the JSON form of an extraction result
+type ResultRecord struct {
+	File         string              `json:"file"`
+	Type         string              `json:"type"`
+	Extension    string              `json:"extension"`
+	Start        int                 `json:"start"`
+	End          int                 `json:"end"`
+	Size         int                 `json:"size"`
+	OriginalName string              `json:"original_name,omitempty"`
+	HighPriority bool                `json:"high_priority,omitempty"`
+	Encrypted    bool                `json:"encrypted,omitempty"`
+	Malware      string              `json:"malware,omitempty"`
+	ScanError    string              `json:"scan_error,omitempty"`
+	Suspect      string              `json:"suspect,omitempty"`
+	Entropy      float64             `json:"entropy"`
+	ModTime      *time.Time          `json:"mod_time,omitempty"`
+	SHA256       string              `json:"sha256,omitempty"`
+	Office       *jsonOffice         `json:"office,omitempty"`
+	PDF          *jsonPDF            `json:"pdf,omitempty"`
+	Zip          *jsonZip            `json:"zip,omitempty"`
+	SFX          *jsonSFX            `json:"sfx,omitempty"`
+	Cache        *jsonCache          `json:"cache,omitempty"`
+	Location     *models.GeoLocation `json:"location,omitempty"`
+	Matches      []jsonMatch         `json:"matches,omitempty"`
+	Preview      string              `json:"preview,omitempty"`
+	CaseID       string              `json:"case_id,omitempty"`
+	EvidenceID   string              `json:"evidence_id,omitempty"`
+}
+
+// ErrorRecord is the JSON form of a candidate that was not extracted
+type ErrorRecord struct {
+	Error     string `json:"error"`
+	Kind      string `json:"kind,omitempty"`
+	Extension string `json:"extension,omitempty"`
+	Offset    *int   `json:"offset,omitempty"`
+	File      string `json:"file,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// NewErrorRecord converts a failure and the error it was made of to its JSON
+// form; without the error, the message is made of the kind and the reason
+func NewErrorRecord(err error, failure models.ProcessingFailure) ErrorRecord {
+	record := ErrorRecord{
+		Error:     failure.Kind + ": " + failure.Reason,
+		Kind:      failure.Kind,
+		Extension: failure.Extension,
+		File:      failure.Path,
+		Reason:    failure.Reason,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if failure.Offset >= 0 {
+		offset := failure.Offset
+		record.Offset = &offset
+	}
+	return record
+}
+
+type jsonOffice struct {
+	Document       string      `json:"document"`
+	Version        string      `json:"version,omitempty"`
+	Encrypted      bool        `json:"encrypted"`
+	Macros         bool        `json:"macros"`
+	VBAModules     int         `json:"vba_modules,omitempty"`
+	Password       string      `json:"password,omitempty"`
+	DecryptedFile  string      `json:"decrypted_file,omitempty"`
+	Title          string      `json:"title,omitempty"`
+	Creator        string      `json:"creator,omitempty"`
+	LastModifiedBy string      `json:"last_modified_by,omitempty"`
+	Created        *time.Time  `json:"created,omitempty"`
+	Modified       *time.Time  `json:"modified,omitempty"`
+	Application    string      `json:"application,omitempty"`
+	Embedded       []string    `json:"embedded,omitempty"`
+	External       []string    `json:"external,omitempty"`
+	Media          []jsonMedia `json:"media,omitempty"`
+	Indicators     []string    `json:"indicators,omitempty"`
+}
+
+// jsonMedia is a media part of the document written as a file of its own
+type jsonMedia struct {
+	Part   string `json:"part"`
+	File   string `json:"file"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type jsonPDF struct {
+	Version     string           `json:"version,omitempty"`
+	Pages       int              `json:"pages"`
+	Linearized  bool             `json:"linearized"`
+	Encrypted   bool             `json:"encrypted"`
+	Encryption  string           `json:"encryption,omitempty"`
+	Indicators  []string         `json:"indicators,omitempty"`
+	Repaired    bool             `json:"repaired,omitempty"`
+	Revisions   []jsonRevision   `json:"revisions,omitempty"`
+	Attachments []jsonAttachment `json:"attachments,omitempty"`
+}
+
+// jsonRevision is an earlier revision of the PDF written as a file of its own
+type jsonRevision struct {
+	File   string `json:"file"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// jsonAttachment is a file embedded in the PDF written as a file of its own
+type jsonAttachment struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// jsonZip describes a ZIP-based file: its entries, whether it is a segment of a
+// spanned or split archive, has password-protected entries, was rebuilt from
+// its local file headers or had its entries carved
+type jsonZip struct {
+	Entries       []jsonZipEntry `json:"entries,omitempty"`
+	Segment       int            `json:"segment,omitempty"`
+	Segments      int            `json:"segments,omitempty"`
+	Encryption    string         `json:"encryption,omitempty"`
+	Reconstructed bool           `json:"reconstructed,omitempty"`
+	CarvedDir     string         `json:"carved_dir,omitempty"`
+	Carved        []ResultRecord `json:"carved,omitempty"`
+	NotCarved     string         `json:"not_carved,omitempty"`
+}
+
+// jsonZipEntry is an entry of a ZIP-based file; the CRC-32 of its data is in hex
+type jsonZipEntry struct {
+	Name           string `json:"name"`
+	Size           uint64 `json:"size"`
+	CompressedSize uint64 `json:"compressed_size"`
+	CRC32          string `json:"crc32"`
+}
+
+// jsonSFX describes a self-extracting archive and the files its stub and
+// archive were written to
+type jsonSFX struct {
+	Archive       string     `json:"archive"`
+	StubFile      string     `json:"stub_file,omitempty"`
+	StubSize      int        `json:"stub_size"`
+	StubSHA256    string     `json:"stub_sha256,omitempty"`
+	ArchiveFile   string     `json:"archive_file,omitempty"`
+	ArchiveSize   int        `json:"archive_size"`
+	ArchiveSHA256 string     `json:"archive_sha256,omitempty"`
+	Linked        *time.Time `json:"linked,omitempty"`
+}
+
+// jsonMatch is a content filter hit; Offset is relative to the start of the file
+type jsonMatch struct {
+	Pattern  string `json:"pattern"`
+	Offset   int    `json:"offset"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type jsonCache struct {
+	Browser string `json:"browser"`
+	URL     string `json:"url"`
+}
+
+// NewResultRecord converts an extraction result for JSON output
+func NewResultRecord(result models.ExtractionResult) ResultRecord {
+	r := ResultRecord{
+		File:         result.Filename,
+		Type:         result.FileType,
+		Extension:    result.Extension,
+		Start:        result.Start,
+		End:          result.End,
+		Size:         result.Size,
+		OriginalName: result.OriginalName,
+		HighPriority: result.HighPriority,
+		Encrypted:    result.IsEncrypted,
+		Malware:      result.MalwareName,
+		ScanError:    result.ScanError,
+		Suspect:      result.Suspect,
+		Entropy:      math.Round(result.Entropy*1000) / 1000,
+		ModTime:      optionalTime(result.ModTime),
+		SHA256:       result.SHA256,
+		Location:     result.Location,
+		Preview:      result.Preview,
+		CaseID:       result.CaseID,
+		EvidenceID:   result.EvidenceID,
+	}
+
+	if info := result.OfficeInfo; info != nil {
+		r.Office = &jsonOffice{
+			Document:       officeLabel(info.Type),
+			Version:        info.Version,
+			Encrypted:      info.IsEncrypted,
+			Macros:         info.IsMacro,
+			VBAModules:     info.VBAModules,
+			Password:       info.Password,
+			DecryptedFile:  info.DecryptedFile,
+			Title:          info.Title,
+			Creator:        info.Creator,
+			LastModifiedBy: info.LastModifiedBy,
+			Created:        optionalTime(info.Created),
+			Modified:       optionalTime(info.Modified),
+			Application:    info.Application,
+			Indicators:     info.Indicators,
+		}
+		for _, obj := range info.Embedded {
+			r.Office.Embedded = append(r.Office.Embedded, obj.Kind+": "+obj.Name)
+		}
+		for _, ref := range info.External {
+			r.Office.External = append(r.Office.External, ref.Type+": "+ref.Target)
+		}
+		for _, media := range info.Media {
+			r.Office.Media = append(r.Office.Media, jsonMedia{Part: media.Part, File: media.File, Size: media.Size, SHA256: media.SHA256})
+		}
+	}
+
+	if info := result.PDFInfo; info != nil {
+		r.PDF = &jsonPDF{Version: info.Version, Pages: info.Pages, Linearized: info.Linearized, Encrypted: info.IsEncrypted, Encryption: info.Encryption, Indicators: info.Indicators, Repaired: info.Repaired}
+		for _, revision := range info.Revisions {
+			r.PDF.Revisions = append(r.PDF.Revisions, jsonRevision{File: revision.File, Size: revision.Size, SHA256: revision.SHA256})
+		}
+		for _, file := range info.Attachments {
+			r.PDF.Attachments = append(r.PDF.Attachments, jsonAttachment{Name: file.Name, File: file.File, Size: file.Size, SHA256: file.SHA256})
+		}
+	}
+
+	if info := result.ZipInfo; info != nil {
+		r.Zip = &jsonZip{Segment: info.Segment, Segments: info.Segments, Encryption: info.Encryption, Reconstructed: info.Reconstructed, CarvedDir: info.CarvedDir, NotCarved: info.NotCarved}
+		for _, entry := range info.Entries {
+			r.Zip.Entries = append(r.Zip.Entries, jsonZipEntry{Name: entry.Name, Size: entry.Size, CompressedSize: entry.CompressedSize, CRC32: fmt.Sprintf("%08x", entry.CRC32)})
+		}
+		for _, carved := range info.Carved {
+			r.Zip.Carved = append(r.Zip.Carved, NewResultRecord(carved))
+		}
+	}
+
+	if info := result.SFXInfo; info != nil {
+		r.SFX = &jsonSFX{
+			Archive:       info.Archive,
+			StubFile:      info.StubFile,
+			StubSize:      info.StubSize,
+			StubSHA256:    info.StubSHA256,
+			ArchiveFile:   info.ArchiveFile,
+			ArchiveSize:   info.ArchiveSize,
+			ArchiveSHA256: info.ArchiveSHA256,
+			Linked:        optionalTime(info.Linked),
+		}
+	}
+
+	if result.CacheInfo != nil {
+		r.Cache = &jsonCache{Browser: result.CacheInfo.Browser, URL: result.CacheInfo.URL}
+	}
+	for _, m := range result.Matches {
+		r.Matches = append(r.Matches, jsonMatch{Pattern: m.Pattern, Offset: m.Offset, Encoding: m.Encoding})
+	}
+	return r
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// officeLabel names the application an Office document belongs to
+func officeLabel(t models.OfficeFileType) string {
+	switch t {
+	case models.WordDocument:
+		return "Word"
+	case models.ExcelDocument:
+		return "Excel"
+	case models.PowerPointDocument:
+		return "PowerPoint"
+	case models.VisioDocument:
+		return "Visio"
+	case models.PublisherDocument:
+		return "Publisher"
+	case models.ProjectDocument:
+		return "Project"
+	case models.WorksDocument:
+		return "Works"
+	default:
+		return "Unknown Office"
+	}
+}
+
+// formatResult builds the console line describing an extracted file
+func formatResult(result models.ExtractionResult) string {
+	label := result.FileType
+	if result.OfficeInfo != nil {
+		label = officeLabel(result.OfficeInfo.Type)
+	}
+
+	info := fmt.Sprintf("Extracted %s (%s, %d bytes, pos %d-%d)",
+		filepath.Base(result.Filename), label, result.Size, result.Start, result.End)
+	if result.OfficeInfo != nil {
+		if result.OfficeInfo.IsEncrypted {
+			info += formatEncrypted(result)
+		}
+		if result.OfficeInfo.DecryptedFile != "" {
+			info += " [decrypted: " + filepath.Base(result.OfficeInfo.DecryptedFile) + "]"
+		}
+		if result.OfficeInfo.IsMacro {
+			info += " [MACROS]"
+		}
+		if result.OfficeInfo.VBAModules > 0 {
+			info += fmt.Sprintf(" [VBA source: %d modules]", result.OfficeInfo.VBAModules)
+		}
+		if result.OfficeInfo.Version != "" {
+			info += fmt.Sprintf(" [v%s]", result.OfficeInfo.Version)
+		}
+		if props := formatDocumentProperties(result.OfficeInfo); props != "" {
+			info += " [" + props + "]"
+		}
+		if embedded := formatEmbeddedObjects(result.OfficeInfo); embedded != "" {
+			info += " [embedded: " + embedded + "]"
+		}
+		if n := len(result.OfficeInfo.External); n > 0 {
+			info += fmt.Sprintf(" [external references: %d]", n)
+		}
+		if len(result.OfficeInfo.Indicators) > 0 {
+			info += " [indicators: " + strings.Join(result.OfficeInfo.Indicators, ", ") + "]"
+		}
+		if n := len(result.OfficeInfo.Media); n > 0 {
+			info += fmt.Sprintf(" [media: %d files in %s]", n, filepath.Base(filepath.Dir(result.OfficeInfo.Media[0].File)))
+		}
+	}
+
+	if result.IsEncrypted {
+		info += formatEncrypted(result)
+	}
+
+	if result.PDFInfo != nil {
+		info += " [" + formatPDFDetails(result.PDFInfo) + "]"
+		if result.PDFInfo.IsEncrypted {
+			if result.PDFInfo.Encryption != "" {
+				info += " [ENCRYPTED: " + result.PDFInfo.Encryption + "]"
+			} else {
+				info += " [ENCRYPTED]"
+			}
+		}
+		if result.PDFInfo.Repaired {
+			info += " [REPAIRED]"
+		}
+		if len(result.PDFInfo.Indicators) > 0 {
+			info += " [indicators: " + strings.Join(result.PDFInfo.Indicators, ", ") + "]"
+		}
+		if n := len(result.PDFInfo.Revisions); n > 0 {
+			info += fmt.Sprintf(" [earlier revisions: %d]", n)
+		}
+		if n := len(result.PDFInfo.Attachments); n > 0 {
+			info += fmt.Sprintf(" [attachments: %d files in %s]", n, filepath.Base(filepath.Dir(result.PDFInfo.Attachments[0].File)))
+		}
+	}
+
+	if result.ZipInfo != nil && result.ZipInfo.Reconstructed {
+		info += " [RECONSTRUCTED]"
+	}
+	if result.ZipInfo != nil && result.ZipInfo.Segment > 0 {
+		if result.ZipInfo.Segments > 0 {
+			info += fmt.Sprintf(" [SPANNED: segment %d of %d]", result.ZipInfo.Segment, result.ZipInfo.Segments)
+		} else {
+			info += fmt.Sprintf(" [SPANNED: segment %d]", result.ZipInfo.Segment)
+		}
+	}
+	if result.SFXInfo != nil {
+		info += fmt.Sprintf(" [SFX: %d-byte stub", result.SFXInfo.StubSize)
+		if result.SFXInfo.ArchiveFile != "" {
+			info += ", " + result.SFXInfo.Archive + " archive in " + filepath.Base(filepath.Dir(result.SFXInfo.ArchiveFile))
+		}
+		info += "]"
+	}
+	if result.ZipInfo != nil && len(result.ZipInfo.Carved) > 0 {
+		info += fmt.Sprintf(" [carved: %d files in %s]", len(result.ZipInfo.Carved), filepath.Base(result.ZipInfo.CarvedDir))
+	}
+	if result.ZipInfo != nil && result.ZipInfo.NotCarved != "" {
+		info += " [not carved: " + result.ZipInfo.NotCarved + "]"
+	}
+
+	if result.CacheInfo != nil {
+		info += fmt.Sprintf(" [%s cache: %s]", result.CacheInfo.Browser, result.CacheInfo.URL)
+	}
+
+	if result.Location != nil {
+		info += fmt.Sprintf(" [GPS: %.6f, %.6f]", result.Location.Latitude, result.Location.Longitude)
+	}
+
+	if result.MalwareName != "" {
+		info += fmt.Sprintf(" [MALWARE: %s]", result.MalwareName)
+	}
+	if result.ScanError != "" {
+		info += " [" + result.ScanError + "]"
+	}
+	if result.Suspect != "" {
+		info += " [SUSPECT: " + result.Suspect + "]"
+	}
+
+	if n := len(result.Matches); n > 0 {
+		info += fmt.Sprintf(" [matches: %d, first at +%d]", n, result.Matches[0].Offset)
+	}
+
+	if result.HighPriority {
+		info += " [HIGH PRIORITY]"
+	}
+
+	return info
+}
+
+// formatDocumentProperties lists the known document properties of an Office file
+func formatDocumentProperties(info *models.OfficeDocumentInfo) string {
+	var props []string
+	if info.Title != "" {
+		props = append(props, fmt.Sprintf("title: %q", info.Title))
+	}
+	if info.Creator != "" {
+		props = append(props, "creator: "+info.Creator)
+	}
+	if info.LastModifiedBy != "" {
+		props = append(props, "last modified by: "+info.LastModifiedBy)
+	}
+	if !info.Created.IsZero() {
+		props = append(props, "created: "+info.Created.Format(time.RFC3339))
+	}
+	if !info.Modified.IsZero() {
+		props = append(props, "modified: "+info.Modified.Format(time.RFC3339))
+	}
+	if info.Application != "" {
+		props = append(props, "application: "+info.Application)
+	}
+	return strings.Join(props, ", ")
+}
+
+// formatEncrypted labels a password-protected file, with the scheme of its
+// encrypted ZIP entries when known
+func formatEncrypted(result models.ExtractionResult) string {
+	if result.ZipInfo != nil && result.ZipInfo.Encryption != "" {
+		return " [ENCRYPTED: " + result.ZipInfo.Encryption + "]"
+	}
+	return " [ENCRYPTED]"
+}
+
+// formatPDFDetails describes the version, length and layout of a PDF
+func formatPDFDetails(info *models.PDFDocumentInfo) string {
+	details := []string{"v" + info.Version}
+	if info.Pages == 1 {
+		details = append(details, "1 page")
+	} else {
+		details = append(details, fmt.Sprintf("%d pages", info.Pages))
+	}
+	if info.Linearized {
+		details = append(details, "linearized")
+	}
+	return strings.Join(details, ", ")
+}
+
+// formatEmbeddedObjects counts the embedded objects of an Office file by kind
+func formatEmbeddedObjects(info *models.OfficeDocumentInfo) string {
+	var kinds []string
+	counts := make(map[string]int)
+	for _, obj := range info.Embedded {
+		if counts[obj.Kind] == 0 {
+			kinds = append(kinds, obj.Kind)
+		}
+		counts[obj.Kind]++
+	}
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s: %d", kind, counts[kind]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"splitter-files/internal/extractor"
+)
+
+// scanBatchSize is the number of consecutive candidates a scan worker
+// validates at a time
+const scanBatchSize = 256
+
+// scanBatch is a run of consecutive candidates and the channel its validated
+// chunks are sent on
+type scanBatch struct {
+	candidates []int
+	chunks     chan []FileChunk
+}
+
+// scanCandidates turns the candidate positions of the index into jobs, validating
+// the signatures of batches of candidates concurrently at level; Office documents
+// get a higher priority. The jobs are sent in input order as they are validated,
+// a few batches ahead of the reader, and the channel is closed once they all are
+// or cancelled returns true; the reader must drain it. scanned (if not nil) is
+// called with the last candidate of every batch validated, in input order.
+// Validations given up on after timeout are counted by running.
+func scanCandidates(data []byte, index *extractor.SignatureIndex, allowedExtensions map[string]bool, level extractor.ValidationLevel, workers int, timeout time.Duration, running *sync.WaitGroup, cancelled func() bool, scanned func(pos int)) <-chan FileChunk {
+	candidates := index.Candidates(allowedExtensions)
+	for len(candidates) > 0 && len(data)-candidates[len(candidates)-1] < 8 {
+		candidates = candidates[:len(candidates)-1]
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Batches are queued to the sender in the order they are handed to the
+	// workers, which bounds how far validation runs ahead of dispatch
+	batches := make(chan scanBatch)
+	pending := make(chan scanBatch, workers*2)
+	go func() {
+		defer close(batches)
+		defer close(pending)
+		for i := 0; i < len(candidates) && !cancelled(); i += scanBatchSize {
+			batch := scanBatch{
+				candidates: candidates[i:min(i+scanBatchSize, len(candidates))],
+				chunks:     make(chan []FileChunk, 1),
+			}
+			pending <- batch
+			batches <- batch
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for batch := range batches {
+				chunks := make([]FileChunk, 0, len(batch.candidates))
+				for _, pos := range batch.candidates {
+					if cancelled() {
+						break
+					}
+					chunks = append(chunks, scanGuarded(data, pos, allowedExtensions, level, timeout, running))
+				}
+				batch.chunks <- chunks
+			}
+		}()
+	}
+
+	out := make(chan FileChunk, workers*2)
+	go func() {
+		defer close(out)
+		for batch := range pending {
+			chunks := <-batch.chunks
+			if scanned != nil {
+				scanned(batch.candidates[len(batch.candidates)-1])
+			}
+			for _, chunk := range chunks {
+				out <- chunk
+			}
+		}
+	}()
+	return out
+}
+
+// scanGuarded scans the candidate at pos within timeout (0 - no limit). When
+// validating it panics or takes too long the candidate is left as a plain job,
+// whose processing then fails the same way and is reported; the validation
+// stops at its next deadline check, counted by running until then.
+func scanGuarded(data []byte, pos int, allowedExtensions map[string]bool, level extractor.ValidationLevel, timeout time.Duration, running *sync.WaitGroup) FileChunk {
+	chunk := FileChunk{Start: pos, End: len(data), Counter: int64(pos + 1)}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	scan := func() (scanned FileChunk) {
+		scanned = chunk
+		defer func() {
+			if recover() != nil {
+				scanned = chunk
+			}
+		}()
+		scanCandidate(data, &scanned, allowedExtensions, level, deadline)
+		return scanned
+	}
+	if timeout <= 0 {
+		return scan()
+	}
+
+	done := make(chan FileChunk, 1)
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		done <- scan()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case scanned := <-done:
+		return scanned
+	case <-timer.C:
+		return chunk
+	}
+}
+
+// scanCandidate validates the signatures of the candidate at the start of the
+// chunk, setting its priority and moving its start back over the data before
+// the signature that belongs to the file; no signature is tried after deadline
+func scanCandidate(data []byte, chunk *FileChunk, allowedExtensions map[string]bool, level extractor.ValidationLevel, deadline time.Time) {
+	pos := chunk.Start
+	sigs := extractor.FindFileSignaturesBefore(data[pos:], allowedExtensions, level, deadline)
+	for _, sig := range sigs {
+		if extractor.IsOfficeExtension(sig.Extension) {
+			chunk.Priority = 1
+			break
+		}
+	}
+
+	// Junk before the header of a PDF belongs to it when its offsets count it
+	if len(sigs) > 0 && sigs[0].Extension == "pdf" {
+		if start := extractor.PDFStart(data, pos); start < pos {
+			chunk.Start = start
+			chunk.Counter = int64(start + 1)
+		}
+	}
+	// So does the spanning marker before the first local header of a split
+	// ZIP archive, which only the zip signature accepts
+	if len(sigs) > 0 && (len(allowedExtensions) == 0 || allowedExtensions["zip"]) {
+		if start := extractor.ZipStart(data, pos); start < pos {
+			chunk.Start = start
+			chunk.Counter = int64(start + 1)
+		}
+	}
+}
@@ -1,217 +1,467 @@
 package worker
 
 import (
+	"errors"
 	"fmt"
-	"path/filepath"
-	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"splitter-files/internal/extractor"
 	"splitter-files/internal/models"
+	"splitter-files/internal/output"
+	"splitter-files/internal/scanner"
+	"splitter-files/pkg/fileutils"
 )
 
-func ProcessFile(data []byte, outputDir string, numWorkers int, allowedExtensions map[string]bool) ([]models.ExtractionResult, *models.ExtractionStats, error) {
-	wp := NewWorkerPool(numWorkers)
-	processor := &extractor.DefaultFileProcessor{}
-	wp.Start(outputDir, allowedExtensions, processor)
+func ProcessFile(data []byte, outputDir string, opts Options) ([]models.ExtractionResult, *models.ExtractionStats, error) {
+	allowedExtensions := opts.AllowedExtensions
+	reporter := opts.Reporter
+	if reporter == nil {
+		reporter = SilentReporter{}
+	}
+	if opts.Events != nil {
+		reporter = teeReporter{reporter, opts.Events}
+	}
+	wp := NewWorkerPool(opts.NumWorkers)
+	// The checks given up on after the candidate timeout run on until their
+	// next deadline check; the caller may unmap data once they are done
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	// stop ends the run early: indexing and validation stop, nothing more is
+	// dispatched and queued candidates are dropped
+	var stopOnce sync.Once
+	var stopReason string
+	stop := func(reason string) {
+		stopOnce.Do(func() {
+			stopReason = reason
+			wp.Cancel()
+		})
+	}
+	if opts.MaxDuration > 0 {
+		timer := time.AfterFunc(opts.MaxDuration, func() {
+			stop(fmt.Sprintf("time limit of %s reached", opts.MaxDuration))
+		})
+		defer timer.Stop()
+	}
+
+	// The progress events follow the indexing of the input, then the
+	// validation of the candidates found in it, by turns for each window of
+	// the input the index covers
+	var indexed, scanned atomic.Int64
+	var scanning atomic.Bool
+	stopProgress := make(chan struct{})
+	var progressWg sync.WaitGroup
+	if opts.Events != nil {
+		opts.Events.started(opts.Offset, opts.Offset+len(data), opts.NumWorkers)
+		progressWg.Add(1)
+		go func() {
+			defer progressWg.Done()
+			ticker := time.NewTicker(ProgressInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if scanning.Load() {
+						opts.Events.progress(opts.Offset, PhaseScan, int(scanned.Load()), len(data))
+					} else {
+						opts.Events.progress(opts.Offset, PhaseIndex, int(indexed.Load()), len(data))
+					}
+				case <-stopProgress:
+					return
+				}
+			}
+		}()
+	}
+	// Also ends the progress events of the runs that fail before the scan
+	endProgress := sync.OnceFunc(func() {
+		close(stopProgress)
+		progressWg.Wait()
+	})
+	defer endProgress()
+
+	// With a memory budget a quarter of it holds the signature index, which
+	// then covers a window of the input at a time, and the rest holds the
+	// files being written at once
+	var budget *fileutils.MemoryBudget
+	var indexLimit int64
+	if opts.MaxMemory > 0 {
+		indexLimit = opts.MaxMemory / 4
+		budget = fileutils.NewMemoryBudget(opts.MaxMemory - indexLimit)
+	}
+	index := &extractor.SignatureIndex{}
+	advance := func(start int) int {
+		scanning.Store(false)
+		defer scanning.Store(true)
+		return index.Advance(data, start, indexLimit, opts.NumWorkers, wp.Cancelled, func(n int) { indexed.Add(int64(n)) })
+	}
+	window := advance(0)
+
+	sink := opts.Sink
+	if sink == nil {
+		sink = &fileutils.DirSink{BufferSize: opts.WriteBufferSize, Fsync: opts.Fsync}
+	}
+	// Files are compressed before they are encrypted, so the encrypting sink
+	// is wrapped first
+	var nameSuffix string
+	if opts.EncryptKey != nil {
+		encrypted, err := output.NewEncryptSink(opts.EncryptKey, sink)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink = encrypted
+		nameSuffix = output.EncryptionSuffix
+	}
+	if opts.Compress != "" {
+		// The method is checked by the caller with output.CompressionSuffix
+		suffix, _ := output.CompressionSuffix(opts.Compress)
+		compressed, err := output.NewCompressSink(opts.Compress, sink)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink = compressed
+		nameSuffix = suffix + nameSuffix
+	}
+	if opts.WriteLatency != nil {
+		sink = &timedSink{next: sink, observe: opts.WriteLatency}
+	}
+
+	files := opts.files
+	if files == nil && opts.MaxFiles > 0 {
+		files = fileutils.NewFileLimit(opts.MaxFiles)
+	}
+
+	var objects *sync.Map
+	if opts.ContentAddressed {
+		objects = &sync.Map{}
+	}
+	// Only a local output directory can hold files of earlier runs
+	var names *extractor.OutputNames
+	if !opts.ContentAddressed {
+		names = extractor.NewOutputNames(outputDir, opts.Sink == nil)
+	}
+	var shards *fileutils.Shards
+	if opts.ShardFiles > 0 || opts.ShardBytes > 0 {
+		shards = &fileutils.Shards{MaxFiles: opts.ShardFiles, MaxBytes: opts.ShardBytes}
+	}
+
+	// clamd scans the written file, so writes stay synchronous with it
+	var writer *fileutils.AsyncWriter
+	if opts.Writers > 0 && opts.Clamd == nil {
+		writer = fileutils.NewAsyncWriter(opts.Writers, opts.WriteQueue, sink, budget)
+	}
+
+	// The entries of archives are carved by runs of their own, which share the
+	// limits of this one and report nothing; their output is listed in the
+	// result of the archive
+	nesting := opts.nesting
+	if opts.Recursive && nesting == nil {
+		maxDepth := opts.MaxDepth
+		if maxDepth <= 0 {
+			maxDepth = DefaultMaxDepth
+		}
+		nesting = &extractor.NestingLimits{MaxDepth: maxDepth, MaxExpanded: opts.MaxExpanded}
+	}
+	var carve func(data []byte, outputDir string) []models.ExtractionResult
+	if nesting != nil {
+		carve = func(entry []byte, entryDir string) []models.ExtractionResult {
+			nested := opts
+			nested.NumWorkers = 1
+			nested.Offset = 0
+			nested.IgnoreRanges = nil
+			nested.Reporter = nil
+			nested.Events = nil
+			nested.MaxFiles = 0
+			nested.MaxDuration = 0
+			nested.Writers = 0
+			nested.ContentAddressed = false
+			nested.ShardFiles, nested.ShardBytes = 0, 0
+			nested.DetectContainers = false
+			nested.DumpUncovered = false
+			nested.nesting = nesting
+			nested.files = files
+			nested.depth = opts.depth + 1
+			results, _, _ := ProcessFile(entry, entryDir, nested)
+			return results
+		}
+	}
+
+	var processor extractor.FileProcessor = &extractor.DefaultFileProcessor{
+		OriginalNames:      opts.OriginalNames,
+		SetTimes:           opts.SetTimes,
+		DumpVBA:            opts.DumpVBA,
+		Index:              index,
+		Budget:             budget,
+		Writer:             writer,
+		Sink:               sink,
+		CaseID:             opts.CaseID,
+		EvidenceID:         opts.EvidenceID,
+		Objects:            objects,
+		Names:              names,
+		Files:              files,
+		Shards:             shards,
+		NameSuffix:         nameSuffix,
+		Hash:               opts.Hash,
+		Passwords:          opts.Passwords,
+		ExtractMedia:       opts.ExtractMedia,
+		ExtractAttachments: opts.ExtractAttachments,
+		PDFRevisions:       opts.PDFRevisions,
+		RepairPDF:          opts.RepairPDF,
+		RepairZip:          opts.RepairZip,
+		TextPreview:        opts.TextPreview,
+		SelfVerify:         opts.SelfVerify,
+		Strict:             opts.Strict,
+		Validation:         opts.Validation,
+		Timeout:            opts.CandidateTimeout,
+		Running:            &running,
+		SizeFilter:         opts.SizeFilter,
+		OnlyEncrypted:      opts.OnlyEncrypted,
+		OnlyMacros:         opts.OnlyMacros,
+		After:              opts.After,
+		Before:             opts.Before,
+		Grep:               opts.Grep,
+		Nesting:            nesting,
+		Depth:              opts.depth,
+		Carve:              carve,
+	}
+	if opts.Clamd != nil {
+		processor = &scanner.ClamdProcessor{Next: processor, Client: opts.Clamd, QuarantineDir: opts.QuarantineDir}
+	}
+	wp.Start(data, outputDir, allowedExtensions, processor)
 
 	stats := &models.ExtractionStats{
 		InputSize: int64(len(data)),
 		FileTypes: make(map[string]int),
 	}
 
+	// Once the output is full or can't be written to, every file that follows
+	// would fail too
+	if writer != nil {
+		writer.Abort = stop
+	}
+
+	// Candidates in ignored ranges are not dispatched, and the ranges aren't
+	// reported as uncovered
+	var ignored intervalSet
+	for _, r := range opts.IgnoreRanges {
+		start, end := int(r.Start)-opts.Offset, int(r.End)-opts.Offset
+		if start < 0 {
+			start = 0
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		if start < end {
+			ignored.Add(start, end)
+		}
+	}
+	stats.Ignored = ignored.Covered()
+
 	var results []models.ExtractionResult
-	var processingErrors []error
+	var processingErrors ProcessingError
 	var resultWg sync.WaitGroup
-	var extractedFiles int32
+	var extractedFiles int
 	resultWg.Add(1)
 
 	go func() {
 		defer resultWg.Done()
-		extractedRanges := make([][2]int, 0)
+		var extracted intervalSet
 
 		for result := range wp.results {
-			if result.Error != nil {
-				processingErrors = append(processingErrors, result.Error)
+			// The limit is checked before a file is written, so the files
+			// being carved when it is reached are dropped
+			if opts.MaxFiles > 0 && files.Full() {
+				stop(fmt.Sprintf("limit of %d files reached", opts.MaxFiles))
+			}
+			if errors.Is(result.Error, extractor.ErrFileLimit) {
 				continue
 			}
-
-			atomic.AddInt32(&extractedFiles, 1)
-			results = append(results, result)
-			stats.TotalSize += int64(result.Size)
-			stats.FileTypes[result.FileType]++
-
-			newRange := [2]int{result.Start, result.End}
-			overlapFound := false
-
-			for _, r := range extractedRanges {
-				if newRange[0] < r[1] && newRange[1] > r[0] {
-					stats.Overlaps++
-					overlapFound = true
-					break
-				}
+			var skipped *extractor.SkipError
+			if errors.As(result.Error, &skipped) {
+				skipped.Start += opts.Offset
+				stats.Skipped++
+				reporter.Failed(result.Error)
+				continue
 			}
-
-			if !overlapFound {
-				extractedRanges = append(extractedRanges, newRange)
+			// The message of the worker is made before the position is moved
+			var candidate *extractor.CandidateError
+			if errors.As(result.Error, &candidate) {
+				candidate.Start += opts.Offset
+				result.Error = candidate
 			}
-
-			if result.OfficeInfo != nil {
-				var officeType string
-				switch result.OfficeInfo.Type {
-				case models.WordDocument:
-					officeType = "Word"
-				case models.ExcelDocument:
-					officeType = "Excel"
-				case models.PowerPointDocument:
-					officeType = "PowerPoint"
-				default:
-					officeType = "Unknown Office"
-				}
-
-				info := fmt.Sprintf("Extracted %s (%s, %d bytes, pos %d-%d)",
-					filepath.Base(result.Filename), officeType, result.Size, result.Start, result.End)
-				if result.OfficeInfo.IsEncrypted {
-					info += " [ENCRYPTED]"
-				}
-				if result.OfficeInfo.IsMacro {
-					info += " [MACROS]"
-				}
-				if result.OfficeInfo.Version != "" {
-					info += fmt.Sprintf(" [v%s]", result.OfficeInfo.Version)
+			if result.Error != nil {
+				processingErrors.add(result.Error, result.Start+opts.Offset)
+				reporter.Failed(result.Error)
+				if reason := fileutils.OutputFailure(result.Error); reason != "" {
+					stop(reason)
 				}
-
-				fmt.Println(info)
-			} else {
-				fmt.Printf("Extracted %s (%s, %d bytes, pos %d-%d)\n",
-					filepath.Base(result.Filename), result.FileType, result.Size, result.Start, result.End)
+				continue
 			}
-		}
 
-		// Analyze data coverage
-		covered := make([]bool, len(data))
-		for _, r := range extractedRanges {
-			start := r[0]
+			extractedFiles++
+			stats.TotalSize += int64(result.Size)
+			stats.FileTypes[result.FileType]++
+
+			start, end := result.Start, result.End
 			if start < 0 {
 				start = 0
 			}
-			end := r[1]
 			if end > len(data) {
 				end = len(data)
 			}
-			for i := start; i < end; i++ {
-				covered[i] = true
-			}
-		}
 
-		coveredCount := 0
-		for _, v := range covered {
-			if v {
-				coveredCount++
+			// A file overlapping others, such as a container carved after the
+			// files inside it, still covers its whole range
+			if extracted.Overlaps(start, end) {
+				stats.Overlaps++
 			}
-		}
+			extracted.Add(start, end)
 
-		stats.Coverage = float64(coveredCount) / float64(len(data)) * 100
-		stats.TotalExtracted = int(extractedFiles)
-		stats.UncoveredAreas = analyzeUncoveredAreas(covered)
-	}()
+			result.Start += opts.Offset
+			result.End += opts.Offset
+			results = append(results, result)
 
-	pos := 0
-	var counter int32 = 1
-	const backoffTime = 100 * time.Millisecond
+			reporter.Extracted(result)
+		}
 
-	officeQueue := make([]FileChunk, 0)
-	regularQueue := make([]FileChunk, 0)
+		// Analyze data coverage
+		stats.Coverage = float64(extracted.Covered()) / float64(len(data)) * 100
+		stats.TotalExtracted = extractedFiles
+		for _, r := range ignored.ranges {
+			extracted.Add(r.Start, r.End)
+		}
+		uncovered := analyzeUncoveredAreas(extracted.Gaps(len(data)))
 
-	for pos < len(data) || len(officeQueue) > 0 || len(regularQueue) > 0 {
-		if len(officeQueue) > 0 {
-			chunk := officeQueue[0]
-			select {
-			case wp.jobs <- chunk:
-				officeQueue = officeQueue[1:]
-				counter++
-			case <-time.After(backoffTime):
+		if opts.DetectContainers {
+			minSize := opts.ContainerMinSize
+			if minSize <= 0 {
+				minSize = DefaultContainerMinSize
 			}
-			continue
+			stats.PossibleContainers = detectEncryptedContainers(data, uncovered, minSize)
 		}
 
-		if len(regularQueue) > 0 {
-			chunk := regularQueue[0]
-			select {
-			case wp.jobs <- chunk:
-				regularQueue = regularQueue[1:]
-				counter++
-			case <-time.After(backoffTime):
-			}
-			continue
+		for _, area := range uncovered {
+			// Copied, as the input may be unmapped before the statistics are printed
+			preview := data[area.Start:min(area.End+1, area.Start+uncoveredPreviewSize)]
+			stats.UncoveredAreas = append(stats.UncoveredAreas, models.UncoveredArea{
+				Start:   area.Start + opts.Offset,
+				End:     area.End + opts.Offset,
+				Preview: append([]byte(nil), preview...),
+				Entropy: fileutils.Entropy(data[area.Start : area.End+1]),
+			})
+		}
+		for i := range stats.PossibleContainers {
+			stats.PossibleContainers[i].Start += opts.Offset
+			stats.PossibleContainers[i].End += opts.Offset
 		}
+	}()
 
-		if pos < len(data) {
-			remaining := data[pos:]
-			if len(remaining) < 8 {
-				break
+	// Only positions where some magic number matches can start a file; once the
+	// run is stopped the channel is still drained so the scan goroutines end.
+	// The index moves on to the next window once the candidates of one are
+	// dispatched, while the workers are still extracting them.
+	for {
+		chunks := scanCandidates(data, index, allowedExtensions, opts.Validation, opts.NumWorkers, opts.CandidateTimeout, &running, wp.Cancelled, func(pos int) { scanned.Store(int64(pos)) })
+		for chunk := range chunks {
+			if wp.Cancelled() {
+				continue
+			}
+			if ignored.Overlaps(chunk.Start, chunk.Start+1) {
+				continue
 			}
+			if opts.Events != nil {
+				opts.Events.candidate(chunk.Start + opts.Offset)
+			}
+			chunk.Counter += int64(opts.Offset)
+			// Blocks while all workers are busy, so dispatch runs at the pace of extraction
+			wp.Submit(chunk)
+		}
+		if window >= len(data) || wp.Cancelled() {
+			break
+		}
+		window = advance(window)
+	}
 
-			var isOfficeFile bool
-			foundSigs := extractor.FindFileSignatures(remaining, allowedExtensions)
-			for _, sig := range foundSigs {
-				if strings.HasPrefix(sig.Extension, "doc") ||
-					strings.HasPrefix(sig.Extension, "xls") ||
-					strings.HasPrefix(sig.Extension, "ppt") {
-					isOfficeFile = true
-					break
+	wp.Stop()
+	resultWg.Wait()
+	endProgress()
+	// Keeps a time limit expiring from now on from touching the finished run
+	stopOnce.Do(func() {})
+	stats.StoppedEarly = stopReason
+
+	if writer != nil {
+		// Queued files are matched to their candidates by name; the files
+		// written next to them have no position of their own
+		var written map[string]*models.ExtractionResult
+		unwritten := make(map[*models.ExtractionResult]bool)
+		for _, err := range writer.Close() {
+			if written == nil {
+				written = make(map[string]*models.ExtractionResult, len(results))
+				for i := range results {
+					written[results[i].Filename] = &results[i]
 				}
 			}
-
-			chunk := FileChunk{
-				Data:     remaining,
-				Start:    pos,
-				Counter:  counter,
-				Priority: 0,
+			var writeErr *fileutils.WriteError
+			if errors.As(err, &writeErr) && written[writeErr.Path] != nil {
+				res := written[writeErr.Path]
+				err = &extractor.CandidateError{Kind: models.FailureWrite, Extension: res.Extension, Start: res.Start, Err: err}
+				unwritten[res] = true
 			}
+			processingErrors.add(err, -1)
+			reporter.Failed(err)
+		}
 
-			if isOfficeFile {
-				chunk.Priority = 1
-				officeQueue = append(officeQueue, chunk)
-			} else {
-				regularQueue = append(regularQueue, chunk)
+		// Files that failed to write are left out of the results, as they are
+		// when writes are synchronous
+		if len(unwritten) > 0 {
+			kept := results[:0]
+			for i := range results {
+				if res := &results[i]; unwritten[res] {
+					stats.TotalExtracted--
+					stats.TotalSize -= int64(res.Size)
+					if stats.FileTypes[res.FileType]--; stats.FileTypes[res.FileType] == 0 {
+						delete(stats.FileTypes, res.FileType)
+					}
+				} else {
+					kept = append(kept, *res)
+				}
 			}
+			results = kept
+		}
+	}
 
-			pos++
+	if opts.DumpUncovered {
+		minSize := opts.UncoveredMinSize
+		if minSize <= 0 {
+			minSize = DefaultUncoveredMinSize
 		}
+		stats.UncoveredDumped = dumpUncoveredAreas(data, stats.UncoveredAreas, opts.Offset, minSize, sink, outputDir, nameSuffix, names, func(err error, start int) {
+			processingErrors.add(err, start)
+			reporter.Failed(err)
+		})
 	}
 
-	wp.Stop()
-	resultWg.Wait()
+	if opts.Events != nil {
+		if stats.StoppedEarly == "" {
+			opts.Events.progress(opts.Offset, PhaseScan, len(data), len(data))
+		}
+		opts.Events.done(stats, len(processingErrors.Failures))
+	}
 
-	if len(processingErrors) > 0 {
-		return results, stats, fmt.Errorf("encountered %d processing errors", len(processingErrors))
+	if len(processingErrors.Failures) > 0 {
+		processingErrors.sort()
+		return results, stats, &processingErrors
 	}
 
 	return results, stats, nil
 }
 
-func analyzeUncoveredAreas(covered []bool) []struct{ Start, End int } {
-	var uncovered []struct{ Start, End int }
-	inUncovered := false
-	start := 0
-
-	for i, v := range covered {
-		if !v && !inUncovered {
-			inUncovered = true
-			start = i
-		} else if v && inUncovered {
-			inUncovered = false
-			uncovered = append(uncovered, struct{ Start, End int }{start, i - 1})
-		}
-	}
-
-	if inUncovered {
-		uncovered = append(uncovered, struct{ Start, End int }{start, len(covered) - 1})
-	}
+// uncoveredPreviewSize is the number of leading bytes of uncovered areas shown in the statistics
+const uncoveredPreviewSize = 16
 
+// analyzeUncoveredAreas merges uncovered areas separated by small covered gaps
+func analyzeUncoveredAreas(uncovered []struct{ Start, End int }) []struct{ Start, End int } {
 	// Merge close areas
 	if len(uncovered) > 1 {
 		merged := make([]struct{ Start, End int }, 0)
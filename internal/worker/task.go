@@ -3,46 +3,90 @@ package worker
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"splitter-files/internal/extractor"
 	"splitter-files/internal/models"
 )
 
+// FileChunk is a candidate position in the shared input buffer; workers read
+// data[Start:End] instead of every job carrying its own slice
 type FileChunk struct {
-	Data     []byte
 	Start    int
-	Counter  int32
+	End      int
+	Counter  int64
 	Priority int
 }
 
-// DefaultFileProcessor implements the basic file processing
-type DefaultFileProcessor struct{}
-
-func worker(id int, jobs <-chan FileChunk, results chan<- models.ExtractionResult,
-	outputDir string, wg *sync.WaitGroup, allowedExtensions map[string]bool,
+func worker(id int, data []byte, priorityJobs, jobs <-chan FileChunk, results chan<- models.ExtractionResult,
+	outputDir string, wg *sync.WaitGroup, cancelled *atomic.Bool, allowedExtensions map[string]bool,
 	processor extractor.FileProcessor) {
 	defer wg.Done()
 
-	for chunk := range jobs {
-		size, endPos, filename, fileType, officeInfo, err := processor.Process(
-			chunk.Data, outputDir, chunk.Counter, chunk.Start, allowedExtensions)
+	for {
+		chunk, ok := nextChunk(priorityJobs, jobs)
+		if !ok {
+			return
+		}
+		if cancelled.Load() {
+			continue
+		}
+
+		result, err := process(processor, data, chunk, outputDir, allowedExtensions)
 
 		if err != nil {
 			results <- models.ExtractionResult{
-				Error:   fmt.Errorf("worker %d: %v", id, err),
+				Error:   fmt.Errorf("worker %d: %w", id, err),
+				Start:   chunk.Start,
 				Counter: chunk.Counter,
 			}
 			continue
 		}
 
-		results <- models.ExtractionResult{
-			Filename:   filename,
-			Size:       size,
-			Start:      chunk.Start,
-			End:        endPos,
-			Counter:    chunk.Counter,
-			FileType:   fileType,
-			OfficeInfo: officeInfo,
+		results <- *result
+	}
+}
+
+// process runs the processor on a chunk; a panic fails the candidate instead
+// of the run
+func process(processor extractor.FileProcessor, data []byte, chunk FileChunk, outputDir string,
+	allowedExtensions map[string]bool) (result *models.ExtractionResult, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			result, err = nil, extractor.PanicError(v, chunk.Start)
+		}
+	}()
+	return processor.Process(data[chunk.Start:chunk.End], outputDir, chunk.Counter, chunk.Start, allowedExtensions)
+}
+
+// nextChunk takes a priority chunk if one is waiting and otherwise blocks until
+// any chunk arrives; ok is false once both queues are closed and drained
+func nextChunk(priorityJobs, jobs <-chan FileChunk) (FileChunk, bool) {
+	for priorityJobs != nil || jobs != nil {
+		select {
+		case chunk, ok := <-priorityJobs:
+			if ok {
+				return chunk, true
+			}
+			priorityJobs = nil
+			continue
+		default:
+		}
+
+		select {
+		case chunk, ok := <-priorityJobs:
+			if !ok {
+				priorityJobs = nil
+				continue
+			}
+			return chunk, true
+		case chunk, ok := <-jobs:
+			if !ok {
+				jobs = nil
+				continue
+			}
+			return chunk, true
 		}
 	}
+	return FileChunk{}, false
 }
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"fmt"
+
+	"splitter-files/internal/extractor"
+	"splitter-files/internal/models"
+	"splitter-files/pkg/fileutils"
+)
+
+// UncoveredDir is the subdirectory of the output the uncovered areas are
+// dumped to, so that another tool can be pointed at the leftovers alone
+const UncoveredDir = "uncovered"
+
+// dumpUncoveredAreas writes the uncovered areas of at least minSize bytes with
+// the sink as UncoveredDir/unknown_<start>_<end>.bin, the end exclusive as in
+// -ignore-ranges, and returns how many were written; fail is called for those
+// that could not be. The positions of areas are absolute, data starts at offset.
+func dumpUncoveredAreas(data []byte, areas []models.UncoveredArea, offset, minSize int, sink fileutils.Sink, outputDir, nameSuffix string, names *extractor.OutputNames, fail func(err error, start int)) int {
+	var dumped int
+	for _, area := range areas {
+		if area.End-area.Start+1 < minSize {
+			continue
+		}
+		name := fmt.Sprintf("%s/unknown_%d_%d.bin%s", UncoveredDir, area.Start, area.End+1, nameSuffix)
+		if names != nil {
+			name = names.Claim(name, ".bin"+nameSuffix)
+		}
+		job := fileutils.WriteJob{Path: fileutils.OutputPath(outputDir, name), Data: data[area.Start-offset : area.End+1-offset]}
+		if err := sink.Put(job); err != nil {
+			fail(err, area.Start)
+			if fileutils.OutputFailure(err) != "" {
+				break
+			}
+			continue
+		}
+		dumped++
+	}
+	return dumped
+}
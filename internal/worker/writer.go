@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"time"
+
+	"splitter-files/pkg/fileutils"
+)
+
+// timedSink tells observe how long each file took to store with the next sink
+type timedSink struct {
+	next    fileutils.Sink
+	observe func(time.Duration)
+}
+
+func (s *timedSink) Put(job fileutils.WriteJob) error {
+	start := time.Now()
+	err := s.next.Put(job)
+	s.observe(time.Since(start))
+	return err
+}
@@ -0,0 +1,130 @@
+package fileutils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"splitter-files/internal/models"
+)
+
+// carveMapHeader starts the first line of a carve map
+const carveMapHeader = "# carvemap v1"
+
+// CarveEntry is an extracted file recorded in a carve map: its position in the
+// input, format and content hash
+type CarveEntry struct {
+	Start     int64
+	Length    int64
+	Extension string
+	SHA256    string
+}
+
+// CarveMap lists the files carved from an input, so they can be extracted again
+// straight from the input without scanning it
+type CarveMap struct {
+	// Input is the base name of the input and InputSize its size when it was scanned
+	Input     string
+	InputSize int64
+	Entries   []CarveEntry
+}
+
+// WriteCarveMap writes the carve map of results extracted from input: a header
+// line, then "start length extension sha256" per file in input order. Repaired
+// PDFs and reconstructed ZIP archives are left out, as their content isn't a
+// copy of the input.
+func WriteCarveMap(path, input string, inputSize int64, results []models.ExtractionResult) error {
+	entries := make([]CarveEntry, 0, len(results))
+	for _, res := range results {
+		if res.PDFInfo != nil && res.PDFInfo.Repaired || res.ZipInfo != nil && res.ZipInfo.Reconstructed {
+			continue
+		}
+		entries = append(entries, CarveEntry{
+			Start:     int64(res.Start),
+			Length:    int64(res.Size),
+			Extension: res.Extension,
+			SHA256:    res.SHA256,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Start < entries[j].Start })
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s input=%s size=%d\n", carveMapHeader, filepath.Base(input), inputSize)
+	for _, e := range entries {
+		hash := e.SHA256
+		if hash == "" {
+			hash = "-"
+		}
+		fmt.Fprintf(&sb, "%d %d %s %s\n", e.Start, e.Length, e.Extension, hash)
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
+
+// ReadCarveMap reads a carve map written by WriteCarveMap
+func ReadCarveMap(path string) (*CarveMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &CarveMap{}
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), carveMapHeader) {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s is not a carve map", path)
+	}
+	for _, field := range strings.Fields(strings.TrimPrefix(scanner.Text(), carveMapHeader)) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "input":
+			m.Input = value
+		case "size":
+			m.InputSize, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("malformed carve map line %d", line)
+		}
+		start, err1 := strconv.ParseInt(fields[0], 10, 64)
+		length, err2 := strconv.ParseInt(fields[1], 10, 64)
+		if err1 != nil || err2 != nil || start < 0 || length <= 0 {
+			return nil, fmt.Errorf("malformed carve map line %d", line)
+		}
+		if !validExtension(fields[2]) {
+			return nil, fmt.Errorf("invalid extension %q on carve map line %d", fields[2], line)
+		}
+		e := CarveEntry{Start: start, Length: length, Extension: fields[2]}
+		if fields[3] != "-" {
+			e.SHA256 = strings.ToLower(fields[3])
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	return m, scanner.Err()
+}
+
+// validExtension accepts the extensions WriteCarveMap writes, lowercase ASCII
+// letters and digits, so an entry can't name a path outside the output
+func validExtension(ext string) bool {
+	if ext == "" {
+		return false
+	}
+	for _, c := range []byte(ext) {
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+package fileutils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"path"
+	"sort"
+)
+
+// Size of the coverage map images: a strip of coverageMapWidth columns per
+// partition of the input
+const (
+	coverageMapWidth    = 1024
+	coverageStripHeight = 24
+	coverageStripGap    = 8
+	// coverageLabelHeight is the space above each strip of an SVG map for its label
+	coverageLabelHeight = 18
+)
+
+// Colors of the coverage map; a column of the PNG map mixes them by the share
+// of its bytes in each state
+var (
+	coverageColorCovered   = color.RGBA{0x2e, 0x7d, 0x32, 0xff}
+	coverageColorUncovered = color.RGBA{0xcf, 0xd8, 0xdc, 0xff}
+	coverageColorIgnored   = color.RGBA{0x78, 0x90, 0x9c, 0xff}
+	coverageColorUnscanned = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// CoverageFile is an extracted file on the coverage map
+type CoverageFile struct {
+	Start, End int64
+	Name       string
+}
+
+// CoverageMap is what of the input the extracted files cover: a strip per
+// partition of the input, or one for all of it if no partitions are given
+type CoverageMap struct {
+	InputName string
+	InputSize int64
+	// Scanned is the region of the input that was scanned
+	Scanned ByteRange
+	// Ignored are the ranges where no files were looked for
+	Ignored    []ByteRange
+	Files      []CoverageFile
+	Partitions []ByteRange
+}
+
+// RenderCoverageMap draws the coverage map as a PNG or an SVG image; only the
+// SVG image has labels, with the share of each partition covered, and tooltips
+// naming the files and the offsets of the uncovered areas
+func RenderCoverageMap(m CoverageMap, format string) ([]byte, error) {
+	switch format {
+	case "png":
+		return renderCoveragePNG(m)
+	case "svg":
+		return renderCoverageSVG(m), nil
+	}
+	return nil, fmt.Errorf("unsupported coverage map format %q (use png or svg)", format)
+}
+
+// strips returns the ranges drawn as strips
+func (m CoverageMap) strips() []ByteRange {
+	if len(m.Partitions) == 0 {
+		return []ByteRange{{Start: 0, End: m.InputSize}}
+	}
+	return m.Partitions
+}
+
+// covered returns the union of the ranges of the extracted files
+func (m CoverageMap) covered() []ByteRange {
+	ranges := make([]ByteRange, 0, len(m.Files))
+	for _, file := range m.Files {
+		ranges = append(ranges, ByteRange{Start: file.Start, End: file.End})
+	}
+	return mergeRanges(ranges)
+}
+
+// mergeRanges returns the union of ranges as sorted disjoint ranges
+func mergeRanges(ranges []ByteRange) []ByteRange {
+	sorted := append([]ByteRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	var merged []ByteRange
+	for _, r := range sorted {
+		if r.End <= r.Start {
+			continue
+		}
+		if n := len(merged); n > 0 && r.Start <= merged[n-1].End {
+			if r.End > merged[n-1].End {
+				merged[n-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// overlap returns the number of bytes of [start, end) in ranges, which are
+// sorted and disjoint
+func overlap(ranges []ByteRange, start, end int64) int64 {
+	var n int64
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].End > start })
+	for ; i < len(ranges) && ranges[i].Start < end; i++ {
+		n += min(ranges[i].End, end) - max(ranges[i].Start, start)
+	}
+	return n
+}
+
+// shares returns the bytes of [start, end) that were covered, ignored and not
+// scanned; the rest was scanned without finding a file
+func (m CoverageMap) shares(covered, ignored []ByteRange, start, end int64) (cov, ign, unscanned int64) {
+	unscanned = end - start - overlap([]ByteRange{m.Scanned}, start, end)
+	ign = overlap(ignored, start, end)
+	cov = overlap(covered, start, end)
+	return cov, ign, unscanned
+}
+
+func renderCoveragePNG(m CoverageMap) ([]byte, error) {
+	strips := m.strips()
+	covered, ignored := m.covered(), mergeRanges(m.Ignored)
+	height := len(strips)*(coverageStripHeight+coverageStripGap) - coverageStripGap
+	img := image.NewRGBA(image.Rect(0, 0, coverageMapWidth, height))
+
+	for row, strip := range strips {
+		top := row * (coverageStripHeight + coverageStripGap)
+		size := strip.End - strip.Start
+		for x := 0; x < coverageMapWidth; x++ {
+			start := strip.Start + size*int64(x)/coverageMapWidth
+			end := strip.Start + size*int64(x+1)/coverageMapWidth
+			c := coverageColorUnscanned
+			if end > start {
+				cov, ign, unscanned := m.shares(covered, ignored, start, end)
+				c = mixColors(end-start, []int64{cov, ign, unscanned}, []color.RGBA{coverageColorCovered, coverageColorIgnored, coverageColorUnscanned}, coverageColorUncovered)
+			}
+			for y := top; y < top+coverageStripHeight; y++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mixColors averages colors weighted by the bytes of a column of total bytes
+// in each state; the remaining bytes are drawn in rest
+func mixColors(total int64, bytes []int64, colors []color.RGBA, rest color.RGBA) color.RGBA {
+	remaining := total
+	var r, g, b int64
+	for i, n := range bytes {
+		remaining -= n
+		r += n * int64(colors[i].R)
+		g += n * int64(colors[i].G)
+		b += n * int64(colors[i].B)
+	}
+	if remaining > 0 {
+		r += remaining * int64(rest.R)
+		g += remaining * int64(rest.G)
+		b += remaining * int64(rest.B)
+	}
+	return color.RGBA{uint8(r / total), uint8(g / total), uint8(b / total), 0xff}
+}
+
+func renderCoverageSVG(m CoverageMap) []byte {
+	strips := m.strips()
+	covered, ignored := m.covered(), mergeRanges(m.Ignored)
+	rowHeight := coverageLabelHeight + coverageStripHeight + coverageStripGap
+	legendTop := len(strips) * rowHeight
+	height := legendTop + coverageLabelHeight
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", coverageMapWidth, height)
+	fmt.Fprintf(&b, "<title>Coverage of %s</title>\n", svgText(path.Base(m.InputName)))
+
+	for row, strip := range strips {
+		top := row*rowHeight + coverageLabelHeight
+		size := strip.End - strip.Start
+		if size <= 0 {
+			continue
+		}
+		x := func(pos int64) float64 {
+			return float64(pos-strip.Start) * coverageMapWidth / float64(size)
+		}
+		rect := func(r ByteRange, c color.RGBA, title string) {
+			start, end := max(r.Start, strip.Start), min(r.End, strip.End)
+			if end <= start {
+				return
+			}
+			fmt.Fprintf(&b, `<rect x="%.2f" y="%d" width="%.2f" height="%d" fill="%s">`, x(start), top, x(end)-x(start), coverageStripHeight, svgColor(c))
+			fmt.Fprintf(&b, "<title>%s</title></rect>\n", svgText(title))
+		}
+
+		cov, ign, unscanned := m.shares(covered, ignored, strip.Start, strip.End)
+		label := fmt.Sprintf("%d-%d (%d bytes)", strip.Start, strip.End, size)
+		if len(m.Partitions) > 0 {
+			label = fmt.Sprintf("Partition %d: %s", row+1, label)
+		}
+		if searched := size - ign - unscanned; searched > 0 {
+			label += fmt.Sprintf(": %.1f%% covered", float64(cov)*100/float64(searched))
+		}
+		fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`+"\n", top-5, svgText(label))
+
+		fmt.Fprintf(&b, `<rect x="0" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", top, coverageMapWidth, coverageStripHeight, svgColor(coverageColorUnscanned))
+		// Uncovered areas are what is left of the scanned region between the files
+		rect(m.Scanned, coverageColorUncovered, fmt.Sprintf("scanned %d-%d", m.Scanned.Start, m.Scanned.End))
+		for _, gap := range gaps(covered, max(m.Scanned.Start, strip.Start), min(m.Scanned.End, strip.End)) {
+			rect(gap, coverageColorUncovered, fmt.Sprintf("uncovered %d-%d (%d bytes)", gap.Start, gap.End, gap.End-gap.Start))
+		}
+		for _, r := range ignored {
+			rect(r, coverageColorIgnored, fmt.Sprintf("ignored %d-%d", r.Start, r.End))
+		}
+		for _, file := range m.Files {
+			rect(ByteRange{Start: file.Start, End: file.End}, coverageColorCovered, fmt.Sprintf("%s: %d-%d", path.Base(file.Name), file.Start, file.End))
+		}
+	}
+
+	legend := []struct {
+		label string
+		c     color.RGBA
+	}{
+		{"extracted", coverageColorCovered},
+		{"uncovered", coverageColorUncovered},
+		{"ignored", coverageColorIgnored},
+		{"not scanned", coverageColorUnscanned},
+	}
+	for i, entry := range legend {
+		x := i * 120
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="12" height="12" fill="%s" stroke="#607d8b"/>`, x, legendTop+2, svgColor(entry.c))
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", x+16, legendTop+12, entry.label)
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// gaps returns the parts of [start, end) outside ranges, which are sorted and
+// disjoint
+func gaps(ranges []ByteRange, start, end int64) []ByteRange {
+	var result []ByteRange
+	pos := start
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].End > start })
+	for ; i < len(ranges) && ranges[i].Start < end; i++ {
+		if ranges[i].Start > pos {
+			result = append(result, ByteRange{Start: pos, End: ranges[i].Start})
+		}
+		pos = max(pos, ranges[i].End)
+	}
+	if pos < end {
+		result = append(result, ByteRange{Start: pos, End: end})
+	}
+	return result
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// svgText escapes text for SVG
+func svgText(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
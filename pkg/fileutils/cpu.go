@@ -1,44 +1,18 @@
 package fileutils
 
-import (
-	"io/ioutil"
-	"regexp"
-	"runtime"
-)
+import "runtime"
 
+// GetPhysicalCPUCount returns the number of physical cores, or of logical CPUs
+// where the platform doesn't tell, limited to the CPUs the process may run on
+// and to the CPU quota of the container it runs in
 func GetPhysicalCPUCount() int {
-	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" || runtime.GOOS == "freebsd" {
-		data, err := ioutil.ReadFile("/proc/cpuinfo")
-		if err == nil {
-			physicalIDs := make(map[string]bool)
-			re := regexp.MustCompile(`physical id\s*:\s*(\d+)`)
-			matches := re.FindAllStringSubmatch(string(data), -1)
-			for _, match := range matches {
-				if len(match) > 1 {
-					physicalIDs[match[1]] = true
-				}
-			}
-
-			if len(physicalIDs) > 0 {
-				coresPerCPU := make(map[string]int)
-				reCore := regexp.MustCompile(`physical id\s*:\s*(\d+).*?cpu cores\s*:\s*(\d+)`)
-				matchesCore := reCore.FindAllStringSubmatch(string(data), -1)
-				for _, match := range matchesCore {
-					if len(match) > 2 {
-						coresPerCPU[match[1]] = coresPerCPU[match[1]] + 1
-					}
-				}
-
-				totalCores := 0
-				for _, cores := range coresPerCPU {
-					totalCores += cores
-				}
-
-				if totalCores > 0 {
-					return totalCores
-				}
-			}
-		}
+	// runtime.NumCPU honours the affinity mask of the process
+	n := runtime.NumCPU()
+	if cores := physicalCores(); cores > 0 && cores < n {
+		n = cores
+	}
+	if quota := cpuQuota(); quota > 0 && quota < n {
+		n = quota
 	}
-	return runtime.NumCPU()
+	return n
 }
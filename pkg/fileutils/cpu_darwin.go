@@ -0,0 +1,14 @@
+//go:build darwin
+
+package fileutils
+
+import "syscall"
+
+// physicalCores returns the hw.physicalcpu sysctl, or 0 if it can't be read
+func physicalCores() int {
+	n, err := syscall.SysctlUint32("hw.physicalcpu")
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
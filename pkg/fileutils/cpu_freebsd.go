@@ -0,0 +1,14 @@
+//go:build freebsd
+
+package fileutils
+
+import "syscall"
+
+// physicalCores returns the kern.smp.cores sysctl, or 0 if it can't be read
+func physicalCores() int {
+	n, err := syscall.SysctlUint32("kern.smp.cores")
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
@@ -0,0 +1,111 @@
+//go:build linux
+
+package fileutils
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// physicalCores counts the distinct cores of /proc/cpuinfo, or returns 0 when
+// it doesn't list them, as on most ARM systems
+func physicalCores() int {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	// Processors are separated by blank lines; hyper-threads of a core share
+	// its physical id and core id
+	cores := make(map[[2]string]bool)
+	var physicalID, coreID string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		switch key, value = strings.TrimSpace(key), strings.TrimSpace(value); {
+		case !ok:
+			if coreID != "" {
+				cores[[2]string{physicalID, coreID}] = true
+			}
+			physicalID, coreID = "", ""
+		case key == "physical id":
+			physicalID = value
+		case key == "core id":
+			coreID = value
+		}
+	}
+	if coreID != "" {
+		cores[[2]string{physicalID, coreID}] = true
+	}
+	return len(cores)
+}
+
+// cpuQuota returns the number of CPUs the CFS quota of the cgroup of the
+// process allows, rounded up, or 0 if it has none; both cgroup v2 (cpu.max)
+// and v1 (cpu.cfs_quota_us) are read
+func cpuQuota() int {
+	for _, dir := range cgroupDirs("") {
+		if data, err := os.ReadFile(filepath.Join(dir, "cpu.max")); err == nil {
+			fields := strings.Fields(string(data))
+			if len(fields) == 2 {
+				return quotaCPUs(fields[0], fields[1])
+			}
+		}
+	}
+	for _, dir := range cgroupDirs("cpu") {
+		quota, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+		if err != nil {
+			continue
+		}
+		period, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+		if err != nil {
+			continue
+		}
+		return quotaCPUs(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+	}
+	return 0
+}
+
+// quotaCPUs converts a CFS quota and period in microseconds to CPUs; the quota
+// is "max" (v2) or -1 (v1) without a limit
+func quotaCPUs(quota, period string) int {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return int((q + p - 1) / p)
+}
+
+// cgroupDirs returns where the cgroup of the process may be mounted for the
+// controller, "" for the unified hierarchy of cgroup v2: its own directory as
+// /proc/self/cgroup names it, then the root, which is what containers see
+func cgroupDirs(controller string) []string {
+	root := "/sys/fs/cgroup"
+	if controller != "" {
+		root = filepath.Join(root, controller)
+	}
+
+	var dirs []string
+	if data, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		// Lines are "hierarchy-ID:controllers:path"; cgroup v2 has no controllers
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.SplitN(line, ":", 3)
+			if len(fields) != 3 {
+				continue
+			}
+			controllers := "," + fields[1] + ","
+			if controller == "" && fields[1] == "" || controller != "" && strings.Contains(controllers, ","+controller+",") {
+				dirs = append(dirs, filepath.Join(root, fields[2]))
+			}
+		}
+	}
+	return append(dirs, root)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package fileutils
+
+// cpuQuota returns 0: only Linux limits the CPUs of containers with cgroups
+func cpuQuota() int {
+	return 0
+}
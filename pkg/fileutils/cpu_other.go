@@ -0,0 +1,8 @@
+//go:build !linux && !windows && !darwin && !freebsd
+
+package fileutils
+
+// physicalCores is unknown here; all logical CPUs are used
+func physicalCores() int {
+	return 0
+}
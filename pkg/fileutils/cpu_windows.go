@@ -0,0 +1,47 @@
+//go:build windows
+
+package fileutils
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetLogicalProcessorInformation = syscall.NewLazyDLL("kernel32.dll").NewProc("GetLogicalProcessorInformation")
+
+// systemLogicalProcessorInformation is SYSTEM_LOGICAL_PROCESSOR_INFORMATION,
+// whose union of details is left out
+type systemLogicalProcessorInformation struct {
+	ProcessorMask uintptr
+	Relationship  uint32
+	_             [2]uint64
+}
+
+// relationProcessorCore is the relationship of the entries that describe a
+// physical core
+const relationProcessorCore = 0
+
+// physicalCores counts the processor cores GetLogicalProcessorInformation
+// describes, or returns 0 if it fails
+func physicalCores() int {
+	var size uint32
+	// The first call fails with the size of the buffer needed
+	procGetLogicalProcessorInformation.Call(0, uintptr(unsafe.Pointer(&size)))
+	entrySize := uint32(unsafe.Sizeof(systemLogicalProcessorInformation{}))
+	if size < entrySize {
+		return 0
+	}
+
+	entries := make([]systemLogicalProcessorInformation, size/entrySize)
+	if ok, _, _ := procGetLogicalProcessorInformation.Call(uintptr(unsafe.Pointer(&entries[0])), uintptr(unsafe.Pointer(&size))); ok == 0 {
+		return 0
+	}
+
+	var cores int
+	for _, entry := range entries[:size/entrySize] {
+		if entry.Relationship == relationProcessorCore {
+			cores++
+		}
+	}
+	return cores
+}
@@ -0,0 +1,126 @@
+package fileutils
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"splitter-files/internal/models"
+)
+
+// ExportLocations writes the GPS locations of the extracted files as KML (.kml)
+// or GeoJSON (.geojson, .json) depending on the extension of path and returns
+// the number of placemarks written
+func ExportLocations(path string, results []models.ExtractionResult) (int, error) {
+	var located []models.ExtractionResult
+	for _, res := range results {
+		if res.Location != nil {
+			located = append(located, res)
+		}
+	}
+
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".kml":
+		data, err = buildKML(located)
+	case ".geojson", ".json":
+		data, err = buildGeoJSON(located)
+	default:
+		return 0, fmt.Errorf("unsupported location export format %q (use .kml or .geojson)", filepath.Ext(path))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, err
+	}
+	return len(located), nil
+}
+
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Coordinates string `xml:"Point>coordinates"`
+}
+
+type kmlDocument struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Namespace  string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+func buildKML(results []models.ExtractionResult) ([]byte, error) {
+	doc := kmlDocument{
+		Namespace: "http://www.opengis.net/kml/2.2",
+		Name:      "Extracted file locations",
+	}
+	for _, res := range results {
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			Name:        filepath.Base(res.Filename),
+			Description: fmt.Sprintf("%s, offset %d-%d", res.FileType, res.Start, res.End),
+			Coordinates: strings.Join(locationCoordinates(res.Location), ","),
+		})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func buildGeoJSON(results []models.ExtractionResult) ([]byte, error) {
+	collection := struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+
+	for _, res := range results {
+		point := []float64{res.Location.Longitude, res.Location.Latitude}
+		if res.Location.HasAltitude {
+			point = append(point, res.Location.Altitude)
+		}
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONPoint{Type: "Point", Coordinates: point},
+			Properties: map[string]interface{}{
+				"file":  filepath.Base(res.Filename),
+				"type":  res.FileType,
+				"start": res.Start,
+				"end":   res.End,
+			},
+		})
+	}
+
+	data, err := json.MarshalIndent(collection, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// locationCoordinates formats a location in KML order (longitude, latitude[, altitude])
+func locationCoordinates(loc *models.GeoLocation) []string {
+	coords := []string{fmt.Sprintf("%.7f", loc.Longitude), fmt.Sprintf("%.7f", loc.Latitude)}
+	if loc.HasAltitude {
+		coords = append(coords, fmt.Sprintf("%.2f", loc.Altitude))
+	}
+	return coords
+}
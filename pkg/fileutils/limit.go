@@ -0,0 +1,28 @@
+package fileutils
+
+import "sync/atomic"
+
+// FileLimit hands out a fixed number of output files to the workers writing
+// them at once, so that none is written past the limit; a nil limit is
+// unlimited
+type FileLimit struct {
+	max   int64
+	taken atomic.Int64
+}
+
+func NewFileLimit(max int) *FileLimit {
+	return &FileLimit{max: int64(max)}
+}
+
+// Take reserves a file, and returns false once all of them are taken
+func (l *FileLimit) Take() bool {
+	if l == nil {
+		return true
+	}
+	return l.taken.Add(1) <= l.max
+}
+
+// Full reports whether all files are taken
+func (l *FileLimit) Full() bool {
+	return l != nil && l.taken.Load() >= l.max
+}
@@ -0,0 +1,98 @@
+package fileutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"splitter-files/internal/models"
+)
+
+// ManifestName is the manifest file written to the output directory, in the
+// format of sha256sum so it can also be checked with `sha256sum -c`
+const ManifestName = "manifest.sha256"
+
+// WriteManifest records the SHA-256 of every extracted file, with its path
+// relative to outputDir, in outputDir/ManifestName
+func WriteManifest(outputDir string, results []models.ExtractionResult) error {
+	return os.WriteFile(filepath.Join(outputDir, ManifestName), ManifestData(outputDir, results), 0644)
+}
+
+// ManifestData returns the content of the manifest of results extracted to outputDir
+func ManifestData(outputDir string, results []models.ExtractionResult) []byte {
+	var lines []string
+	add := func(hash, path string) {
+		if hash == "" {
+			return
+		}
+		name, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			name = path
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s\n", hash, filepath.ToSlash(name)))
+	}
+	var addResults func(results []models.ExtractionResult)
+	addResults = func(results []models.ExtractionResult) {
+		for _, res := range results {
+			add(res.SHA256, res.Filename)
+			// Decrypted copies and media of Office documents, earlier revisions
+			// and attachments of PDFs, the stub and archive of self-extracting
+			// archives, and the files carved from the entries of archives are
+			// written next to them
+			if res.OfficeInfo != nil {
+				add(res.OfficeInfo.DecryptedSHA256, res.OfficeInfo.DecryptedFile)
+				for _, media := range res.OfficeInfo.Media {
+					add(media.SHA256, media.File)
+				}
+			}
+			if res.PDFInfo != nil {
+				for _, revision := range res.PDFInfo.Revisions {
+					add(revision.SHA256, revision.File)
+				}
+				for _, file := range res.PDFInfo.Attachments {
+					add(file.SHA256, file.File)
+				}
+			}
+			if res.SFXInfo != nil {
+				add(res.SFXInfo.StubSHA256, res.SFXInfo.StubFile)
+				add(res.SFXInfo.ArchiveSHA256, res.SFXInfo.ArchiveFile)
+			}
+			if res.ZipInfo != nil {
+				addResults(res.ZipInfo.Carved)
+			}
+		}
+	}
+	addResults(results)
+	sort.Slice(lines, func(i, j int) bool { return lines[i][66:] < lines[j][66:] })
+
+	return []byte(strings.Join(lines, ""))
+}
+
+// ReadManifest returns the hashes of a manifest by file path relative to its directory
+func ReadManifest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseManifest(f)
+}
+
+// ParseManifest returns the hashes of the manifest read from r by file path
+// relative to its directory
+func ParseManifest(r io.Reader) (map[string]string, error) {
+	hashes := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		hash, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok || len(hash) != 64 {
+			return nil, fmt.Errorf("malformed manifest line %d", line)
+		}
+		hashes[filepath.FromSlash(name)] = strings.ToLower(hash)
+	}
+	return hashes, scanner.Err()
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package fileutils
+
+import "os"
+
+// MapFile reads the whole file on platforms without mmap support
+func MapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
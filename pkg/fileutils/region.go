@@ -0,0 +1,103 @@
+package fileutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseOffset parses a byte position given either as a size (see ParseSize) or
+// as a hexadecimal number with a 0x prefix, as printed by hex editors
+func ParseOffset(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if hex, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		n, err := strconv.ParseInt(hex, 16, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		return n, nil
+	}
+	return ParseSize(s)
+}
+
+// ReadFileRange reads length bytes of a file starting at offset (length 0 - up
+// to the end of the file) without reading the rest of it
+func ReadFileRange(path string, offset, length int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if offset > info.Size() {
+		return nil, fmt.Errorf("offset %d is past the end of %s (%d bytes)", offset, path, info.Size())
+	}
+	if length == 0 || offset+length > info.Size() {
+		length = info.Size() - offset
+	}
+
+	data := make([]byte, length)
+	if _, err := f.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ByteRange is a half-open range [Start, End) of input positions
+type ByteRange struct {
+	Start, End int64
+}
+
+// ReadRanges reads byte ranges, one per line, as "start-end" with the end
+// exclusive (decimal, 0x hex or sizes such as 512M) or as the JSON lines of a
+// previous run's jsonl report, whose start and end are taken; lines without
+// them and text after # are ignored
+func ReadRanges(path string) ([]ByteRange, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []ByteRange
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "{") {
+			var record struct {
+				Start *int64 `json:"start"`
+				End   *int64 `json:"end"`
+			}
+			if json.Unmarshal([]byte(line), &record) == nil && record.Start != nil && record.End != nil {
+				ranges = append(ranges, ByteRange{*record.Start, *record.End})
+			}
+			continue
+		}
+
+		if i := strings.Index(line, "#"); i != -1 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		startStr, endStr, ok := strings.Cut(line, "-")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected start-end", path, n+1)
+		}
+		start, err := ParseOffset(startStr)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n+1, err)
+		}
+		end, err := ParseOffset(endStr)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("%s:%d: invalid range end %q", path, n+1, strings.TrimSpace(endStr))
+		}
+		ranges = append(ranges, ByteRange{start, end})
+	}
+	return ranges, nil
+}
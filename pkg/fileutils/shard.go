@@ -0,0 +1,53 @@
+package fileutils
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// shardPrefix starts the names of shard subdirectories
+const shardPrefix = "shard_"
+
+// Shards spreads output files over numbered subdirectories of the output
+// (shard_0001, shard_0002, ...) holding at most MaxFiles files or MaxBytes
+// bytes each; a zero limit is not applied
+type Shards struct {
+	MaxFiles int
+	MaxBytes int64
+
+	mu    sync.Mutex
+	index int
+	files int
+	bytes int64
+}
+
+// Assign returns the subdirectory of the next file of size bytes; a file larger
+// than MaxBytes gets a shard of its own
+func (s *Shards) Assign(size int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	full := s.MaxFiles > 0 && s.files >= s.MaxFiles || s.MaxBytes > 0 && s.bytes+int64(size) > s.MaxBytes
+	if s.index == 0 || full && s.files > 0 {
+		s.index++
+		s.files, s.bytes = 0, 0
+	}
+	s.files++
+	s.bytes += int64(size)
+	return fmt.Sprintf("%s%04d", shardPrefix, s.index)
+}
+
+// IsShardDir reports whether a subdirectory of the output holds a shard
+func IsShardDir(name string) bool {
+	digits := strings.TrimPrefix(name, shardPrefix)
+	if digits == name || digits == "" {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
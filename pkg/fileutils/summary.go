@@ -0,0 +1,96 @@
+package fileutils
+
+import "sort"
+
+// InputSummary totals the files carved from one input
+type InputSummary struct {
+	Input     string `json:"input"`
+	InputSize int64  `json:"input_size"`
+	Files     int    `json:"files"`
+	Bytes     int64  `json:"bytes"`
+	// Covered is the number of input bytes inside carved files, overlaps counted once
+	Covered int64 `json:"covered"`
+	// Distinct is the number of different files by content
+	Distinct int            `json:"distinct"`
+	Types    map[string]int `json:"types"`
+}
+
+// Summary rolls up the carve maps of several inputs
+type Summary struct {
+	Inputs []InputSummary `json:"inputs"`
+	Types  map[string]int `json:"types"`
+	Files  int            `json:"files"`
+	Bytes  int64          `json:"bytes"`
+	// Distinct and DistinctBytes count every content once over all inputs;
+	// Shared is the number of contents found in more than one input
+	Distinct      int   `json:"distinct"`
+	DistinctBytes int64 `json:"distinct_bytes"`
+	Shared        int   `json:"shared"`
+}
+
+// SummarizeCarveMaps totals the carve maps of several inputs per input, per
+// file type and by content; entries without a hash count as distinct
+func SummarizeCarveMaps(maps []*CarveMap) Summary {
+	summary := Summary{Types: make(map[string]int)}
+	// inputs holds the inputs each content was found in
+	inputs := make(map[string]map[int]bool)
+	for i, m := range maps {
+		input := InputSummary{Input: m.Input, InputSize: m.InputSize, Types: make(map[string]int)}
+		seen := make(map[string]bool)
+		ranges := make([]ByteRange, 0, len(m.Entries))
+		for _, e := range m.Entries {
+			input.Files++
+			input.Bytes += e.Length
+			input.Types[e.Extension]++
+			ranges = append(ranges, ByteRange{Start: e.Start, End: e.Start + e.Length})
+
+			if e.SHA256 == "" {
+				input.Distinct++
+				summary.Distinct++
+				summary.DistinctBytes += e.Length
+				continue
+			}
+			if !seen[e.SHA256] {
+				seen[e.SHA256] = true
+				input.Distinct++
+			}
+			if inputs[e.SHA256] == nil {
+				inputs[e.SHA256] = make(map[int]bool)
+				summary.Distinct++
+				summary.DistinctBytes += e.Length
+			}
+			inputs[e.SHA256][i] = true
+		}
+		for _, r := range mergeRanges(ranges) {
+			input.Covered += r.End - r.Start
+		}
+
+		summary.Files += input.Files
+		summary.Bytes += input.Bytes
+		for ext, n := range input.Types {
+			summary.Types[ext] += n
+		}
+		summary.Inputs = append(summary.Inputs, input)
+	}
+	for _, found := range inputs {
+		if len(found) > 1 {
+			summary.Shared++
+		}
+	}
+	return summary
+}
+
+// SortedTypes returns the extensions of types, the most frequent first
+func SortedTypes(types map[string]int) []string {
+	exts := make([]string, 0, len(types))
+	for ext := range types {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if types[exts[i]] != types[exts[j]] {
+			return types[exts[i]] > types[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+	return exts
+}
@@ -0,0 +1,118 @@
+package fileutils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"splitter-files/internal/models"
+)
+
+// timelineEvent is a timestamp recovered from the metadata of an extracted file
+type timelineEvent struct {
+	Time   time.Time
+	Source string
+	// Created marks creation times, which go to the crtime column of a
+	// bodyfile; all others go to mtime
+	Created bool
+	Result  models.ExtractionResult
+}
+
+// ExportTimeline writes the timestamps recovered from the extracted files,
+// including those carved from archives, oldest first as CSV (.csv) or as a
+// Sleuth Kit bodyfile for mactime (.body, .bodyfile) depending on the extension
+// of path, and returns the number of events written
+func ExportTimeline(path string, results []models.ExtractionResult) (int, error) {
+	events := timelineEvents(results, nil)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		data, err = buildTimelineCSV(events)
+	case ".body", ".bodyfile":
+		data = buildBodyfile(events)
+	default:
+		return 0, fmt.Errorf("unsupported timeline format %q (use .csv or .body)", filepath.Ext(path))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// timelineEvents appends the timestamps of results and of the files carved
+// from them to events
+func timelineEvents(results []models.ExtractionResult, events []timelineEvent) []timelineEvent {
+	add := func(t time.Time, source string, created bool, res models.ExtractionResult) {
+		if !t.IsZero() {
+			events = append(events, timelineEvent{Time: t.UTC(), Source: source, Created: created, Result: res})
+		}
+	}
+	for _, res := range results {
+		switch {
+		case res.OfficeInfo != nil:
+			add(res.OfficeInfo.Created, "document created", true, res)
+			add(res.OfficeInfo.Modified, "document modified", false, res)
+		case res.Extension == "jpg" || res.Extension == "jpeg":
+			add(res.ModTime, "photo taken", true, res)
+		case res.SFXInfo != nil:
+			// The time of an executable is its link time
+			add(res.SFXInfo.Linked, "executable linked", true, res)
+		default:
+			add(res.ModTime, "modified", false, res)
+		}
+		if res.ZipInfo != nil {
+			events = timelineEvents(res.ZipInfo.Carved, events)
+		}
+	}
+	return events
+}
+
+func buildTimelineCSV(events []timelineEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"time", "event", "file", "type", "start", "end", "sha256"})
+	for _, e := range events {
+		w.Write([]string{
+			e.Time.Format(time.RFC3339),
+			e.Source,
+			e.Result.Filename,
+			e.Result.FileType,
+			strconv.Itoa(e.Result.Start),
+			strconv.Itoa(e.Result.End),
+			e.Result.SHA256,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// buildBodyfile writes a line of the Sleuth Kit 3.x bodyfile format per event:
+// MD5|name|inode|mode|UID|GID|size|atime|mtime|ctime|crtime, with the event in
+// the name and its time in the mtime or crtime column
+func buildBodyfile(events []timelineEvent) []byte {
+	var buf bytes.Buffer
+	for _, e := range events {
+		var mtime, crtime int64
+		if e.Created {
+			crtime = e.Time.Unix()
+		} else {
+			mtime = e.Time.Unix()
+		}
+		name := fmt.Sprintf("%s (%s, %s at %d)", e.Result.Filename, e.Source, e.Result.FileType, e.Result.Start)
+		fmt.Fprintf(&buf, "0|%s|0|0|0|0|%d|0|%d|0|%d\n", strings.ReplaceAll(name, "|", "_"), e.Result.Size, mtime, crtime)
+	}
+	return buf.Bytes()
+}
@@ -2,7 +2,10 @@ package fileutils
 
 import (
 	"fmt"
+	"io"
+	"path/filepath"
 	"splitter-files/internal/models"
+	"strings"
 )
 
 func GetMapKeys(m map[string]bool) []string {
@@ -13,44 +16,99 @@ func GetMapKeys(m map[string]bool) []string {
 	return keys
 }
 
-func PrintStats(stats *models.ExtractionStats, results []models.ExtractionResult) {
-	fmt.Printf("\n=== Detailed Statistics ===\n")
-	fmt.Printf("Input file size:       %d bytes\n", stats.InputSize)
-	fmt.Printf("Extracted files:       %d\n", stats.TotalExtracted)
-	fmt.Printf("Total extracted size:  %d bytes\n", stats.TotalSize)
-	fmt.Printf("Data coverage:         %.2f%%\n", stats.Coverage)
-	fmt.Printf("Overlaps detected:     %d\n", stats.Overlaps)
+// hexPreview formats data as a hexdump -C line: the bytes in hex, then as
+// ASCII with a dot for every byte that is not printable
+func hexPreview(data []byte) string {
+	var b strings.Builder
+	for i := 0; i < 16; i++ {
+		if i == 8 {
+			b.WriteByte(' ')
+		}
+		if i < len(data) {
+			fmt.Fprintf(&b, "%02x ", data[i])
+		} else {
+			b.WriteString("   ")
+		}
+	}
+	b.WriteString(" |")
+	for _, c := range data {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte('|')
+	return b.String()
+}
+
+func PrintStats(w io.Writer, stats *models.ExtractionStats, results []models.ExtractionResult) {
+	fmt.Fprintf(w, "\n=== Detailed Statistics ===\n")
+	fmt.Fprintf(w, "Input file size:       %d bytes\n", stats.InputSize)
+	fmt.Fprintf(w, "Extracted files:       %d\n", stats.TotalExtracted)
+	fmt.Fprintf(w, "Total extracted size:  %d bytes\n", stats.TotalSize)
+	fmt.Fprintf(w, "Data coverage:         %.2f%%\n", stats.Coverage)
+	fmt.Fprintf(w, "Overlaps detected:     %d\n", stats.Overlaps)
+	if stats.Ignored > 0 {
+		fmt.Fprintf(w, "Ignored ranges:        %d bytes\n", stats.Ignored)
+	}
+	if stats.Skipped > 0 {
+		fmt.Fprintf(w, "Skipped by filters:    %d\n", stats.Skipped)
+	}
+
+	if stats.StoppedEarly != "" {
+		fmt.Fprintf(w, "\nWarning: Run stopped early (%s); the statistics cover only the part of the input scanned.\n", stats.StoppedEarly)
+	}
 
 	if stats.Coverage < 90.0 {
-		fmt.Printf("\nWarning: Low data coverage (%.2f%%). Possible issues with file detection.\n", stats.Coverage)
+		fmt.Fprintf(w, "\nWarning: Low data coverage (%.2f%%). Possible issues with file detection.\n", stats.Coverage)
 	}
 
 	if float64(stats.TotalSize) > float64(stats.InputSize)*1.1 {
-		fmt.Printf("\nWarning: Extracted data size (%.2f%%) exceeds input size. Possible overlaps or false positives.\n",
+		fmt.Fprintf(w, "\nWarning: Extracted data size (%.2f%%) exceeds input size. Possible overlaps or false positives.\n",
 			float64(stats.TotalSize)/float64(stats.InputSize)*100)
 	}
 
-	fmt.Printf("\nFile types distribution:\n")
+	fmt.Fprintf(w, "\nFile types distribution:\n")
 	for fileType, count := range stats.FileTypes {
-		fmt.Printf("- %-30s: %d\n", fileType, count)
+		fmt.Fprintf(w, "- %-30s: %d\n", fileType, count)
 	}
 
 	if len(stats.UncoveredAreas) > 0 {
-		fmt.Printf("\nUncovered areas (total %d):\n", len(stats.UncoveredAreas))
+		fmt.Fprintf(w, "\nUncovered areas (total %d):\n", len(stats.UncoveredAreas))
 		for i, area := range stats.UncoveredAreas {
 			size := area.End - area.Start + 1
 			if i < 10 || size > 1024 {
-				fmt.Printf("- %8d - %8d (%6d bytes)\n", area.Start, area.End, size)
+				fmt.Fprintf(w, "- %8d - %8d (%6d bytes, entropy %.2f)\n", area.Start, area.End, size, area.Entropy)
+				if len(area.Preview) > 0 {
+					fmt.Fprintf(w, "    %s\n", hexPreview(area.Preview))
+				}
 			}
 			if i == 10 && len(stats.UncoveredAreas) > 10 {
-				fmt.Printf("  ... and %d more uncovered areas\n", len(stats.UncoveredAreas)-10)
+				fmt.Fprintf(w, "  ... and %d more uncovered areas\n", len(stats.UncoveredAreas)-10)
 				break
 			}
 		}
+		if stats.UncoveredDumped > 0 {
+			fmt.Fprintf(w, "Uncovered areas dumped: %d\n", stats.UncoveredDumped)
+		}
 	}
 
-	var officeFiles, encryptedFiles, macroFiles int
+	if len(stats.PossibleContainers) > 0 {
+		fmt.Fprintf(w, "\nPossible encrypted containers (total %d):\n", len(stats.PossibleContainers))
+		for _, c := range stats.PossibleContainers {
+			fmt.Fprintf(w, "- %8d - %8d (%d bytes, entropy %.3f) %s\n", c.Start, c.End, c.End-c.Start+1, c.Entropy, c.Kind)
+		}
+	}
+
+	var officeFiles, encryptedFiles, macroFiles, highPriorityFiles, encryptedArtifacts int
+	var pdfFiles, encryptedPDFs, repairedPDFs int
 	for _, res := range results {
+		if res.HighPriority {
+			highPriorityFiles++
+		}
+		if res.IsEncrypted {
+			encryptedArtifacts++
+		}
 		if res.OfficeInfo != nil {
 			officeFiles++
 			if res.OfficeInfo.IsEncrypted {
@@ -60,11 +118,115 @@ func PrintStats(stats *models.ExtractionStats, results []models.ExtractionResult
 				macroFiles++
 			}
 		}
+		if res.PDFInfo != nil {
+			pdfFiles++
+			if res.PDFInfo.IsEncrypted {
+				encryptedPDFs++
+			}
+			if res.PDFInfo.Repaired {
+				repairedPDFs++
+			}
+		}
+	}
+
+	if highPriorityFiles > 0 {
+		fmt.Fprintf(w, "\nHigh-priority artifacts: %d\n", highPriorityFiles)
+		for _, res := range results {
+			if res.HighPriority {
+				fmt.Fprintf(w, "- %s (%s, pos %d-%d)\n", filepath.Base(res.Filename), res.FileType, res.Start, res.End)
+			}
+		}
+	}
+
+	var detections []models.ExtractionResult
+	for _, res := range results {
+		if res.MalwareName != "" {
+			detections = append(detections, res)
+		}
+	}
+	if len(detections) > 0 {
+		fmt.Fprintf(w, "\nMalware detections (clamd): %d\n", len(detections))
+		for _, res := range detections {
+			fmt.Fprintf(w, "- %s: %s\n", res.Filename, res.MalwareName)
+		}
+	}
+
+	var scanErrors []models.ExtractionResult
+	for _, res := range results {
+		if res.ScanError != "" {
+			scanErrors = append(scanErrors, res)
+		}
+	}
+	if len(scanErrors) > 0 {
+		fmt.Fprintf(w, "\nFiles not scanned or quarantined (clamd): %d\n", len(scanErrors))
+		for _, res := range scanErrors {
+			fmt.Fprintf(w, "- %s: %s\n", res.Filename, res.ScanError)
+		}
+	}
+
+	var suspects []models.ExtractionResult
+	for _, res := range results {
+		if res.Suspect != "" {
+			suspects = append(suspects, res)
+		}
+	}
+	if len(suspects) > 0 {
+		fmt.Fprintf(w, "\nSuspect files (failed -self-verify): %d\n", len(suspects))
+		for _, res := range suspects {
+			fmt.Fprintf(w, "- %s: %s\n", filepath.Base(res.Filename), res.Suspect)
+		}
+	}
+
+	if encryptedArtifacts > 0 {
+		fmt.Fprintf(w, "\nEncrypted artifacts: %d\n", encryptedArtifacts)
 	}
 
 	if officeFiles > 0 {
-		fmt.Printf("\nOffice documents found: %d\n", officeFiles)
-		fmt.Printf("- Encrypted: %d\n", encryptedFiles)
-		fmt.Printf("- With macros: %d\n", macroFiles)
+		fmt.Fprintf(w, "\nOffice documents found: %d\n", officeFiles)
+		fmt.Fprintf(w, "- Encrypted: %d\n", encryptedFiles)
+		fmt.Fprintf(w, "- With macros: %d\n", macroFiles)
+	}
+
+	if pdfFiles > 0 {
+		fmt.Fprintf(w, "\nPDF documents found: %d\n", pdfFiles)
+		fmt.Fprintf(w, "- Encrypted: %d\n", encryptedPDFs)
+		if repairedPDFs > 0 {
+			fmt.Fprintf(w, "- Repaired: %d\n", repairedPDFs)
+		}
+	}
+
+	printEmbeddedObjects(w, results)
+}
+
+// printEmbeddedObjects lists the OLE objects, ActiveX controls and external references
+// of Office documents; images are only counted on the result line
+func printEmbeddedObjects(w io.Writer, results []models.ExtractionResult) {
+	header := false
+	for _, res := range results {
+		if res.OfficeInfo == nil {
+			continue
+		}
+
+		var lines []string
+		for _, obj := range res.OfficeInfo.Embedded {
+			if obj.Kind != "image" {
+				lines = append(lines, fmt.Sprintf("  %s: %s", obj.Kind, obj.Name))
+			}
+		}
+		for _, ref := range res.OfficeInfo.External {
+			lines = append(lines, fmt.Sprintf("  external %s (%s): %s", ref.Type, ref.Source, ref.Target))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		if !header {
+			fmt.Fprintf(w, "\nEmbedded objects and external references:\n")
+			header = true
+		}
+		fmt.Fprintf(w, "- %s\n", filepath.Base(res.Filename))
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
 	}
 }
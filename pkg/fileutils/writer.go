@@ -0,0 +1,296 @@
+package fileutils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultWriteBufferSize is the buffer used between extracted data and the output file
+const DefaultWriteBufferSize = 256 * 1024
+
+// maxWriteBatch is the number of queued files a writer takes at once
+const maxWriteBatch = 32
+
+// writeRetries is the number of times DirSink tries to write a file that fails
+// with a transient error
+const writeRetries = 3
+
+// WriteJob is an output file to be written
+type WriteJob struct {
+	Path string
+	Data []byte
+	// ModTime is applied to the file after writing unless it is zero
+	ModTime time.Time
+}
+
+// Sink stores output files; failures are returned as *WriteError
+type Sink interface {
+	Put(job WriteJob) error
+}
+
+// DirSink writes output files to the local file system through a buffer of
+// BufferSize bytes; with Fsync every file is flushed to stable storage. Missing
+// subdirectories of the output, such as shards, are created.
+type DirSink struct {
+	BufferSize int
+	Fsync      bool
+}
+
+func (s *DirSink) Put(job WriteJob) error {
+	var err error
+	for attempt := 0; attempt < writeRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		if err = s.put(job); !transientWriteError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *DirSink) put(job WriteJob) error {
+	err := WriteFile(job, s.BufferSize, s.Fsync)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	dir := filepath.Dir(job.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return &WriteError{Path: job.Path, Err: err}
+	}
+	if s.Fsync {
+		if err := syncDir(filepath.Dir(dir)); err != nil {
+			return &WriteError{Path: dir, Err: err}
+		}
+	}
+	return WriteFile(job, s.BufferSize, s.Fsync)
+}
+
+// transientWriteError reports whether a write failed for a reason that may
+// pass, such as an interrupted call or a busy or timed out network file system
+func transientWriteError(err error) bool {
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY) ||
+		errors.Is(err, syscall.ETIMEDOUT) || errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// outputFullErrors mean the output device has no room left
+var outputFullErrors = append([]error{syscall.ENOSPC, syscall.EDQUOT}, platformFullErrors...)
+
+// OutputFailure returns why a write error means that no more files can be
+// written to the output - the device is full, read-only or not writable - or
+// "" if other files may still be written
+func OutputFailure(err error) string {
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		return ""
+	}
+	for _, full := range outputFullErrors {
+		if errors.Is(err, full) {
+			return "output device is full"
+		}
+	}
+	switch {
+	case errors.Is(err, syscall.EROFS):
+		return "output is on a read-only file system"
+	case errors.Is(err, fs.ErrPermission):
+		return "output is not writable"
+	}
+	return ""
+}
+
+// OutputPath joins an output file name to the output location, which is either
+// a directory or a URL such as s3://bucket/prefix
+func OutputPath(outputDir, name string) string {
+	if strings.Contains(outputDir, "://") {
+		return strings.TrimSuffix(outputDir, "/") + "/" + name
+	}
+	return filepath.Join(outputDir, name)
+}
+
+// WriteError is a failure to write an output file
+type WriteError struct {
+	Path string
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("failed to write file %s: %v", e.Path, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// WriteFile writes a job through a buffer of bufferSize bytes; with fsync the data
+// is flushed to stable storage before returning
+func WriteFile(job WriteJob, bufferSize int, fsync bool) error {
+	if err := writeFile(job, bufferSize, fsync); err != nil {
+		return &WriteError{Path: job.Path, Err: err}
+	}
+	return nil
+}
+
+// writeFile removes what it wrote of a file it fails to write, so that no
+// truncated files are left in the output. The names of extracted files are
+// claimed by creating them empty (extractor.OutputNames), which is the file
+// truncated here.
+func writeFile(job WriteJob, bufferSize int, fsync bool) error {
+	f, err := os.OpenFile(job.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if bufferSize <= 0 {
+		bufferSize = DefaultWriteBufferSize
+	}
+	w := bufio.NewWriterSize(f, bufferSize)
+	if _, err := w.Write(job.Data); err != nil {
+		f.Close()
+		os.Remove(job.Path)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(job.Path)
+		return err
+	}
+	if fsync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			os.Remove(job.Path)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(job.Path)
+		return err
+	}
+
+	if !job.ModTime.IsZero() {
+		return os.Chtimes(job.Path, job.ModTime, job.ModTime)
+	}
+	return nil
+}
+
+// AsyncWriter stores output files from a bounded queue with a pool of writers, so
+// extraction workers don't wait for slow disks or uploads
+type AsyncWriter struct {
+	// Abort, if set, is called with the reason when a write fails in a way
+	// that means the output can't take more files
+	Abort func(reason string)
+
+	jobs   chan WriteJob
+	sink   Sink
+	budget *MemoryBudget
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewAsyncWriter starts workers writers storing files with sink; Write blocks
+// once queueSize files are waiting or the queued data exceeds the budget
+func NewAsyncWriter(workers, queueSize int, sink Sink, budget *MemoryBudget) *AsyncWriter {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	w := &AsyncWriter{
+		jobs:   make(chan WriteJob, queueSize),
+		sink:   sink,
+		budget: budget,
+	}
+	for i := 0; i < workers; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+	return w
+}
+
+// Write queues a file; the data must not change until it is written
+func (w *AsyncWriter) Write(job WriteJob) {
+	w.budget.Acquire(int64(len(job.Data)))
+	w.jobs <- job
+}
+
+// Close waits for the queued files and returns the errors of failed writes
+func (w *AsyncWriter) Close() []error {
+	close(w.jobs)
+	w.wg.Wait()
+	return w.errs
+}
+
+func (w *AsyncWriter) run() {
+	defer w.wg.Done()
+
+	for job := range w.jobs {
+		// Take whatever else is queued to sync the directories once per batch
+		batch := []WriteJob{job}
+	drain:
+		for len(batch) < maxWriteBatch {
+			select {
+			case next, ok := <-w.jobs:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		dirs := make(map[string]bool)
+		for _, job := range batch {
+			if err := w.sink.Put(job); err != nil {
+				w.fail(err)
+			}
+			dirs[filepath.Dir(job.Path)] = true
+			w.budget.Release(int64(len(job.Data)))
+		}
+
+		if dirSink, ok := w.sink.(*DirSink); ok && dirSink.Fsync {
+			for dir := range dirs {
+				if err := syncDir(dir); err != nil {
+					w.fail(&WriteError{Path: dir, Err: err})
+				}
+			}
+		}
+	}
+}
+
+func (w *AsyncWriter) fail(err error) {
+	w.mu.Lock()
+	w.errs = append(w.errs, err)
+	w.mu.Unlock()
+	if reason := OutputFailure(err); reason != "" && w.Abort != nil {
+		w.Abort(reason)
+	}
+}
+
+// syncDir makes the new directory entries durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Windows can't sync directories, the files themselves are synced there
+	if err := d.Sync(); err != nil && runtime.GOOS != "windows" {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package fileutils
+
+// platformFullErrors are the errors other than ENOSPC and EDQUOT with which
+// writes to a full device fail; there are none here
+var platformFullErrors []error
@@ -0,0 +1,9 @@
+//go:build windows
+
+package fileutils
+
+import "syscall"
+
+// platformFullErrors are ERROR_HANDLE_DISK_FULL and ERROR_DISK_FULL, with
+// which Windows fails writes to a full disk
+var platformFullErrors = []error{syscall.Errno(39), syscall.Errno(112)}